@@ -20,4 +20,45 @@ const (
 	MaxIdleConns = 10             //最大空闲连接
 	MaxOpenConns = 100            //最大连接数
 	MaxLifeTime  = 30 * time.Second //最大生存时间
+
+	//敏感数据配置
+	//允许查看Secret明文的管理员用户名
+	AdminUser = "admin"
+	//ConfigMap上带有该annotation且值为"true"时, 视为敏感数据并脱敏展示
+	SensitiveAnnotation = "dashboard.platops.dev/sensitive"
+	//脱敏后的占位值
+	MaskValue = "******"
+
+	//通过node proxy获取kubelet用量数据的超时时间, 所有node共用
+	KubeletStatsTimeout = 5 * time.Second
+
+	//server-side apply时使用的fieldManager
+	FieldManager = "k8s-dashboard"
+
+	//helm配置
+	//release信息的存储方式: secret configmap memory
+	HelmDriver = "secret"
+	//helm仓库、仓库缓存和OCI仓库认证文件的路径, 为空时使用helm的默认路径
+	HelmRepositoryConfig = ""
+	HelmRepositoryCache  = ""
+	HelmRegistryConfig   = ""
+	//允许安装本地chart的目录, Chart为本地路径时必须位于该目录下, 为空时不允许使用本地路径
+	HelmLocalChartDir = ""
+	//install、upgrade等操作等待资源就绪的默认超时时间(秒)
+	HelmTimeout = 300
+
+	//kustomize配置
+	//上传的kustomization压缩包解压后的大小上限(字节)
+	KustomizeMaxArchiveSize = 50 << 20
+	//从bundle apply的对象上的标签, 值为bundle名字, prune时只删除带有该标签的对象
+	KustomizeBundleLabel = "dashboard.platops.dev/kustomize-bundle"
+
+	//workflow列表并发获取集群状态的goroutine数
+	WorkflowHealthWorkers = 8
+	//workflow创建的HTTPRoute上的标签, 值为workflow名字, 每个host一个HTTPRoute, 据此查找和清理
+	WorkflowLabel = "dashboard.platops.dev/workflow"
+
+	//备份配置
+	//restore时上传的备份解压后的大小上限(字节)
+	BackupMaxArchiveSize = 200 << 20
 )
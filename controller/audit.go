@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"net/http"
+	"test4/dao"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var Audit audit
+
+type audit struct{}
+
+// 获取审计记录列表, 支持按用户名过滤和分页
+func (a *audit) GetList(ctx *gin.Context) {
+	params := new(struct {
+		UserName string `form:"username"`
+		Page     int    `form:"page"`
+		Limit    int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := dao.Audit.GetList(params.UserName, params.Page, params.Limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取审计记录列表成功",
+		"data": data,
+	})
+}
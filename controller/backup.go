@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var Backup backup
+
+type backup struct{}
+
+// 导出namespace中的资源并下载, namespaces为逗号分隔的列表, 为空时导出所有非系统namespace
+// format为yaml或tar, include_workflows为true时tar包中包含workflow数据
+func (b *backup) Export(ctx *gin.Context) {
+	params := new(struct {
+		Namespaces       string `form:"namespaces"`
+		Format           string `form:"format"`
+		IncludeWorkflows bool   `form:"include_workflows"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	namespaces := make([]string, 0)
+	for _, namespace := range strings.Split(params.Namespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	content, fileName, err := service.Backup.Export(namespaces, params.Format, params.IncludeWorkflows)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	contentType := "application/x-yaml"
+	if strings.HasSuffix(fileName, ".tar.gz") {
+		contentType = "application/gzip"
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	ctx.Data(http.StatusOK, contentType, content)
+}
+
+// 恢复导出的yaml或tar.gz, 使用multipart/form-data(字段名backup)
+// namespace_map[原namespace]=目标namespace, name_map[原名字]=新名字, 返回每个对象的结果
+// target_kubeconfig和target_context指定时恢复到其他集群
+func (b *backup) Restore(ctx *gin.Context) {
+	params := new(struct {
+		TargetKubeconfig string `form:"target_kubeconfig"`
+		TargetContext    string `form:"target_context"`
+		DryRun           bool   `form:"dry_run"`
+	})
+	if err := ctx.ShouldBind(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	content, err := readFormFile(ctx, "backup")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Backup.Restore(content, &service.BackupRestore{
+		NamespaceMap:     ctx.PostFormMap("namespace_map"),
+		NameMap:          ctx.PostFormMap("name_map"),
+		TargetKubeconfig: params.TargetKubeconfig,
+		TargetContext:    params.TargetContext,
+		DryRun:           params.DryRun,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("恢复备份完成, 成功%d个, 失败%d个", len(data.Results)-data.Failed, data.Failed),
+		"data": data,
+	})
+}
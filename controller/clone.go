@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var Clone clone
+
+type clone struct{}
+
+// 克隆Deployment、StatefulSet或workflow及其依赖的资源到目标namespace
+func (c *clone) CloneWorkload(ctx *gin.Context) {
+	params := new(service.CloneWorkload)
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Clone.CloneWorkload(params)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("克隆%s完成, 成功%d个, 失败%d个", params.Kind, len(data.Results)-data.Failed, data.Failed),
+		"data": data,
+	})
+}
+
+// 克隆整个namespace中的资源到目标namespace
+func (c *clone) CloneNamespace(ctx *gin.Context) {
+	params := new(service.CloneNamespace)
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Clone.CloneNamespace(params)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("克隆namespace: %s 完成, 成功%d个, 失败%d个", params.Namespace, len(data.Results)-data.Failed, data.Failed),
+		"data": data,
+	})
+}
@@ -81,6 +81,7 @@ func (cm *configMap) DeleteConfigMap(ctx *gin.Context)  {
 	params := new(struct{
 		ConfigMapName	string	`json:"configmap_name"`
 		Namespace		string	`json:"namespace"`
+		Force			bool	`json:"force"`
 	})
 	if err := ctx.ShouldBindJSON(params); err != nil {
 		logger.Error("ShouldBind请求参数绑定失败," + err.Error())
@@ -90,7 +91,23 @@ func (cm *configMap) DeleteConfigMap(ctx *gin.Context)  {
 		})
 		return
 	}
-	err := service.ConfigMap.DeleteConfigMap(params.ConfigMapName, params.Namespace)
+	//仍被工作负载引用时, 除非指定force, 否则拒绝删除并返回引用方
+	consumers, err := service.Reference.GetConsumers(service.RefKindConfigMap, params.ConfigMapName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if len(consumers) > 0 && !params.Force {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": fmt.Sprintf("ConfigMap %s 仍被%d个工作负载引用, 确认删除请指定force", params.ConfigMapName, len(consumers)),
+			"data": consumers,
+		})
+		return
+	}
+	err = service.ConfigMap.DeleteConfigMap(params.ConfigMapName, params.Namespace)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"msg": err.Error(),
@@ -108,6 +125,8 @@ func (cm *configMap) UpdateConfigMap(ctx *gin.Context)  {
 	params := new(struct{
 		Content			string	`json:"content"`
 		Namespace		string	`json:"namespace"`
+		Restart			bool	`json:"restart"`
+		DryRun			bool	`json:"dry_run"`
 	})
 	if err := ctx.ShouldBindJSON(params); err != nil {
 		logger.Error("ShouldBind请求参数绑定失败," + err.Error())
@@ -117,7 +136,7 @@ func (cm *configMap) UpdateConfigMap(ctx *gin.Context)  {
 		})
 		return
 	}
-	err := service.ConfigMap.UpdateConfigMap(params.Namespace, params.Content)
+	data, err := service.ConfigMap.UpdateConfigMap(params.Namespace, params.Content, params.Restart, params.DryRun)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"msg": err.Error(),
@@ -125,8 +144,12 @@ func (cm *configMap) UpdateConfigMap(ctx *gin.Context)  {
 		})
 		return
 	}
+	msg := fmt.Sprintf("更新Namespace: %s 下的ConfigMap 成功", params.Namespace)
+	if params.DryRun {
+		msg = fmt.Sprintf("预览更新Namespace: %s 下的ConfigMap 成功, 未实际生效", params.Namespace)
+	}
 	ctx.JSON(http.StatusOK, gin.H{
-		"msg": fmt.Sprintf("更新Namespace: %s 下的ConfigMap 成功", params.Namespace),
-		"data": nil,
+		"msg": msg,
+		"data": data,
 	})
 }
\ No newline at end of file
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var Crd crd
+
+type crd struct{}
+
+// 获取crd列表, 支持过滤、排序、分页
+func (c *crd) GetCustomResourceDefinitions(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Crd.GetCustomResourceDefinitions(params.FilterName, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取CRD列表成功",
+		"data": data,
+	})
+}
+
+// 获取crd详情
+func (c *crd) GetCustomResourceDefinitionDetail(ctx *gin.Context) {
+	params := new(struct {
+		CrdName string `form:"crd_name"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Crd.GetCustomResourceDefinitionDetail(params.CrdName)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取CRD: %s 详情成功", params.CrdName),
+		"data": data,
+	})
+}
@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var CronJob cronJob
+
+type cronJob struct{}
+
+// 获取cronjob列表, 支持过滤、排序、分页
+func (cj *cronJob) GetCronJobs(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Namespace  string `form:"namespace"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.CronJob.GetCronJobs(params.FilterName, params.Namespace, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取CronJob列表成功",
+		"data": data,
+	})
+}
+
+// 获取cronjob详情
+func (cj *cronJob) GetCronJobDetail(ctx *gin.Context) {
+	params := new(struct {
+		CronJobName string `form:"cronjob_name"`
+		Namespace   string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.CronJob.GetCronJobDetail(params.CronJobName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取CronJob详情成功",
+		"data": data,
+	})
+}
+
+// 创建cronjob
+func (cj *cronJob) CreateCronJob(ctx *gin.Context) {
+	var (
+		cronJobCreate = new(service.CronJobCreate)
+		err           error
+	)
+	if err = ctx.ShouldBindJSON(cronJobCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.CronJob.CreateCronJob(cronJobCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建CronJob成功: %s", cronJobCreate.Name),
+		"data": nil,
+	})
+}
+
+// 删除cronjob, propagation为删除传播策略(Background/Foreground/Orphan)
+func (cj *cronJob) DeleteCronJob(ctx *gin.Context) {
+	params := new(struct {
+		CronJobName string `json:"cronjob_name"`
+		Namespace   string `json:"namespace"`
+		Propagation string `json:"propagation"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.CronJob.DeleteCronJob(params.CronJobName, params.Namespace, params.Propagation); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除CronJob %s 成功", params.CronJobName),
+		"data": nil,
+	})
+}
+
+// 立即触发cronjob, 使用jobTemplate创建一个job
+func (cj *cronJob) TriggerCronJob(ctx *gin.Context) {
+	params := new(struct {
+		CronJobName string `json:"cronjob_name"`
+		Namespace   string `json:"namespace"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.CronJob.TriggerCronJob(params.CronJobName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("触发CronJob %s 成功", params.CronJobName),
+		"data": data,
+	})
+}
+
+// 暂停或恢复cronjob
+func (cj *cronJob) SuspendCronJob(ctx *gin.Context) {
+	params := new(struct {
+		CronJobName string `json:"cronjob_name"`
+		Namespace   string `json:"namespace"`
+		Suspend     bool   `json:"suspend"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.CronJob.SuspendCronJob(params.CronJobName, params.Namespace, params.Suspend); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("设置CronJob %s 暂停状态为 %t 成功", params.CronJobName, params.Suspend),
+		"data": nil,
+	})
+}
+
+// 获取cronjob最近创建的job及其pod
+func (cj *cronJob) GetCronJobJobs(ctx *gin.Context) {
+	params := new(struct {
+		CronJobName string `form:"cronjob_name"`
+		Namespace   string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.CronJob.GetCronJobJobs(params.CronJobName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取CronJob的Job列表成功",
+		"data": data,
+	})
+}
@@ -72,9 +72,11 @@ func (d *deployment) GetDeploymentDetail(ctx *gin.Context)  {
 		})
 		return
 	}
+	//附带控制该Deployment的hpa, 获取失败不影响详情返回
+	hpa, _ := service.HorizontalPodAutoscaler.GetWorkloadHorizontalPodAutoscaler("Deployment", params.DeploymentName, params.Namespace)
 	ctx.JSON(http.StatusOK, gin.H{
 		"msg": "获取Deployment详情成功",
-		"data": data,
+		"data": &service.DeploymentDetail{Deployment: data, HorizontalPodAutoscaler: hpa},
 	})
 }
 
@@ -85,6 +87,7 @@ func (d *deployment) ScaleDeployment(ctx *gin.Context)  {
 		DeploymentName  string	`json:"deployment_name"`
 		Namespace		string	`json:"namespace"`
 		ScaleNum		int		`json:"scale_num"`
+		Force			bool	`json:"force"`
 	})
 	//PUT请求, 绑定参数方法改为ctx.ShouldBindJSON
 	if err := ctx.ShouldBindJSON(params); err != nil {
@@ -95,6 +98,22 @@ func (d *deployment) ScaleDeployment(ctx *gin.Context)  {
 		})
 		return
 	}
+	//被hpa控制的工作负载, 手动设置的副本数会被hpa覆盖, 除非指定force, 否则拒绝
+	hpa, err := service.HorizontalPodAutoscaler.GetWorkloadHorizontalPodAutoscaler("Deployment", params.DeploymentName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if hpa != nil && !params.Force {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": fmt.Sprintf("Deployment %s 由HorizontalPodAutoscaler %s 控制(最大%d副本), 手动设置的副本数会被覆盖, 确认设置请指定force", params.DeploymentName, hpa.Name, hpa.Spec.MaxReplicas),
+			"data": hpa,
+		})
+		return
+	}
 	data, err := service.Deployment.ScaleDeployment(params.DeploymentName, params.Namespace, params.ScaleNum)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
@@ -103,8 +122,12 @@ func (d *deployment) ScaleDeployment(ctx *gin.Context)  {
 		})
 		return
 	}
+	msg := "设置Deployment副本数成功"
+	if hpa != nil {
+		msg = fmt.Sprintf("设置Deployment副本数成功, 注意: 副本数会被HorizontalPodAutoscaler %s 调整", hpa.Name)
+	}
 	ctx.JSON(http.StatusOK, gin.H{
-		"msg": "设置Deployment副本数成功",
+		"msg": msg,
 		"data": fmt.Sprintf("最新副本数：%d", data),
 	})
 }
@@ -0,0 +1,487 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var Gateway gateway
+
+type gateway struct{}
+
+// 获取gatewayclass列表, 支持过滤、排序、分页
+func (g *gateway) GetGatewayClasses(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Gateway.GetGatewayClasses(params.FilterName, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取GatewayClass列表成功",
+		"data": data,
+	})
+}
+
+// 获取gatewayclass详情
+func (g *gateway) GetGatewayClassDetail(ctx *gin.Context) {
+	params := new(struct {
+		GatewayClassName string `form:"gateway_class_name"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Gateway.GetGatewayClassDetail(params.GatewayClassName)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取GatewayClass: %s 详情成功", params.GatewayClassName),
+		"data": data,
+	})
+}
+
+// 创建gatewayclass
+func (g *gateway) CreateGatewayClass(ctx *gin.Context) {
+	var (
+		gatewayClassCreate = new(service.GatewayClassCreate)
+		err                error
+	)
+	if err = ctx.ShouldBindJSON(gatewayClassCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.Gateway.CreateGatewayClass(gatewayClassCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建GatewayClass: %s 成功", gatewayClassCreate.Name),
+		"data": nil,
+	})
+}
+
+// 删除gatewayclass
+func (g *gateway) DeleteGatewayClass(ctx *gin.Context) {
+	params := new(struct {
+		GatewayClassName string `json:"gateway_class_name"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Gateway.DeleteGatewayClass(params.GatewayClassName); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除GatewayClass: %s 成功", params.GatewayClassName),
+		"data": nil,
+	})
+}
+
+// 获取gateway列表, 支持过滤、排序、分页
+func (g *gateway) GetGateways(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Namespace  string `form:"namespace"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Gateway.GetGateways(params.FilterName, params.Namespace, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取Namespace: %s 下的Gateway列表成功", params.Namespace),
+		"data": data,
+	})
+}
+
+// 获取gateway详情
+func (g *gateway) GetGatewayDetail(ctx *gin.Context) {
+	params := new(struct {
+		GatewayName string `form:"gateway_name"`
+		Namespace   string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Gateway.GetGatewayDetail(params.GatewayName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取Gateway: %s 详情成功", params.GatewayName),
+		"data": data,
+	})
+}
+
+// 创建gateway
+func (g *gateway) CreateGateway(ctx *gin.Context) {
+	var (
+		gatewayCreate = new(service.GatewayCreate)
+		err           error
+	)
+	if err = ctx.ShouldBindJSON(gatewayCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.Gateway.CreateGateway(gatewayCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建Gateway: %s 成功", gatewayCreate.Name),
+		"data": nil,
+	})
+}
+
+// 删除gateway
+func (g *gateway) DeleteGateway(ctx *gin.Context) {
+	params := new(struct {
+		GatewayName string `json:"gateway_name"`
+		Namespace   string `json:"namespace"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Gateway.DeleteGateway(params.GatewayName, params.Namespace); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除Gateway: %s 成功", params.GatewayName),
+		"data": nil,
+	})
+}
+
+// 获取httproute列表, 支持过滤、排序、分页
+func (g *gateway) GetHTTPRoutes(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Namespace  string `form:"namespace"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Gateway.GetHTTPRoutes(params.FilterName, params.Namespace, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取Namespace: %s 下的HTTPRoute列表成功", params.Namespace),
+		"data": data,
+	})
+}
+
+// 获取httproute详情, 包含在各parentRef上的挂载状态
+func (g *gateway) GetHTTPRouteDetail(ctx *gin.Context) {
+	params := new(struct {
+		HTTPRouteName string `form:"httproute_name"`
+		Namespace     string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Gateway.GetHTTPRouteDetail(params.HTTPRouteName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取HTTPRoute: %s 详情成功", params.HTTPRouteName),
+		"data": data,
+	})
+}
+
+// 创建httproute
+func (g *gateway) CreateHTTPRoute(ctx *gin.Context) {
+	var (
+		httpRouteCreate = new(service.HTTPRouteCreate)
+		err             error
+	)
+	if err = ctx.ShouldBindJSON(httpRouteCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.Gateway.CreateHTTPRoute(httpRouteCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建HTTPRoute: %s 成功", httpRouteCreate.Name),
+		"data": nil,
+	})
+}
+
+// 删除httproute
+func (g *gateway) DeleteHTTPRoute(ctx *gin.Context) {
+	params := new(struct {
+		HTTPRouteName string `json:"httproute_name"`
+		Namespace     string `json:"namespace"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Gateway.DeleteHTTPRoute(params.HTTPRouteName, params.Namespace); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除HTTPRoute: %s 成功", params.HTTPRouteName),
+		"data": nil,
+	})
+}
+
+// 获取grpcroute列表, 支持过滤、排序、分页
+func (g *gateway) GetGRPCRoutes(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Namespace  string `form:"namespace"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Gateway.GetGRPCRoutes(params.FilterName, params.Namespace, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取Namespace: %s 下的GRPCRoute列表成功", params.Namespace),
+		"data": data,
+	})
+}
+
+// 获取grpcroute详情, 包含在各parentRef上的挂载状态
+func (g *gateway) GetGRPCRouteDetail(ctx *gin.Context) {
+	params := new(struct {
+		GRPCRouteName string `form:"grpcroute_name"`
+		Namespace     string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Gateway.GetGRPCRouteDetail(params.GRPCRouteName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取GRPCRoute: %s 详情成功", params.GRPCRouteName),
+		"data": data,
+	})
+}
+
+// 创建grpcroute
+func (g *gateway) CreateGRPCRoute(ctx *gin.Context) {
+	var (
+		grpcRouteCreate = new(service.GRPCRouteCreate)
+		err             error
+	)
+	if err = ctx.ShouldBindJSON(grpcRouteCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.Gateway.CreateGRPCRoute(grpcRouteCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建GRPCRoute: %s 成功", grpcRouteCreate.Name),
+		"data": nil,
+	})
+}
+
+// 删除grpcroute
+func (g *gateway) DeleteGRPCRoute(ctx *gin.Context) {
+	params := new(struct {
+		GRPCRouteName string `json:"grpcroute_name"`
+		Namespace     string `json:"namespace"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Gateway.DeleteGRPCRoute(params.GRPCRouteName, params.Namespace); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除GRPCRoute: %s 成功", params.GRPCRouteName),
+		"data": nil,
+	})
+}
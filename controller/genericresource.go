@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var GenericResource genericResource
+
+type genericResource struct{}
+
+// 获取任意资源列表, 支持过滤、排序、分页
+func (g *genericResource) GetResources(ctx *gin.Context) {
+	params := new(struct {
+		Group      string `form:"group"`
+		Version    string `form:"version"`
+		Resource   string `form:"resource"`
+		Namespace  string `form:"namespace"`
+		FilterName string `form:"filter_name"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.GenericResource.GetResources(params.Group, params.Version, params.Resource, params.Namespace, params.FilterName, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取%s列表成功", params.Resource),
+		"data": data,
+	})
+}
+
+// 获取任意资源详情
+func (g *genericResource) GetResourceDetail(ctx *gin.Context) {
+	params := new(struct {
+		Group     string `form:"group"`
+		Version   string `form:"version"`
+		Resource  string `form:"resource"`
+		Namespace string `form:"namespace"`
+		Name      string `form:"name"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.GenericResource.GetResourceDetail(params.Group, params.Version, params.Resource, params.Namespace, params.Name)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取%s: %s 详情成功", params.Resource, params.Name),
+		"data": data,
+	})
+}
+
+// 删除任意资源
+func (g *genericResource) DeleteResource(ctx *gin.Context) {
+	params := new(struct {
+		Group     string `json:"group"`
+		Version   string `json:"version"`
+		Resource  string `json:"resource"`
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.GenericResource.DeleteResource(params.Group, params.Version, params.Resource, params.Namespace, params.Name); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除%s: %s 成功", params.Resource, params.Name),
+		"data": nil,
+	})
+}
+
+// 应用任意资源, 不存在则创建
+func (g *genericResource) ApplyResource(ctx *gin.Context) {
+	params := new(struct {
+		Content string `json:"content"`
+		DryRun  bool   `json:"dry_run"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.GenericResource.ApplyResource(params.Content, params.DryRun)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	msg := "应用资源成功"
+	if params.DryRun {
+		msg = "预览应用资源成功, 未实际生效"
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  msg,
+		"data": data,
+	})
+}
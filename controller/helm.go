@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var Helm helm
+
+type helm struct{}
+
+// 获取helm release列表, namespace为空时获取所有namespace, 支持过滤、排序、分页
+func (h *helm) GetReleases(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Namespace  string `form:"namespace"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Helm.GetReleases(params.FilterName, params.Namespace, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取Helm Release列表成功",
+		"data": data,
+	})
+}
+
+// 获取helm release详情, 包括values、manifest、notes和历史版本
+func (h *helm) GetReleaseDetail(ctx *gin.Context) {
+	params := new(struct {
+		ReleaseName string `form:"release_name"`
+		Namespace   string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Helm.GetReleaseDetail(params.ReleaseName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取Helm Release详情成功",
+		"data": data,
+	})
+}
+
+// 获取helm release的历史版本
+func (h *helm) GetReleaseHistory(ctx *gin.Context) {
+	params := new(struct {
+		ReleaseName string `form:"release_name"`
+		Namespace   string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Helm.GetReleaseHistory(params.ReleaseName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取Helm Release历史版本成功",
+		"data": data,
+	})
+}
+
+// 安装chart, 支持json传入chart地址, 或者multipart/form-data上传chart压缩包(字段名chart_file)
+func (h *helm) InstallRelease(ctx *gin.Context) {
+	params, archive, err := bindHelmRelease(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Helm.InstallRelease(params, archive)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("安装Helm Release: %s 成功", params.Name),
+		"data": data,
+	})
+}
+
+// 升级helm release, 参数与安装相同, install为true时release不存在则安装
+func (h *helm) UpgradeRelease(ctx *gin.Context) {
+	params, archive, err := bindHelmRelease(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Helm.UpgradeRelease(params, archive)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("升级Helm Release: %s 成功", params.Name),
+		"data": data,
+	})
+}
+
+// 回滚helm release到指定版本, revision为0时回滚到上一个版本
+func (h *helm) RollbackRelease(ctx *gin.Context) {
+	params := new(struct {
+		ReleaseName string `json:"release_name"`
+		Namespace   string `json:"namespace"`
+		Revision    int    `json:"revision"`
+		Wait        bool   `json:"wait"`
+		Timeout     int    `json:"timeout"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Revision < 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "revision参数不合法",
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Helm.RollbackRelease(params.ReleaseName, params.Namespace, params.Revision, params.Wait, params.Timeout); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("回滚Helm Release: %s 成功", params.ReleaseName),
+		"data": nil,
+	})
+}
+
+// 卸载helm release
+func (h *helm) UninstallRelease(ctx *gin.Context) {
+	params := new(struct {
+		ReleaseName string `json:"release_name"`
+		Namespace   string `json:"namespace"`
+		KeepHistory bool   `json:"keep_history"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Helm.UninstallRelease(params.ReleaseName, params.Namespace, params.KeepHistory); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("卸载Helm Release: %s 成功", params.ReleaseName),
+		"data": nil,
+	})
+}
+
+// bindHelmRelease 绑定install和upgrade的参数, multipart请求时读取上传的chart压缩包
+func bindHelmRelease(ctx *gin.Context) (*service.HelmReleaseCreate, []byte, error) {
+	params := new(service.HelmReleaseCreate)
+	if !strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		if err := ctx.ShouldBindJSON(params); err != nil {
+			logger.Error("ShouldBind请求参数失败, " + err.Error())
+			return nil, nil, err
+		}
+		return params, nil, nil
+	}
+
+	if err := ctx.ShouldBind(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		return nil, nil, err
+	}
+	archive, err := readFormFile(ctx, "chart_file")
+	if err != nil {
+		return nil, nil, err
+	}
+	return params, archive, nil
+}
+
+// readFormFile 读取multipart请求中上传的文件内容
+func readFormFile(ctx *gin.Context, field string) ([]byte, error) {
+	fileHeader, err := ctx.FormFile(field)
+	if err != nil {
+		logger.Error("获取上传的文件" + field + "失败, " + err.Error())
+		return nil, fmt.Errorf("获取上传的文件%s失败, %v", field, err)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		logger.Error("读取上传的文件" + field + "失败, " + err.Error())
+		return nil, fmt.Errorf("读取上传的文件%s失败, %v", field, err)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		logger.Error("读取上传的文件" + field + "失败, " + err.Error())
+		return nil, fmt.Errorf("读取上传的文件%s失败, %v", field, err)
+	}
+	return content, nil
+}
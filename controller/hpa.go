@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var HorizontalPodAutoscaler horizontalPodAutoscaler
+
+type horizontalPodAutoscaler struct{}
+
+// 获取hpa列表, 支持过滤、排序、分页
+func (h *horizontalPodAutoscaler) GetHorizontalPodAutoscalers(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Namespace  string `form:"namespace"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.HorizontalPodAutoscaler.GetHorizontalPodAutoscalers(params.FilterName, params.Namespace, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取HorizontalPodAutoscaler列表成功",
+		"data": data,
+	})
+}
+
+// 获取hpa详情
+func (h *horizontalPodAutoscaler) GetHorizontalPodAutoscalerDetail(ctx *gin.Context) {
+	params := new(struct {
+		HpaName   string `form:"hpa_name"`
+		Namespace string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.HorizontalPodAutoscaler.GetHorizontalPodAutoscalerDetail(params.HpaName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取HorizontalPodAutoscaler详情成功",
+		"data": data,
+	})
+}
+
+// 创建hpa
+func (h *horizontalPodAutoscaler) CreateHorizontalPodAutoscaler(ctx *gin.Context) {
+	var (
+		hpaCreate = new(service.HpaCreate)
+		err       error
+	)
+	if err = ctx.ShouldBindJSON(hpaCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.HorizontalPodAutoscaler.CreateHorizontalPodAutoscaler(hpaCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建HorizontalPodAutoscaler成功: %s", hpaCreate.Name),
+		"data": nil,
+	})
+}
+
+// 更新hpa
+func (h *horizontalPodAutoscaler) UpdateHorizontalPodAutoscaler(ctx *gin.Context) {
+	params := new(struct {
+		Namespace string `json:"namespace"`
+		Content   string `json:"content"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.HorizontalPodAutoscaler.UpdateHorizontalPodAutoscaler(params.Namespace, params.Content); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "更新HorizontalPodAutoscaler成功",
+		"data": nil,
+	})
+}
+
+// 删除hpa
+func (h *horizontalPodAutoscaler) DeleteHorizontalPodAutoscaler(ctx *gin.Context) {
+	params := new(struct {
+		HpaName   string `json:"hpa_name"`
+		Namespace string `json:"namespace"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.HorizontalPodAutoscaler.DeleteHorizontalPodAutoscaler(params.HpaName, params.Namespace); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除HorizontalPodAutoscaler %s 成功", params.HpaName),
+		"data": nil,
+	})
+}
@@ -108,7 +108,7 @@ func (i *ingress) CreateIngress(ctx *gin.Context)  {
 		IngressCreate = new(service.IngressCreate)
 		err error
 	)
-	if ctx.ShouldBindJSON(IngressCreate); err != nil {
+	if err = ctx.ShouldBindJSON(IngressCreate); err != nil {
 		logger.Error("ShouldBind请求参数失败, " + err.Error())
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"msg": err.Error(),
@@ -134,6 +134,8 @@ func (i *ingress) UpdateIngress(ctx *gin.Context)  {
 	params := new(struct{
 		Content			string	`json:"content"`
 		Namespace		string	`json:"namespace"`
+		//需要同时创建或替换的TLS Secret
+		TLS				[]*service.IngressTLSCreate	`json:"tls"`
 	})
 	if err := ctx.ShouldBindJSON(params); err != nil {
 		logger.Error("ShouldBind请求参数失败, " + err.Error())
@@ -151,7 +153,7 @@ func (i *ingress) UpdateIngress(ctx *gin.Context)  {
 		})
 		return
 	}
-	err := service.Ingress.UpdateIngress(params.Namespace, params.Content)
+	err := service.Ingress.UpdateIngress(params.Namespace, params.Content, params.TLS)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"msg": err.Error(),
@@ -163,4 +165,68 @@ func (i *ingress) UpdateIngress(ctx *gin.Context)  {
 		"msg": fmt.Sprintf("Namespace: %s 下的 Ingress更新成功", params.Namespace),
 		"data": nil,
 	})
-}
\ No newline at end of file
+}
+
+//获取ingress的后端service、端口、endpoint以及tls secret, 并标记断开的环节
+func (i *ingress) GetIngressChain(ctx *gin.Context)  {
+	params := new(struct{
+		IngressName		string	`form:"ingress_name"`
+		Namespace		string	`form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Ingress.GetIngressChain(params.IngressName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": fmt.Sprintf("获取Namespace: %s 下 Ingress: %s 链路成功, 发现%d个问题", params.Namespace, params.IngressName, len(data.Problems)),
+		"data": data,
+	})
+}
+
+
+//获取IngressClass列表, 创建ingress时用于选择ingress_class_name
+func (i *ingress) GetIngressClasses(ctx *gin.Context)  {
+	data, err := service.Ingress.GetIngressClasses()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": "获取IngressClass列表成功",
+		"data": data,
+	})
+}
+
+//获取nginx、traefik控制器的注解预设
+func (i *ingress) GetAnnotationPresets(ctx *gin.Context)  {
+	params := new(struct{
+		Controller		string	`form:"controller"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": "获取Ingress注解预设成功",
+		"data": service.Ingress.GetAnnotationPresets(params.Controller),
+	})
+}
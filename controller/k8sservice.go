@@ -157,4 +157,32 @@ func (svc *k8sService) UpdateK8sService(ctx *gin.Context)  {
 		"msg": fmt.Sprintf("创建Namespace: %s 下 Service 成功. ", params.Namespace),
 		"data": nil,
 	})
-}
\ No newline at end of file
+}
+
+//获取service的endpointslice、后端pod及其就绪状态, 并标记断开的环节
+func (svc *k8sService) GetK8sServiceChain(ctx *gin.Context)  {
+	params := new(struct{
+		K8sServiceName	string	`form:"k8s_service_name"`
+		Namespace		string	`form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.K8sService.GetK8sServiceChain(params.K8sServiceName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": fmt.Sprintf("获取Namespace: %s 下 Service: %s 链路成功, 发现%d个问题", params.Namespace, params.K8sServiceName, len(data.Problems)),
+		"data": data,
+	})
+}
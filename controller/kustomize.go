@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var Kustomize kustomize
+
+type kustomize struct{}
+
+// 获取kustomize bundle列表, 支持按名字过滤和分页
+func (k *kustomize) GetBundles(ctx *gin.Context) {
+	params := new(struct {
+		Name  string `form:"name"`
+		Page  int    `form:"page"`
+		Limit int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Kustomize.GetBundles(params.Name, params.Page, params.Limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取Kustomize Bundle列表成功",
+		"data": data,
+	})
+}
+
+// 获取kustomize bundle详情
+func (k *kustomize) GetBundleDetail(ctx *gin.Context) {
+	params := new(struct {
+		ID int `form:"id"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Kustomize.GetBundleDetail(params.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取Kustomize Bundle详情成功",
+		"data": data,
+	})
+}
+
+// 上传kustomization压缩包保存为bundle, 同名bundle存在时替换, 使用multipart/form-data(字段名archive)
+func (k *kustomize) UploadBundle(ctx *gin.Context) {
+	params := new(struct {
+		Name        string `form:"name"`
+		Description string `form:"description"`
+		Path        string `form:"path"`
+	})
+	if err := ctx.ShouldBind(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	archive, err := readFormFile(ctx, "archive")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Kustomize.SaveBundle(params.Name, params.Description, params.Path, archive)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("保存Kustomize Bundle: %s 成功", params.Name),
+		"data": data,
+	})
+}
+
+// 删除kustomize bundle, 已apply的对象保留在集群中
+func (k *kustomize) DeleteBundle(ctx *gin.Context) {
+	params := new(struct {
+		ID int `json:"id"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Kustomize.DeleteBundle(params.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "删除Kustomize Bundle成功",
+		"data": nil,
+	})
+}
+
+// 渲染kustomization, 支持上传压缩包或json指定bundle_id
+func (k *kustomize) Render(ctx *gin.Context) {
+	params, archive, err := bindKustomizeSource(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Kustomize.Render(params, archive)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "渲染Kustomization成功",
+		"data": data,
+	})
+}
+
+// 对比kustomization渲染结果与集群中的对象
+func (k *kustomize) Diff(ctx *gin.Context) {
+	params, archive, err := bindKustomizeSource(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Kustomize.Diff(params, archive)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "对比Kustomization成功",
+		"data": data,
+	})
+}
+
+// 应用kustomization, 返回每个对象的结果
+func (k *kustomize) Apply(ctx *gin.Context) {
+	params, archive, err := bindKustomizeSource(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Kustomize.Apply(params, archive)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("应用Kustomization完成, 成功%d个, 失败%d个", len(data.Results)-data.Failed, data.Failed),
+		"data": data,
+	})
+}
+
+// bindKustomizeSource 绑定render、diff和apply的参数, multipart请求时读取上传的压缩包(字段名archive)
+func bindKustomizeSource(ctx *gin.Context) (*service.KustomizeSource, []byte, error) {
+	params := new(service.KustomizeSource)
+	if !strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		if err := ctx.ShouldBindJSON(params); err != nil {
+			logger.Error("ShouldBind请求参数失败, " + err.Error())
+			return nil, nil, err
+		}
+		return params, nil, nil
+	}
+
+	if err := ctx.ShouldBind(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		return nil, nil, err
+	}
+	archive, err := readFormFile(ctx, "archive")
+	if err != nil {
+		return nil, nil, err
+	}
+	return params, archive, nil
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var NetworkPolicy networkPolicy
+
+type networkPolicy struct{}
+
+// 获取networkpolicy列表, 支持过滤、排序、分页
+func (np *networkPolicy) GetNetworkPolicies(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Namespace  string `form:"namespace"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.NetworkPolicy.GetNetworkPolicies(params.FilterName, params.Namespace, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取Namespace: %s 下的NetworkPolicy列表成功", params.Namespace),
+		"data": data,
+	})
+}
+
+// 获取networkpolicy详情
+func (np *networkPolicy) GetNetworkPolicyDetail(ctx *gin.Context) {
+	params := new(struct {
+		NetworkPolicyName string `form:"networkpolicy_name"`
+		Namespace         string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.NetworkPolicy.GetNetworkPolicyDetail(params.NetworkPolicyName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取NetworkPolicy: %s 详情成功", params.NetworkPolicyName),
+		"data": data,
+	})
+}
+
+// 删除networkpolicy
+func (np *networkPolicy) DeleteNetworkPolicy(ctx *gin.Context) {
+	params := new(struct {
+		NetworkPolicyName string `json:"networkpolicy_name"`
+		Namespace         string `json:"namespace"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.NetworkPolicy.DeleteNetworkPolicy(params.NetworkPolicyName, params.Namespace); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除NetworkPolicy: %s 成功", params.NetworkPolicyName),
+		"data": nil,
+	})
+}
+
+// 创建networkpolicy
+func (np *networkPolicy) CreateNetworkPolicy(ctx *gin.Context) {
+	var (
+		networkPolicyCreate = new(service.NetworkPolicyCreate)
+		err                 error
+	)
+	if err = ctx.ShouldBindJSON(networkPolicyCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.NetworkPolicy.CreateNetworkPolicy(networkPolicyCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建NetworkPolicy: %s 成功", networkPolicyCreate.Name),
+		"data": nil,
+	})
+}
+
+// 更新networkpolicy
+func (np *networkPolicy) UpdateNetworkPolicy(ctx *gin.Context) {
+	params := new(struct {
+		Namespace string `json:"namespace"`
+		Content   string `json:"content"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.NetworkPolicy.UpdateNetworkPolicy(params.Namespace, params.Content); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("更新Namespace: %s 下的NetworkPolicy成功", params.Namespace),
+		"data": nil,
+	})
+}
+
+// 判断源pod到目标pod端口的流量是否被networkpolicy放行, 并返回命中的规则
+func (np *networkPolicy) ExplainNetworkPolicy(ctx *gin.Context) {
+	params := new(struct {
+		SourcePod            string `form:"source_pod"`
+		SourceNamespace      string `form:"source_namespace"`
+		DestinationPod       string `form:"destination_pod"`
+		DestinationNamespace string `form:"destination_namespace"`
+		Port                 int32  `form:"port"`
+		Protocol             string `form:"protocol"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.NetworkPolicy.ExplainNetworkPolicy(params.SourcePod, params.SourceNamespace, params.DestinationPod, params.DestinationNamespace, params.Port, params.Protocol)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "NetworkPolicy连通性判断完成",
+		"data": data,
+	})
+}
@@ -82,6 +82,7 @@ func (pvc *persistentvolumeClaim) DeletePersistentVolumeClaim(ctx *gin.Context)
 	params := new(struct{
 		PersistentvolumeClaimName	string	`json:"persistent_volume_claim_name"`
 		Namespace					string	`json:"namespace"`
+		Force						bool	`json:"force"`
 	})
 	if err := ctx.ShouldBindJSON(params); err != nil {
 		logger.Error("ShouldBind请求参数绑定失败," + err.Error())
@@ -91,7 +92,23 @@ func (pvc *persistentvolumeClaim) DeletePersistentVolumeClaim(ctx *gin.Context)
 		})
 		return
 	}
-	err := service.PersistentVolumeClaim.DeletePersistentVolumeClaim(params.PersistentvolumeClaimName, params.Namespace)
+	//仍被工作负载引用时, 除非指定force, 否则拒绝删除并返回引用方
+	consumers, err := service.Reference.GetConsumers(service.RefKindPersistentVolumeClaim, params.PersistentvolumeClaimName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if len(consumers) > 0 && !params.Force {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": fmt.Sprintf("PersistentVolumeClaim %s 仍被%d个工作负载引用, 确认删除请指定force", params.PersistentvolumeClaimName, len(consumers)),
+			"data": consumers,
+		})
+		return
+	}
+	err = service.PersistentVolumeClaim.DeletePersistentVolumeClaim(params.PersistentvolumeClaimName, params.Namespace)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"msg": err.Error(),
@@ -130,4 +147,138 @@ func (pvc *persistentvolumeClaim) UpdatePersistentVolumeClaim(ctx *gin.Context)
 		"msg": fmt.Sprintf("更新Namespace: %s 下的PersistentVolumeClaim 成功", params.Namespace),
 		"data": nil,
 	})
-}
\ No newline at end of file
+}
+func (pvc *persistentvolumeClaim) CreatePersistentVolumeClaim(ctx *gin.Context)  {
+	var (
+		persistentVolumeClaimCreate = new(service.PersistentVolumeClaimCreate)
+		err error
+	)
+	if err = ctx.ShouldBindJSON(persistentVolumeClaimCreate); err != nil {
+		logger.Error("ShouldBind请求参数绑定失败," + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.PersistentVolumeClaim.CreatePersistentVolumeClaim(persistentVolumeClaimCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": fmt.Sprintf("创建Namespace: %s 下的PersistentVolumeClaim: %s 成功", persistentVolumeClaimCreate.Namespace, persistentVolumeClaimCreate.Name),
+		"data": nil,
+	})
+}
+
+func (pvc *persistentvolumeClaim) ResizePersistentVolumeClaim(ctx *gin.Context)  {
+	params := new(struct{
+		PersistentvolumeClaimName	string	`json:"persistent_volume_claim_name"`
+		Namespace					string	`json:"namespace"`
+		StorageSize					string	`json:"storage_size"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数绑定失败," + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.PersistentVolumeClaim.ResizePersistentVolumeClaim(params.PersistentvolumeClaimName, params.Namespace, params.StorageSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": fmt.Sprintf("扩容Namespace: %s 下的PersistentVolumeClaim: %s 至 %s 已提交", params.Namespace, params.PersistentvolumeClaimName, params.StorageSize),
+		"data": data,
+	})
+}
+
+func (pvc *persistentvolumeClaim) GetPersistentVolumeClaimStatus(ctx *gin.Context)  {
+	params := new(struct{
+		PersistentvolumeClaimName	string	`form:"persistent_volume_claim_name"`
+		Namespace					string	`form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数绑定失败," + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.PersistentVolumeClaim.GetPersistentVolumeClaimStatus(params.PersistentvolumeClaimName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": fmt.Sprintf("获取Namespace: %s 下的PersistentVolumeClaim: %s 扩容状态成功", params.Namespace, params.PersistentvolumeClaimName),
+		"data": data,
+	})
+}
+
+func (pvc *persistentvolumeClaim) GetPersistentVolumeClaimPods(ctx *gin.Context)  {
+	params := new(struct{
+		PersistentvolumeClaimName	string	`form:"persistent_volume_claim_name"`
+		Namespace					string	`form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数绑定失败," + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.PersistentVolumeClaim.GetPersistentVolumeClaimPods(params.PersistentvolumeClaimName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": fmt.Sprintf("获取挂载了PersistentVolumeClaim: %s 的Pod成功", params.PersistentvolumeClaimName),
+		"data": data,
+	})
+}
+
+func (pvc *persistentvolumeClaim) GetPersistentVolumeClaimUsage(ctx *gin.Context)  {
+	params := new(struct{
+		PersistentvolumeClaimName	string	`form:"persistent_volume_claim_name"`
+		Namespace					string	`form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数绑定失败," + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.PersistentVolumeClaim.GetPersistentVolumeClaimUsage(params.PersistentvolumeClaimName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": fmt.Sprintf("获取PersistentVolumeClaim: %s 用量成功", params.PersistentvolumeClaimName),
+		"data": data,
+	})
+}
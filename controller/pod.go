@@ -210,6 +210,35 @@ func (p *pod) GetPodLog(ctx *gin.Context) {
 	})
 }
 
+// 获取pod中容器的环境变量, 敏感来源的值脱敏返回
+func (p *pod) GetPodEnv(ctx *gin.Context) {
+	params := new(struct{
+		ContainerName	string	`form:"container_name"`
+		PodName			string	`form:"pod_name"`
+		Namespace		string	`form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Pod.GetPodEnv(params.ContainerName, params.PodName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": "获取Pod中容器环境变量成功",
+		"data": data,
+	})
+}
+
 // 7. 获取每个namespace 的pod数量
 func (p *pod) GetPodNumPerNp(ctx *gin.Context)  {
 	data, err := service.Pod.GetPodNumPerNp()
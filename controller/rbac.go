@@ -0,0 +1,503 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var Rbac rbac
+
+type rbac struct{}
+
+// 获取role列表, 支持过滤、排序、分页
+func (r *rbac) GetRoles(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Namespace  string `form:"namespace"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.GetRoles(params.FilterName, params.Namespace, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取Namespace: %s 下的Role列表成功", params.Namespace),
+		"data": data,
+	})
+}
+
+// 获取role详情
+func (r *rbac) GetRoleDetail(ctx *gin.Context) {
+	params := new(struct {
+		RoleName  string `form:"role_name"`
+		Namespace string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.GetRoleDetail(params.RoleName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取Role: %s 详情成功", params.RoleName),
+		"data": data,
+	})
+}
+
+// 获取clusterrole列表, 支持过滤、排序、分页
+func (r *rbac) GetClusterRoles(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.GetClusterRoles(params.FilterName, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取ClusterRole列表成功",
+		"data": data,
+	})
+}
+
+// 获取clusterrole详情
+func (r *rbac) GetClusterRoleDetail(ctx *gin.Context) {
+	params := new(struct {
+		ClusterRoleName string `form:"clusterrole_name"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.GetClusterRoleDetail(params.ClusterRoleName)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取ClusterRole: %s 详情成功", params.ClusterRoleName),
+		"data": data,
+	})
+}
+
+// 获取rolebinding列表, 支持过滤、排序、分页
+func (r *rbac) GetRoleBindings(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Namespace  string `form:"namespace"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.GetRoleBindings(params.FilterName, params.Namespace, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取Namespace: %s 下的RoleBinding列表成功", params.Namespace),
+		"data": data,
+	})
+}
+
+// 获取rolebinding详情
+func (r *rbac) GetRoleBindingDetail(ctx *gin.Context) {
+	params := new(struct {
+		RoleBindingName string `form:"rolebinding_name"`
+		Namespace       string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.GetRoleBindingDetail(params.RoleBindingName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取RoleBinding: %s 详情成功", params.RoleBindingName),
+		"data": data,
+	})
+}
+
+// 获取clusterrolebinding列表, 支持过滤、排序、分页
+func (r *rbac) GetClusterRoleBindings(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.GetClusterRoleBindings(params.FilterName, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取ClusterRoleBinding列表成功",
+		"data": data,
+	})
+}
+
+// 获取clusterrolebinding详情
+func (r *rbac) GetClusterRoleBindingDetail(ctx *gin.Context) {
+	params := new(struct {
+		ClusterRoleBindingName string `form:"clusterrolebinding_name"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.GetClusterRoleBindingDetail(params.ClusterRoleBindingName)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取ClusterRoleBinding: %s 详情成功", params.ClusterRoleBindingName),
+		"data": data,
+	})
+}
+
+// 获取serviceaccount列表, 支持过滤、排序、分页
+func (r *rbac) GetServiceAccounts(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Namespace  string `form:"namespace"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.GetServiceAccounts(params.FilterName, params.Namespace, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取Namespace: %s 下的ServiceAccount列表成功", params.Namespace),
+		"data": data,
+	})
+}
+
+// 获取serviceaccount详情
+func (r *rbac) GetServiceAccountDetail(ctx *gin.Context) {
+	params := new(struct {
+		ServiceAccountName string `form:"serviceaccount_name"`
+		Namespace          string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.GetServiceAccountDetail(params.ServiceAccountName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取ServiceAccount: %s 详情成功", params.ServiceAccountName),
+		"data": data,
+	})
+}
+
+// 创建rolebinding
+func (r *rbac) CreateRoleBinding(ctx *gin.Context) {
+	var (
+		roleBindingCreate = new(service.RoleBindingCreate)
+		err               error
+	)
+	if err = ctx.ShouldBindJSON(roleBindingCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.Rbac.CreateRoleBinding(roleBindingCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建RoleBinding: %s 成功", roleBindingCreate.Name),
+		"data": nil,
+	})
+}
+
+// 删除rolebinding
+func (r *rbac) DeleteRoleBinding(ctx *gin.Context) {
+	params := new(struct {
+		RoleBindingName string `json:"rolebinding_name"`
+		Namespace       string `json:"namespace"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Rbac.DeleteRoleBinding(params.RoleBindingName, params.Namespace); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除RoleBinding: %s 成功", params.RoleBindingName),
+		"data": nil,
+	})
+}
+
+// 创建clusterrolebinding
+func (r *rbac) CreateClusterRoleBinding(ctx *gin.Context) {
+	var (
+		clusterRoleBindingCreate = new(service.ClusterRoleBindingCreate)
+		err                      error
+	)
+	if err = ctx.ShouldBindJSON(clusterRoleBindingCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.Rbac.CreateClusterRoleBinding(clusterRoleBindingCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建ClusterRoleBinding: %s 成功", clusterRoleBindingCreate.Name),
+		"data": nil,
+	})
+}
+
+// 删除clusterrolebinding
+func (r *rbac) DeleteClusterRoleBinding(ctx *gin.Context) {
+	params := new(struct {
+		ClusterRoleBindingName string `json:"clusterrolebinding_name"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Rbac.DeleteClusterRoleBinding(params.ClusterRoleBindingName); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除ClusterRoleBinding: %s 成功", params.ClusterRoleBindingName),
+		"data": nil,
+	})
+}
+
+// 查询哪些主体可以在namespace下对资源执行某个操作
+func (r *rbac) WhoCan(ctx *gin.Context) {
+	params := new(struct {
+		Verb         string `form:"verb"`
+		ApiGroup     string `form:"api_group"`
+		Resource     string `form:"resource"`
+		ResourceName string `form:"resource_name"`
+		Namespace    string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.WhoCan(params.Verb, params.ApiGroup, params.Resource, params.ResourceName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("查询可以%s %s的主体成功", params.Verb, params.Resource),
+		"data": data,
+	})
+}
+
+// 查询主体拥有的权限
+func (r *rbac) WhatCan(ctx *gin.Context) {
+	params := new(struct {
+		SubjectKind      string `form:"subject_kind"`
+		SubjectName      string `form:"subject_name"`
+		SubjectNamespace string `form:"subject_namespace"`
+		Namespace        string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Rbac.WhatCan(params.SubjectKind, params.SubjectName, params.SubjectNamespace, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("查询%s: %s 的权限成功", params.SubjectKind, params.SubjectName),
+		"data": data,
+	})
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var Reference reference
+
+type reference struct{}
+
+// 获取namespace下ConfigMap/Secret/PVC的引用索引
+func (r *reference) GetReferenceIndex(ctx *gin.Context) {
+	params := new(struct {
+		Namespace string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Reference.BuildIndex(params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取Namespace: %s 下的引用索引成功", params.Namespace),
+		"data": data,
+	})
+}
+
+// 获取引用了指定ConfigMap/Secret/PVC的工作负载
+func (r *reference) GetConsumers(ctx *gin.Context) {
+	params := new(struct {
+		Kind      string `form:"kind"`
+		Name      string `form:"name"`
+		Namespace string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Reference.GetConsumers(params.Kind, params.Name, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取%s %s 的引用方成功", params.Kind, params.Name),
+		"data": data,
+	})
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"net/http"
+	"test4/middle"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,6 +29,7 @@ func (r *router) InitApiRouter(router *gin.Engine)  {
 	GET("/api/k8s/pods/container", Pod.GetPodContainer).
 	GET("/api/k8s/pods/log", Pod.GetPodLog).
 	GET("/api/k8s/pods/numnp", Pod.GetPodNumPerNp).
+	GET("/api/k8s/pods/env", Pod.GetPodEnv).
 	//deployment操作
 	GET("/api/k8s/deployments", Deployment.GetDeployments).
 	GET("/api/k8s/deployment/detail", Deployment.GetDeploymentDetail).
@@ -54,6 +56,27 @@ func (r *router) InitApiRouter(router *gin.Engine)  {
 	PUT("/api/k8s/daemonset/restart", DaemonSet.RestartDaemonSet).
 	PUT("/api/k8s/daemonset/update", DaemonSet.UpdateDaemonSet).
 	GET("/api/k8s/daemonset/numnp", DaemonSet.GetDaemonSetNumPerNp).
+	//hpa操作
+	GET("/api/k8s/hpas", HorizontalPodAutoscaler.GetHorizontalPodAutoscalers).
+	GET("/api/k8s/hpa/detail", HorizontalPodAutoscaler.GetHorizontalPodAutoscalerDetail).
+	POST("/api/k8s/hpa/create", HorizontalPodAutoscaler.CreateHorizontalPodAutoscaler).
+	PUT("/api/k8s/hpa/update", HorizontalPodAutoscaler.UpdateHorizontalPodAutoscaler).
+	DELETE("/api/k8s/hpa/delete", HorizontalPodAutoscaler.DeleteHorizontalPodAutoscaler).
+	//job操作
+	GET("/api/k8s/jobs", Job.GetJobs).
+	GET("/api/k8s/job/detail", Job.GetJobDetail).
+	POST("/api/k8s/job/create", Job.CreateJob).
+	DELETE("/api/k8s/job/delete", Job.DeleteJob).
+	GET("/api/k8s/job/pods", Job.GetJobPods).
+	GET("/api/k8s/job/log", Job.GetJobLogs).
+	//cronjob操作
+	GET("/api/k8s/cronjobs", CronJob.GetCronJobs).
+	GET("/api/k8s/cronjob/detail", CronJob.GetCronJobDetail).
+	POST("/api/k8s/cronjob/create", CronJob.CreateCronJob).
+	DELETE("/api/k8s/cronjob/delete", CronJob.DeleteCronJob).
+	POST("/api/k8s/cronjob/trigger", CronJob.TriggerCronJob).
+	PUT("/api/k8s/cronjob/suspend", CronJob.SuspendCronJob).
+	GET("/api/k8s/cronjob/jobs", CronJob.GetCronJobJobs).
 	//集群级别-node操作
 	GET("/api/k8s/nodes", K8sNode.GetK8sNodes).
 	GET("/api/k8s/node/detail", K8sNode.GetK8sNodeDetail).
@@ -65,18 +88,85 @@ func (r *router) InitApiRouter(router *gin.Engine)  {
 	GET("/api/k8s/persistentvolumes", PersistentVolume.GetPersistentVolumes).
 	GET("/api/k8s/persistentvolume/detail", PersistentVolume.GetPersistentVolumeDetail).
 	DELETE("/api/k8s/persistentvolume/delete", PersistentVolume.DeletePersistentVolume).
+	//集群级别-storageclass
+	GET("/api/k8s/storageclasses", StorageClass.GetStorageClasses).
+	GET("/api/k8s/storageclass/detail", StorageClass.GetStorageClassDetail).
+	POST("/api/k8s/storageclass/create", StorageClass.CreateStorageClass).
+	DELETE("/api/k8s/storageclass/delete", StorageClass.DeleteStorageClass).
+	PUT("/api/k8s/storageclass/default", StorageClass.SetDefaultStorageClass).
+	//VolumeSnapshot操作
+	GET("/api/k8s/volumesnapshotclasses", VolumeSnapshot.GetVolumeSnapshotClasses).
+	GET("/api/k8s/volumesnapshots", VolumeSnapshot.GetVolumeSnapshots).
+	GET("/api/k8s/volumesnapshot/detail", VolumeSnapshot.GetVolumeSnapshotDetail).
+	POST("/api/k8s/volumesnapshot/create", VolumeSnapshot.CreateVolumeSnapshot).
+	DELETE("/api/k8s/volumesnapshot/delete", VolumeSnapshot.DeleteVolumeSnapshot).
+	POST("/api/k8s/volumesnapshot/restore", VolumeSnapshot.RestoreVolumeSnapshot).
 	//service操作
 	GET("/api/k8s/services", K8sService.GetK8sServices).
 	GET("/api/k8s/service/detail", K8sService.GetK8sServiceDetail).
+	GET("/api/k8s/service/chain", K8sService.GetK8sServiceChain).
 	DELETE("/api/k8s/service/delete", K8sService.DeleteK8sService).
 	POST("/api/k8s/service/create", K8sService.CreateService).
 	PUT("/api/k8s/service/update", K8sService.UpdateK8sService).
 	//Ingress操作
 	GET("/api/k8s/ingress", Ingress.GetIngress).
 	GET("/api/k8s/ingress/detail", Ingress.GetIngressDetail).
+	GET("/api/k8s/ingress/chain", Ingress.GetIngressChain).
 	DELETE("/api/k8s/ingress/delete", Ingress.DeleteIngress).
 	POST("/api/k8s/ingress/create", Ingress.CreateIngress).
 	PUT("/api/k8s/ingress/update", Ingress.UpdateIngress).
+	GET("/api/k8s/ingressclasses", Ingress.GetIngressClasses).
+	GET("/api/k8s/ingress/presets", Ingress.GetAnnotationPresets).
+	//Gateway API操作
+	GET("/api/k8s/gatewayclasses", Gateway.GetGatewayClasses).
+	GET("/api/k8s/gatewayclass/detail", Gateway.GetGatewayClassDetail).
+	POST("/api/k8s/gatewayclass/create", Gateway.CreateGatewayClass).
+	DELETE("/api/k8s/gatewayclass/delete", Gateway.DeleteGatewayClass).
+	GET("/api/k8s/gateways", Gateway.GetGateways).
+	GET("/api/k8s/gateway/detail", Gateway.GetGatewayDetail).
+	POST("/api/k8s/gateway/create", Gateway.CreateGateway).
+	DELETE("/api/k8s/gateway/delete", Gateway.DeleteGateway).
+	GET("/api/k8s/httproutes", Gateway.GetHTTPRoutes).
+	GET("/api/k8s/httproute/detail", Gateway.GetHTTPRouteDetail).
+	POST("/api/k8s/httproute/create", Gateway.CreateHTTPRoute).
+	DELETE("/api/k8s/httproute/delete", Gateway.DeleteHTTPRoute).
+	GET("/api/k8s/grpcroutes", Gateway.GetGRPCRoutes).
+	GET("/api/k8s/grpcroute/detail", Gateway.GetGRPCRouteDetail).
+	POST("/api/k8s/grpcroute/create", Gateway.CreateGRPCRoute).
+	DELETE("/api/k8s/grpcroute/delete", Gateway.DeleteGRPCRoute).
+	//NetworkPolicy操作
+	GET("/api/k8s/networkpolicies", NetworkPolicy.GetNetworkPolicies).
+	GET("/api/k8s/networkpolicy/detail", NetworkPolicy.GetNetworkPolicyDetail).
+	DELETE("/api/k8s/networkpolicy/delete", NetworkPolicy.DeleteNetworkPolicy).
+	POST("/api/k8s/networkpolicy/create", NetworkPolicy.CreateNetworkPolicy).
+	PUT("/api/k8s/networkpolicy/update", NetworkPolicy.UpdateNetworkPolicy).
+	GET("/api/k8s/networkpolicy/explain", NetworkPolicy.ExplainNetworkPolicy).
+	//RBAC操作
+	GET("/api/k8s/roles", Rbac.GetRoles).
+	GET("/api/k8s/role/detail", Rbac.GetRoleDetail).
+	GET("/api/k8s/clusterroles", Rbac.GetClusterRoles).
+	GET("/api/k8s/clusterrole/detail", Rbac.GetClusterRoleDetail).
+	GET("/api/k8s/rolebindings", Rbac.GetRoleBindings).
+	GET("/api/k8s/rolebinding/detail", Rbac.GetRoleBindingDetail).
+	GET("/api/k8s/clusterrolebindings", Rbac.GetClusterRoleBindings).
+	GET("/api/k8s/clusterrolebinding/detail", Rbac.GetClusterRoleBindingDetail).
+	//创建binding可给任意主体授予任意角色, 需要管理员权限
+	POST("/api/k8s/rolebinding/create", middle.JWTAuth(), middle.AdminAuth(), Rbac.CreateRoleBinding).
+	DELETE("/api/k8s/rolebinding/delete", middle.JWTAuth(), middle.AdminAuth(), Rbac.DeleteRoleBinding).
+	POST("/api/k8s/clusterrolebinding/create", middle.JWTAuth(), middle.AdminAuth(), Rbac.CreateClusterRoleBinding).
+	DELETE("/api/k8s/clusterrolebinding/delete", middle.JWTAuth(), middle.AdminAuth(), Rbac.DeleteClusterRoleBinding).
+	GET("/api/k8s/serviceaccounts", Rbac.GetServiceAccounts).
+	GET("/api/k8s/serviceaccount/detail", Rbac.GetServiceAccountDetail).
+	GET("/api/k8s/rbac/whocan", Rbac.WhoCan).
+	GET("/api/k8s/rbac/whatcan", Rbac.WhatCan).
+	//CRD及任意资源操作
+	GET("/api/k8s/crds", Crd.GetCustomResourceDefinitions).
+	GET("/api/k8s/crd/detail", Crd.GetCustomResourceDefinitionDetail).
+	GET("/api/k8s/resources", GenericResource.GetResources).
+	GET("/api/k8s/resource/detail", GenericResource.GetResourceDetail).
+	//可创建和删除任意资源, 包括Secret和ClusterRoleBinding, 需要管理员权限
+	DELETE("/api/k8s/resource/delete", middle.JWTAuth(), middle.AdminAuth(), GenericResource.DeleteResource).
+	POST("/api/k8s/resource/apply", middle.JWTAuth(), middle.AdminAuth(), GenericResource.ApplyResource).
 	//ConfigMap操作
 	GET("/api/k8s/configmaps", ConfigMap.GetConfigMaps).
 	GET("/api/k8s/configmap/detail", ConfigMap.GetConfigMapDetail).
@@ -87,16 +177,71 @@ func (r *router) InitApiRouter(router *gin.Engine)  {
 	GET("/api/k8s/secret/detail", Secret.GetSecretDetail).
 	DELETE("/api/k8s/secret/delete", Secret.DeleteSecret).
 	PUT("/api/k8s/secret/update", Secret.UpdateSecret).
+	//查看Secret明文需要管理员权限, 并记录审计日志
+	GET("/api/k8s/secret/reveal", middle.JWTAuth(), middle.AdminAuth(), Secret.RevealSecret).
+	//审计记录
+	GET("/api/audits", middle.JWTAuth(), middle.AdminAuth(), Audit.GetList).
 	//PersistentVolumeClaim操作
 	GET("/api/k8s/persistentvolumeclaims", PersistentVolumeClaim.PersistentVolumeClaims).
 	GET("/api/k8s/persistentvolumeclaim/detail", PersistentVolumeClaim.GetPersistentVolumeClaimDetail).
 	DELETE("/api/k8s/persistentvolumeclaim/delete", PersistentVolumeClaim.DeletePersistentVolumeClaim).
 	PUT("/api/k8s/persistentvolumeclaim/update", PersistentVolumeClaim.UpdatePersistentVolumeClaim).
+	POST("/api/k8s/persistentvolumeclaim/create", PersistentVolumeClaim.CreatePersistentVolumeClaim).
+	PUT("/api/k8s/persistentvolumeclaim/resize", PersistentVolumeClaim.ResizePersistentVolumeClaim).
+	GET("/api/k8s/persistentvolumeclaim/status", PersistentVolumeClaim.GetPersistentVolumeClaimStatus).
+	GET("/api/k8s/persistentvolumeclaim/pods", PersistentVolumeClaim.GetPersistentVolumeClaimPods).
+	GET("/api/k8s/persistentvolumeclaim/usage", PersistentVolumeClaim.GetPersistentVolumeClaimUsage).
+	//ConfigMap/Secret/PVC引用关系
+	GET("/api/k8s/references", Reference.GetReferenceIndex).
+	GET("/api/k8s/reference/consumers", Reference.GetConsumers).
 	//Workflow操作
 	GET("/api/k8s/workflows", Workflow.GetList).
 	GET("/api/k8s/workflow/detail", Workflow.GetById).
 	POST("/api/k8s/workflow/create", Workflow.CreateWorkflow).
-	DELETE("/api/k8s/workflow/delete", Workflow.DelById)
+	DELETE("/api/k8s/workflow/delete", Workflow.DelById).
+	PUT("/api/k8s/workflow/update", Workflow.UpdateWorkflow).
+	GET("/api/k8s/workflow/specs", Workflow.GetWorkflowSpecs).
+	GET("/api/k8s/workflow/drift", Workflow.GetWorkflowDrift).
+	POST("/api/k8s/workflow/reconcile", Workflow.ReconcileWorkflow).
+	POST("/api/k8s/workflow/rollback", Workflow.RollbackWorkflow).
+	DELETE("/api/k8s/workflow/bulkdelete", Workflow.BulkDelete).
+	POST("/api/k8s/workflow/bulkreconcile", Workflow.BulkReconcile).
+	GET("/api/k8s/workflow/releases", Workflow.GetReleases).
+	POST("/api/k8s/workflow/release/start", Workflow.StartRelease).
+	POST("/api/k8s/workflow/release/promote", Workflow.PromoteRelease).
+	POST("/api/k8s/workflow/release/abort", Workflow.AbortRelease).
+	//Workflow模板
+	GET("/api/k8s/workflowtemplates", WorkflowTemplate.GetTemplates).
+	GET("/api/k8s/workflowtemplate/detail", WorkflowTemplate.GetTemplateDetail).
+	POST("/api/k8s/workflowtemplate/create", WorkflowTemplate.CreateTemplate).
+	PUT("/api/k8s/workflowtemplate/update", WorkflowTemplate.UpdateTemplate).
+	DELETE("/api/k8s/workflowtemplate/delete", WorkflowTemplate.DeleteTemplate).
+	POST("/api/k8s/workflowtemplate/render", WorkflowTemplate.RenderTemplate).
+	POST("/api/k8s/workflowtemplate/instantiate", WorkflowTemplate.InstantiateTemplate).
+	//Helm操作
+	GET("/api/k8s/helm/releases", Helm.GetReleases).
+	//release详情中的manifest和values可能包含Secret和密码, 变更操作可部署任意资源, 需要管理员权限
+	GET("/api/k8s/helm/release/detail", middle.JWTAuth(), middle.AdminAuth(), Helm.GetReleaseDetail).
+	GET("/api/k8s/helm/release/history", middle.JWTAuth(), middle.AdminAuth(), Helm.GetReleaseHistory).
+	POST("/api/k8s/helm/release/install", middle.JWTAuth(), middle.AdminAuth(), Helm.InstallRelease).
+	PUT("/api/k8s/helm/release/upgrade", middle.JWTAuth(), middle.AdminAuth(), Helm.UpgradeRelease).
+	POST("/api/k8s/helm/release/rollback", middle.JWTAuth(), middle.AdminAuth(), Helm.RollbackRelease).
+	DELETE("/api/k8s/helm/release/uninstall", middle.JWTAuth(), middle.AdminAuth(), Helm.UninstallRelease).
+	//Kustomize操作
+	GET("/api/k8s/kustomize/bundles", Kustomize.GetBundles).
+	GET("/api/k8s/kustomize/bundle/detail", Kustomize.GetBundleDetail).
+	POST("/api/k8s/kustomize/bundle/upload", Kustomize.UploadBundle).
+	DELETE("/api/k8s/kustomize/bundle/delete", Kustomize.DeleteBundle).
+	//diff会读取集群中的同名对象, apply可部署任意资源, 需要管理员权限
+	POST("/api/k8s/kustomize/render", middle.JWTAuth(), middle.AdminAuth(), Kustomize.Render).
+	POST("/api/k8s/kustomize/diff", middle.JWTAuth(), middle.AdminAuth(), Kustomize.Diff).
+	POST("/api/k8s/kustomize/apply", middle.JWTAuth(), middle.AdminAuth(), Kustomize.Apply).
+	//备份与恢复, 导出内容包含Secret, 需要管理员权限
+	GET("/api/k8s/backup/export", middle.JWTAuth(), middle.AdminAuth(), Backup.Export).
+	POST("/api/k8s/backup/restore", middle.JWTAuth(), middle.AdminAuth(), Backup.Restore).
+	//克隆工作负载或namespace, 会复制Secret, 需要管理员权限
+	POST("/api/k8s/clone/workload", middle.JWTAuth(), middle.AdminAuth(), Clone.CloneWorkload).
+	POST("/api/k8s/clone/namespace", middle.JWTAuth(), middle.AdminAuth(), Clone.CloneNamespace)
 
 }
 
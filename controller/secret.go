@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"test4/service"
+	"test4/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wonderivan/logger"
@@ -82,6 +83,7 @@ func (st *secret) DeleteSecret(ctx *gin.Context)  {
 	params := new(struct{
 		SecretName		string	`json:"secret_name"`
 		Namespace		string	`json:"namespace"`
+		Force			bool	`json:"force"`
 	})
 	if err := ctx.ShouldBindJSON(params); err != nil {
 		logger.Error("ShouldBind请求参数绑定失败," + err.Error())
@@ -91,7 +93,23 @@ func (st *secret) DeleteSecret(ctx *gin.Context)  {
 		})
 		return
 	}
-	err := service.Secret.DeleteSecret(params.SecretName, params.Namespace)
+	//仍被工作负载引用时, 除非指定force, 否则拒绝删除并返回引用方
+	consumers, err := service.Reference.GetConsumers(service.RefKindSecret, params.SecretName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if len(consumers) > 0 && !params.Force {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": fmt.Sprintf("Secret %s 仍被%d个工作负载引用, 确认删除请指定force", params.SecretName, len(consumers)),
+			"data": consumers,
+		})
+		return
+	}
+	err = service.Secret.DeleteSecret(params.SecretName, params.Namespace)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"msg": err.Error(),
@@ -109,6 +127,8 @@ func (st *secret) UpdateSecret(ctx *gin.Context)  {
 	params := new(struct{
 		Content			string	`json:"content"`
 		Namespace		string	`json:"namespace"`
+		Restart			bool	`json:"restart"`
+		DryRun			bool	`json:"dry_run"`
 	})
 	if err := ctx.ShouldBindJSON(params); err != nil {
 		logger.Error("ShouldBind请求参数绑定失败," + err.Error())
@@ -118,7 +138,7 @@ func (st *secret) UpdateSecret(ctx *gin.Context)  {
 		})
 		return
 	}
-	err := service.Secret.UpdateSecret(params.Namespace, params.Content)
+	data, err := service.Secret.UpdateSecret(params.Namespace, params.Content, params.Restart, params.DryRun)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"msg": err.Error(),
@@ -126,8 +146,47 @@ func (st *secret) UpdateSecret(ctx *gin.Context)  {
 		})
 		return
 	}
+	msg := fmt.Sprintf("更新Namespace: %s 下的Secret 成功", params.Namespace)
+	if params.DryRun {
+		msg = fmt.Sprintf("预览更新Namespace: %s 下的Secret 成功, 未实际生效", params.Namespace)
+	}
 	ctx.JSON(http.StatusOK, gin.H{
-		"msg": fmt.Sprintf("更新Namespace: %s 下的Secret 成功", params.Namespace),
-		"data": nil,
+		"msg": msg,
+		"data": data,
+	})
+}
+//查看secret中单个key的明文, 需要管理员权限, 每次查看都会记录审计日志
+func (st *secret) RevealSecret(ctx *gin.Context)  {
+	params := new(struct{
+		SecretName		string	`form:"secret_name"`
+		Namespace		string	`form:"namespace"`
+		Key				string	`form:"key"`
 	})
-}
\ No newline at end of file
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数绑定失败," + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	//AdminAuth中间件已校验过claims, 这里直接取用户名用于审计
+	var userName string
+	if claims, ok := ctx.Get("claims"); ok {
+		if customClaims, ok := claims.(*utils.CustomClaims); ok {
+			userName = customClaims.UserName
+		}
+	}
+	data, err := service.Secret.RevealSecret(params.SecretName, params.Namespace, params.Key, userName, ctx.ClientIP())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": fmt.Sprintf("查看Namespace: %s 下的Secret %s 的key: %s 成功", params.Namespace, params.SecretName, params.Key),
+		"data": data,
+	})
+}
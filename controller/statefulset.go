@@ -71,9 +71,11 @@ func (s *statefulSet) GetStatefulSetDetail(ctx *gin.Context)  {
 		})
 		return
 	}
+	//附带控制该StatefulSet的hpa, 获取失败不影响详情返回
+	hpa, _ := service.HorizontalPodAutoscaler.GetWorkloadHorizontalPodAutoscaler("StatefulSet", params.StatefulSetName, params.Namespace)
 	ctx.JSON(http.StatusOK, gin.H{
 		"msg": fmt.Sprintf("statefulset: %s列表获取成功", params.StatefulSetName),
-		"data": data,
+		"data": &service.StatefulSetDetail{StatefulSet: data, HorizontalPodAutoscaler: hpa},
 	})
 }
 
@@ -82,6 +84,7 @@ func (s *statefulSet) ScaleStatefulSet(ctx *gin.Context)  {
 		StatefulSetName		string	`json:"statefulset_name"`
 		Namespace			string	`json:"namespace"`
 		ScaleNum			int		`json:"scale_num"`
+		Force			bool	`json:"force"`
 	})
 	if err := ctx.ShouldBindJSON(params); err != nil {
 		logger.Error("ShouldBind请求参数绑定失败. " + err.Error())
@@ -91,6 +94,22 @@ func (s *statefulSet) ScaleStatefulSet(ctx *gin.Context)  {
 		})
 		return
 	}
+	//被hpa控制的工作负载, 手动设置的副本数会被hpa覆盖, 除非指定force, 否则拒绝
+	hpa, err := service.HorizontalPodAutoscaler.GetWorkloadHorizontalPodAutoscaler("StatefulSet", params.StatefulSetName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if hpa != nil && !params.Force {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": fmt.Sprintf("StatefulSet %s 由HorizontalPodAutoscaler %s 控制(最大%d副本), 手动设置的副本数会被覆盖, 确认设置请指定force", params.StatefulSetName, hpa.Name, hpa.Spec.MaxReplicas),
+			"data": hpa,
+		})
+		return
+	}
 	data, err := service.StatefulSet.ScaleStatefulSet(params.StatefulSetName, params.Namespace, params.ScaleNum)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
@@ -99,8 +118,12 @@ func (s *statefulSet) ScaleStatefulSet(ctx *gin.Context)  {
 		})
 		return
 	}
+	msg := fmt.Sprintf("statefulset: %s副本数更新成功", params.StatefulSetName)
+	if hpa != nil {
+		msg = fmt.Sprintf("statefulset: %s副本数更新成功, 注意: 副本数会被HorizontalPodAutoscaler %s 调整", params.StatefulSetName, hpa.Name)
+	}
 	ctx.JSON(http.StatusOK, gin.H{
-		"msg": fmt.Sprintf("statefulset: %s副本数更新成功", params.StatefulSetName),
+		"msg": msg,
 		"data": fmt.Sprintf("statefulset: %s副本数更新成功, 最新副本数为：%d", params.StatefulSetName, data),
 	})
 }
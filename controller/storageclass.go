@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var StorageClass storageClass
+
+type storageClass struct{}
+
+// 获取StorageClass列表, 支持过滤、排序、分页
+func (sc *storageClass) GetStorageClasses(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.StorageClass.GetStorageClasses(params.FilterName, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取StorageClass列表成功",
+		"data": data,
+	})
+}
+
+// 获取StorageClass详情
+func (sc *storageClass) GetStorageClassDetail(ctx *gin.Context) {
+	params := new(struct {
+		StorageClassName string `form:"storage_class_name"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.StorageClass.GetStorageClassDetail(params.StorageClassName)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取StorageClass: %s 详情成功", params.StorageClassName),
+		"data": data,
+	})
+}
+
+// 创建StorageClass
+func (sc *storageClass) CreateStorageClass(ctx *gin.Context) {
+	var (
+		storageClassCreate = new(service.StorageClassCreate)
+		err                error
+	)
+	if err = ctx.ShouldBindJSON(storageClassCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.StorageClass.CreateStorageClass(storageClassCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建StorageClass: %s 成功", storageClassCreate.Name),
+		"data": nil,
+	})
+}
+
+// 删除StorageClass
+func (sc *storageClass) DeleteStorageClass(ctx *gin.Context) {
+	params := new(struct {
+		StorageClassName string `json:"storage_class_name"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.StorageClass.DeleteStorageClass(params.StorageClassName); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除StorageClass: %s 成功", params.StorageClassName),
+		"data": nil,
+	})
+}
+
+// 设置或取消默认StorageClass
+func (sc *storageClass) SetDefaultStorageClass(ctx *gin.Context) {
+	params := new(struct {
+		StorageClassName string `json:"storage_class_name"`
+		IsDefault        bool   `json:"is_default"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.StorageClass.SetDefaultStorageClass(params.StorageClassName, params.IsDefault); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("设置StorageClass: %s 默认标记为 %t 成功", params.StorageClassName, params.IsDefault),
+		"data": nil,
+	})
+}
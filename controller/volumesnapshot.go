@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var VolumeSnapshot volumeSnapshot
+
+type volumeSnapshot struct{}
+
+// 获取VolumeSnapshotClass列表
+func (vs *volumeSnapshot) GetVolumeSnapshotClasses(ctx *gin.Context) {
+	data, err := service.VolumeSnapshot.GetVolumeSnapshotClasses()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取VolumeSnapshotClass列表成功",
+		"data": data,
+	})
+}
+
+// 获取VolumeSnapshot列表, 支持过滤、排序、分页
+func (vs *volumeSnapshot) GetVolumeSnapshots(ctx *gin.Context) {
+	params := new(struct {
+		FilterName string `form:"filter_name"`
+		Namespace  string `form:"namespace"`
+		Page       int    `form:"page"`
+		Limit      int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.VolumeSnapshot.GetVolumeSnapshots(params.FilterName, params.Namespace, params.Limit, params.Page)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取Namespace: %s 下的VolumeSnapshot列表成功", params.Namespace),
+		"data": data,
+	})
+}
+
+// 获取VolumeSnapshot详情
+func (vs *volumeSnapshot) GetVolumeSnapshotDetail(ctx *gin.Context) {
+	params := new(struct {
+		VolumeSnapshotName string `form:"volume_snapshot_name"`
+		Namespace          string `form:"namespace"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.VolumeSnapshot.GetVolumeSnapshotDetail(params.VolumeSnapshotName, params.Namespace)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("获取VolumeSnapshot: %s 详情成功", params.VolumeSnapshotName),
+		"data": data,
+	})
+}
+
+// 给PVC创建快照
+func (vs *volumeSnapshot) CreateVolumeSnapshot(ctx *gin.Context) {
+	var (
+		volumeSnapshotCreate = new(service.VolumeSnapshotCreate)
+		err                  error
+	)
+	if err = ctx.ShouldBindJSON(volumeSnapshotCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.VolumeSnapshot.CreateVolumeSnapshot(volumeSnapshotCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建VolumeSnapshot: %s 成功", volumeSnapshotCreate.Name),
+		"data": nil,
+	})
+}
+
+// 删除VolumeSnapshot
+func (vs *volumeSnapshot) DeleteVolumeSnapshot(ctx *gin.Context) {
+	params := new(struct {
+		VolumeSnapshotName string `json:"volume_snapshot_name"`
+		Namespace          string `json:"namespace"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.VolumeSnapshot.DeleteVolumeSnapshot(params.VolumeSnapshotName, params.Namespace); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除VolumeSnapshot: %s 成功", params.VolumeSnapshotName),
+		"data": nil,
+	})
+}
+
+// 将快照恢复为新的PVC
+func (vs *volumeSnapshot) RestoreVolumeSnapshot(ctx *gin.Context) {
+	var (
+		volumeSnapshotRestore = new(service.VolumeSnapshotRestore)
+		err                   error
+	)
+	if err = ctx.ShouldBindJSON(volumeSnapshotRestore); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.VolumeSnapshot.RestoreVolumeSnapshot(volumeSnapshotRestore); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("从VolumeSnapshot: %s 恢复PersistentVolumeClaim: %s 成功", volumeSnapshotRestore.VolumeSnapshotName, volumeSnapshotRestore.Name),
+		"data": nil,
+	})
+}
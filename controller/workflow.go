@@ -13,13 +13,9 @@ var Workflow workflow
 
 type workflow struct{}
 
-// 获取列表分页查询
+// 获取列表分页查询, 支持按namespace、类型、团队、标签和创建时间过滤并排序
 func (wf *workflow) GetList(ctx *gin.Context) {
-	params := new(struct {
-		Name  string `form:"name"`
-		Page  int    `form:"page"`
-		Limit int    `form:"limit"`
-	})
+	params := new(service.WorkflowListQuery)
 	if err := ctx.Bind(params); err != nil {
 		logger.Error("Bind请求参数失败, " + err.Error())
 		ctx.JSON(http.StatusInternalServerError, gin.H{
@@ -28,8 +24,15 @@ func (wf *workflow) GetList(ctx *gin.Context) {
 		})
 		return
 	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": "Limit/Page参数不合法或小于等于0",
+			"data": nil,
+		})
+		return
+	}
 
-	data, err := service.Workflow.GetList(params.Name, params.Page, params.Limit)
+	data, err := service.Workflow.GetList(params)
 	if err != nil {
 		logger.Error("获取Workflow列表失败, " + err.Error())
 		ctx.JSON(http.StatusInternalServerError, gin.H{
@@ -131,4 +134,313 @@ func (wf *workflow) DelById(ctx *gin.Context)  {
 		"msg": "删除Workflow",
 		"data": nil,
 	})
-}
\ No newline at end of file
+}
+//更新workflow, 将新的spec应用到集群并保存为新版本
+func (wf *workflow) UpdateWorkflow(ctx *gin.Context)  {
+	params := new(struct{
+		ID		int						`json:"id"`
+		Spec	*service.WorkflowCreate	`json:"spec"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数绑定失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Spec == nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": "spec 未传参, 请传参数",
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Workflow.UpdateWorkflow(params.ID, params.Spec); err != nil {
+		logger.Error("更新Workflow失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": "更新Workflow成功",
+		"data": nil,
+	})
+}
+
+//获取workflow的spec版本列表
+func (wf *workflow) GetWorkflowSpecs(ctx *gin.Context)  {
+	params := new(struct{
+		ID int	`form:"id"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Workflow.GetWorkflowSpecs(params.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": "获取Workflow spec版本列表成功",
+		"data": data,
+	})
+}
+
+//对比集群中的资源与workflow当前的spec
+func (wf *workflow) GetWorkflowDrift(ctx *gin.Context)  {
+	params := new(struct{
+		ID int	`form:"id"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Workflow.GetWorkflowDrift(params.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": fmt.Sprintf("Workflow对比完成, 发现%d处不一致", len(data.Drifts)),
+		"data": data,
+	})
+}
+
+//按当前spec重新应用workflow的资源
+func (wf *workflow) ReconcileWorkflow(ctx *gin.Context)  {
+	params := new(struct{
+		ID int	`json:"id"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数绑定失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Workflow.ReconcileWorkflow(params.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": "同步Workflow成功",
+		"data": nil,
+	})
+}
+
+//回滚workflow到指定的spec版本
+func (wf *workflow) RollbackWorkflow(ctx *gin.Context)  {
+	params := new(struct{
+		ID		int	`json:"id"`
+		Version	int	`json:"version"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数绑定失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Version <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": "version参数不合法或小于等于0",
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Workflow.RollbackWorkflow(params.ID, params.Version); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": fmt.Sprintf("Workflow回滚到版本%d成功", params.Version),
+		"data": nil,
+	})
+}
+
+//批量删除workflow, 返回每个workflow的删除结果
+func (wf *workflow) BulkDelete(ctx *gin.Context)  {
+	params := new(struct{
+		IDs []int	`json:"ids"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数绑定失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data := service.Workflow.BulkDelete(params.IDs)
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": bulkResultMsg("批量删除Workflow", data),
+		"data": data,
+	})
+}
+
+//批量按当前spec同步workflow, 返回每个workflow的同步结果
+func (wf *workflow) BulkReconcile(ctx *gin.Context)  {
+	params := new(struct{
+		IDs []int	`json:"ids"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数绑定失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data := service.Workflow.BulkReconcile(params.IDs)
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": bulkResultMsg("批量同步Workflow", data),
+		"data": data,
+	})
+}
+
+func bulkResultMsg(action string, results []*service.WorkflowBulkResult) string {
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+	return fmt.Sprintf("%s完成, 成功%d个, 失败%d个", action, len(results)-failed, failed)
+}
+
+//获取workflow的发布记录
+func (wf *workflow) GetReleases(ctx *gin.Context)  {
+	params := new(struct{
+		ID int	`form:"id"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Workflow.GetReleases(params.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": "获取Workflow发布记录成功",
+		"data": data,
+	})
+}
+
+//按蓝绿或金丝雀策略开始发布
+func (wf *workflow) StartRelease(ctx *gin.Context)  {
+	params := new(service.WorkflowReleaseCreate)
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数绑定失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Spec == nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": "spec 未传参, 请传参数",
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Workflow.StartRelease(params); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": "开始发布成功",
+		"data": nil,
+	})
+}
+
+//推进workflow进行中的发布, 返回推进后的发布记录
+func (wf *workflow) PromoteRelease(ctx *gin.Context)  {
+	params := new(struct{
+		ID int	`json:"id"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数绑定失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.Workflow.PromoteRelease(params.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": "推进发布成功",
+		"data": data,
+	})
+}
+
+//终止workflow进行中的发布
+func (wf *workflow) AbortRelease(ctx *gin.Context)  {
+	params := new(struct{
+		ID int	`json:"id"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数绑定失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.Workflow.AbortRelease(params.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg": "终止发布成功",
+		"data": nil,
+	})
+}
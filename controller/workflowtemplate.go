@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"test4/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wonderivan/logger"
+)
+
+var WorkflowTemplate workflowTemplate
+
+type workflowTemplate struct{}
+
+// 获取workflow模板目录, 支持按名字、分类过滤
+func (wt *workflowTemplate) GetTemplates(ctx *gin.Context) {
+	params := new(struct {
+		Name     string `form:"name"`
+		Category string `form:"category"`
+		Page     int    `form:"page"`
+		Limit    int    `form:"limit"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if params.Limit <= 0 || params.Page <= 0 {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  "Limit/Page参数错误",
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.WorkflowTemplate.GetTemplates(params.Name, params.Category, params.Page, params.Limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取workflow模板目录成功",
+		"data": data,
+	})
+}
+
+// 获取workflow模板详情
+func (wt *workflowTemplate) GetTemplateDetail(ctx *gin.Context) {
+	params := new(struct {
+		ID int `form:"id"`
+	})
+	if err := ctx.Bind(params); err != nil {
+		logger.Error("Bind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.WorkflowTemplate.GetTemplateDetail(params.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "获取workflow模板详情成功",
+		"data": data,
+	})
+}
+
+// 创建workflow模板
+func (wt *workflowTemplate) CreateTemplate(ctx *gin.Context) {
+	var (
+		templateCreate = new(service.WorkflowTemplateCreate)
+		err            error
+	)
+	if err = ctx.ShouldBindJSON(templateCreate); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err = service.WorkflowTemplate.CreateTemplate(templateCreate); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("创建workflow模板: %s 成功", templateCreate.Name),
+		"data": nil,
+	})
+}
+
+// 更新workflow模板
+func (wt *workflowTemplate) UpdateTemplate(ctx *gin.Context) {
+	params := new(struct {
+		ID       int                             `json:"id"`
+		Template *service.WorkflowTemplateCreate `json:"template"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.WorkflowTemplate.UpdateTemplate(params.ID, params.Template); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("更新workflow模板: %d 成功", params.ID),
+		"data": nil,
+	})
+}
+
+// 删除workflow模板
+func (wt *workflowTemplate) DeleteTemplate(ctx *gin.Context) {
+	params := new(struct {
+		ID int `json:"id"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	if err := service.WorkflowTemplate.DeleteTemplate(params.ID); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("删除workflow模板: %d 成功", params.ID),
+		"data": nil,
+	})
+}
+
+// 使用参数渲染workflow模板, 只返回结果不创建
+func (wt *workflowTemplate) RenderTemplate(ctx *gin.Context) {
+	params := new(struct {
+		ID     int                    `json:"id"`
+		Params map[string]interface{} `json:"params"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.WorkflowTemplate.RenderTemplate(params.ID, params.Params)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  "渲染workflow模板成功",
+		"data": data,
+	})
+}
+
+// 使用参数渲染workflow模板并创建workflow
+func (wt *workflowTemplate) InstantiateTemplate(ctx *gin.Context) {
+	params := new(struct {
+		ID     int                    `json:"id"`
+		Params map[string]interface{} `json:"params"`
+	})
+	if err := ctx.ShouldBindJSON(params); err != nil {
+		logger.Error("ShouldBind请求参数失败, " + err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	data, err := service.WorkflowTemplate.InstantiateTemplate(params.ID, params.Params)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"msg":  err.Error(),
+			"data": nil,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"msg":  fmt.Sprintf("使用模板创建workflow: %s 成功", data.Name),
+		"data": data,
+	})
+}
@@ -0,0 +1,59 @@
+package dao
+
+import (
+	"errors"
+	"test4/db"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+)
+
+var Audit audit
+
+type audit struct{}
+
+// 定义审计列表返回内容
+type AuditResp struct {
+	Items []*model.AuditLog `json:"items"`
+	Total int               `json:"total"`
+}
+
+//获取审计记录列表, 支持按用户名过滤和分页
+func (a *audit) GetList(userName string, page, limit int) (auditResp *AuditResp, err error) {
+	startSet := (page - 1) * limit
+
+	var (
+		auditList []*model.AuditLog
+		total     int
+	)
+	tx := db.GORM.Model(&model.AuditLog{}).Where("user_name like ?", "%"+userName+"%").Count(&total)
+	if tx.Error != nil {
+		logger.Error("获取审计记录总数失败," + tx.Error.Error())
+		return nil, errors.New("获取审计记录总数失败," + tx.Error.Error())
+	}
+
+	tx = db.GORM.Where("user_name like ?", "%"+userName+"%").
+		Limit(limit).
+		Offset(startSet).
+		Order("id desc").
+		Find(&auditList)
+	if tx.Error != nil && tx.Error.Error() != "record not found" {
+		logger.Error("获取审计记录列表失败," + tx.Error.Error())
+		return nil, errors.New("获取审计记录列表失败," + tx.Error.Error())
+	}
+
+	return &AuditResp{
+		Items: auditList,
+		Total: total,
+	}, nil
+}
+
+//新增审计记录
+func (a *audit) Add(auditLog *model.AuditLog) (err error) {
+	tx := db.GORM.Create(auditLog)
+	if tx.Error != nil {
+		logger.Error("添加审计记录失败," + tx.Error.Error())
+		return errors.New("添加审计记录失败," + tx.Error.Error())
+	}
+	return nil
+}
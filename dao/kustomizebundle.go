@@ -0,0 +1,92 @@
+package dao
+
+import (
+	"errors"
+	"test4/db"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+)
+
+var KustomizeBundle kustomizeBundle
+
+type kustomizeBundle struct{}
+
+//定义列表返回内容, Items是bundle列表, 不包含压缩包内容, Total为过滤后的总数
+type KustomizeBundleResp struct {
+	Items []*model.KustomizeBundle `json:"items"`
+	Total int                      `json:"total"`
+}
+
+//列表不查询archive字段
+const kustomizeBundleListColumns = "id, created_at, updated_at, name, description, path, size, inventory, applied_at"
+
+//获取bundle列表, 按名字过滤并分页
+func (kb *kustomizeBundle) GetList(name string, page, limit int) (bundleResp *KustomizeBundleResp, err error) {
+	startSet := (page - 1) * limit
+	var bundles []*model.KustomizeBundle
+	total := 0
+
+	tx := db.GORM.Model(&model.KustomizeBundle{}).Where("name like ?", "%"+name+"%")
+	if err := tx.Count(&total).Error; err != nil {
+		logger.Error("获取kustomize bundle数量失败," + err.Error())
+		return nil, errors.New("获取kustomize bundle数量失败," + err.Error())
+	}
+	tx = tx.Select(kustomizeBundleListColumns).Limit(limit).Offset(startSet).Order("id desc").Find(&bundles)
+	if tx.Error != nil && tx.Error.Error() != "record not found" {
+		logger.Error("获取kustomize bundle列表失败," + tx.Error.Error())
+		return nil, errors.New("获取kustomize bundle列表失败," + tx.Error.Error())
+	}
+	return &KustomizeBundleResp{
+		Items: bundles,
+		Total: total,
+	}, nil
+}
+
+//获取bundle单条数据, 包含压缩包内容, 不存在时返回nil
+func (kb *kustomizeBundle) GetById(id int) (bundle *model.KustomizeBundle, err error) {
+	bundle = &model.KustomizeBundle{}
+	tx := db.GORM.Where("id = ?", id).First(bundle)
+	if tx.Error != nil {
+		if tx.RecordNotFound() {
+			return nil, nil
+		}
+		logger.Error("获取kustomize bundle失败," + tx.Error.Error())
+		return nil, errors.New("获取kustomize bundle失败," + tx.Error.Error())
+	}
+	return bundle, nil
+}
+
+//按名字获取bundle, 不存在时返回nil
+func (kb *kustomizeBundle) GetByName(name string) (bundle *model.KustomizeBundle, err error) {
+	bundle = &model.KustomizeBundle{}
+	tx := db.GORM.Where("name = ?", name).First(bundle)
+	if tx.Error != nil {
+		if tx.RecordNotFound() {
+			return nil, nil
+		}
+		logger.Error("获取kustomize bundle失败," + tx.Error.Error())
+		return nil, errors.New("获取kustomize bundle失败," + tx.Error.Error())
+	}
+	return bundle, nil
+}
+
+//新增或更新bundle, ID为0时新增
+func (kb *kustomizeBundle) Save(bundle *model.KustomizeBundle) (err error) {
+	tx := db.GORM.Save(bundle)
+	if tx.Error != nil {
+		logger.Error("保存kustomize bundle失败," + tx.Error.Error())
+		return errors.New("保存kustomize bundle失败," + tx.Error.Error())
+	}
+	return nil
+}
+
+//删除bundle
+func (kb *kustomizeBundle) DelById(id int) (err error) {
+	tx := db.GORM.Where("id = ?", id).Delete(&model.KustomizeBundle{})
+	if tx.Error != nil {
+		logger.Error("删除kustomize bundle失败," + tx.Error.Error())
+		return errors.New("删除kustomize bundle失败," + tx.Error.Error())
+	}
+	return nil
+}
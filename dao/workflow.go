@@ -4,6 +4,7 @@ import (
 	"errors"
 	"test4/db"
 	"test4/model"
+	"time"
 
 	"github.com/wonderivan/logger"
 )
@@ -18,19 +19,88 @@ type WorkflowResp struct {
 	Total	int				`json:"total"`
 }
 
+//WorkflowQuery 列表查询条件, 字符串条件为空时不过滤
+//CreatedFrom、CreatedTo为创建时间范围, 包含CreatedFrom不包含CreatedTo
+//Labels中的每个标签都需要匹配
+type WorkflowQuery struct {
+	Name        string
+	Namespace   string
+	Type        string
+	Team        string
+	Labels      map[string]string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	SortBy      string
+	Order       string
+	Page        int
+	Limit       int
+}
+
+//可用于排序的字段, 排序字段会拼接到sql中, 必须在白名单内
+var workflowSortColumns = map[string]bool{
+	"id":         true,
+	"name":       true,
+	"namespace":  true,
+	"type":       true,
+	"team":       true,
+	"replicas":   true,
+	"created_at": true,
+	"updated_at": true,
+}
+
 //获取列表分页查询
-func (wf *workflow) GetList(name string, page, limit int) (workflowResp *WorkflowResp, err error) {
+func (wf *workflow) GetList(query *WorkflowQuery) (workflowResp *WorkflowResp, err error) {
 	//定义分页数据的起始位置
-	startSet := (page -1) * limit
+	startSet := (query.Page -1) * query.Limit
 
 	//定义数据库查询返回内容
 	var workflowList []*model.Workflow
+	total := 0
+
+	//排序字段和方向, 默认按id倒序
+	sortBy := "id"
+	if query.SortBy != "" {
+		if !workflowSortColumns[query.SortBy] {
+			return nil, errors.New("不支持的排序字段: " + query.SortBy)
+		}
+		sortBy = query.SortBy
+	}
+	order := "desc"
+	if query.Order == "asc" {
+		order = "asc"
+	}
+
+	tx := db.GORM.Model(&model.Workflow{}).Where("name like ?", "%" + query.Name + "%")
+	if query.Namespace != "" {
+		tx = tx.Where("namespace = ?", query.Namespace)
+	}
+	if query.Type != "" {
+		tx = tx.Where("type = ?", query.Type)
+	}
+	if query.Team != "" {
+		tx = tx.Where("team = ?", query.Team)
+	}
+	if query.CreatedFrom != nil {
+		tx = tx.Where("created_at >= ?", *query.CreatedFrom)
+	}
+	if query.CreatedTo != nil {
+		tx = tx.Where("created_at < ?", *query.CreatedTo)
+	}
+	for key, value := range query.Labels {
+		tx = tx.Where("id in (?)", db.GORM.Model(&model.WorkflowLabel{}).Select("workflow_id").
+			Where("label_key = ? and label_value = ?", key, value).QueryExpr())
+	}
+
+	//先统计过滤后的总数, 再分页查询
+	if err = tx.Count(&total).Error; err != nil {
+		logger.Error("获取workflow数量失败," + err.Error())
+		return nil, errors.New("获取workflow数量失败," + err.Error())
+	}
 
 	//数据库查询, limit方法用于限制条数, offset方法设置起始位置
-	tx := db.GORM.Where("name like ?", "%" + name + "%").
-	Limit(limit).
+	tx = tx.Limit(query.Limit).
 	Offset(startSet).
-	Order("id desc").
+	Order(sortBy + " " + order).
 	Find(&workflowList)
 
 	//gorm会默认把空数据也放到err中, 故这里要排除空数据的情况
@@ -41,7 +111,7 @@ func (wf *workflow) GetList(name string, page, limit int) (workflowResp *Workflo
 
 	return &WorkflowResp{
 		Items: workflowList,
-		Total: len(workflowList),
+		Total: total,
 	}, nil
 }
 
@@ -56,6 +126,31 @@ func (wf *workflow) GetById(id int) (workflow *model.Workflow, err error) {
 	return
 }
 
+//按名字获取workflow, 不存在时返回nil
+func (wf *workflow) GetByName(name string) (workflow *model.Workflow, err error) {
+	workflow = &model.Workflow{}
+	tx := db.GORM.Where("name = ?", name).First(&workflow)
+	if tx.RecordNotFound() {
+		return nil, nil
+	}
+	if tx.Error != nil {
+		logger.Error("获取workflow单条数据失败," + tx.Error.Error())
+		return nil, errors.New("获取workflow单条数据失败," + tx.Error.Error())
+	}
+	return
+}
+
+//获取多个namespace下的全部workflow
+func (wf *workflow) GetByNamespaces(namespaces []string) (workflows []*model.Workflow, err error) {
+	workflows = make([]*model.Workflow, 0)
+	tx := db.GORM.Where("namespace in (?)", namespaces).Order("id").Find(&workflows)
+	if tx.Error != nil && tx.Error.Error() != "record not found" {
+		logger.Error("获取workflow列表失败," + tx.Error.Error())
+		return nil, errors.New("获取workflow列表失败," + tx.Error.Error())
+	}
+	return workflows, nil
+}
+
 //表数据新增
 func (wf *workflow) Add(workflow *model.Workflow) (err error) {
 	tx := db.GORM.Create(&workflow)
@@ -79,4 +174,24 @@ func (wf *workflow) DelById(id int) (err error) {
 		return errors.New("删除workflow数据失败," + tx.Error.Error())
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+//硬删除, name有唯一索引, 软删除的数据会导致同名workflow无法再次创建
+func (wf *workflow) HardDelById(id int) (err error) {
+	tx := db.GORM.Unscoped().Where("id = ?", id).Delete(&model.Workflow{})
+	if tx.Error != nil {
+		logger.Error("删除workflow数据失败," + tx.Error.Error())
+		return errors.New("删除workflow数据失败," + tx.Error.Error())
+	}
+	return nil
+}
+
+//表数据更新, 更新所有字段
+func (wf *workflow) Update(workflow *model.Workflow) (err error) {
+	tx := db.GORM.Save(workflow)
+	if tx.Error != nil {
+		logger.Error("更新workflow数据失败," + tx.Error.Error())
+		return errors.New("更新workflow数据失败," + tx.Error.Error())
+	}
+	return nil
+}
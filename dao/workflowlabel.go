@@ -0,0 +1,66 @@
+package dao
+
+import (
+	"errors"
+	"test4/db"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+)
+
+var WorkflowLabel workflowLabel
+
+type workflowLabel struct{}
+
+//批量获取workflow的标签, 返回workflow id到标签的映射
+func (wl *workflowLabel) GetByWorkflowIds(workflowIDs []uint) (labels map[uint]map[string]string, err error) {
+	labels = map[uint]map[string]string{}
+	if len(workflowIDs) == 0 {
+		return labels, nil
+	}
+	var items []*model.WorkflowLabel
+	tx := db.GORM.Where("workflow_id in (?)", workflowIDs).Find(&items)
+	if tx.Error != nil && tx.Error.Error() != "record not found" {
+		logger.Error("获取workflow标签失败," + tx.Error.Error())
+		return nil, errors.New("获取workflow标签失败," + tx.Error.Error())
+	}
+	for _, item := range items {
+		if labels[item.WorkflowID] == nil {
+			labels[item.WorkflowID] = map[string]string{}
+		}
+		labels[item.WorkflowID][item.Key] = item.Value
+	}
+	return labels, nil
+}
+
+//替换workflow的所有标签
+func (wl *workflowLabel) Replace(workflowID uint, labels map[string]string) (err error) {
+	tx := db.GORM.Begin()
+	if err = tx.Where("workflow_id = ?", workflowID).Delete(&model.WorkflowLabel{}).Error; err != nil {
+		tx.Rollback()
+		logger.Error("更新workflow标签失败," + err.Error())
+		return errors.New("更新workflow标签失败," + err.Error())
+	}
+	for key, value := range labels {
+		if err = tx.Create(&model.WorkflowLabel{WorkflowID: workflowID, Key: key, Value: value}).Error; err != nil {
+			tx.Rollback()
+			logger.Error("更新workflow标签失败," + err.Error())
+			return errors.New("更新workflow标签失败," + err.Error())
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		logger.Error("更新workflow标签失败," + err.Error())
+		return errors.New("更新workflow标签失败," + err.Error())
+	}
+	return nil
+}
+
+//删除workflow的所有标签
+func (wl *workflowLabel) DelByWorkflowId(workflowID uint) (err error) {
+	tx := db.GORM.Where("workflow_id = ?", workflowID).Delete(&model.WorkflowLabel{})
+	if tx.Error != nil {
+		logger.Error("删除workflow标签失败," + tx.Error.Error())
+		return errors.New("删除workflow标签失败," + tx.Error.Error())
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"errors"
+	"test4/db"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+)
+
+var WorkflowRelease workflowRelease
+
+type workflowRelease struct{}
+
+//获取workflow的发布记录, 按时间倒序
+func (wr *workflowRelease) GetList(workflowID uint) (releases []*model.WorkflowRelease, err error) {
+	tx := db.GORM.Where("workflow_id = ?", workflowID).Order("id desc").Find(&releases)
+	if tx.Error != nil && tx.Error.Error() != "record not found" {
+		logger.Error("获取workflow发布记录失败," + tx.Error.Error())
+		return nil, errors.New("获取workflow发布记录失败," + tx.Error.Error())
+	}
+	return releases, nil
+}
+
+//获取workflow进行中的发布, 不存在时返回nil
+func (wr *workflowRelease) GetActive(workflowID uint, phase string) (release *model.WorkflowRelease, err error) {
+	release = &model.WorkflowRelease{}
+	tx := db.GORM.Where("workflow_id = ? and phase = ?", workflowID, phase).Order("id desc").First(release)
+	if tx.Error != nil {
+		if tx.RecordNotFound() {
+			return nil, nil
+		}
+		logger.Error("获取workflow进行中的发布失败," + tx.Error.Error())
+		return nil, errors.New("获取workflow进行中的发布失败," + tx.Error.Error())
+	}
+	return release, nil
+}
+
+//新增或更新发布记录, ID为0时新增
+func (wr *workflowRelease) Save(release *model.WorkflowRelease) (err error) {
+	tx := db.GORM.Save(release)
+	if tx.Error != nil {
+		logger.Error("保存workflow发布记录失败," + tx.Error.Error())
+		return errors.New("保存workflow发布记录失败," + tx.Error.Error())
+	}
+	return nil
+}
+
+//删除workflow的所有发布记录
+func (wr *workflowRelease) DelByWorkflowId(workflowID uint) (err error) {
+	tx := db.GORM.Where("workflow_id = ?", workflowID).Delete(&model.WorkflowRelease{})
+	if tx.Error != nil {
+		logger.Error("删除workflow发布记录失败," + tx.Error.Error())
+		return errors.New("删除workflow发布记录失败," + tx.Error.Error())
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package dao
+
+import (
+	"errors"
+	"test4/db"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+)
+
+var WorkflowSpec workflowSpec
+
+type workflowSpec struct{}
+
+//获取workflow的所有spec版本, 按版本倒序
+func (ws *workflowSpec) GetList(workflowID uint) (specs []*model.WorkflowSpec, err error) {
+	tx := db.GORM.Where("workflow_id = ?", workflowID).Order("version desc").Find(&specs)
+	if tx.Error != nil && tx.Error.Error() != "record not found" {
+		logger.Error("获取workflow spec列表失败," + tx.Error.Error())
+		return nil, errors.New("获取workflow spec列表失败," + tx.Error.Error())
+	}
+	return specs, nil
+}
+
+//获取workflow指定版本的spec, version为0时获取最新版本, 不存在时返回nil
+func (ws *workflowSpec) Get(workflowID uint, version int) (spec *model.WorkflowSpec, err error) {
+	spec = &model.WorkflowSpec{}
+	tx := db.GORM.Where("workflow_id = ?", workflowID)
+	if version > 0 {
+		tx = tx.Where("version = ?", version)
+	}
+	tx = tx.Order("version desc").First(spec)
+	if tx.Error != nil {
+		if tx.RecordNotFound() {
+			return nil, nil
+		}
+		logger.Error("获取workflow spec失败," + tx.Error.Error())
+		return nil, errors.New("获取workflow spec失败," + tx.Error.Error())
+	}
+	return spec, nil
+}
+
+//新增spec版本
+func (ws *workflowSpec) Add(spec *model.WorkflowSpec) (err error) {
+	tx := db.GORM.Create(spec)
+	if tx.Error != nil {
+		logger.Error("添加workflow spec失败," + tx.Error.Error())
+		return errors.New("添加workflow spec失败," + tx.Error.Error())
+	}
+	return nil
+}
+
+//删除workflow的所有spec版本
+func (ws *workflowSpec) DelByWorkflowId(workflowID uint) (err error) {
+	tx := db.GORM.Where("workflow_id = ?", workflowID).Delete(&model.WorkflowSpec{})
+	if tx.Error != nil {
+		logger.Error("删除workflow spec失败," + tx.Error.Error())
+		return errors.New("删除workflow spec失败," + tx.Error.Error())
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package dao
+
+import (
+	"errors"
+	"test4/db"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+)
+
+var WorkflowStep workflowStep
+
+type workflowStep struct{}
+
+//获取workflow最近一次操作的步骤, 按执行顺序排列
+func (ws *workflowStep) GetList(workflowID uint) (steps []*model.WorkflowStep, err error) {
+	tx := db.GORM.Where("workflow_id = ?", workflowID).Order("id asc").Find(&steps)
+	if tx.Error != nil && tx.Error.Error() != "record not found" {
+		logger.Error("获取workflow步骤失败," + tx.Error.Error())
+		return nil, errors.New("获取workflow步骤失败," + tx.Error.Error())
+	}
+	return steps, nil
+}
+
+//新增或更新步骤, ID为0时新增
+func (ws *workflowStep) Save(step *model.WorkflowStep) (err error) {
+	tx := db.GORM.Save(step)
+	if tx.Error != nil {
+		logger.Error("保存workflow步骤失败," + tx.Error.Error())
+		return errors.New("保存workflow步骤失败," + tx.Error.Error())
+	}
+	return nil
+}
+
+//删除workflow的所有步骤
+func (ws *workflowStep) DelByWorkflowId(workflowID uint) (err error) {
+	tx := db.GORM.Where("workflow_id = ?", workflowID).Delete(&model.WorkflowStep{})
+	if tx.Error != nil {
+		logger.Error("删除workflow步骤失败," + tx.Error.Error())
+		return errors.New("删除workflow步骤失败," + tx.Error.Error())
+	}
+	return nil
+}
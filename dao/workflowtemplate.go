@@ -0,0 +1,88 @@
+package dao
+
+import (
+	"errors"
+	"test4/db"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+)
+
+var WorkflowTemplate workflowTemplate
+
+type workflowTemplate struct{}
+
+//定义列表返回内容, Items是模板列表, Total为过滤后的模板总数
+type WorkflowTemplateResp struct {
+	Items []*model.WorkflowTemplate `json:"items"`
+	Total int                       `json:"total"`
+}
+
+//获取模板列表, 按名字和分类过滤并分页
+func (wt *workflowTemplate) GetList(name, category string, page, limit int) (templateResp *WorkflowTemplateResp, err error) {
+	startSet := (page - 1) * limit
+	var templates []*model.WorkflowTemplate
+	total := 0
+
+	tx := db.GORM.Model(&model.WorkflowTemplate{}).Where("name like ?", "%"+name+"%")
+	if category != "" {
+		tx = tx.Where("category = ?", category)
+	}
+	if err := tx.Count(&total).Error; err != nil {
+		logger.Error("获取workflow模板数量失败," + err.Error())
+		return nil, errors.New("获取workflow模板数量失败," + err.Error())
+	}
+	tx = tx.Limit(limit).Offset(startSet).Order("id desc").Find(&templates)
+	if tx.Error != nil && tx.Error.Error() != "record not found" {
+		logger.Error("获取workflow模板列表失败," + tx.Error.Error())
+		return nil, errors.New("获取workflow模板列表失败," + tx.Error.Error())
+	}
+	return &WorkflowTemplateResp{
+		Items: templates,
+		Total: total,
+	}, nil
+}
+
+//获取模板单条数据, 不存在时返回nil
+func (wt *workflowTemplate) GetById(id int) (template *model.WorkflowTemplate, err error) {
+	template = &model.WorkflowTemplate{}
+	tx := db.GORM.Where("id = ?", id).First(template)
+	if tx.Error != nil {
+		if tx.RecordNotFound() {
+			return nil, nil
+		}
+		logger.Error("获取workflow模板失败," + tx.Error.Error())
+		return nil, errors.New("获取workflow模板失败," + tx.Error.Error())
+	}
+	return template, nil
+}
+
+//新增模板
+func (wt *workflowTemplate) Add(template *model.WorkflowTemplate) (err error) {
+	tx := db.GORM.Create(template)
+	if tx.Error != nil {
+		logger.Error("添加workflow模板失败," + tx.Error.Error())
+		return errors.New("添加workflow模板失败," + tx.Error.Error())
+	}
+	return nil
+}
+
+//更新模板, 更新所有字段
+func (wt *workflowTemplate) Update(template *model.WorkflowTemplate) (err error) {
+	tx := db.GORM.Save(template)
+	if tx.Error != nil {
+		logger.Error("更新workflow模板失败," + tx.Error.Error())
+		return errors.New("更新workflow模板失败," + tx.Error.Error())
+	}
+	return nil
+}
+
+//删除模板
+func (wt *workflowTemplate) DelById(id int) (err error) {
+	tx := db.GORM.Where("id = ?", id).Delete(&model.WorkflowTemplate{})
+	if tx.Error != nil {
+		logger.Error("删除workflow模板失败," + tx.Error.Error())
+		return errors.New("删除workflow模板失败," + tx.Error.Error())
+	}
+	return nil
+}
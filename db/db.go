@@ -57,7 +57,7 @@ func Init()  {
 	GORM.LogMode(config.LogMode)
 
 	//迁移数据表
-	GORM.Set("gorm:table_options", "CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci ENGINE=InnoDB").AutoMigrate(&model.Workflow{})
+	GORM.Set("gorm:table_options", "CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci ENGINE=InnoDB").AutoMigrate(&model.Workflow{}, &model.AuditLog{}, &model.WorkflowSpec{}, &model.WorkflowStep{}, &model.WorkflowTemplate{}, &model.WorkflowLabel{}, &model.WorkflowRelease{}, &model.KustomizeBundle{})
 	logger.Info("自动迁移数据库表成功")
 
 	//开启连接池
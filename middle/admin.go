@@ -0,0 +1,34 @@
+package middle
+
+import (
+	"net/http"
+	"test4/config"
+	"test4/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+//AdminAuth 中间件, 需在JWTAuth之后使用, 只放行管理员用户
+func AdminAuth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := ctx.Get("claims")
+		if !ok {
+			ctx.JSON(http.StatusForbidden, gin.H{
+				"msg":  "未获取到用户信息, 无权访问",
+				"data": nil,
+			})
+			ctx.Abort()
+			return
+		}
+		customClaims, ok := claims.(*utils.CustomClaims)
+		if !ok || customClaims.UserName != config.AdminUser {
+			ctx.JSON(http.StatusForbidden, gin.H{
+				"msg":  "需要管理员权限, 无权访问",
+				"data": nil,
+			})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
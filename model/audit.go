@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+//审计记录, 用于记录Secret明文查看等敏感操作
+type AuditLog struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt *time.Time `json:"created_at"`
+	UserName  string     `json:"username"`
+	ClientIP  string     `json:"client_ip"`
+	Action    string     `json:"action"`
+	Kind      string     `json:"kind"`
+	Namespace string     `json:"namespace"`
+	Name      string     `json:"name"`
+	Key       string     `json:"key"`
+}
+
+func (*AuditLog) TableName() string {
+	return "audit_log"
+}
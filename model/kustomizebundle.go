@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+//保存的kustomization压缩包, Path为kustomization所在的目录
+//Inventory为上次apply成功的对象列表json, 用于prune时找出overlay中已删除的对象
+type KustomizeBundle struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+	Name        string     `json:"name" gorm:"unique"`
+	Description string     `json:"description"`
+	Path        string     `json:"path"`
+	Size        int        `json:"size"`
+	Archive     []byte     `json:"-" gorm:"type:longblob"`
+	Inventory   string     `json:"inventory" gorm:"type:longtext"`
+	AppliedAt   *time.Time `json:"applied_at"`
+}
+
+func (*KustomizeBundle) TableName() string {
+	return "kustomize_bundle"
+}
@@ -12,11 +12,26 @@ type Workflow struct {
 	Name string `json:"name" gorm:"unique"`
 	Namespace string `json:"namespace"`
 	Replicas int32 `json:"replicas"`
+	//工作负载名, 与workflow同名, 类型由WorkloadKind决定
 	Deployment string `json:"deployment"`
 	Service string `json:"service"`
 	Ingress string `json:"ingress"`
 	Type string `json:"type" gorm:"column:type"`
 	//Type: clusterip nodeport ingress
+	//IngressKind: Ingress类型workflow的入口资源, 空或Ingress为Ingress, HTTPRoute为Gateway API
+	IngressKind string `json:"ingress_kind"`
+	//WorkloadKind: Deployment StatefulSet DaemonSet, 为空时为Deployment
+	WorkloadKind string `json:"workload_kind"`
+	//当前生效的spec版本, 对应workflow_spec表中的version
+	SpecVersion int `json:"spec_version"`
+	//负责该workflow的团队
+	Team string `json:"team" gorm:"index"`
+	//Status: Creating Running Failed Deleting DeleteFailed
+	Status string `json:"status"`
+	//最近一次创建或删除的步骤, 不存储在workflow表中
+	Steps []*WorkflowStep `json:"steps,omitempty" gorm:"-"`
+	//workflow的标签, 存储在workflow_label表中
+	WorkflowLabels map[string]string `json:"workflow_labels" gorm:"-"`
 }
 
 //定义TableName方法，返回mysql表名，以此来定义mysql中的表名
@@ -0,0 +1,14 @@
+package model
+
+//workflow的标签, 用于分组和过滤, 不会设置到k8s资源上
+//key是mysql的保留字, 所以列名使用label_key、label_value
+type WorkflowLabel struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	WorkflowID uint   `json:"workflow_id" gorm:"index"`
+	Key        string `json:"key" gorm:"column:label_key;index:idx_workflow_label"`
+	Value      string `json:"value" gorm:"column:label_value;index:idx_workflow_label"`
+}
+
+func (*WorkflowLabel) TableName() string {
+	return "workflow_label"
+}
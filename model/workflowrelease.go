@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+//workflow的发布记录, 同一个workflow同时只能有一个Progressing状态的发布
+type WorkflowRelease struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt  *time.Time `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+	WorkflowID uint       `json:"workflow_id" gorm:"index"`
+	//Strategy: BlueGreen Canary
+	Strategy string `json:"strategy"`
+	//CanaryMode: Ingress(nginx canary注解按权重分流) Replicas(按副本数比例分流)
+	CanaryMode string `json:"canary_mode"`
+	//Weights 金丝雀各步骤的流量比例, json数组
+	Weights string `json:"weights"`
+	//Step 已执行的推进次数
+	Step int `json:"step"`
+	//Phase: Progressing Promoted Aborted
+	Phase   string `json:"phase"`
+	Message string `json:"message" gorm:"type:text"`
+	//发布前的spec版本以及发布完成后生成的spec版本
+	FromVersion int `json:"from_version"`
+	ToVersion   int `json:"to_version"`
+	//Spec 新版本的WorkflowCreate json
+	Spec string `json:"spec" gorm:"type:longtext"`
+}
+
+func (*WorkflowRelease) TableName() string {
+	return "workflow_release"
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+//workflow的spec版本, 每次创建、更新或回滚都会新增一个版本
+//Spec为创建workflow时的完整参数(service.WorkflowCreate)序列化后的json
+type WorkflowSpec struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt  *time.Time `json:"created_at"`
+	WorkflowID uint       `json:"workflow_id" gorm:"index"`
+	Version    int        `json:"version"`
+	Spec       string     `json:"spec" gorm:"type:longtext"`
+}
+
+func (*WorkflowSpec) TableName() string {
+	return "workflow_spec"
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+//workflow创建、删除时每个k8s资源对应的步骤, 只保留最近一次操作的步骤
+type WorkflowStep struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt  *time.Time `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at"`
+	WorkflowID uint       `json:"workflow_id" gorm:"index"`
+	//Action: create delete
+	Action string `json:"action"`
+	//Kind: Deployment StatefulSet DaemonSet Service Ingress HTTPRoute
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	//Status: Pending Succeeded Failed Compensated CompensateFailed
+	Status  string `json:"status"`
+	Message string `json:"message" gorm:"type:text"`
+}
+
+func (*WorkflowStep) TableName() string {
+	return "workflow_step"
+}
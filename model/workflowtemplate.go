@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+//workflow模板, Parameters为参数定义的json, Template为渲染成WorkflowCreate json的go template
+type WorkflowTemplate struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+	Name        string     `json:"name" gorm:"unique"`
+	Category    string     `json:"category"`
+	Description string     `json:"description"`
+	Parameters  string     `json:"parameters" gorm:"type:text"`
+	Template    string     `json:"template" gorm:"type:longtext"`
+}
+
+func (*WorkflowTemplate) TableName() string {
+	return "workflow_template"
+}
@@ -0,0 +1,883 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"test4/config"
+	"test4/dao"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+var Backup backup
+
+type backup struct{}
+
+//导出格式, yaml只包含k8s对象, 可以直接kubectl apply, tar为tar.gz, 额外包含manifest和workflow数据
+const (
+	BackupFormatYaml = "yaml"
+	BackupFormatTar  = "tar"
+)
+
+//restore中每个对象的处理结果
+const (
+	BackupActionApplied = "Applied"
+	BackupActionCreated = "Created"
+	BackupActionSkipped = "Skipped"
+	BackupActionFailed  = "Failed"
+)
+
+//tar包中的文件
+const (
+	backupManifestFile  = "manifest.json"
+	backupWorkflowsFile = "workflows.json"
+	backupResourcesDir  = "resources"
+)
+
+//backupResource 导出的资源类型, 按restore时的应用顺序排列, 被引用的资源在前
+type backupResource struct {
+	gvr  schema.GroupVersionResource
+	kind string
+}
+
+var backupNamespaceGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+var backupResources = []backupResource{
+	{schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, "ConfigMap"},
+	{schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, "Secret"},
+	{schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}, "PersistentVolumeClaim"},
+	{schema.GroupVersionResource{Version: "v1", Resource: "services"}, "Service"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, "Deployment"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, "StatefulSet"},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}, "DaemonSet"},
+	{schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}, "Ingress"},
+}
+
+//未指定namespace时不导出的系统namespace
+var backupSystemNamespaces = map[string]bool{
+	"kube-system":     true,
+	"kube-public":     true,
+	"kube-node-lease": true,
+}
+
+//导出时去掉的apiserver和controller维护的metadata字段
+var backupMetadataFields = []string{
+	"uid", "resourceVersion", "generation", "creationTimestamp", "deletionTimestamp",
+	"deletionGracePeriodSeconds", "managedFields", "selfLink",
+}
+
+//导出时去掉的注解, pvc的绑定信息在新的namespace或集群中没有意义
+var backupAnnotations = []string{
+	corev1.LastAppliedConfigAnnotation,
+	deploymentRevisionAnnotation,
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/selected-node",
+}
+
+//BackupObjectRef 备份中对象的标识
+type BackupObjectRef struct {
+	APIVersion string `json:"api_version"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+}
+
+//BackupManifest tar包中的manifest.json, 记录备份的范围和内容
+type BackupManifest struct {
+	CreatedAt  time.Time         `json:"created_at"`
+	Namespaces []string          `json:"namespaces"`
+	Objects    []BackupObjectRef `json:"objects"`
+	Workflows  []string          `json:"workflows"`
+}
+
+//WorkflowBackup 导出的workflow数据, 包含标签和全部spec版本
+type WorkflowBackup struct {
+	Workflow *model.Workflow       `json:"workflow"`
+	Labels   map[string]string     `json:"labels"`
+	Specs    []*model.WorkflowSpec `json:"specs"`
+}
+
+//BackupRestore restore的参数
+type BackupRestore struct {
+	//原namespace到目标namespace的映射, 未指定的namespace保持不变
+	NamespaceMap map[string]string `json:"namespace_map"`
+	//原名字到新名字的映射, 对所有类型的对象生效, workflow名字同时映射其service、ingress和httproute的名字
+	NameMap map[string]string `json:"name_map"`
+	//TargetKubeconfig为空时恢复到当前连接的集群, 指定时恢复到kubeconfig中TargetContext对应的集群
+	//恢复到其他集群时只恢复k8s资源, 不新增workflow记录
+	TargetKubeconfig string `json:"target_kubeconfig"`
+	TargetContext    string `json:"target_context"`
+	DryRun           bool   `json:"dry_run"`
+}
+
+type BackupRestoreResult struct {
+	BackupObjectRef
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BackupRestoreResp struct {
+	Results []*BackupRestoreResult `json:"results"`
+	Failed  int                    `json:"failed"`
+}
+
+//导出namespace中的资源, namespaces为空时导出所有非系统namespace
+//返回导出的内容和下载时使用的文件名
+func (b *backup) Export(namespaces []string, format string, includeWorkflows bool) (content []byte, fileName string, err error) {
+	if format == "" {
+		format = BackupFormatYaml
+	}
+	if format != BackupFormatYaml && format != BackupFormatTar {
+		return nil, "", errors.New("不支持的导出格式: " + format + ", 可选值为yaml、tar")
+	}
+	if includeWorkflows && format != BackupFormatTar {
+		return nil, "", errors.New("导出workflow数据时格式必须为tar")
+	}
+	namespaces, err = backupNamespaces(namespaces)
+	if err != nil {
+		logger.Error(errors.New("导出资源失败, " + err.Error()))
+		return nil, "", errors.New("导出资源失败, " + err.Error())
+	}
+	objs, err := collectBackupObjects(namespaces)
+	if err != nil {
+		logger.Error(errors.New("导出资源失败, " + err.Error()))
+		return nil, "", errors.New("导出资源失败, " + err.Error())
+	}
+
+	scope := "all"
+	if len(namespaces) == 1 {
+		scope = namespaces[0]
+	}
+	fileName = fmt.Sprintf("backup-%s-%s", scope, time.Now().Format("20060102150405"))
+	if format == BackupFormatYaml {
+		content, err = marshalBackupYaml(objs)
+		if err != nil {
+			logger.Error(errors.New("导出资源失败, " + err.Error()))
+			return nil, "", errors.New("导出资源失败, " + err.Error())
+		}
+		return content, fileName + ".yaml", nil
+	}
+
+	workflows := make([]*WorkflowBackup, 0)
+	if includeWorkflows {
+		if workflows, err = collectWorkflowBackups(namespaces); err != nil {
+			return nil, "", err
+		}
+	}
+	content, err = writeBackupArchive(namespaces, objs, workflows)
+	if err != nil {
+		logger.Error(errors.New("导出资源失败, " + err.Error()))
+		return nil, "", errors.New("导出资源失败, " + err.Error())
+	}
+	return content, fileName + ".tar.gz", nil
+}
+
+//将导出的yaml或tar.gz应用到当前集群, 支持namespace和名字的映射
+//对象按Namespace、ConfigMap、Secret、PVC、Service、工作负载、Ingress的顺序应用, 单个对象失败不影响其他对象
+//workflow数据在对象之后恢复, 同名workflow已存在时跳过
+func (b *backup) Restore(content []byte, params *BackupRestore) (restoreResp *BackupRestoreResp, err error) {
+	remapper, err := newBackupRemapper(params.NamespaceMap, params.NameMap)
+	if err != nil {
+		logger.Error(errors.New("恢复备份失败, " + err.Error()))
+		return nil, errors.New("恢复备份失败, " + err.Error())
+	}
+	objs, workflows, err := readBackup(content)
+	if err != nil {
+		logger.Error(errors.New("解析备份内容失败, " + err.Error()))
+		return nil, errors.New("解析备份内容失败, " + err.Error())
+	}
+	if len(objs) == 0 && len(workflows) == 0 {
+		return nil, errors.New("备份中没有可恢复的内容")
+	}
+	target, err := newCloneTarget(params.TargetKubeconfig, params.TargetContext)
+	if err != nil {
+		logger.Error(errors.New("恢复备份失败, " + err.Error()))
+		return nil, errors.New("恢复备份失败, " + err.Error())
+	}
+
+	sortBackupObjects(objs)
+	restoreResp = &BackupRestoreResp{Results: make([]*BackupRestoreResult, 0, len(objs)+len(workflows))}
+	for _, obj := range objs {
+		err := remapper.remap(obj)
+		result := &BackupRestoreResult{BackupObjectRef: backupObjectRef(obj), Action: BackupActionApplied}
+		restoreResp.Results = append(restoreResp.Results, result)
+		if err == nil {
+			_, err = applyClusterObject(target.dynamic, target.mapper, obj, params.DryRun)
+			result.BackupObjectRef = backupObjectRef(obj)
+		}
+		if err != nil {
+			logger.Error(errors.New("恢复" + obj.GetKind() + ": " + obj.GetName() + " 失败, " + err.Error()))
+			result.Action = BackupActionFailed
+			result.Error = err.Error()
+			restoreResp.Failed++
+		}
+	}
+	for _, workflowBackup := range workflows {
+		//workflow记录管理的是当前连接的集群, 恢复到其他集群时跳过
+		if target.remote {
+			restoreResp.Results = append(restoreResp.Results, skippedWorkflowBackup(workflowBackup, remapper))
+			continue
+		}
+		result := restoreWorkflowBackup(workflowBackup, remapper, params.DryRun)
+		restoreResp.Results = append(restoreResp.Results, result)
+		if result.Action == BackupActionFailed {
+			restoreResp.Failed++
+		}
+	}
+	return restoreResp, nil
+}
+
+//backupNamespaces 未指定时返回所有非系统namespace, 指定时确认每个namespace都存在
+func backupNamespaces(namespaces []string) ([]string, error) {
+	if len(namespaces) > 0 {
+		for _, namespace := range namespaces {
+			if _, err := K8s.Clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{}); err != nil {
+				return nil, err
+			}
+		}
+		return namespaces, nil
+	}
+	list, err := K8s.Clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range list.Items {
+		if !backupSystemNamespaces[item.Name] {
+			namespaces = append(namespaces, item.Name)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+//collectBackupObjects 获取namespace本身以及其中需要导出的对象, 并去掉不能重复应用的字段
+func collectBackupObjects(namespaces []string) ([]*unstructured.Unstructured, error) {
+	objs := make([]*unstructured.Unstructured, 0)
+	for _, namespace := range namespaces {
+		namespaceObj, err := resourceClient(backupNamespaceGVR, false, "").Get(context.TODO(), namespace, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		namespaceObj.SetAPIVersion("v1")
+		namespaceObj.SetKind("Namespace")
+		objs = append(objs, cleanBackupObject(namespaceObj))
+
+		for _, resource := range backupResources {
+			list, err := resourceClient(resource.gvr, true, namespace).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("获取namespace %s 中的%s列表失败, %v", namespace, resource.kind, err)
+			}
+			for i := range list.Items {
+				obj := &list.Items[i]
+				obj.SetAPIVersion(resource.gvr.GroupVersion().String())
+				obj.SetKind(resource.kind)
+				if skipBackupObject(obj) {
+					continue
+				}
+				objs = append(objs, cleanBackupObject(obj))
+			}
+		}
+	}
+	return objs, nil
+}
+
+//skipBackupObject 不导出由其他对象或集群自动创建的对象, 以及发布过程中临时的预览和金丝雀对象
+func skipBackupObject(obj *unstructured.Unstructured) bool {
+	if len(obj.GetOwnerReferences()) > 0 {
+		return true
+	}
+	if _, ok := obj.GetLabels()[releaseTrackLabel]; ok {
+		return true
+	}
+	if obj.GetAnnotations()[nginxCanaryAnnotation] == "true" {
+		return true
+	}
+	switch obj.GetKind() {
+	case "Secret":
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		return secretType == string(corev1.SecretTypeServiceAccountToken)
+	case "ConfigMap":
+		return obj.GetName() == "kube-root-ca.crt"
+	case "Service":
+		if obj.GetNamespace() == metav1.NamespaceDefault && obj.GetName() == "kubernetes" {
+			return true
+		}
+		_, ok, _ := unstructured.NestedString(obj.Object, "spec", "selector", releaseTrackLabel)
+		return ok
+	}
+	return false
+}
+
+//cleanBackupObject 去掉status和apiserver设置的字段, 使导出的对象可以重新应用到任意namespace或集群
+func cleanBackupObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	cleaned := obj.DeepCopy()
+	for _, field := range backupMetadataFields {
+		unstructured.RemoveNestedField(cleaned.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(cleaned.Object, "status")
+	annotations := cleaned.GetAnnotations()
+	for _, key := range backupAnnotations {
+		delete(annotations, key)
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	cleaned.SetAnnotations(annotations)
+
+	switch cleaned.GetKind() {
+	case "Namespace":
+		labels := cleaned.GetLabels()
+		delete(labels, corev1.LabelMetadataName)
+		if len(labels) == 0 {
+			labels = nil
+		}
+		cleaned.SetLabels(labels)
+		unstructured.RemoveNestedField(cleaned.Object, "spec")
+	case "Service":
+		//headless service的clusterIP为None, 需要保留
+		clusterIP, _, _ := unstructured.NestedString(cleaned.Object, "spec", "clusterIP")
+		if clusterIP != corev1.ClusterIPNone {
+			unstructured.RemoveNestedField(cleaned.Object, "spec", "clusterIP")
+			unstructured.RemoveNestedField(cleaned.Object, "spec", "clusterIPs")
+		}
+		unstructured.RemoveNestedField(cleaned.Object, "spec", "healthCheckNodePort")
+		//发布过程中service会固定到稳定版本的pod-template-hash
+		unstructured.RemoveNestedField(cleaned.Object, "spec", "selector", podTemplateHashLabel)
+	case "PersistentVolumeClaim":
+		unstructured.RemoveNestedField(cleaned.Object, "spec", "volumeName")
+	}
+	return cleaned
+}
+
+//collectWorkflowBackups 获取namespace中workflow的数据, 包含标签和全部spec版本
+func collectWorkflowBackups(namespaces []string) ([]*WorkflowBackup, error) {
+	workflows, err := dao.Workflow.GetByNamespaces(namespaces)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(workflows))
+	for _, workflow := range workflows {
+		ids = append(ids, workflow.ID)
+	}
+	labels, err := dao.WorkflowLabel.GetByWorkflowIds(ids)
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]*WorkflowBackup, 0, len(workflows))
+	for _, workflow := range workflows {
+		specs, err := dao.WorkflowSpec.GetList(workflow.ID)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, &WorkflowBackup{Workflow: workflow, Labels: labels[workflow.ID], Specs: specs})
+	}
+	return backups, nil
+}
+
+//marshalBackupYaml 将对象序列化为---分隔的多文档yaml
+func marshalBackupYaml(objs []*unstructured.Unstructured) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for i, obj := range objs {
+		content, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(content)
+	}
+	return buf.Bytes(), nil
+}
+
+//writeBackupArchive 生成tar.gz, 每个对象保存为resources/<namespace>/<kind>/<name>.yaml
+func writeBackupArchive(namespaces []string, objs []*unstructured.Unstructured, workflows []*WorkflowBackup) ([]byte, error) {
+	now := time.Now()
+	manifest := &BackupManifest{
+		CreatedAt:  now,
+		Namespaces: namespaces,
+		Objects:    make([]BackupObjectRef, 0, len(objs)),
+		Workflows:  make([]string, 0, len(workflows)),
+	}
+	files := make(map[string][]byte, len(objs)+2)
+	names := make([]string, 0, len(objs)+2)
+	for _, obj := range objs {
+		content, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		namespace := obj.GetNamespace()
+		if obj.GetKind() == "Namespace" {
+			namespace = obj.GetName()
+		}
+		name := path.Join(backupResourcesDir, namespace, strings.ToLower(obj.GetKind()), obj.GetName()+".yaml")
+		files[name] = content
+		names = append(names, name)
+		manifest.Objects = append(manifest.Objects, backupObjectRef(obj))
+	}
+	if len(workflows) > 0 {
+		content, err := json.MarshalIndent(workflows, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files[backupWorkflowsFile] = content
+		names = append(names, backupWorkflowsFile)
+		for _, workflow := range workflows {
+			manifest.Workflows = append(manifest.Workflows, workflow.Workflow.Name)
+		}
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files[backupManifestFile] = content
+	names = append([]string{backupManifestFile}, names...)
+
+	buf := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), ModTime: now, Typeflag: tar.TypeReg}
+		if err = tarWriter.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err = tarWriter.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err = tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	if err = gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//readBackup 解析导出的内容, gzip压缩时按tar.gz读取resources目录和workflows.json, 否则按多文档yaml读取
+func readBackup(content []byte) ([]*unstructured.Unstructured, []*WorkflowBackup, error) {
+	if len(content) == 0 {
+		return nil, nil, errors.New("备份内容为空")
+	}
+	if !bytes.HasPrefix(content, []byte{0x1f, 0x8b}) {
+		objs, err := decodeObjects(content)
+		return objs, nil, err
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+	objs := make([]*unstructured.Unstructured, 0)
+	workflows := make([]*WorkflowBackup, 0)
+	total := int64(0)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.New("不是合法的tar.gz压缩包, " + err.Error())
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		if name != backupWorkflowsFile && !strings.HasPrefix(name, backupResourcesDir+"/") {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(tarReader, config.BackupMaxArchiveSize-total+1))
+		if err != nil {
+			return nil, nil, err
+		}
+		total += int64(len(data))
+		if total > config.BackupMaxArchiveSize {
+			return nil, nil, fmt.Errorf("解压后的大小超过%dMB", config.BackupMaxArchiveSize>>20)
+		}
+		if name == backupWorkflowsFile {
+			if err = json.Unmarshal(data, &workflows); err != nil {
+				return nil, nil, errors.New("解析" + backupWorkflowsFile + "失败, " + err.Error())
+			}
+			continue
+		}
+		fileObjs, err := decodeObjects(data)
+		if err != nil {
+			return nil, nil, errors.New("解析" + name + "失败, " + err.Error())
+		}
+		objs = append(objs, fileObjs...)
+	}
+	return objs, workflows, nil
+}
+
+//sortBackupObjects 按应用顺序排列对象, 同类对象保持原来的顺序
+func sortBackupObjects(objs []*unstructured.Unstructured) {
+	sort.SliceStable(objs, func(i, j int) bool {
+		return backupKindOrder(objs[i]) < backupKindOrder(objs[j])
+	})
+}
+
+//backupKindOrder restore时对象的应用顺序, namespace最先, 不在导出范围内的类型最后
+func backupKindOrder(obj *unstructured.Unstructured) int {
+	if obj.GetKind() == "Namespace" {
+		return 0
+	}
+	for i, resource := range backupResources {
+		if obj.GetKind() == resource.kind && obj.GroupVersionKind().Group == resource.gvr.Group {
+			return i + 1
+		}
+	}
+	return len(backupResources) + 1
+}
+
+func backupObjectRef(obj *unstructured.Unstructured) BackupObjectRef {
+	return BackupObjectRef{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
+
+//backupRemapper restore时的namespace和名字映射, 同时修改对象之间按名字的引用
+type backupRemapper struct {
+	namespaces map[string]string
+	names      map[string]string
+}
+
+//newBackupRemapper 校验映射的目标名字, workflow名字的映射展开到其service、ingress和httproute
+func newBackupRemapper(namespaces, names map[string]string) (*backupRemapper, error) {
+	for from, to := range namespaces {
+		if errs := validation.IsDNS1123Label(to); len(errs) > 0 {
+			return nil, fmt.Errorf("namespace %s 的目标名字 %s 不合法, %s", from, to, strings.Join(errs, ", "))
+		}
+	}
+	expanded := make(map[string]string, len(names)*4)
+	for from, to := range names {
+		if errs := validation.IsDNS1123Subdomain(to); len(errs) > 0 {
+			return nil, fmt.Errorf("%s 的目标名字 %s 不合法, %s", from, to, strings.Join(errs, ", "))
+		}
+		expanded[getServiceName(from)] = getServiceName(to)
+		expanded[getIngressName(from)] = getIngressName(to)
+		expanded[getHTTPRouteName(from)] = getHTTPRouteName(to)
+	}
+	//显式指定的映射优先于展开的映射
+	for from, to := range names {
+		expanded[from] = to
+	}
+	return &backupRemapper{namespaces: namespaces, names: expanded}, nil
+}
+
+func (r *backupRemapper) namespace(namespace string) string {
+	if to, ok := r.namespaces[namespace]; ok {
+		return to
+	}
+	return namespace
+}
+
+func (r *backupRemapper) name(name string) string {
+	if to, ok := r.names[name]; ok {
+		return to
+	}
+	return name
+}
+
+//remap 修改对象的namespace和名字, 以及pod模板、StatefulSet和Ingress中引用的其他对象的名字
+//移动或重命名的service去掉nodePort, 避免与原service冲突
+func (r *backupRemapper) remap(obj *unstructured.Unstructured) error {
+	if obj.GetKind() == "Namespace" && obj.GroupVersionKind().Group == "" {
+		obj.SetName(r.namespace(obj.GetName()))
+		return nil
+	}
+	moved := false
+	if namespace := obj.GetNamespace(); namespace != "" {
+		obj.SetNamespace(r.namespace(namespace))
+		moved = obj.GetNamespace() != namespace
+	}
+	name := obj.GetName()
+	obj.SetName(r.name(name))
+	renamed := obj.GetName() != name
+
+	switch obj.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Group: "apps", Kind: "Deployment"}, schema.GroupKind{Group: "apps", Kind: "DaemonSet"}:
+		return r.remapPodTemplate(obj)
+	case schema.GroupKind{Group: "apps", Kind: "StatefulSet"}:
+		if serviceName, ok, _ := unstructured.NestedString(obj.Object, "spec", "serviceName"); ok {
+			if err := unstructured.SetNestedField(obj.Object, r.name(serviceName), "spec", "serviceName"); err != nil {
+				return err
+			}
+		}
+		return r.remapPodTemplate(obj)
+	case schema.GroupKind{Group: "networking.k8s.io", Kind: "Ingress"}:
+		return r.remapIngress(obj)
+	case schema.GroupKind{Kind: "Service"}:
+		if moved || renamed {
+			return removeNodePorts(obj)
+		}
+	}
+	return nil
+}
+
+//remapPodTemplate 修改pod模板中引用的configMap、secret、pvc和imagePullSecret的名字
+func (r *backupRemapper) remapPodTemplate(obj *unstructured.Unstructured) error {
+	return mutatePodSpec(obj, r.remapPodSpec)
+}
+
+func (r *backupRemapper) remapPodSpec(podSpec *corev1.PodSpec) {
+	for i := range podSpec.Volumes {
+		volume := &podSpec.Volumes[i]
+		if volume.ConfigMap != nil {
+			volume.ConfigMap.Name = r.name(volume.ConfigMap.Name)
+		}
+		if volume.Secret != nil {
+			volume.Secret.SecretName = r.name(volume.Secret.SecretName)
+		}
+		if volume.PersistentVolumeClaim != nil {
+			volume.PersistentVolumeClaim.ClaimName = r.name(volume.PersistentVolumeClaim.ClaimName)
+		}
+		if volume.Projected != nil {
+			for j := range volume.Projected.Sources {
+				source := &volume.Projected.Sources[j]
+				if source.ConfigMap != nil {
+					source.ConfigMap.Name = r.name(source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					source.Secret.Name = r.name(source.Secret.Name)
+				}
+			}
+		}
+	}
+	for i := range podSpec.ImagePullSecrets {
+		podSpec.ImagePullSecrets[i].Name = r.name(podSpec.ImagePullSecrets[i].Name)
+	}
+	for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+		for i := range containers {
+			r.remapContainer(&containers[i])
+		}
+	}
+}
+
+func (r *backupRemapper) remapContainer(container *corev1.Container) {
+	for i := range container.EnvFrom {
+		envFrom := &container.EnvFrom[i]
+		if envFrom.ConfigMapRef != nil {
+			envFrom.ConfigMapRef.Name = r.name(envFrom.ConfigMapRef.Name)
+		}
+		if envFrom.SecretRef != nil {
+			envFrom.SecretRef.Name = r.name(envFrom.SecretRef.Name)
+		}
+	}
+	for i := range container.Env {
+		valueFrom := container.Env[i].ValueFrom
+		if valueFrom == nil {
+			continue
+		}
+		if valueFrom.ConfigMapKeyRef != nil {
+			valueFrom.ConfigMapKeyRef.Name = r.name(valueFrom.ConfigMapKeyRef.Name)
+		}
+		if valueFrom.SecretKeyRef != nil {
+			valueFrom.SecretKeyRef.Name = r.name(valueFrom.SecretKeyRef.Name)
+		}
+	}
+}
+
+//remapIngress 修改Ingress后端service和tls证书secret的名字
+func (r *backupRemapper) remapIngress(obj *unstructured.Unstructured) error {
+	return mutateIngressSpec(obj, func(spec *nwv1.IngressSpec) {
+		if spec.DefaultBackend != nil && spec.DefaultBackend.Service != nil {
+			spec.DefaultBackend.Service.Name = r.name(spec.DefaultBackend.Service.Name)
+		}
+		for i := range spec.Rules {
+			if spec.Rules[i].HTTP == nil {
+				continue
+			}
+			for j := range spec.Rules[i].HTTP.Paths {
+				if service := spec.Rules[i].HTTP.Paths[j].Backend.Service; service != nil {
+					service.Name = r.name(service.Name)
+				}
+			}
+		}
+		for i := range spec.TLS {
+			spec.TLS[i].SecretName = r.name(spec.TLS[i].SecretName)
+		}
+	})
+}
+
+//mutatePodSpec 将工作负载的pod模板转换为结构体修改后写回, 对象没有pod模板时不做处理
+func mutatePodSpec(obj *unstructured.Unstructured, mutate func(podSpec *corev1.PodSpec)) error {
+	content, ok, err := unstructured.NestedMap(obj.Object, "spec", "template", "spec")
+	if err != nil || !ok {
+		return err
+	}
+	podSpec := &corev1.PodSpec{}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(content, podSpec); err != nil {
+		return err
+	}
+	mutate(podSpec)
+	content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(podSpec)
+	if err != nil {
+		return err
+	}
+	return unstructured.SetNestedMap(obj.Object, content, "spec", "template", "spec")
+}
+
+//mutateIngressSpec 将Ingress的spec转换为结构体修改后写回
+func mutateIngressSpec(obj *unstructured.Unstructured, mutate func(spec *nwv1.IngressSpec)) error {
+	content, ok, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil || !ok {
+		return err
+	}
+	spec := &nwv1.IngressSpec{}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(content, spec); err != nil {
+		return err
+	}
+	mutate(spec)
+	content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(spec)
+	if err != nil {
+		return err
+	}
+	return unstructured.SetNestedMap(obj.Object, content, "spec")
+}
+
+//removeNodePorts 去掉service端口上指定的nodePort, 由集群重新分配
+func removeNodePorts(obj *unstructured.Unstructured) error {
+	ports, ok, err := unstructured.NestedSlice(obj.Object, "spec", "ports")
+	if err != nil || !ok {
+		return err
+	}
+	for _, port := range ports {
+		if portMap, ok := port.(map[string]interface{}); ok {
+			delete(portMap, "nodePort")
+		}
+	}
+	return unstructured.SetNestedSlice(obj.Object, ports, "spec", "ports")
+}
+
+//restoreWorkflowBackup 按映射后的名字和namespace恢复workflow数据, 包括标签和全部spec版本
+func restoreWorkflowBackup(workflowBackup *WorkflowBackup, remapper *backupRemapper, dryRun bool) *BackupRestoreResult {
+	result := &BackupRestoreResult{BackupObjectRef: BackupObjectRef{Kind: "Workflow"}, Action: BackupActionCreated}
+	if workflowBackup.Workflow == nil {
+		result.Action = BackupActionFailed
+		result.Error = "workflow数据为空"
+		return result
+	}
+	workflow := *workflowBackup.Workflow
+	workflow.ID = 0
+	workflow.CreatedAt, workflow.UpdatedAt, workflow.DeletedAt = nil, nil, nil
+	workflow.Steps, workflow.WorkflowLabels = nil, nil
+	workflow.Name = remapper.name(workflow.Name)
+	workflow.Namespace = remapper.namespace(workflow.Namespace)
+	workflow.Deployment = remapper.name(workflow.Deployment)
+	workflow.Service = remapper.name(workflow.Service)
+	workflow.Ingress = remapper.name(workflow.Ingress)
+	result.Namespace, result.Name = workflow.Namespace, workflow.Name
+
+	existing, err := dao.Workflow.GetByName(workflow.Name)
+	if err != nil {
+		result.Action = BackupActionFailed
+		result.Error = err.Error()
+		return result
+	}
+	if existing != nil {
+		result.Action = BackupActionSkipped
+		result.Error = "同名workflow已存在"
+		return result
+	}
+	if dryRun {
+		return result
+	}
+
+	if err = dao.Workflow.Add(&workflow); err != nil {
+		result.Action = BackupActionFailed
+		result.Error = err.Error()
+		return result
+	}
+	for _, spec := range workflowBackup.Specs {
+		restored := &model.WorkflowSpec{
+			WorkflowID: workflow.ID,
+			Version:    spec.Version,
+			Spec:       remapper.remapWorkflowSpec(spec.Spec),
+		}
+		if err = dao.WorkflowSpec.Add(restored); err != nil {
+			result.Action = BackupActionFailed
+			result.Error = err.Error()
+			return result
+		}
+	}
+	if len(workflowBackup.Labels) > 0 {
+		if err = dao.WorkflowLabel.Replace(workflow.ID, workflowBackup.Labels); err != nil {
+			result.Action = BackupActionFailed
+			result.Error = err.Error()
+		}
+	}
+	return result
+}
+
+//skippedWorkflowBackup 恢复到其他集群时workflow记录的结果
+func skippedWorkflowBackup(workflowBackup *WorkflowBackup, remapper *backupRemapper) *BackupRestoreResult {
+	result := &BackupRestoreResult{BackupObjectRef: BackupObjectRef{Kind: "Workflow"}, Action: BackupActionSkipped}
+	result.Error = "恢复到其他集群时不新增workflow记录"
+	if workflowBackup.Workflow != nil {
+		result.Namespace = remapper.namespace(workflowBackup.Workflow.Namespace)
+		result.Name = remapper.name(workflowBackup.Workflow.Name)
+	}
+	return result
+}
+
+//remapWorkflowSpec 修改序列化的spec, 无法解析时原样保留
+func (r *backupRemapper) remapWorkflowSpec(spec string) string {
+	data := &WorkflowCreate{}
+	if err := json.Unmarshal([]byte(spec), data); err != nil {
+		return spec
+	}
+	r.remapWorkflowCreate(data)
+	content, err := json.Marshal(data)
+	if err != nil {
+		return spec
+	}
+	return string(content)
+}
+
+//remapWorkflowCreate 修改spec中的名字、namespace, 以及容器和ingress后端引用的其他对象的名字
+func (r *backupRemapper) remapWorkflowCreate(data *WorkflowCreate) {
+	name, namespace := r.name(data.Name), r.namespace(data.Namespace)
+	//与restore的service一致, 移动或重命名后由集群重新分配nodePort
+	if name != data.Name || namespace != data.Namespace {
+		data.NodePort = 0
+	}
+	data.Name, data.Namespace = name, namespace
+	for i := range data.Containers {
+		r.remapContainer(&data.Containers[i])
+	}
+	for _, paths := range data.Hosts {
+		for _, httpPath := range paths {
+			if httpPath != nil {
+				httpPath.ServiceName = r.name(httpPath.ServiceName)
+			}
+		}
+	}
+}
@@ -0,0 +1,624 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"test4/dao"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+var Clone clone
+
+type clone struct{}
+
+//可以克隆的类型, 除Workflow外与工作负载类型相同
+const CloneKindWorkflow = "Workflow"
+
+//克隆中每个对象的处理结果, 目标namespace中已存在的对象不会被覆盖
+const (
+	CloneActionCreated = "Created"
+	CloneActionSkipped = "Skipped"
+	CloneActionFailed  = "Failed"
+)
+
+//CloneOptions 克隆的公共参数
+//TargetKubeconfig为空时克隆到当前连接的集群, 此时目标namespace不能与原namespace相同
+//指定时克隆到kubeconfig中TargetContext对应的集群, 为空时使用current-context, TargetNamespace为空时保持原namespace
+type CloneOptions struct {
+	TargetNamespace  string `json:"target_namespace"`
+	TargetKubeconfig string `json:"target_kubeconfig"`
+	TargetContext    string `json:"target_context"`
+	//Ingress host的替换, key为原host, 没有host的规则使用空字符串作为key
+	//克隆到当前集群时Ingress的每个host都必须指定映射, 否则会与原Ingress的路由重复, 这样的Ingress会被跳过
+	HostMap map[string]string `json:"host_map"`
+	//覆盖所有容器镜像的tag, 为空时保持不变
+	ImageTag string `json:"image_tag"`
+	DryRun   bool   `json:"dry_run"`
+}
+
+//CloneWorkload 克隆单个工作负载的参数, Deployment和StatefulSet使用Name和Namespace, Workflow使用ID
+type CloneWorkload struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	ID        int    `json:"id"`
+	//克隆后的名字, 为空时与原名字相同, workflow的名字不能重复, 克隆workflow时必须指定
+	TargetName string `json:"target_name"`
+	CloneOptions
+}
+
+//CloneNamespace 克隆整个namespace的参数
+type CloneNamespace struct {
+	Namespace string `json:"namespace"`
+	CloneOptions
+}
+
+type CloneResult struct {
+	BackupObjectRef
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+type CloneResp struct {
+	Results []*CloneResult `json:"results"`
+	Failed  int            `json:"failed"`
+}
+
+//克隆Deployment、StatefulSet或workflow到目标namespace或其他集群
+//同时克隆其引用的ConfigMap、Secret、PVC, 选中其pod的Service以及指向这些Service的Ingress
+//workflow的Service和入口资源由创建workflow时生成, 并新增一条workflow记录
+func (c *clone) CloneWorkload(params *CloneWorkload) (cloneResp *CloneResp, err error) {
+	if params.Kind != CloneKindWorkflow && params.Kind != WorkloadKindDeployment && params.Kind != WorkloadKindStatefulSet {
+		return nil, errors.New("不支持克隆的类型: " + params.Kind + ", 可选值为Deployment、StatefulSet、Workflow")
+	}
+	target, err := newCloneTarget(params.TargetKubeconfig, params.TargetContext)
+	if err != nil {
+		logger.Error(errors.New("克隆" + params.Kind + "失败, " + err.Error()))
+		return nil, errors.New("克隆" + params.Kind + "失败, " + err.Error())
+	}
+	if params.Kind == CloneKindWorkflow {
+		return c.cloneWorkflow(params, target)
+	}
+	return c.cloneWorkload(params.Kind, params.Namespace, params.Name, params.TargetName, &params.CloneOptions, target)
+}
+
+//克隆整个namespace中的资源到目标namespace或其他集群, 范围与导出相同
+//namespace中的workflow只克隆k8s资源, 不会新增workflow记录
+func (c *clone) CloneNamespace(params *CloneNamespace) (cloneResp *CloneResp, err error) {
+	target, err := newCloneTarget(params.TargetKubeconfig, params.TargetContext)
+	if err != nil {
+		logger.Error(errors.New("克隆namespace失败, " + err.Error()))
+		return nil, errors.New("克隆namespace失败, " + err.Error())
+	}
+	remapper, err := newCloneRemapper(params.Namespace, nil, &params.CloneOptions, target)
+	if err != nil {
+		logger.Error(errors.New("克隆namespace失败, " + err.Error()))
+		return nil, errors.New("克隆namespace失败, " + err.Error())
+	}
+	objs, err := collectBackupObjects([]string{params.Namespace})
+	if err != nil {
+		logger.Error(errors.New("获取namespace: " + params.Namespace + " 中的资源失败, " + err.Error()))
+		return nil, errors.New("获取namespace: " + params.Namespace + " 中的资源失败, " + err.Error())
+	}
+	return cloneObjects(objs, nil, remapper, &params.CloneOptions, target)
+}
+
+//cloneWorkload 克隆工作负载及其依赖的资源, targetName为空时保持原名字
+func (c *clone) cloneWorkload(kind, namespace, name, targetName string, options *CloneOptions, target *cloneTarget) (*CloneResp, error) {
+	names := map[string]string{}
+	if targetName != "" && targetName != name {
+		names[name] = targetName
+	}
+	remapper, err := newCloneRemapper(namespace, names, options, target)
+	if err != nil {
+		logger.Error(errors.New("克隆" + kind + "失败, " + err.Error()))
+		return nil, errors.New("克隆" + kind + "失败, " + err.Error())
+	}
+	workload, err := getCloneObject(kind, namespace, name)
+	if err != nil {
+		logger.Error(errors.New("获取" + kind + ": " + name + " 失败, " + err.Error()))
+		return nil, errors.New("获取" + kind + ": " + name + " 失败, " + err.Error())
+	}
+	objs, results, err := collectCloneDependencies(workload, true)
+	if err != nil {
+		logger.Error(errors.New("获取" + kind + ": " + name + " 的依赖资源失败, " + err.Error()))
+		return nil, errors.New("获取" + kind + ": " + name + " 的依赖资源失败, " + err.Error())
+	}
+	objs = append(objs, cleanBackupObject(workload))
+	return cloneObjects(objs, results, remapper, options, target)
+}
+
+//cloneWorkflow 先克隆工作负载引用的ConfigMap、Secret和PVC, 再按当前生效的spec创建新的workflow
+//依赖资源克隆失败时不创建workflow
+//workflow记录只管理当前集群, 克隆到其他集群时按工作负载克隆其k8s资源, 不新增workflow记录
+func (c *clone) cloneWorkflow(params *CloneWorkload, target *cloneTarget) (*CloneResp, error) {
+	workflow, err := getWorkflow(params.ID)
+	if err != nil {
+		return nil, err
+	}
+	if target.remote {
+		return c.cloneWorkload(workflowWorkloadKind(workflow.WorkloadKind), workflow.Namespace, workflow.Deployment, params.TargetName, &params.CloneOptions, target)
+	}
+	if params.TargetName == "" {
+		return nil, errors.New("克隆workflow失败, workflow名字不能重复, 必须指定target_name")
+	}
+	existing, err := dao.Workflow.GetByName(params.TargetName)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.New("克隆workflow失败, workflow: " + params.TargetName + " 已存在")
+	}
+	remapper, err := newCloneRemapper(workflow.Namespace, map[string]string{workflow.Name: params.TargetName}, &params.CloneOptions, target)
+	if err != nil {
+		logger.Error(errors.New("克隆workflow失败, " + err.Error()))
+		return nil, errors.New("克隆workflow失败, " + err.Error())
+	}
+	data, err := getWorkflowSpec(workflow, workflow.SpecVersion)
+	if err != nil {
+		return nil, err
+	}
+	if data.Type == "Ingress" {
+		hosts := make([]string, 0, len(data.Hosts))
+		for host := range data.Hosts {
+			hosts = append(hosts, host)
+		}
+		if unmapped := unmappedCloneHosts(hosts, params.HostMap); len(unmapped) > 0 {
+			return nil, errors.New("克隆workflow失败, host " + strings.Join(unmapped, ", ") + " 未在host_map中指定映射, 克隆后会与原workflow的路由重复")
+		}
+	}
+
+	kind := workflowWorkloadKind(workflow.WorkloadKind)
+	workload, err := getCloneObject(kind, workflow.Namespace, workflow.Deployment)
+	if err != nil {
+		logger.Error(errors.New("获取" + kind + ": " + workflow.Deployment + " 失败, " + err.Error()))
+		return nil, errors.New("获取" + kind + ": " + workflow.Deployment + " 失败, " + err.Error())
+	}
+	objs, results, err := collectCloneDependencies(workload, false)
+	if err != nil {
+		logger.Error(errors.New("获取workflow: " + workflow.Name + " 的依赖资源失败, " + err.Error()))
+		return nil, errors.New("获取workflow: " + workflow.Name + " 的依赖资源失败, " + err.Error())
+	}
+	cloneResp, err := cloneObjects(objs, results, remapper, &params.CloneOptions, target)
+	if err != nil {
+		return nil, err
+	}
+
+	remapper.remapWorkflowCreate(data)
+	overrideCloneWorkflow(data, &params.CloneOptions)
+	result := &CloneResult{
+		BackupObjectRef: BackupObjectRef{Kind: CloneKindWorkflow, Namespace: data.Namespace, Name: data.Name},
+		Action:          CloneActionCreated,
+	}
+	cloneResp.Results = append(cloneResp.Results, result)
+	if cloneResp.Failed > 0 {
+		result.Action = CloneActionSkipped
+		result.Error = "存在克隆失败的依赖资源, 跳过创建workflow"
+		return cloneResp, nil
+	}
+	if !params.DryRun {
+		if err = Workflow.CreateWorkflow(data); err != nil {
+			result.Action = CloneActionFailed
+			result.Error = err.Error()
+			cloneResp.Failed++
+		}
+	}
+	return cloneResp, nil
+}
+
+//newCloneRemapper 校验目标namespace, 返回将原namespace映射到目标namespace的remapper
+//克隆到其他集群时目标namespace可以与原namespace相同, 为空时保持原namespace
+func newCloneRemapper(namespace string, names map[string]string, options *CloneOptions, target *cloneTarget) (*backupRemapper, error) {
+	targetNamespace := options.TargetNamespace
+	if targetNamespace == "" && target.remote {
+		targetNamespace = namespace
+	}
+	if targetNamespace == "" {
+		return nil, errors.New("target_namespace不能为空")
+	}
+	if targetNamespace == namespace && !target.remote {
+		return nil, errors.New("目标namespace不能与原namespace相同")
+	}
+	if errs := validation.IsDNS1123Label(targetNamespace); len(errs) > 0 {
+		return nil, errors.New("target_namespace不合法, " + strings.Join(errs, ", "))
+	}
+	return newBackupRemapper(map[string]string{namespace: targetNamespace}, names)
+}
+
+//cloneTarget 克隆的目标集群
+type cloneTarget struct {
+	dynamic dynamic.Interface
+	mapper  meta.RESTMapper
+	//是否为当前连接的集群以外的集群
+	remote bool
+}
+
+//newCloneTarget 未指定target_kubeconfig时使用当前连接的集群, 否则按kubeconfig和context连接目标集群
+//备份恢复到其他集群时也使用
+func newCloneTarget(kubeconfig, kubeContext string) (*cloneTarget, error) {
+	if kubeconfig == "" {
+		if kubeContext != "" {
+			return nil, errors.New("指定target_context时必须同时指定target_kubeconfig")
+		}
+		mapper, err := newRESTMapper()
+		if err != nil {
+			return nil, errors.New("获取API资源映射失败, " + err.Error())
+		}
+		return &cloneTarget{dynamic: K8s.Dynamic, mapper: mapper}, nil
+	}
+
+	rawConfig, err := clientcmd.Load([]byte(kubeconfig))
+	if err != nil {
+		return nil, errors.New("解析target_kubeconfig失败, " + err.Error())
+	}
+	if err = checkCloneKubeconfig(rawConfig); err != nil {
+		return nil, err
+	}
+	restConfig, err := clientcmd.NewNonInteractiveClientConfig(*rawConfig, kubeContext, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		return nil, errors.New("创建目标集群配置失败, " + err.Error())
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, errors.New("创建目标集群discovery client失败, " + err.Error())
+	}
+	groupResources, err := restmapper.GetAPIGroupResources(discoveryClient)
+	if err != nil {
+		return nil, errors.New("获取目标集群API资源映射失败, " + err.Error())
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, errors.New("创建目标集群dynamic client失败, " + err.Error())
+	}
+	return &cloneTarget{
+		dynamic: dynamicClient,
+		mapper:  restmapper.NewDiscoveryRESTMapper(groupResources),
+		remote:  true,
+	}, nil
+}
+
+//checkCloneKubeconfig kubeconfig来自请求, 只允许内嵌的证书和token
+//不允许exec和auth-provider插件, 以及引用dashboard所在主机上文件的字段
+func checkCloneKubeconfig(rawConfig *clientcmdapi.Config) error {
+	for name, authInfo := range rawConfig.AuthInfos {
+		if authInfo.Exec != nil || authInfo.AuthProvider != nil {
+			return errors.New("target_kubeconfig的用户 " + name + " 使用了exec或auth-provider插件, 不支持")
+		}
+		if authInfo.ClientCertificate != "" || authInfo.ClientKey != "" || authInfo.TokenFile != "" {
+			return errors.New("target_kubeconfig的用户 " + name + " 引用了本地文件, 请使用client-certificate-data、client-key-data或token")
+		}
+	}
+	for name, cluster := range rawConfig.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return errors.New("target_kubeconfig的集群 " + name + " 引用了本地文件, 请使用certificate-authority-data")
+		}
+	}
+	return nil
+}
+
+//getCloneObject 获取导出范围内的对象, 并补全apiVersion和kind
+func getCloneObject(kind, namespace, name string) (*unstructured.Unstructured, error) {
+	for _, resource := range backupResources {
+		if resource.kind != kind {
+			continue
+		}
+		obj, err := resourceClient(resource.gvr, true, namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		obj.SetAPIVersion(resource.gvr.GroupVersion().String())
+		obj.SetKind(resource.kind)
+		return obj, nil
+	}
+	return nil, errors.New("不支持的类型: " + kind)
+}
+
+//collectCloneDependencies 获取工作负载所在的namespace以及pod模板引用的ConfigMap、Secret和PVC
+//withNetwork为true时同时获取选中其pod的Service(StatefulSet的serviceName)以及指向这些Service的Ingress
+//引用的对象在原namespace中不存在时记为跳过
+func collectCloneDependencies(workload *unstructured.Unstructured, withNetwork bool) ([]*unstructured.Unstructured, []*CloneResult, error) {
+	namespace := workload.GetNamespace()
+	namespaceObj, err := resourceClient(backupNamespaceGVR, false, "").Get(context.TODO(), namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	namespaceObj.SetAPIVersion("v1")
+	namespaceObj.SetKind("Namespace")
+	objs := []*unstructured.Unstructured{cleanBackupObject(namespaceObj)}
+	results := make([]*CloneResult, 0)
+
+	seen := map[string]bool{}
+	addRef := func(kind, name string) error {
+		if name == "" || seen[refKey(kind, name)] {
+			return nil
+		}
+		seen[refKey(kind, name)] = true
+		obj, err := getCloneObject(kind, namespace, name)
+		if apierrors.IsNotFound(err) {
+			results = append(results, &CloneResult{
+				BackupObjectRef: BackupObjectRef{Kind: kind, Namespace: namespace, Name: name},
+				Action:          CloneActionSkipped,
+				Error:           "原namespace中不存在",
+			})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		objs = append(objs, cleanBackupObject(obj))
+		return nil
+	}
+
+	content, _, err := unstructured.NestedMap(workload.Object, "spec", "template")
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &corev1.PodTemplateSpec{}
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(content, template); err != nil {
+		return nil, nil, err
+	}
+	for _, ref := range podSpecReferences(&template.Spec) {
+		if err = addRef(ref.kind, ref.name); err != nil {
+			return nil, nil, err
+		}
+	}
+	if !withNetwork {
+		return objs, results, nil
+	}
+
+	services, err := listCloneObjects("Service", namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	serviceName, _, _ := unstructured.NestedString(workload.Object, "spec", "serviceName")
+	selected := map[string]bool{}
+	for _, service := range services {
+		selector, _, _ := unstructured.NestedStringMap(service.Object, "spec", "selector")
+		matched := len(selector) > 0 && labels.SelectorFromSet(selector).Matches(labels.Set(template.Labels))
+		if matched || service.GetName() == serviceName {
+			selected[service.GetName()] = true
+			objs = append(objs, service)
+		}
+	}
+	ingresses, err := listCloneObjects("Ingress", namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, ingress := range ingresses {
+		content, _, _ := unstructured.NestedMap(ingress.Object, "spec")
+		spec := &nwv1.IngressSpec{}
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(content, spec); err != nil {
+			return nil, nil, err
+		}
+		if !ingressSelectsService(spec, selected) {
+			continue
+		}
+		objs = append(objs, ingress)
+		for _, tls := range spec.TLS {
+			if err = addRef(RefKindSecret, tls.SecretName); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return objs, results, nil
+}
+
+//listCloneObjects 获取namespace中导出范围内的某类对象, 已去掉不能重复应用的字段
+func listCloneObjects(kind, namespace string) ([]*unstructured.Unstructured, error) {
+	objs := make([]*unstructured.Unstructured, 0)
+	for _, resource := range backupResources {
+		if resource.kind != kind {
+			continue
+		}
+		list, err := resourceClient(resource.gvr, true, namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			obj.SetAPIVersion(resource.gvr.GroupVersion().String())
+			obj.SetKind(resource.kind)
+			if !skipBackupObject(obj) {
+				objs = append(objs, cleanBackupObject(obj))
+			}
+		}
+	}
+	return objs, nil
+}
+
+//ingressSelectsService 判断Ingress的后端是否指向其中某个Service
+func ingressSelectsService(spec *nwv1.IngressSpec, services map[string]bool) bool {
+	if spec.DefaultBackend != nil && spec.DefaultBackend.Service != nil && services[spec.DefaultBackend.Service.Name] {
+		return true
+	}
+	for _, rule := range spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service != nil && services[path.Backend.Service.Name] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//cloneObjects 按依赖顺序将对象映射到目标namespace后在目标集群中创建, 目标中已存在的对象跳过
+//克隆到当前集群时, host未全部指定映射的Ingress跳过
+//results为收集依赖时已经产生的结果, 追加在最后
+func cloneObjects(objs []*unstructured.Unstructured, results []*CloneResult, remapper *backupRemapper, options *CloneOptions, target *cloneTarget) (*CloneResp, error) {
+	sortBackupObjects(objs)
+
+	cloneResp := &CloneResp{Results: make([]*CloneResult, 0, len(objs)+len(results))}
+	for _, obj := range objs {
+		result := &CloneResult{Action: CloneActionCreated}
+		cloneResp.Results = append(cloneResp.Results, result)
+		if !target.remote && obj.GetKind() == "Ingress" {
+			if unmapped := unmappedCloneHosts(ingressHosts(obj), options.HostMap); len(unmapped) > 0 {
+				result.BackupObjectRef = backupObjectRef(obj)
+				result.Action = CloneActionSkipped
+				result.Error = "host " + strings.Join(unmapped, ", ") + " 未在host_map中指定映射, 克隆后会与原Ingress的路由重复"
+				continue
+			}
+		}
+		err := remapper.remap(obj)
+		if err == nil {
+			err = overrideCloneObject(obj, options)
+		}
+		result.BackupObjectRef = backupObjectRef(obj)
+		exists := false
+		if err == nil {
+			exists, err = objectExists(target, obj)
+		}
+		if err == nil && exists {
+			result.Action = CloneActionSkipped
+			result.Error = "目标中已存在"
+			continue
+		}
+		if err == nil {
+			_, err = applyClusterObject(target.dynamic, target.mapper, obj, options.DryRun)
+		}
+		if err != nil {
+			logger.Error(errors.New("克隆" + obj.GetKind() + ": " + obj.GetName() + " 失败, " + err.Error()))
+			result.Action = CloneActionFailed
+			result.Error = err.Error()
+			cloneResp.Failed++
+		}
+	}
+	cloneResp.Results = append(cloneResp.Results, results...)
+	return cloneResp, nil
+}
+
+//objectExists 判断对象在目标集群中是否已存在
+func objectExists(target *cloneTarget, obj *unstructured.Unstructured) (bool, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := target.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, err
+	}
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	_, err = clusterResourceClient(target.dynamic, mapping.Resource, namespaced, obj.GetNamespace()).Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+//overrideCloneObject 替换Ingress的host和工作负载容器镜像的tag
+func overrideCloneObject(obj *unstructured.Unstructured, options *CloneOptions) error {
+	switch obj.GetKind() {
+	case "Ingress":
+		if len(options.HostMap) == 0 {
+			return nil
+		}
+		return mutateIngressSpec(obj, func(spec *nwv1.IngressSpec) {
+			for i := range spec.Rules {
+				spec.Rules[i].Host = cloneHost(spec.Rules[i].Host, options.HostMap)
+			}
+			for i := range spec.TLS {
+				for j := range spec.TLS[i].Hosts {
+					spec.TLS[i].Hosts[j] = cloneHost(spec.TLS[i].Hosts[j], options.HostMap)
+				}
+			}
+		})
+	case WorkloadKindDeployment, WorkloadKindStatefulSet, WorkloadKindDaemonSet:
+		if options.ImageTag == "" {
+			return nil
+		}
+		return mutatePodSpec(obj, func(podSpec *corev1.PodSpec) {
+			for _, containers := range [][]corev1.Container{podSpec.InitContainers, podSpec.Containers} {
+				for i := range containers {
+					containers[i].Image = replaceImageTag(containers[i].Image, options.ImageTag)
+				}
+			}
+		})
+	}
+	return nil
+}
+
+//overrideCloneWorkflow 替换workflow spec中的host和镜像tag
+func overrideCloneWorkflow(data *WorkflowCreate, options *CloneOptions) {
+	if len(options.HostMap) > 0 && data.Hosts != nil {
+		hosts := make(map[string][]*HttpPath, len(data.Hosts))
+		for host, paths := range data.Hosts {
+			hosts[cloneHost(host, options.HostMap)] = paths
+		}
+		data.Hosts = hosts
+	}
+	if options.ImageTag != "" {
+		if data.Image != "" {
+			data.Image = replaceImageTag(data.Image, options.ImageTag)
+		}
+		for i := range data.Containers {
+			data.Containers[i].Image = replaceImageTag(data.Containers[i].Image, options.ImageTag)
+		}
+	}
+}
+
+//ingressHosts 返回Ingress规则中的host, 没有host的规则返回空字符串
+func ingressHosts(obj *unstructured.Unstructured) []string {
+	hosts := make([]string, 0)
+	rules, _, _ := unstructured.NestedSlice(obj.Object, "spec", "rules")
+	for _, rule := range rules {
+		ruleMap, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		host, _, _ := unstructured.NestedString(ruleMap, "host")
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+//unmappedCloneHosts 返回未在hostMap中指定映射的host, 空host显示为*
+func unmappedCloneHosts(hosts []string, hostMap map[string]string) []string {
+	unmapped := make([]string, 0)
+	for _, host := range hosts {
+		if _, ok := hostMap[host]; ok {
+			continue
+		}
+		if host == "" {
+			host = "*"
+		}
+		unmapped = append(unmapped, host)
+	}
+	sort.Strings(unmapped)
+	return unmapped
+}
+
+func cloneHost(host string, hostMap map[string]string) string {
+	if to, ok := hostMap[host]; ok {
+		return to
+	}
+	return host
+}
+
+//replaceImageTag 替换镜像的tag, 去掉digest, 镜像仓库地址中的端口不受影响
+func replaceImageTag(image, tag string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image + ":" + tag
+}
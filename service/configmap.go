@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"test4/config"
 
 	"github.com/wonderivan/logger"
 	corev1 "k8s.io/api/core/v1"
@@ -57,6 +58,10 @@ func (cm *configMap) GetConfigMaps(filterName, namespace string, limit, page int
 	data := filtered.Sort().Paginate()
 
 	configMapResps := cm.fromCells(data.GenericDataList)
+	//标记为敏感的ConfigMap, 列表中的data脱敏
+	for i := range configMapResps {
+		cm.maskConfigMap(&configMapResps[i])
+	}
 
 	return &ConfigMapResp{
 		Items: configMapResps,
@@ -70,9 +75,29 @@ func (cm *configMap) GetConfigMapDetail(configMapName, namespace string) (config
 		logger.Error(errors.New("获取Namespace: %s 下的ConfigMap %s 详情失败. " +err.Error()), namespace, configMapName)
 		return nil, errors.New("获取Namespace下的ConfigMap 详情失败. " + err.Error())
 	}
+	cm.maskConfigMap(ConfigMap)
 	return ConfigMap, nil
 }
 
+//判断ConfigMap是否通过annotation标记为敏感数据
+func isSensitiveConfigMap(configMap *corev1.ConfigMap) bool {
+	return configMap.Annotations[config.SensitiveAnnotation] == "true"
+}
+
+//脱敏敏感ConfigMap, 非敏感的ConfigMap原样返回
+func (cm *configMap) maskConfigMap(configMap *corev1.ConfigMap) {
+	if !isSensitiveConfigMap(configMap) {
+		return
+	}
+	for key := range configMap.Data {
+		configMap.Data[key] = config.MaskValue
+	}
+	for key := range configMap.BinaryData {
+		configMap.BinaryData[key] = []byte(config.MaskValue)
+	}
+	delete(configMap.Annotations, corev1.LastAppliedConfigAnnotation)
+}
+
 func (cm *configMap) DeleteConfigMap(configMapName, namespace string) (err error) {
 	err = K8s.Clientset.CoreV1().ConfigMaps(namespace).Delete(context.TODO(), configMapName, metav1.DeleteOptions{})
 	if err != nil {
@@ -82,18 +107,51 @@ func (cm *configMap) DeleteConfigMap(configMapName, namespace string) (err error
 	return nil
 }
 
-func (cm *configMap) UpdateConfigMap(namespace, content string) (err error) {
+//更新ConfigMap, restart为true时重启引用了它的工作负载
+//dryRun为true时使用apiserver的dry-run校验更新, 不落库, 只返回将要重启的工作负载
+func (cm *configMap) UpdateConfigMap(namespace, content string, restart, dryRun bool) (restarted []*RestartResult, err error) {
 	var configMap = &corev1.ConfigMap{}
 
 	err = json.Unmarshal([]byte(content), configMap)
 	if err != nil {
 		logger.Error(errors.New("JONS反序列化失败." + err.Error()))
-		return errors.New("JONS反序列化失败." + err.Error())
+		return nil, errors.New("JONS反序列化失败." + err.Error())
 	}
-	_, err = K8s.Clientset.CoreV1().ConfigMaps(namespace).Update(context.TODO(), configMap, metav1.UpdateOptions{})
+
+	//敏感ConfigMap是脱敏返回的, 提交内容中仍为占位值的key保留集群中的原值
+	current, err := K8s.Clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), configMap.Name, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Namespace: %s 下的ConfigMap %s 详情失败. " +err.Error()), namespace, configMap.Name)
+		return nil, errors.New("获取Namespace下的ConfigMap 详情失败. " + err.Error())
+	}
+	if isSensitiveConfigMap(current) {
+		for key, value := range configMap.Data {
+			if value == config.MaskValue {
+				configMap.Data[key] = current.Data[key]
+			}
+		}
+		for key, value := range configMap.BinaryData {
+			if string(value) == config.MaskValue {
+				configMap.BinaryData[key] = current.BinaryData[key]
+			}
+		}
+		if last, ok := current.Annotations[corev1.LastAppliedConfigAnnotation]; ok {
+			if _, exist := configMap.Annotations[corev1.LastAppliedConfigAnnotation]; !exist {
+				if configMap.Annotations == nil {
+					configMap.Annotations = map[string]string{}
+				}
+				configMap.Annotations[corev1.LastAppliedConfigAnnotation] = last
+			}
+		}
+	}
+
+	_, err = K8s.Clientset.CoreV1().ConfigMaps(namespace).Update(context.TODO(), configMap, metav1.UpdateOptions{DryRun: dryRunOption(dryRun)})
 	if err != nil {
 		logger.Error(errors.New("更新Namespace: %s 下的ConfigMap %s 失败. " + err.Error()), namespace, configMap.Name)
-		return errors.New("更新Namespace下的ConfigMap 失败. " + err.Error())
+		return nil, errors.New("更新Namespace下的ConfigMap 失败. " + err.Error())
 	}
-	return nil
+	if !restart {
+		return nil, nil
+	}
+	return Reference.RestartConsumers(RefKindConfigMap, configMap.Name, namespace, dryRun)
 }
\ No newline at end of file
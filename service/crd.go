@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/wonderivan/logger"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var Crd crd
+
+type crd struct{}
+
+//CRD本身通过dynamic client获取, 避免引入apiextensions的client
+var crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+//PrinterColumn 对应CRD中的additionalPrinterColumns
+type PrinterColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	JSONPath    string `json:"json_path"`
+	Description string `json:"description"`
+	Priority    int64  `json:"priority"`
+}
+
+type CrdVersion struct {
+	Name           string           `json:"name"`
+	Served         bool             `json:"served"`
+	Storage        bool             `json:"storage"`
+	PrinterColumns []*PrinterColumn `json:"printer_columns"`
+}
+
+//CrdSummary CRD列表中展示的信息, 浏览对应资源时使用Group、Versions和Plural
+type CrdSummary struct {
+	Name              string        `json:"name"`
+	Group             string        `json:"group"`
+	Kind              string        `json:"kind"`
+	Plural            string        `json:"plural"`
+	Scope             string        `json:"scope"`
+	Versions          []*CrdVersion `json:"versions"`
+	CreationTimestamp metav1.Time   `json:"creation_timestamp"`
+}
+
+type CrdResp struct {
+	Items []*CrdSummary `json:"items"`
+	Total int           `json:"total"`
+}
+
+func (c *crd) toCells(std []unstructured.Unstructured) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = UnstructuredCell(std[i])
+	}
+	return cells
+}
+
+func (c *crd) fromCells(cells []DataCell) []unstructured.Unstructured {
+	items := make([]unstructured.Unstructured, len(cells))
+	for i := range cells {
+		items[i] = unstructured.Unstructured(cells[i].(UnstructuredCell))
+	}
+	return items
+}
+
+//获取CRD列表, 支持过滤、排序、分页
+func (c *crd) GetCustomResourceDefinitions(filterName string, limit, page int) (crdResp *CrdResp, err error) {
+	list, err := K8s.Dynamic.Resource(crdGVR).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取CRD列表失败, " + err.Error()))
+		return nil, errors.New("获取CRD列表失败, " + err.Error())
+	}
+
+	selectableData := &dataSelector{
+		GenericDataList: c.toCells(list.Items),
+		DataSelectQuery: &DataSelectQuery{
+			FilterQuery: &FilterQuery{Name: filterName},
+			PaginateQuery: &PaginateQuery{
+				Limit: limit,
+				Page:  page,
+			},
+		},
+	}
+
+	filtered := selectableData.Filter()
+	total := len(filtered.GenericDataList)
+	data := filtered.Sort().Paginate()
+
+	items := c.fromCells(data.GenericDataList)
+	summaries := make([]*CrdSummary, 0, len(items))
+	for i := range items {
+		summaries = append(summaries, toCrdSummary(&items[i]))
+	}
+	return &CrdResp{
+		Items: summaries,
+		Total: total,
+	}, nil
+}
+
+//获取CRD详情
+func (c *crd) GetCustomResourceDefinitionDetail(crdName string) (detail *unstructured.Unstructured, err error) {
+	detail, err = K8s.Dynamic.Resource(crdGVR).Get(context.TODO(), crdName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取CRD详情失败, " + err.Error()))
+		return nil, errors.New("获取CRD详情失败, " + err.Error())
+	}
+	return detail, nil
+}
+
+//getPrinterColumns 获取资源在指定版本下的additionalPrinterColumns, 内置资源没有对应的CRD, 返回空
+func (c *crd) getPrinterColumns(group, version, resource string) ([]*PrinterColumn, error) {
+	list, err := K8s.Dynamic.Resource(crdGVR).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		summary := toCrdSummary(&list.Items[i])
+		if summary.Group != group || summary.Plural != resource {
+			continue
+		}
+		for _, crdVersion := range summary.Versions {
+			if crdVersion.Name == version {
+				return crdVersion.PrinterColumns, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+//toCrdSummary 从unstructured中取出CRD的关键字段
+func toCrdSummary(obj *unstructured.Unstructured) *CrdSummary {
+	summary := &CrdSummary{
+		Name:              obj.GetName(),
+		CreationTimestamp: obj.GetCreationTimestamp(),
+	}
+	summary.Group, _, _ = unstructured.NestedString(obj.Object, "spec", "group")
+	summary.Kind, _, _ = unstructured.NestedString(obj.Object, "spec", "names", "kind")
+	summary.Plural, _, _ = unstructured.NestedString(obj.Object, "spec", "names", "plural")
+	summary.Scope, _, _ = unstructured.NestedString(obj.Object, "spec", "scope")
+
+	versions, _, _ := unstructured.NestedSlice(obj.Object, "spec", "versions")
+	for _, v := range versions {
+		versionMap, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		crdVersion := &CrdVersion{}
+		crdVersion.Name, _, _ = unstructured.NestedString(versionMap, "name")
+		crdVersion.Served, _, _ = unstructured.NestedBool(versionMap, "served")
+		crdVersion.Storage, _, _ = unstructured.NestedBool(versionMap, "storage")
+		columns, _, _ := unstructured.NestedSlice(versionMap, "additionalPrinterColumns")
+		for _, col := range columns {
+			colMap, ok := col.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			column := &PrinterColumn{}
+			column.Name, _, _ = unstructured.NestedString(colMap, "name")
+			column.Type, _, _ = unstructured.NestedString(colMap, "type")
+			column.JSONPath, _, _ = unstructured.NestedString(colMap, "jsonPath")
+			column.Description, _, _ = unstructured.NestedString(colMap, "description")
+			column.Priority, _, _ = unstructured.NestedInt64(colMap, "priority")
+			crdVersion.PrinterColumns = append(crdVersion.PrinterColumns, column)
+		}
+		summary.Versions = append(summary.Versions, crdVersion)
+	}
+	return summary
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/wonderivan/logger"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var CronJob cronJob
+
+type cronJob struct{}
+
+type CronJobResp struct {
+	Items []batchv1.CronJob `json:"items"`
+	Total int               `json:"total"`
+}
+
+// 定义CronJobCreate结构体, 用于创建cronjob需要的参数属性的定义
+// JobCreate中的属性用于组装jobTemplate, json中与调度属性平铺
+type CronJobCreate struct {
+	JobCreate
+	Schedule                   string `json:"schedule"`
+	TimeZone                   string `json:"time_zone"`
+	ConcurrencyPolicy          string `json:"concurrency_policy"`
+	Suspend                    bool   `json:"suspend"`
+	StartingDeadlineSeconds    int64  `json:"starting_deadline_seconds"`
+	SuccessfulJobsHistoryLimit int32  `json:"successful_jobs_history_limit"`
+	FailedJobsHistoryLimit     int32  `json:"failed_jobs_history_limit"`
+}
+
+// 定义CronJobJob类型, 用于返回cronjob最近创建的job及其pod
+type CronJobJob struct {
+	Job  batchv1.Job `json:"job"`
+	Pods []*JobPod   `json:"pods"`
+}
+
+func (cj *cronJob) toCells(std []batchv1.CronJob) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = CronJobCell(std[i])
+	}
+	return cells
+}
+
+func (cj *cronJob) fromCells(cells []DataCell) []batchv1.CronJob {
+	cronJobs := make([]batchv1.CronJob, len(cells))
+	for i := range cells {
+		cronJobs[i] = batchv1.CronJob(cells[i].(CronJobCell))
+	}
+	return cronJobs
+}
+
+// 获取cronjob列表, 支持过滤、排序、分页
+func (cj *cronJob) GetCronJobs(filterName, namespace string, limit, page int) (cronJobResp *CronJobResp, err error) {
+	cronJobList, err := K8s.Clientset.BatchV1().CronJobs(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取CronJob列表失败, " + err.Error()))
+		return nil, errors.New("获取CronJob列表失败, " + err.Error())
+	}
+
+	selectableData := &dataSelector{
+		GenericDataList: cj.toCells(cronJobList.Items),
+		DataSelectQuery: &DataSelectQuery{
+			FilterQuery: &FilterQuery{Name: filterName},
+			PaginateQuery: &PaginateQuery{
+				Limit: limit,
+				Page:  page,
+			},
+		},
+	}
+
+	filtered := selectableData.Filter()
+	total := len(filtered.GenericDataList)
+	data := filtered.Sort().Paginate()
+
+	return &CronJobResp{
+		Items: cj.fromCells(data.GenericDataList),
+		Total: total,
+	}, nil
+}
+
+// 获取cronjob详情
+func (cj *cronJob) GetCronJobDetail(cronJobName, namespace string) (cronJob *batchv1.CronJob, err error) {
+	cronJob, err = K8s.Clientset.BatchV1().CronJobs(namespace).Get(context.TODO(), cronJobName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取CronJob详情失败, " + err.Error()))
+		return nil, errors.New("获取CronJob详情失败, " + err.Error())
+	}
+	return cronJob, nil
+}
+
+// 创建cronjob, 并接收CronJobCreate对象
+func (cj *cronJob) CreateCronJob(data *CronJobCreate) (err error) {
+	jobSpec, err := buildJobSpec(&data.JobCreate)
+	if err != nil {
+		logger.Error(errors.New("创建CronJob失败, " + err.Error()))
+		return errors.New("创建CronJob失败, " + err.Error())
+	}
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      data.Name,
+			Namespace: data.Namespace,
+			Labels:    data.Label,
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule: data.Schedule,
+			Suspend:  &data.Suspend,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: data.Label,
+				},
+				Spec: jobSpec,
+			},
+		},
+	}
+	if data.TimeZone != "" {
+		cronJob.Spec.TimeZone = &data.TimeZone
+	}
+	if data.ConcurrencyPolicy != "" {
+		cronJob.Spec.ConcurrencyPolicy = batchv1.ConcurrencyPolicy(data.ConcurrencyPolicy)
+	}
+	if data.StartingDeadlineSeconds > 0 {
+		cronJob.Spec.StartingDeadlineSeconds = &data.StartingDeadlineSeconds
+	}
+	if data.SuccessfulJobsHistoryLimit > 0 {
+		cronJob.Spec.SuccessfulJobsHistoryLimit = &data.SuccessfulJobsHistoryLimit
+	}
+	if data.FailedJobsHistoryLimit > 0 {
+		cronJob.Spec.FailedJobsHistoryLimit = &data.FailedJobsHistoryLimit
+	}
+
+	_, err = K8s.Clientset.BatchV1().CronJobs(data.Namespace).Create(context.TODO(), cronJob, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建CronJob失败, " + err.Error()))
+		return errors.New("创建CronJob失败, " + err.Error())
+	}
+	return nil
+}
+
+// 删除cronjob, propagation为删除传播策略, 不传时同时删除其创建的job和pod
+func (cj *cronJob) DeleteCronJob(cronJobName, namespace, propagation string) (err error) {
+	options, err := deleteOptions(propagation)
+	if err != nil {
+		return err
+	}
+	err = K8s.Clientset.BatchV1().CronJobs(namespace).Delete(context.TODO(), cronJobName, options)
+	if err != nil {
+		logger.Error(errors.New("删除CronJob失败, " + err.Error()))
+		return errors.New("删除CronJob失败, " + err.Error())
+	}
+	return nil
+}
+
+// 立即触发cronjob, 等同于 kubectl create job --from=cronjob/<name>
+// 使用jobTemplate创建job, 并设置ownerReference, 使其出现在cronjob的job列表中
+func (cj *cronJob) TriggerCronJob(cronJobName, namespace string) (jobName string, err error) {
+	cronJob, err := cj.GetCronJobDetail(cronJobName, namespace)
+	if err != nil {
+		return "", err
+	}
+	//job名最长63个字符, 后缀为时间戳
+	suffix := fmt.Sprintf("-manual-%d", time.Now().Unix())
+	jobName = cronJob.Name
+	if len(jobName)+len(suffix) > 63 {
+		jobName = jobName[:63-len(suffix)]
+	}
+	jobName += suffix
+
+	annotations := map[string]string{"cronjob.kubernetes.io/instantiate": "manual"}
+	for key, value := range cronJob.Spec.JobTemplate.Annotations {
+		annotations[key] = value
+	}
+	isController := true
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        jobName,
+			Namespace:   namespace,
+			Labels:      cronJob.Spec.JobTemplate.Labels,
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: batchv1.SchemeGroupVersion.String(),
+					Kind:       "CronJob",
+					Name:       cronJob.Name,
+					UID:        cronJob.UID,
+					Controller: &isController,
+				},
+			},
+		},
+		Spec: cronJob.Spec.JobTemplate.Spec,
+	}
+	_, err = K8s.Clientset.BatchV1().Jobs(namespace).Create(context.TODO(), job, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("触发CronJob失败, " + err.Error()))
+		return "", errors.New("触发CronJob失败, " + err.Error())
+	}
+	return jobName, nil
+}
+
+// 暂停或恢复cronjob
+func (cj *cronJob) SuspendCronJob(cronJobName, namespace string, suspend bool) (err error) {
+	patchData := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"suspend": suspend,
+		},
+	}
+	patchByte, err := json.Marshal(patchData)
+	if err != nil {
+		logger.Error(errors.New("JSON序列化失败, " + err.Error()))
+		return errors.New("JSON序列化失败, " + err.Error())
+	}
+	_, err = K8s.Clientset.BatchV1().CronJobs(namespace).Patch(context.TODO(), cronJobName, types.MergePatchType, patchByte, metav1.PatchOptions{})
+	if err != nil {
+		logger.Error(errors.New("修改CronJob暂停状态失败, " + err.Error()))
+		return errors.New("修改CronJob暂停状态失败, " + err.Error())
+	}
+	return nil
+}
+
+// 获取cronjob最近创建的job及其pod, 按创建时间倒序
+func (cj *cronJob) GetCronJobJobs(cronJobName, namespace string) (jobs []*CronJobJob, err error) {
+	cronJob, err := cj.GetCronJobDetail(cronJobName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	jobList, err := K8s.Clientset.BatchV1().Jobs(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Job列表失败, " + err.Error()))
+		return nil, errors.New("获取Job列表失败, " + err.Error())
+	}
+	var owned []batchv1.Job
+	for _, item := range jobList.Items {
+		for _, owner := range item.OwnerReferences {
+			if owner.UID == cronJob.UID {
+				owned = append(owned, item)
+				break
+			}
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		return owned[j].CreationTimestamp.Before(&owned[i].CreationTimestamp)
+	})
+	for _, item := range owned {
+		pods, err := Job.GetJobPods(item.Name, namespace)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &CronJobJob{Job: item, Pods: pods})
+	}
+	return jobs, nil
+}
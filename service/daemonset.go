@@ -104,6 +104,17 @@ func (ds *daemonSet) GetDaemonSetDetail(daemonSetName, namespace string) (daemon
 
 //创建Daemonset, 接收DaemonCreate对象
 func (ds *daemonSet) CreateDaemonSet(data *DaemonSetCreate) (err error) {
+	daemonSet := buildDaemonSet(data)
+	_, err = K8s.Clientset.AppsV1().DaemonSets(data.Namespace).Create(context.TODO(), daemonSet, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建DaemonSet失败." + err.Error()))
+		return errors.New("创建DaemonSet失败." + err.Error())
+	}
+	return nil
+}
+
+//buildDaemonSet 将创建参数组装成appsv1.DaemonSet对象, workflow更新和对比时也使用
+func buildDaemonSet(data *DaemonSetCreate) *appsv1.DaemonSet {
 	daemonSet := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: data.DaemonSetName,
@@ -171,14 +182,7 @@ func (ds *daemonSet) CreateDaemonSet(data *DaemonSetCreate) (err error) {
 			}
 		}
 	}
-		
-	
-	_, err = K8s.Clientset.AppsV1().DaemonSets(data.Namespace).Create(context.TODO(), daemonSet, metav1.CreateOptions{})
-	if err != nil {
-		logger.Error(errors.New("创建DaemonSet失败." + err.Error()))
-		return errors.New("创建DaemonSet失败." + err.Error())
-	}
-	return nil
+	return daemonSet
 }
 
 //删除daemonset
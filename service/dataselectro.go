@@ -7,8 +7,13 @@ import (
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	nwv1     "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 /*
@@ -272,4 +277,127 @@ func (pvc PersistentVolumeClaimCell) GetCreation() time.Time {
 
 func (pvc PersistentVolumeClaimCell) GetName() string {
 	return pvc.Name
-}
\ No newline at end of file
+}
+type StorageClassCell	storagev1.StorageClass
+
+func (sc StorageClassCell) GetCreation() time.Time {
+	return sc.CreationTimestamp.Time
+}
+
+func (sc StorageClassCell) GetName() string {
+	return sc.Name
+}
+
+//dynamic client返回的unstructured对象, 用于VolumeSnapshot等非typed资源
+type UnstructuredCell unstructured.Unstructured
+
+func (u UnstructuredCell) GetCreation() time.Time {
+	obj := unstructured.Unstructured(u)
+	return obj.GetCreationTimestamp().Time
+}
+
+func (u UnstructuredCell) GetName() string {
+	obj := unstructured.Unstructured(u)
+	return obj.GetName()
+}
+
+type JobCell batchv1.Job
+
+func (j JobCell) GetCreation() time.Time {
+	return j.CreationTimestamp.Time
+}
+
+func (j JobCell) GetName() string {
+	return j.Name
+}
+
+type CronJobCell batchv1.CronJob
+
+func (cj CronJobCell) GetCreation() time.Time {
+	return cj.CreationTimestamp.Time
+}
+
+func (cj CronJobCell) GetName() string {
+	return cj.Name
+}
+
+type HorizontalPodAutoscalerCell autoscalingv2.HorizontalPodAutoscaler
+
+func (h HorizontalPodAutoscalerCell) GetCreation() time.Time {
+	return h.CreationTimestamp.Time
+}
+
+func (h HorizontalPodAutoscalerCell) GetName() string {
+	return h.Name
+}
+
+type NetworkPolicyCell nwv1.NetworkPolicy
+
+func (np NetworkPolicyCell) GetCreation() time.Time {
+	return np.CreationTimestamp.Time
+}
+
+func (np NetworkPolicyCell) GetName() string {
+	return np.Name
+}
+
+type RoleCell rbacv1.Role
+
+func (r RoleCell) GetCreation() time.Time {
+	return r.CreationTimestamp.Time
+}
+
+func (r RoleCell) GetName() string {
+	return r.Name
+}
+
+type ClusterRoleCell rbacv1.ClusterRole
+
+func (cr ClusterRoleCell) GetCreation() time.Time {
+	return cr.CreationTimestamp.Time
+}
+
+func (cr ClusterRoleCell) GetName() string {
+	return cr.Name
+}
+
+type RoleBindingCell rbacv1.RoleBinding
+
+func (rb RoleBindingCell) GetCreation() time.Time {
+	return rb.CreationTimestamp.Time
+}
+
+func (rb RoleBindingCell) GetName() string {
+	return rb.Name
+}
+
+type ClusterRoleBindingCell rbacv1.ClusterRoleBinding
+
+func (crb ClusterRoleBindingCell) GetCreation() time.Time {
+	return crb.CreationTimestamp.Time
+}
+
+func (crb ClusterRoleBindingCell) GetName() string {
+	return crb.Name
+}
+
+type ServiceAccountCell corev1.ServiceAccount
+
+func (sa ServiceAccountCell) GetCreation() time.Time {
+	return sa.CreationTimestamp.Time
+}
+
+func (sa ServiceAccountCell) GetName() string {
+	return sa.Name
+}
+
+//helm release按最后部署时间排序
+type HelmReleaseCell HelmReleaseItem
+
+func (hr HelmReleaseCell) GetCreation() time.Time {
+	return hr.Updated
+}
+
+func (hr HelmReleaseCell) GetName() string {
+	return hr.Name
+}
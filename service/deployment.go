@@ -10,6 +10,7 @@ import (
 
 	"github.com/wonderivan/logger"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,6 +28,12 @@ type DeploymentsResp struct {
 	Total int                 `json:"total"`
 }
 
+// 定义详情的返回内容, deployment的字段平铺, 附带控制该deployment的hpa, 没有时为null
+type DeploymentDetail struct {
+	*appsv1.Deployment
+	HorizontalPodAutoscaler *autoscalingv2.HorizontalPodAutoscaler `json:"hpa"`
+}
+
 // 定义DeployCreate结构体, 用于创建deployment需要的参数属性的定义
 type DeployCreate struct {
 	Name          string            `json:"name"`
@@ -156,6 +163,18 @@ func (d *deployment) ScaleDeployment(deploymentName, namespace string, scaleNum
 
 //创建deployment, 并接收DeployCreate对象
 func (d *deployment) CreateDeployment(data *DeployCreate) (err error) {
+	deployment := buildDeployment(data)
+	//调用sdk创建deployment
+	_, err = K8s.Clientset.AppsV1().Deployments(data.Namespace).Create(context.TODO(), deployment, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建Deployment失败, " + err.Error()))
+		return errors.New("创建Deployment失败, " + err.Error())
+	}
+	return nil
+}
+
+//buildDeployment 将创建参数组装成appsv1.Deployment对象, workflow更新和对比时也使用
+func buildDeployment(data *DeployCreate) *appsv1.Deployment {
 	//将data中的数据组组装成appsv1.Deployment对象
 	deployment := &appsv1.Deployment{
 		// ObjectMeta 中定义资源名，命名空间以及标签
@@ -253,13 +272,7 @@ func (d *deployment) CreateDeployment(data *DeployCreate) (err error) {
 			}
 		}
 	}
-	//调用sdk创建deployment
-	_, err = K8s.Clientset.AppsV1().Deployments(data.Namespace).Create(context.TODO(), deployment, metav1.CreateOptions{})
-	if err != nil {
-		logger.Error(errors.New("创建Deployment失败, " + err.Error()))
-		return errors.New("创建Deployment失败, " + err.Error())
-	}
-	return nil
+	return deployment
 }
 
 //删除deployment
@@ -0,0 +1,574 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/wonderivan/logger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var Gateway gateway
+
+type gateway struct{}
+
+//Gateway API资源通过dynamic client操作, 各资源实际使用的版本由discovery决定
+const gatewayGroup = "gateway.networking.k8s.io"
+
+//按优先级排列的版本, GRPCRoute在较老的集群中只有v1alpha2
+var gatewayVersions = []string{"v1", "v1beta1", "v1alpha2"}
+
+var gatewayKinds = map[string]string{
+	"gatewayclasses": "GatewayClass",
+	"gateways":       "Gateway",
+	"httproutes":     "HTTPRoute",
+	"grpcroutes":     "GRPCRoute",
+}
+
+type GatewayResp struct {
+	Items []unstructured.Unstructured `json:"items"`
+	Total int                         `json:"total"`
+}
+
+//定义GatewayClassCreate结构体, ControllerName由网关实现决定, 如gateway.envoyproxy.io/gatewayclass-controller
+type GatewayClassCreate struct {
+	Name           string `json:"name"`
+	ControllerName string `json:"controller_name"`
+	Description    string `json:"description"`
+}
+
+//定义GatewayCreate结构体, 用于创建gateway需要的参数属性的定义
+type GatewayCreate struct {
+	Name             string             `json:"name"`
+	Namespace        string             `json:"namespace"`
+	Labels           map[string]string  `json:"labels"`
+	GatewayClassName string             `json:"gateway_class_name"`
+	Listeners        []*GatewayListener `json:"listeners"`
+}
+
+//GatewayListener Protocol为HTTPS时需要TLSSecretName, AllowedRoutes为Same或All, 默认Same
+type GatewayListener struct {
+	Name          string `json:"name"`
+	Hostname      string `json:"hostname"`
+	Port          int32  `json:"port"`
+	Protocol      string `json:"protocol"`
+	TLSSecretName string `json:"tls_secret_name"`
+	AllowedRoutes string `json:"allowed_routes"`
+}
+
+//RouteParentRef 路由挂载的gateway, Namespace为空时与路由相同
+type RouteParentRef struct {
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	SectionName string `json:"section_name"`
+}
+
+//定义HTTPRouteCreate结构体, 用于创建httproute需要的参数属性的定义
+type HTTPRouteCreate struct {
+	Name       string                 `json:"name"`
+	Namespace  string                 `json:"namespace"`
+	Labels     map[string]string      `json:"labels"`
+	ParentRefs []*RouteParentRef      `json:"parent_refs"`
+	Hostnames  []string               `json:"hostnames"`
+	Rules      []*HTTPRouteRuleCreate `json:"rules"`
+}
+
+//HTTPRouteRuleCreate PathType为PathPrefix或Exact, 默认PathPrefix
+type HTTPRouteRuleCreate struct {
+	Path        string `json:"path"`
+	PathType    string `json:"path_type"`
+	ServiceName string `json:"service_name"`
+	ServicePort int32  `json:"service_port"`
+}
+
+//定义GRPCRouteCreate结构体, 用于创建grpcroute需要的参数属性的定义
+type GRPCRouteCreate struct {
+	Name       string                 `json:"name"`
+	Namespace  string                 `json:"namespace"`
+	Labels     map[string]string      `json:"labels"`
+	ParentRefs []*RouteParentRef      `json:"parent_refs"`
+	Hostnames  []string               `json:"hostnames"`
+	Rules      []*GRPCRouteRuleCreate `json:"rules"`
+}
+
+//GRPCRouteRuleCreate GrpcService、GrpcMethod为空时匹配全部
+type GRPCRouteRuleCreate struct {
+	GrpcService string `json:"grpc_service"`
+	GrpcMethod  string `json:"grpc_method"`
+	ServiceName string `json:"service_name"`
+	ServicePort int32  `json:"service_port"`
+}
+
+//RouteParentStatus 路由在每个parentRef上的挂载状态
+type RouteParentStatus struct {
+	Name           string             `json:"name"`
+	Namespace      string             `json:"namespace"`
+	SectionName    string             `json:"section_name"`
+	ControllerName string             `json:"controller_name"`
+	Attached       bool               `json:"attached"`
+	Conditions     []metav1.Condition `json:"conditions"`
+}
+
+type RouteDetail struct {
+	Route   *unstructured.Unstructured `json:"route"`
+	Parents []*RouteParentStatus       `json:"parents"`
+}
+
+func (g *gateway) toCells(std []unstructured.Unstructured) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = UnstructuredCell(std[i])
+	}
+	return cells
+}
+
+func (g *gateway) fromCells(cells []DataCell) []unstructured.Unstructured {
+	items := make([]unstructured.Unstructured, len(cells))
+	for i := range cells {
+		items[i] = unstructured.Unstructured(cells[i].(UnstructuredCell))
+	}
+	return items
+}
+
+//gvr 按版本优先级找到集群中提供该资源的版本
+func (g *gateway) gvr(resource string) (schema.GroupVersionResource, error) {
+	for _, version := range gatewayVersions {
+		resourceList, err := K8s.Clientset.Discovery().ServerResourcesForGroupVersion(gatewayGroup + "/" + version)
+		if err != nil {
+			continue
+		}
+		for _, apiResource := range resourceList.APIResources {
+			if apiResource.Name == resource {
+				return schema.GroupVersionResource{Group: gatewayGroup, Version: version, Resource: resource}, nil
+			}
+		}
+	}
+	return schema.GroupVersionResource{}, errors.New("集群未安装Gateway API资源 " + resource)
+}
+
+func (g *gateway) list(resource, filterName, namespace string, limit, page int) (*GatewayResp, error) {
+	gvr, err := g.gvr(resource)
+	if err != nil {
+		return nil, err
+	}
+	list, err := resourceClient(gvr, resource != "gatewayclasses", namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	selectableData := &dataSelector{
+		GenericDataList: g.toCells(list.Items),
+		DataSelectQuery: &DataSelectQuery{
+			FilterQuery: &FilterQuery{Name: filterName},
+			PaginateQuery: &PaginateQuery{
+				Limit: limit,
+				Page:  page,
+			},
+		},
+	}
+
+	filtered := selectableData.Filter()
+	total := len(filtered.GenericDataList)
+	data := filtered.Sort().Paginate()
+
+	return &GatewayResp{
+		Items: g.fromCells(data.GenericDataList),
+		Total: total,
+	}, nil
+}
+
+func (g *gateway) get(resource, name, namespace string) (*unstructured.Unstructured, error) {
+	gvr, err := g.gvr(resource)
+	if err != nil {
+		return nil, err
+	}
+	return resourceClient(gvr, resource != "gatewayclasses", namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+func (g *gateway) delete(resource, name, namespace string) error {
+	gvr, err := g.gvr(resource)
+	if err != nil {
+		return err
+	}
+	return resourceClient(gvr, resource != "gatewayclasses", namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+}
+
+//create 补全apiVersion和kind后创建
+func (g *gateway) create(resource string, obj *unstructured.Unstructured) error {
+	gvr, err := g.gvr(resource)
+	if err != nil {
+		return err
+	}
+	obj.SetAPIVersion(gvr.GroupVersion().String())
+	obj.SetKind(gatewayKinds[resource])
+	_, err = resourceClient(gvr, resource != "gatewayclasses", obj.GetNamespace()).Create(context.TODO(), obj, metav1.CreateOptions{})
+	return err
+}
+
+//获取GatewayClass列表, 支持过滤、排序、分页
+func (g *gateway) GetGatewayClasses(filterName string, limit, page int) (gatewayResp *GatewayResp, err error) {
+	gatewayResp, err = g.list("gatewayclasses", filterName, "", limit, page)
+	if err != nil {
+		logger.Error(errors.New("获取GatewayClass列表失败, " + err.Error()))
+		return nil, errors.New("获取GatewayClass列表失败, " + err.Error())
+	}
+	return gatewayResp, nil
+}
+
+func (g *gateway) GetGatewayClassDetail(gatewayClassName string) (gatewayClass *unstructured.Unstructured, err error) {
+	gatewayClass, err = g.get("gatewayclasses", gatewayClassName, "")
+	if err != nil {
+		logger.Error(errors.New("获取GatewayClass: " + gatewayClassName + " 详情失败, " + err.Error()))
+		return nil, errors.New("获取GatewayClass详情失败, " + err.Error())
+	}
+	return gatewayClass, nil
+}
+
+func (g *gateway) CreateGatewayClass(data *GatewayClassCreate) (err error) {
+	spec := map[string]interface{}{
+		"controllerName": data.ControllerName,
+	}
+	if data.Description != "" {
+		spec["description"] = data.Description
+	}
+	gatewayClass := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name": data.Name,
+			},
+			"spec": spec,
+		},
+	}
+	if err = g.create("gatewayclasses", gatewayClass); err != nil {
+		logger.Error(errors.New("创建GatewayClass: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建GatewayClass失败, " + err.Error())
+	}
+	return nil
+}
+
+func (g *gateway) DeleteGatewayClass(gatewayClassName string) (err error) {
+	if err = g.delete("gatewayclasses", gatewayClassName, ""); err != nil {
+		logger.Error(errors.New("删除GatewayClass: " + gatewayClassName + " 失败, " + err.Error()))
+		return errors.New("删除GatewayClass失败, " + err.Error())
+	}
+	return nil
+}
+
+//获取Gateway列表, 支持过滤、排序、分页
+func (g *gateway) GetGateways(filterName, namespace string, limit, page int) (gatewayResp *GatewayResp, err error) {
+	gatewayResp, err = g.list("gateways", filterName, namespace, limit, page)
+	if err != nil {
+		logger.Error(errors.New("获取Gateway列表失败, " + err.Error()))
+		return nil, errors.New("获取Gateway列表失败, " + err.Error())
+	}
+	return gatewayResp, nil
+}
+
+func (g *gateway) GetGatewayDetail(gatewayName, namespace string) (gateway *unstructured.Unstructured, err error) {
+	gateway, err = g.get("gateways", gatewayName, namespace)
+	if err != nil {
+		logger.Error(errors.New("获取Gateway: " + gatewayName + " 详情失败, " + err.Error()))
+		return nil, errors.New("获取Gateway详情失败, " + err.Error())
+	}
+	return gateway, nil
+}
+
+func (g *gateway) CreateGateway(data *GatewayCreate) (err error) {
+	if len(data.Listeners) == 0 {
+		return errors.New("创建Gateway失败, 至少需要一个listener")
+	}
+	listeners := make([]interface{}, 0, len(data.Listeners))
+	for _, item := range data.Listeners {
+		listener := map[string]interface{}{
+			"name":     item.Name,
+			"port":     int64(item.Port),
+			"protocol": item.Protocol,
+		}
+		if item.Hostname != "" {
+			listener["hostname"] = item.Hostname
+		}
+		if item.Protocol == "HTTPS" || item.Protocol == "TLS" {
+			if item.TLSSecretName == "" {
+				return errors.New("创建Gateway失败, listener " + item.Name + " 需要指定tls_secret_name")
+			}
+			listener["tls"] = map[string]interface{}{
+				"mode": "Terminate",
+				"certificateRefs": []interface{}{
+					map[string]interface{}{"kind": "Secret", "name": item.TLSSecretName},
+				},
+			}
+		}
+		if item.AllowedRoutes != "" {
+			listener["allowedRoutes"] = map[string]interface{}{
+				"namespaces": map[string]interface{}{"from": item.AllowedRoutes},
+			}
+		}
+		listeners = append(listeners, listener)
+	}
+	gateway := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      data.Name,
+				"namespace": data.Namespace,
+			},
+			"spec": map[string]interface{}{
+				"gatewayClassName": data.GatewayClassName,
+				"listeners":        listeners,
+			},
+		},
+	}
+	if len(data.Labels) > 0 {
+		gateway.SetLabels(data.Labels)
+	}
+	if err = g.create("gateways", gateway); err != nil {
+		logger.Error(errors.New("创建Gateway: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建Gateway失败, " + err.Error())
+	}
+	return nil
+}
+
+func (g *gateway) DeleteGateway(gatewayName, namespace string) (err error) {
+	if err = g.delete("gateways", gatewayName, namespace); err != nil {
+		logger.Error(errors.New("删除Gateway: " + gatewayName + " 失败, " + err.Error()))
+		return errors.New("删除Gateway失败, " + err.Error())
+	}
+	return nil
+}
+
+//获取HTTPRoute列表, 支持过滤、排序、分页
+func (g *gateway) GetHTTPRoutes(filterName, namespace string, limit, page int) (gatewayResp *GatewayResp, err error) {
+	gatewayResp, err = g.list("httproutes", filterName, namespace, limit, page)
+	if err != nil {
+		logger.Error(errors.New("获取HTTPRoute列表失败, " + err.Error()))
+		return nil, errors.New("获取HTTPRoute列表失败, " + err.Error())
+	}
+	return gatewayResp, nil
+}
+
+//获取HTTPRoute详情, 同时返回在每个parentRef上的挂载状态
+func (g *gateway) GetHTTPRouteDetail(httpRouteName, namespace string) (detail *RouteDetail, err error) {
+	route, err := g.get("httproutes", httpRouteName, namespace)
+	if err != nil {
+		logger.Error(errors.New("获取HTTPRoute: " + httpRouteName + " 详情失败, " + err.Error()))
+		return nil, errors.New("获取HTTPRoute详情失败, " + err.Error())
+	}
+	return &RouteDetail{Route: route, Parents: routeParentStatus(route)}, nil
+}
+
+func (g *gateway) CreateHTTPRoute(data *HTTPRouteCreate) (err error) {
+	if err = g.create("httproutes", buildHTTPRoute(data)); err != nil {
+		logger.Error(errors.New("创建HTTPRoute: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建HTTPRoute失败, " + err.Error())
+	}
+	return nil
+}
+
+//applyHTTPRoute 存在则替换spec, 不存在则创建, 用于workflow更新
+func (g *gateway) applyHTTPRoute(data *HTTPRouteCreate) error {
+	gvr, err := g.gvr("httproutes")
+	if err != nil {
+		return err
+	}
+	client := resourceClient(gvr, true, data.Namespace)
+	live, err := client.Get(context.TODO(), data.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return g.create("httproutes", buildHTTPRoute(data))
+	}
+	if err != nil {
+		return err
+	}
+	desired := buildHTTPRoute(data)
+	live.Object["spec"] = desired.Object["spec"]
+	live.SetLabels(desired.GetLabels())
+	_, err = client.Update(context.TODO(), live, metav1.UpdateOptions{})
+	return err
+}
+
+func buildHTTPRoute(data *HTTPRouteCreate) *unstructured.Unstructured {
+	rules := make([]interface{}, 0, len(data.Rules))
+	for _, item := range data.Rules {
+		pathType := item.PathType
+		if pathType == "" {
+			pathType = "PathPrefix"
+		}
+		path := item.Path
+		if path == "" {
+			path = "/"
+		}
+		rules = append(rules, map[string]interface{}{
+			"matches": []interface{}{
+				map[string]interface{}{
+					"path": map[string]interface{}{"type": pathType, "value": path},
+				},
+			},
+			"backendRefs": []interface{}{routeBackendRef(item.ServiceName, item.ServicePort)},
+		})
+	}
+	return newRoute(data.Name, data.Namespace, data.Labels, data.ParentRefs, data.Hostnames, rules)
+}
+
+func (g *gateway) DeleteHTTPRoute(httpRouteName, namespace string) (err error) {
+	if err = g.delete("httproutes", httpRouteName, namespace); err != nil {
+		logger.Error(errors.New("删除HTTPRoute: " + httpRouteName + " 失败, " + err.Error()))
+		return errors.New("删除HTTPRoute失败, " + err.Error())
+	}
+	return nil
+}
+
+//获取GRPCRoute列表, 支持过滤、排序、分页
+func (g *gateway) GetGRPCRoutes(filterName, namespace string, limit, page int) (gatewayResp *GatewayResp, err error) {
+	gatewayResp, err = g.list("grpcroutes", filterName, namespace, limit, page)
+	if err != nil {
+		logger.Error(errors.New("获取GRPCRoute列表失败, " + err.Error()))
+		return nil, errors.New("获取GRPCRoute列表失败, " + err.Error())
+	}
+	return gatewayResp, nil
+}
+
+//获取GRPCRoute详情, 同时返回在每个parentRef上的挂载状态
+func (g *gateway) GetGRPCRouteDetail(grpcRouteName, namespace string) (detail *RouteDetail, err error) {
+	route, err := g.get("grpcroutes", grpcRouteName, namespace)
+	if err != nil {
+		logger.Error(errors.New("获取GRPCRoute: " + grpcRouteName + " 详情失败, " + err.Error()))
+		return nil, errors.New("获取GRPCRoute详情失败, " + err.Error())
+	}
+	return &RouteDetail{Route: route, Parents: routeParentStatus(route)}, nil
+}
+
+func (g *gateway) CreateGRPCRoute(data *GRPCRouteCreate) (err error) {
+	rules := make([]interface{}, 0, len(data.Rules))
+	for _, item := range data.Rules {
+		rule := map[string]interface{}{
+			"backendRefs": []interface{}{routeBackendRef(item.ServiceName, item.ServicePort)},
+		}
+		if item.GrpcService != "" || item.GrpcMethod != "" {
+			method := map[string]interface{}{"type": "Exact"}
+			if item.GrpcService != "" {
+				method["service"] = item.GrpcService
+			}
+			if item.GrpcMethod != "" {
+				method["method"] = item.GrpcMethod
+			}
+			rule["matches"] = []interface{}{map[string]interface{}{"method": method}}
+		}
+		rules = append(rules, rule)
+	}
+	route := newRoute(data.Name, data.Namespace, data.Labels, data.ParentRefs, data.Hostnames, rules)
+	if err = g.create("grpcroutes", route); err != nil {
+		logger.Error(errors.New("创建GRPCRoute: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建GRPCRoute失败, " + err.Error())
+	}
+	return nil
+}
+
+func (g *gateway) DeleteGRPCRoute(grpcRouteName, namespace string) (err error) {
+	if err = g.delete("grpcroutes", grpcRouteName, namespace); err != nil {
+		logger.Error(errors.New("删除GRPCRoute: " + grpcRouteName + " 失败, " + err.Error()))
+		return errors.New("删除GRPCRoute失败, " + err.Error())
+	}
+	return nil
+}
+
+func newRoute(name, namespace string, labels map[string]string, parentRefs []*RouteParentRef, hostnames []string, rules []interface{}) *unstructured.Unstructured {
+	parents := make([]interface{}, 0, len(parentRefs))
+	for _, parentRef := range parentRefs {
+		parent := map[string]interface{}{"name": parentRef.Name}
+		if parentRef.Namespace != "" {
+			parent["namespace"] = parentRef.Namespace
+		}
+		if parentRef.SectionName != "" {
+			parent["sectionName"] = parentRef.SectionName
+		}
+		parents = append(parents, parent)
+	}
+	spec := map[string]interface{}{
+		"parentRefs": parents,
+		"rules":      rules,
+	}
+	if len(hostnames) > 0 {
+		hosts := make([]interface{}, 0, len(hostnames))
+		for _, hostname := range hostnames {
+			hosts = append(hosts, hostname)
+		}
+		spec["hostnames"] = hosts
+	}
+	route := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+			},
+			"spec": spec,
+		},
+	}
+	if len(labels) > 0 {
+		route.SetLabels(labels)
+	}
+	return route
+}
+
+func routeBackendRef(serviceName string, servicePort int32) map[string]interface{} {
+	return map[string]interface{}{
+		"name": serviceName,
+		"port": int64(servicePort),
+	}
+}
+
+//routeParentStatus 按spec中的parentRef汇总挂载状态, status中没有记录的parentRef视为尚未被网关处理
+func routeParentStatus(route *unstructured.Unstructured) []*RouteParentStatus {
+	parents := make([]*RouteParentStatus, 0)
+	specParents, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	statusParents, _, _ := unstructured.NestedSlice(route.Object, "status", "parents")
+	for _, item := range specParents {
+		ref, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		parent := &RouteParentStatus{Conditions: make([]metav1.Condition, 0)}
+		parent.Name, _, _ = unstructured.NestedString(ref, "name")
+		parent.Namespace, _, _ = unstructured.NestedString(ref, "namespace")
+		parent.SectionName, _, _ = unstructured.NestedString(ref, "sectionName")
+		if parent.Namespace == "" {
+			parent.Namespace = route.GetNamespace()
+		}
+
+		for _, statusItem := range statusParents {
+			status, ok := statusItem.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(status, "parentRef", "name")
+			namespace, _, _ := unstructured.NestedString(status, "parentRef", "namespace")
+			sectionName, _, _ := unstructured.NestedString(status, "parentRef", "sectionName")
+			if namespace == "" {
+				namespace = route.GetNamespace()
+			}
+			if name != parent.Name || namespace != parent.Namespace || sectionName != parent.SectionName {
+				continue
+			}
+			parent.ControllerName, _, _ = unstructured.NestedString(status, "controllerName")
+			conditions, _, _ := unstructured.NestedSlice(status, "conditions")
+			for _, conditionItem := range conditions {
+				conditionMap, ok := conditionItem.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				condition := metav1.Condition{}
+				condition.Type, _, _ = unstructured.NestedString(conditionMap, "type")
+				conditionStatus, _, _ := unstructured.NestedString(conditionMap, "status")
+				condition.Status = metav1.ConditionStatus(conditionStatus)
+				condition.Reason, _, _ = unstructured.NestedString(conditionMap, "reason")
+				condition.Message, _, _ = unstructured.NestedString(conditionMap, "message")
+				parent.Conditions = append(parent.Conditions, condition)
+				if condition.Type == "Accepted" && condition.Status == metav1.ConditionTrue {
+					parent.Attached = true
+				}
+			}
+		}
+		parents = append(parents, parent)
+	}
+	return parents
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"test4/config"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+var GenericResource genericResource
+
+type genericResource struct{}
+
+//ResourceRow 列表中的一行, Cells与Columns一一对应
+type ResourceRow struct {
+	Object unstructured.Unstructured `json:"object"`
+	Cells  []interface{}             `json:"cells"`
+}
+
+type GenericResourceResp struct {
+	Kind       string           `json:"kind"`
+	Namespaced bool             `json:"namespaced"`
+	Columns    []*PrinterColumn `json:"columns"`
+	Items      []*ResourceRow   `json:"items"`
+	Total      int              `json:"total"`
+}
+
+func (g *genericResource) toCells(std []unstructured.Unstructured) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = UnstructuredCell(std[i])
+	}
+	return cells
+}
+
+func (g *genericResource) fromCells(cells []DataCell) []unstructured.Unstructured {
+	items := make([]unstructured.Unstructured, len(cells))
+	for i := range cells {
+		items[i] = unstructured.Unstructured(cells[i].(UnstructuredCell))
+	}
+	return items
+}
+
+//discover 通过discovery确认资源存在, 并获取kind和是否为namespace级别资源
+func (g *genericResource) discover(gvr schema.GroupVersionResource) (*metav1.APIResource, error) {
+	resourceList, err := K8s.Clientset.Discovery().ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return nil, err
+	}
+	for i := range resourceList.APIResources {
+		if resourceList.APIResources[i].Name == gvr.Resource {
+			return &resourceList.APIResources[i], nil
+		}
+	}
+	return nil, errors.New("资源 " + gvr.String() + " 不存在")
+}
+
+//resourceClient 集群级别资源忽略namespace
+func resourceClient(gvr schema.GroupVersionResource, namespaced bool, namespace string) dynamic.ResourceInterface {
+	return clusterResourceClient(K8s.Dynamic, gvr, namespaced, namespace)
+}
+
+//clusterResourceClient 使用指定集群的dynamic client, 用于跨集群克隆
+func clusterResourceClient(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, namespaced bool, namespace string) dynamic.ResourceInterface {
+	if namespaced {
+		return dynamicClient.Resource(gvr).Namespace(namespace)
+	}
+	return dynamicClient.Resource(gvr)
+}
+
+//获取任意资源列表, 支持过滤、排序、分页, 自定义资源按CRD的additionalPrinterColumns计算每列的值
+//namespace为空时获取所有namespace的资源
+func (g *genericResource) GetResources(group, version, resource, namespace, filterName string, limit, page int) (resp *GenericResourceResp, err error) {
+	gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+	apiResource, err := g.discover(gvr)
+	if err != nil {
+		logger.Error(errors.New("获取资源列表失败, " + err.Error()))
+		return nil, errors.New("获取资源列表失败, " + err.Error())
+	}
+	list, err := resourceClient(gvr, apiResource.Namespaced, namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取" + apiResource.Kind + "列表失败, " + err.Error()))
+		return nil, errors.New("获取" + apiResource.Kind + "列表失败, " + err.Error())
+	}
+	columns, err := Crd.getPrinterColumns(group, version, resource)
+	if err != nil {
+		logger.Error(errors.New("获取" + apiResource.Kind + "的printer columns失败, " + err.Error()))
+		return nil, errors.New("获取" + apiResource.Kind + "的printer columns失败, " + err.Error())
+	}
+
+	selectableData := &dataSelector{
+		GenericDataList: g.toCells(list.Items),
+		DataSelectQuery: &DataSelectQuery{
+			FilterQuery: &FilterQuery{Name: filterName},
+			PaginateQuery: &PaginateQuery{
+				Limit: limit,
+				Page:  page,
+			},
+		},
+	}
+
+	filtered := selectableData.Filter()
+	total := len(filtered.GenericDataList)
+	data := filtered.Sort().Paginate()
+
+	items := g.fromCells(data.GenericDataList)
+	rows := make([]*ResourceRow, 0, len(items))
+	for i := range items {
+		//Secret和敏感ConfigMap与专用接口一样脱敏, 明文需通过RevealSecret查看
+		if err := maskSensitiveObject(&items[i]); err != nil {
+			logger.Error(errors.New("脱敏" + apiResource.Kind + ": " + items[i].GetName() + " 失败, " + err.Error()))
+			return nil, errors.New("脱敏" + apiResource.Kind + ": " + items[i].GetName() + " 失败, " + err.Error())
+		}
+		row := &ResourceRow{Object: items[i]}
+		for _, column := range columns {
+			row.Cells = append(row.Cells, columnValue(items[i].Object, column.JSONPath))
+		}
+		rows = append(rows, row)
+	}
+	return &GenericResourceResp{
+		Kind:       apiResource.Kind,
+		Namespaced: apiResource.Namespaced,
+		Columns:    columns,
+		Items:      rows,
+		Total:      total,
+	}, nil
+}
+
+//获取任意资源详情
+func (g *genericResource) GetResourceDetail(group, version, resource, namespace, name string) (detail *unstructured.Unstructured, err error) {
+	gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+	apiResource, err := g.discover(gvr)
+	if err != nil {
+		logger.Error(errors.New("获取资源详情失败, " + err.Error()))
+		return nil, errors.New("获取资源详情失败, " + err.Error())
+	}
+	detail, err = resourceClient(gvr, apiResource.Namespaced, namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取" + apiResource.Kind + ": " + name + " 详情失败, " + err.Error()))
+		return nil, errors.New("获取" + apiResource.Kind + ": " + name + " 详情失败, " + err.Error())
+	}
+	if err = maskSensitiveObject(detail); err != nil {
+		logger.Error(errors.New("脱敏" + apiResource.Kind + ": " + name + " 失败, " + err.Error()))
+		return nil, errors.New("脱敏" + apiResource.Kind + ": " + name + " 失败, " + err.Error())
+	}
+	return detail, nil
+}
+
+//删除任意资源
+func (g *genericResource) DeleteResource(group, version, resource, namespace, name string) (err error) {
+	gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+	apiResource, err := g.discover(gvr)
+	if err != nil {
+		logger.Error(errors.New("删除资源失败, " + err.Error()))
+		return errors.New("删除资源失败, " + err.Error())
+	}
+	err = resourceClient(gvr, apiResource.Namespaced, namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil {
+		logger.Error(errors.New("删除" + apiResource.Kind + ": " + name + " 失败, " + err.Error()))
+		return errors.New("删除" + apiResource.Kind + ": " + name + " 失败, " + err.Error())
+	}
+	return nil
+}
+
+//应用任意资源, content为单个对象的yaml或json, 使用server-side apply, 不存在则创建
+func (g *genericResource) ApplyResource(content string, dryRun bool) (applied *unstructured.Unstructured, err error) {
+	objs, err := decodeObjects([]byte(content))
+	if err != nil {
+		logger.Error(errors.New("解析资源内容失败, " + err.Error()))
+		return nil, errors.New("解析资源内容失败, " + err.Error())
+	}
+	if len(objs) != 1 {
+		return nil, fmt.Errorf("一次只能应用一个资源, 当前为%d个", len(objs))
+	}
+	//详情接口返回的Secret和敏感ConfigMap是脱敏的, 仍为占位值的key保留集群中的原值
+	if err = restoreMaskedObject(objs[0]); err != nil {
+		logger.Error(errors.New("应用" + objs[0].GetKind() + ": " + objs[0].GetName() + " 失败, " + err.Error()))
+		return nil, errors.New("应用" + objs[0].GetKind() + ": " + objs[0].GetName() + " 失败, " + err.Error())
+	}
+	mapper, err := newRESTMapper()
+	if err != nil {
+		logger.Error(errors.New("获取API资源映射失败, " + err.Error()))
+		return nil, errors.New("获取API资源映射失败, " + err.Error())
+	}
+	applied, err = applyObject(mapper, objs[0], dryRun)
+	if err != nil {
+		logger.Error(errors.New("应用" + objs[0].GetKind() + ": " + objs[0].GetName() + " 失败, " + err.Error()))
+		return nil, errors.New("应用" + objs[0].GetKind() + ": " + objs[0].GetName() + " 失败, " + err.Error())
+	}
+	if err = maskSensitiveObject(applied); err != nil {
+		logger.Error(errors.New("脱敏" + applied.GetKind() + ": " + applied.GetName() + " 失败, " + err.Error()))
+		return nil, errors.New("脱敏" + applied.GetKind() + ": " + applied.GetName() + " 失败, " + err.Error())
+	}
+	return applied, nil
+}
+
+//maskSensitiveObject 对core/v1的Secret和敏感ConfigMap使用与专用接口相同的规则脱敏, 其他资源原样返回
+func maskSensitiveObject(obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	if gvk.Group != "" || gvk.Version != "v1" {
+		return nil
+	}
+	switch gvk.Kind {
+	case "Secret":
+		typed := &corev1.Secret{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed); err != nil {
+			return err
+		}
+		Secret.maskSecret(typed)
+		return setUnstructured(obj, typed)
+	case "ConfigMap":
+		typed := &corev1.ConfigMap{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed); err != nil {
+			return err
+		}
+		ConfigMap.maskConfigMap(typed)
+		return setUnstructured(obj, typed)
+	}
+	return nil
+}
+
+//restoreMaskedObject 将core/v1 Secret和敏感ConfigMap中仍为占位值的key替换为集群中的原值
+//集群中不存在对应原值时报错, 避免把占位值写入集群
+func restoreMaskedObject(obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	if gvk.Group != "" || gvk.Version != "v1" || (gvk.Kind != "Secret" && gvk.Kind != "ConfigMap") {
+		return nil
+	}
+	namespace := obj.GetNamespace()
+	if namespace == "" {
+		namespace = "default"
+	}
+	encodedMask := base64.StdEncoding.EncodeToString([]byte(config.MaskValue))
+	switch gvk.Kind {
+	case "Secret":
+		data, _, _ := unstructured.NestedStringMap(obj.Object, "data")
+		stringData, _, _ := unstructured.NestedStringMap(obj.Object, "stringData")
+		masked := make([]string, 0)
+		for key, value := range data {
+			if value == encodedMask {
+				masked = append(masked, key)
+			}
+		}
+		for key, value := range stringData {
+			if value == config.MaskValue {
+				masked = append(masked, key)
+				delete(stringData, key)
+			}
+		}
+		if len(masked) == 0 {
+			return nil
+		}
+		current, err := K8s.Clientset.CoreV1().Secrets(namespace).Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return errors.New("内容包含脱敏占位值, 获取集群中的Secret失败, " + err.Error())
+		}
+		if data == nil {
+			data = map[string]string{}
+		}
+		for _, key := range masked {
+			value, ok := current.Data[key]
+			if !ok {
+				return errors.New("key " + key + " 为脱敏占位值, 但集群中不存在原值")
+			}
+			data[key] = base64.StdEncoding.EncodeToString(value)
+		}
+		if err := unstructured.SetNestedStringMap(obj.Object, data, "data"); err != nil {
+			return err
+		}
+		if len(stringData) == 0 {
+			unstructured.RemoveNestedField(obj.Object, "stringData")
+			return nil
+		}
+		return unstructured.SetNestedStringMap(obj.Object, stringData, "stringData")
+	case "ConfigMap":
+		data, _, _ := unstructured.NestedStringMap(obj.Object, "data")
+		binaryData, _, _ := unstructured.NestedStringMap(obj.Object, "binaryData")
+		masked := false
+		for _, value := range data {
+			masked = masked || value == config.MaskValue
+		}
+		for _, value := range binaryData {
+			masked = masked || value == encodedMask
+		}
+		if !masked {
+			return nil
+		}
+		current, err := K8s.Clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) && obj.GetAnnotations()[config.SensitiveAnnotation] != "true" {
+			return nil
+		}
+		if err != nil {
+			return errors.New("内容包含脱敏占位值, 获取集群中的ConfigMap失败, " + err.Error())
+		}
+		//非敏感ConfigMap不会被脱敏, 占位值按普通内容处理
+		if !isSensitiveConfigMap(current) {
+			return nil
+		}
+		for key, value := range data {
+			if value != config.MaskValue {
+				continue
+			}
+			original, ok := current.Data[key]
+			if !ok {
+				return errors.New("key " + key + " 为脱敏占位值, 但集群中不存在原值")
+			}
+			data[key] = original
+		}
+		for key, value := range binaryData {
+			if value != encodedMask {
+				continue
+			}
+			original, ok := current.BinaryData[key]
+			if !ok {
+				return errors.New("key " + key + " 为脱敏占位值, 但集群中不存在原值")
+			}
+			binaryData[key] = base64.StdEncoding.EncodeToString(original)
+		}
+		if len(data) > 0 {
+			if err := unstructured.SetNestedStringMap(obj.Object, data, "data"); err != nil {
+				return err
+			}
+		}
+		if len(binaryData) > 0 {
+			return unstructured.SetNestedStringMap(obj.Object, binaryData, "binaryData")
+		}
+	}
+	return nil
+}
+
+//setUnstructured 将脱敏后的typed对象写回unstructured, 转换会丢失apiVersion和kind, 需要补回
+func setUnstructured(obj *unstructured.Unstructured, typed interface{}) error {
+	gvk := obj.GroupVersionKind()
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(typed)
+	if err != nil {
+		return err
+	}
+	obj.Object = content
+	obj.SetGroupVersionKind(gvk)
+	return nil
+}
+
+//decodeObjects 解析yaml或json, 支持---分隔的多个对象, 跳过空文档
+func decodeObjects(content []byte) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	objs := make([]*unstructured.Unstructured, 0)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+			return nil, errors.New("资源缺少apiVersion或kind")
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+//newRESTMapper 根据discovery结果构建kind到resource的映射
+func newRESTMapper() (meta.RESTMapper, error) {
+	groupResources, err := restmapper.GetAPIGroupResources(K8s.Clientset.Discovery())
+	if err != nil {
+		return nil, err
+	}
+	return restmapper.NewDiscoveryRESTMapper(groupResources), nil
+}
+
+//applyObject 使用server-side apply应用单个对象, namespace级别资源未指定namespace时使用default
+func applyObject(mapper meta.RESTMapper, obj *unstructured.Unstructured, dryRun bool) (*unstructured.Unstructured, error) {
+	return applyClusterObject(K8s.Dynamic, mapper, obj, dryRun)
+}
+
+//applyClusterObject 将对象应用到指定的集群, mapper需来自同一个集群
+func applyClusterObject(dynamicClient dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured, dryRun bool) (*unstructured.Unstructured, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	if namespaced && obj.GetNamespace() == "" {
+		obj.SetNamespace("default")
+	}
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	force := true
+	return clusterResourceClient(dynamicClient, mapping.Resource, namespaced, obj.GetNamespace()).Patch(context.TODO(), obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: config.FieldManager,
+		Force:        &force,
+		DryRun:       dryRunOption(dryRun),
+	})
+}
+
+//columnValue 按kubectl的方式计算printer column的值, 多个结果时返回列表
+func columnValue(obj map[string]interface{}, path string) interface{} {
+	parser := jsonpath.New("column").AllowMissingKeys(true)
+	if err := parser.Parse(fmt.Sprintf("{%s}", path)); err != nil {
+		return nil
+	}
+	results, err := parser.FindResults(obj)
+	if err != nil || len(results) == 0 || len(results[0]) == 0 {
+		return nil
+	}
+	if len(results[0]) == 1 {
+		return results[0][0].Interface()
+	}
+	values := make([]interface{}, 0, len(results[0]))
+	for _, result := range results[0] {
+		values = append(values, result.Interface())
+	}
+	return values
+}
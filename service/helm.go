@@ -0,0 +1,449 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"test4/config"
+
+	"github.com/wonderivan/logger"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/yaml"
+)
+
+var Helm helm
+
+type helm struct{}
+
+//HelmReleaseItem release列表中的一项, history中的每个版本也使用该结构
+type HelmReleaseItem struct {
+	Name         string    `json:"name"`
+	Namespace    string    `json:"namespace"`
+	Revision     int       `json:"revision"`
+	Status       string    `json:"status"`
+	Chart        string    `json:"chart"`
+	ChartVersion string    `json:"chart_version"`
+	AppVersion   string    `json:"app_version"`
+	Description  string    `json:"description"`
+	Updated      time.Time `json:"updated"`
+}
+
+type HelmReleasesResp struct {
+	Items []*HelmReleaseItem `json:"items"`
+	Total int                `json:"total"`
+}
+
+//HelmReleaseDetail release详情, Values为用户指定的values, ComputedValues为合并chart默认值后的values
+type HelmReleaseDetail struct {
+	*HelmReleaseItem
+	Values         map[string]interface{} `json:"values"`
+	ComputedValues map[string]interface{} `json:"computed_values"`
+	Manifest       string                 `json:"manifest"`
+	Notes          string                 `json:"notes"`
+	History        []*HelmReleaseItem     `json:"history"`
+}
+
+//HelmReleaseCreate install和upgrade的参数
+//Chart可以是本地chart目录或压缩包路径、仓库中的repo/chart、指定RepoURL时的chart名, 以及oci://开头的OCI地址
+//上传chart压缩包时忽略Chart、RepoURL和Version
+//ValuesYaml与Values同时指定时, Values中的值优先
+type HelmReleaseCreate struct {
+	Name            string                 `json:"name" form:"name"`
+	Namespace       string                 `json:"namespace" form:"namespace"`
+	Chart           string                 `json:"chart" form:"chart"`
+	RepoURL         string                 `json:"repo_url" form:"repo_url"`
+	Version         string                 `json:"version" form:"version"`
+	Values          map[string]interface{} `json:"values"`
+	ValuesYaml      string                 `json:"values_yaml" form:"values_yaml"`
+	CreateNamespace bool                   `json:"create_namespace" form:"create_namespace"`
+	Wait            bool                   `json:"wait" form:"wait"`
+	Timeout         int                    `json:"timeout" form:"timeout"`
+	Description     string                 `json:"description" form:"description"`
+	//upgrade时release不存在则安装
+	Install bool `json:"install" form:"install"`
+}
+
+//localChartDir 允许安装本地chart的目录, 默认为config.HelmLocalChartDir, 测试时可替换为testdata目录
+var localChartDir = config.HelmLocalChartDir
+
+//helmActionConfig 创建操作指定namespace的helm配置, namespace为空时操作所有namespace
+//测试时可替换为使用fake kube client和内存存储的配置
+var helmActionConfig = func(namespace string) (*action.Configuration, error) {
+	settings := helmSettings()
+	flags := genericclioptions.NewConfigFlags(false)
+	kubeconfig := config.Kubeconfig
+	flags.KubeConfig = &kubeconfig
+	flags.Namespace = &namespace
+
+	actionConfig := new(action.Configuration)
+	err := actionConfig.Init(flags, namespace, config.HelmDriver, func(format string, v ...interface{}) {
+		logger.Debug(fmt.Sprintf(format, v...))
+	})
+	if err != nil {
+		return nil, err
+	}
+	registryClient, err := registry.NewClient(registry.ClientOptCredentialsFile(settings.RegistryConfig))
+	if err != nil {
+		return nil, err
+	}
+	actionConfig.RegistryClient = registryClient
+	return actionConfig, nil
+}
+
+//helmSettings helm命令行的环境配置, 用于查找仓库和下载chart
+func helmSettings() *cli.EnvSettings {
+	settings := cli.New()
+	if config.HelmRepositoryConfig != "" {
+		settings.RepositoryConfig = config.HelmRepositoryConfig
+	}
+	if config.HelmRepositoryCache != "" {
+		settings.RepositoryCache = config.HelmRepositoryCache
+	}
+	if config.HelmRegistryConfig != "" {
+		settings.RegistryConfig = config.HelmRegistryConfig
+	}
+	return settings
+}
+
+func (h *helm) toCells(std []*HelmReleaseItem) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = HelmReleaseCell(*std[i])
+	}
+	return cells
+}
+
+func (h *helm) fromCells(cells []DataCell) []*HelmReleaseItem {
+	items := make([]*HelmReleaseItem, len(cells))
+	for i := range cells {
+		item := HelmReleaseItem(cells[i].(HelmReleaseCell))
+		items[i] = &item
+	}
+	return items
+}
+
+//获取release列表, 包含所有状态的release, 按最后部署时间倒序
+func (h *helm) GetReleases(filterName, namespace string, limit, page int) (releasesResp *HelmReleasesResp, err error) {
+	actionConfig, err := helmActionConfig(namespace)
+	if err != nil {
+		logger.Error(errors.New("创建helm配置失败, " + err.Error()))
+		return nil, errors.New("创建helm配置失败, " + err.Error())
+	}
+	client := action.NewList(actionConfig)
+	client.All = true
+	client.AllNamespaces = namespace == ""
+	client.SetStateMask()
+	releases, err := client.Run()
+	if err != nil {
+		logger.Error(errors.New("获取helm release列表失败, " + err.Error()))
+		return nil, errors.New("获取helm release列表失败, " + err.Error())
+	}
+
+	items := make([]*HelmReleaseItem, 0, len(releases))
+	for _, rel := range releases {
+		items = append(items, toHelmReleaseItem(rel))
+	}
+	selectableData := &dataSelector{
+		GenericDataList: h.toCells(items),
+		DataSelectQuery: &DataSelectQuery{
+			FilterQuery: &FilterQuery{Name: filterName},
+			PaginateQuery: &PaginateQuery{
+				Limit: limit,
+				Page:  page,
+			},
+		},
+	}
+	filtered := selectableData.Filter()
+	total := len(filtered.GenericDataList)
+	data := filtered.Sort().Paginate()
+
+	return &HelmReleasesResp{
+		Items: h.fromCells(data.GenericDataList),
+		Total: total,
+	}, nil
+}
+
+//获取release详情, 包括values、manifest、notes和历史版本
+func (h *helm) GetReleaseDetail(name, namespace string) (detail *HelmReleaseDetail, err error) {
+	actionConfig, err := helmActionConfig(namespace)
+	if err != nil {
+		logger.Error(errors.New("创建helm配置失败, " + err.Error()))
+		return nil, errors.New("创建helm配置失败, " + err.Error())
+	}
+	rel, err := action.NewGet(actionConfig).Run(name)
+	if err != nil {
+		logger.Error(errors.New("获取helm release: " + name + " 详情失败, " + err.Error()))
+		return nil, errors.New("获取helm release详情失败, " + err.Error())
+	}
+	computedValues, err := chartutil.CoalesceValues(rel.Chart, rel.Config)
+	if err != nil {
+		logger.Error(errors.New("计算helm release: " + name + " 的values失败, " + err.Error()))
+		return nil, errors.New("计算helm release的values失败, " + err.Error())
+	}
+	history, err := h.GetReleaseHistory(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	detail = &HelmReleaseDetail{
+		HelmReleaseItem: toHelmReleaseItem(rel),
+		Values:          rel.Config,
+		ComputedValues:  computedValues,
+		Manifest:        rel.Manifest,
+		History:         history,
+	}
+	if rel.Info != nil {
+		detail.Notes = rel.Info.Notes
+	}
+	return detail, nil
+}
+
+//获取release的历史版本, 按版本号倒序
+func (h *helm) GetReleaseHistory(name, namespace string) (history []*HelmReleaseItem, err error) {
+	actionConfig, err := helmActionConfig(namespace)
+	if err != nil {
+		logger.Error(errors.New("创建helm配置失败, " + err.Error()))
+		return nil, errors.New("创建helm配置失败, " + err.Error())
+	}
+	releases, err := action.NewHistory(actionConfig).Run(name)
+	if err != nil {
+		logger.Error(errors.New("获取helm release: " + name + " 历史版本失败, " + err.Error()))
+		return nil, errors.New("获取helm release历史版本失败, " + err.Error())
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Version > releases[j].Version
+	})
+	history = make([]*HelmReleaseItem, 0, len(releases))
+	for _, rel := range releases {
+		history = append(history, toHelmReleaseItem(rel))
+	}
+	return history, nil
+}
+
+//安装chart, archive不为空时使用上传的chart压缩包
+func (h *helm) InstallRelease(data *HelmReleaseCreate, archive []byte) (item *HelmReleaseItem, err error) {
+	actionConfig, err := helmActionConfig(data.Namespace)
+	if err != nil {
+		logger.Error(errors.New("创建helm配置失败, " + err.Error()))
+		return nil, errors.New("创建helm配置失败, " + err.Error())
+	}
+	client := action.NewInstall(actionConfig)
+	client.ReleaseName = data.Name
+	client.Namespace = data.Namespace
+	client.CreateNamespace = data.CreateNamespace
+	client.Wait = data.Wait
+	client.Timeout = helmTimeout(data.Timeout)
+	client.Description = data.Description
+	client.RepoURL = data.RepoURL
+	client.Version = data.Version
+
+	chrt, values, err := loadHelmChart(&client.ChartPathOptions, data, archive)
+	if err != nil {
+		logger.Error(errors.New("安装helm release: " + data.Name + " 失败, " + err.Error()))
+		return nil, errors.New("安装helm release失败, " + err.Error())
+	}
+	rel, err := client.Run(chrt, values)
+	if err != nil {
+		logger.Error(errors.New("安装helm release: " + data.Name + " 失败, " + err.Error()))
+		return nil, errors.New("安装helm release失败, " + err.Error())
+	}
+	return toHelmReleaseItem(rel), nil
+}
+
+//升级release, Install为true且release不存在时安装
+func (h *helm) UpgradeRelease(data *HelmReleaseCreate, archive []byte) (item *HelmReleaseItem, err error) {
+	actionConfig, err := helmActionConfig(data.Namespace)
+	if err != nil {
+		logger.Error(errors.New("创建helm配置失败, " + err.Error()))
+		return nil, errors.New("创建helm配置失败, " + err.Error())
+	}
+	if data.Install {
+		_, err = actionConfig.Releases.History(data.Name)
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			return h.InstallRelease(data, archive)
+		}
+		if err != nil {
+			logger.Error(errors.New("获取helm release: " + data.Name + " 历史版本失败, " + err.Error()))
+			return nil, errors.New("获取helm release历史版本失败, " + err.Error())
+		}
+	}
+
+	client := action.NewUpgrade(actionConfig)
+	client.Namespace = data.Namespace
+	client.Wait = data.Wait
+	client.Timeout = helmTimeout(data.Timeout)
+	client.Description = data.Description
+	client.RepoURL = data.RepoURL
+	client.Version = data.Version
+
+	chrt, values, err := loadHelmChart(&client.ChartPathOptions, data, archive)
+	if err != nil {
+		logger.Error(errors.New("升级helm release: " + data.Name + " 失败, " + err.Error()))
+		return nil, errors.New("升级helm release失败, " + err.Error())
+	}
+	rel, err := client.Run(data.Name, chrt, values)
+	if err != nil {
+		logger.Error(errors.New("升级helm release: " + data.Name + " 失败, " + err.Error()))
+		return nil, errors.New("升级helm release失败, " + err.Error())
+	}
+	return toHelmReleaseItem(rel), nil
+}
+
+//回滚release到指定版本, revision为0时回滚到上一个版本
+func (h *helm) RollbackRelease(name, namespace string, revision int, wait bool, timeout int) (err error) {
+	actionConfig, err := helmActionConfig(namespace)
+	if err != nil {
+		logger.Error(errors.New("创建helm配置失败, " + err.Error()))
+		return errors.New("创建helm配置失败, " + err.Error())
+	}
+	client := action.NewRollback(actionConfig)
+	client.Version = revision
+	client.Wait = wait
+	client.Timeout = helmTimeout(timeout)
+	if err = client.Run(name); err != nil {
+		logger.Error(errors.New("回滚helm release: " + name + " 失败, " + err.Error()))
+		return errors.New("回滚helm release失败, " + err.Error())
+	}
+	return nil
+}
+
+//卸载release, keepHistory为true时保留历史版本, 之后可以回滚
+func (h *helm) UninstallRelease(name, namespace string, keepHistory bool) (err error) {
+	actionConfig, err := helmActionConfig(namespace)
+	if err != nil {
+		logger.Error(errors.New("创建helm配置失败, " + err.Error()))
+		return errors.New("创建helm配置失败, " + err.Error())
+	}
+	client := action.NewUninstall(actionConfig)
+	client.KeepHistory = keepHistory
+	client.Timeout = helmTimeout(0)
+	if _, err = client.Run(name); err != nil {
+		logger.Error(errors.New("卸载helm release: " + name + " 失败, " + err.Error()))
+		return errors.New("卸载helm release失败, " + err.Error())
+	}
+	return nil
+}
+
+//loadHelmChart 加载chart并合并values
+//未上传压缩包时按Chart定位chart, 仓库和OCI中的chart会先下载到本地缓存
+func loadHelmChart(pathOptions *action.ChartPathOptions, data *HelmReleaseCreate, archive []byte) (*chart.Chart, map[string]interface{}, error) {
+	var (
+		chrt *chart.Chart
+		err  error
+	)
+	if len(archive) > 0 {
+		chrt, err = loader.LoadArchive(bytes.NewReader(archive))
+		if err != nil {
+			return nil, nil, errors.New("解析chart压缩包失败, " + err.Error())
+		}
+	} else {
+		if data.Chart == "" {
+			return nil, nil, errors.New("chart不能为空")
+		}
+		if err = checkLocalChartPath(data.Chart); err != nil {
+			return nil, nil, err
+		}
+		var chartPath string
+		chartPath, err = pathOptions.LocateChart(data.Chart, helmSettings())
+		if err != nil {
+			return nil, nil, errors.New("查找chart失败, " + err.Error())
+		}
+		chrt, err = loader.Load(chartPath)
+		if err != nil {
+			return nil, nil, errors.New("加载chart失败, " + err.Error())
+		}
+	}
+	if chrt.Metadata.Type != "" && chrt.Metadata.Type != "application" {
+		return nil, nil, errors.New("chart类型为" + chrt.Metadata.Type + ", 不能安装")
+	}
+	//依赖需要预先打包在chart的charts目录中
+	if dependencies := chrt.Metadata.Dependencies; dependencies != nil {
+		if err = action.CheckDependencies(chrt, dependencies); err != nil {
+			return nil, nil, errors.New("chart依赖缺失, " + err.Error())
+		}
+	}
+
+	values := map[string]interface{}{}
+	if data.ValuesYaml != "" {
+		if err = yaml.Unmarshal([]byte(data.ValuesYaml), &values); err != nil {
+			return nil, nil, errors.New("解析values_yaml失败, " + err.Error())
+		}
+	}
+	if data.Values != nil {
+		values = chartutil.CoalesceTables(data.Values, values)
+	}
+	return chrt, values, nil
+}
+
+//checkLocalChartPath LocateChart会优先使用dashboard所在主机上存在的路径
+//本地路径只允许位于config.HelmLocalChartDir下, 避免加载主机上任意目录的内容
+func checkLocalChartPath(name string) error {
+	if strings.HasPrefix(name, "oci://") {
+		return nil
+	}
+	if _, err := os.Stat(name); err != nil && !filepath.IsAbs(name) {
+		return nil
+	}
+	if localChartDir == "" {
+		return errors.New("不允许使用本地chart路径, 请上传chart压缩包或使用仓库中的chart")
+	}
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return errors.New("解析chart路径失败, " + err.Error())
+	}
+	dir, err := filepath.Abs(localChartDir)
+	if err != nil {
+		return errors.New("解析本地chart目录失败, " + err.Error())
+	}
+	//解析符号链接, 避免通过目录内的链接指向其他位置
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errors.New("chart路径 " + name + " 不在允许的本地chart目录下")
+	}
+	return nil
+}
+
+func helmTimeout(timeout int) time.Duration {
+	if timeout <= 0 {
+		timeout = config.HelmTimeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+func toHelmReleaseItem(rel *release.Release) *HelmReleaseItem {
+	item := &HelmReleaseItem{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+	}
+	if rel.Info != nil {
+		item.Status = rel.Info.Status.String()
+		item.Description = rel.Info.Description
+		item.Updated = rel.Info.LastDeployed.Time
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		item.Chart = rel.Chart.Metadata.Name
+		item.ChartVersion = rel.Chart.Metadata.Version
+		item.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+	return item
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+//useFakeHelm 使用不连接集群的kube client和内存存储替换helm配置, 本地chart只允许使用testdata目录
+func useFakeHelm(t *testing.T) {
+	store := storage.Init(driver.NewMemory())
+	originConfig, originChartDir := helmActionConfig, localChartDir
+	helmActionConfig = func(namespace string) (*action.Configuration, error) {
+		return &action.Configuration{
+			Releases:     store,
+			KubeClient:   &kubefake.PrintingKubeClient{Out: io.Discard},
+			Capabilities: chartutil.DefaultCapabilities,
+			Log:          func(format string, v ...interface{}) {},
+		}, nil
+	}
+	localChartDir = "testdata/helm"
+	t.Cleanup(func() {
+		helmActionConfig, localChartDir = originConfig, originChartDir
+	})
+}
+
+func TestHelmInstallUpgradeRollback(t *testing.T) {
+	useFakeHelm(t)
+
+	item, err := Helm.InstallRelease(&HelmReleaseCreate{Name: "demo", Namespace: "default", Chart: "testdata/helm/demo"}, nil)
+	if err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if item.Revision != 1 || item.Status != "deployed" || item.Chart != "demo" || item.ChartVersion != "0.1.0" {
+		t.Fatalf("install: unexpected release %+v", item)
+	}
+
+	item, err = Helm.UpgradeRelease(&HelmReleaseCreate{
+		Name:      "demo",
+		Namespace: "default",
+		Chart:     "testdata/helm/demo",
+		Values:    map[string]interface{}{"message": "v2"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	if item.Revision != 2 {
+		t.Fatalf("upgrade: revision = %d, want 2", item.Revision)
+	}
+	detail, err := Helm.GetReleaseDetail("demo", "default")
+	if err != nil {
+		t.Fatalf("detail: %v", err)
+	}
+	if detail.Values["message"] != "v2" || !strings.Contains(detail.Manifest, `message: "v2"`) {
+		t.Fatalf("detail after upgrade: values = %v, manifest = %q", detail.Values, detail.Manifest)
+	}
+
+	if err = Helm.RollbackRelease("demo", "default", 1, false, 0); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	detail, err = Helm.GetReleaseDetail("demo", "default")
+	if err != nil {
+		t.Fatalf("detail: %v", err)
+	}
+	if detail.Revision != 3 || detail.ComputedValues["message"] != "v1" || !strings.Contains(detail.Manifest, `message: "v1"`) {
+		t.Fatalf("detail after rollback: revision = %d, computed values = %v", detail.Revision, detail.ComputedValues)
+	}
+	if len(detail.History) != 3 || detail.History[0].Revision != 3 || detail.History[2].Revision != 1 {
+		t.Fatalf("history: got %d entries, want revisions 3, 2, 1", len(detail.History))
+	}
+}
+
+func TestHelmUpgradeInstallsMissingRelease(t *testing.T) {
+	useFakeHelm(t)
+
+	item, err := Helm.UpgradeRelease(&HelmReleaseCreate{Name: "fresh", Namespace: "default", Chart: "testdata/helm/demo", Install: true}, nil)
+	if err != nil {
+		t.Fatalf("upgrade --install: %v", err)
+	}
+	if item.Revision != 1 {
+		t.Fatalf("upgrade --install: revision = %d, want 1", item.Revision)
+	}
+}
+
+func TestHelmRejectsChartOutsideLocalChartDir(t *testing.T) {
+	useFakeHelm(t)
+
+	for _, chart := range []string{"testdata", "/etc", "testdata/helm/../.."} {
+		if _, err := Helm.InstallRelease(&HelmReleaseCreate{Name: "demo", Namespace: "default", Chart: chart}, nil); err == nil {
+			t.Errorf("install from %q: expected error", chart)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/wonderivan/logger"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var HorizontalPodAutoscaler horizontalPodAutoscaler
+
+type horizontalPodAutoscaler struct{}
+
+type HorizontalPodAutoscalerResp struct {
+	Items []autoscalingv2.HorizontalPodAutoscaler `json:"items"`
+	Total int                                     `json:"total"`
+}
+
+// 定义HpaCreate结构体, 用于创建hpa需要的参数属性的定义
+// TargetKind为Deployment或StatefulSet, Behavior为扩缩容策略, 不传使用默认策略
+type HpaCreate struct {
+	Name        string                                         `json:"name"`
+	Namespace   string                                         `json:"namespace"`
+	Labels      map[string]string                              `json:"labels"`
+	TargetKind  string                                         `json:"target_kind"`
+	TargetName  string                                         `json:"target_name"`
+	MinReplicas int32                                          `json:"min_replicas"`
+	MaxReplicas int32                                          `json:"max_replicas"`
+	Metrics     []*HpaMetric                                   `json:"metrics"`
+	Behavior    *autoscalingv2.HorizontalPodAutoscalerBehavior `json:"behavior"`
+}
+
+// 定义HpaMetric结构体, 描述一个扩缩容指标
+// Type: Resource(cpu/memory), Pods, Object, External
+// TargetType: Utilization(只用于Resource), AverageValue, Value
+type HpaMetric struct {
+	Type               string            `json:"type"`
+	ResourceName       string            `json:"resource_name"`
+	MetricName         string            `json:"metric_name"`
+	MetricSelector     map[string]string `json:"metric_selector"`
+	ObjectKind         string            `json:"object_kind"`
+	ObjectName         string            `json:"object_name"`
+	ObjectAPIVersion   string            `json:"object_api_version"`
+	TargetType         string            `json:"target_type"`
+	AverageUtilization int32             `json:"average_utilization"`
+	AverageValue       string            `json:"average_value"`
+	Value              string            `json:"value"`
+}
+
+func (h *horizontalPodAutoscaler) toCells(std []autoscalingv2.HorizontalPodAutoscaler) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = HorizontalPodAutoscalerCell(std[i])
+	}
+	return cells
+}
+
+func (h *horizontalPodAutoscaler) fromCells(cells []DataCell) []autoscalingv2.HorizontalPodAutoscaler {
+	hpas := make([]autoscalingv2.HorizontalPodAutoscaler, len(cells))
+	for i := range cells {
+		hpas[i] = autoscalingv2.HorizontalPodAutoscaler(cells[i].(HorizontalPodAutoscalerCell))
+	}
+	return hpas
+}
+
+// 获取hpa列表, 支持过滤、排序、分页
+func (h *horizontalPodAutoscaler) GetHorizontalPodAutoscalers(filterName, namespace string, limit, page int) (hpaResp *HorizontalPodAutoscalerResp, err error) {
+	hpaList, err := K8s.Clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取HorizontalPodAutoscaler列表失败, " + err.Error()))
+		return nil, errors.New("获取HorizontalPodAutoscaler列表失败, " + err.Error())
+	}
+
+	selectableData := &dataSelector{
+		GenericDataList: h.toCells(hpaList.Items),
+		DataSelectQuery: &DataSelectQuery{
+			FilterQuery: &FilterQuery{Name: filterName},
+			PaginateQuery: &PaginateQuery{
+				Limit: limit,
+				Page:  page,
+			},
+		},
+	}
+
+	filtered := selectableData.Filter()
+	total := len(filtered.GenericDataList)
+	data := filtered.Sort().Paginate()
+
+	return &HorizontalPodAutoscalerResp{
+		Items: h.fromCells(data.GenericDataList),
+		Total: total,
+	}, nil
+}
+
+// 获取hpa详情
+func (h *horizontalPodAutoscaler) GetHorizontalPodAutoscalerDetail(hpaName, namespace string) (hpa *autoscalingv2.HorizontalPodAutoscaler, err error) {
+	hpa, err = K8s.Clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).Get(context.TODO(), hpaName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取HorizontalPodAutoscaler详情失败, " + err.Error()))
+		return nil, errors.New("获取HorizontalPodAutoscaler详情失败, " + err.Error())
+	}
+	return hpa, nil
+}
+
+// 获取控制指定工作负载的hpa, 没有则返回nil
+func (h *horizontalPodAutoscaler) GetWorkloadHorizontalPodAutoscaler(kind, name, namespace string) (hpa *autoscalingv2.HorizontalPodAutoscaler, err error) {
+	hpaList, err := K8s.Clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取HorizontalPodAutoscaler列表失败, " + err.Error()))
+		return nil, errors.New("获取HorizontalPodAutoscaler列表失败, " + err.Error())
+	}
+	for i := range hpaList.Items {
+		ref := hpaList.Items[i].Spec.ScaleTargetRef
+		if ref.Kind == kind && ref.Name == name {
+			return &hpaList.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// 将HpaMetric转换为autoscaling/v2的MetricSpec
+func (m *HpaMetric) toMetricSpec() (spec autoscalingv2.MetricSpec, err error) {
+	target := autoscalingv2.MetricTarget{Type: autoscalingv2.MetricTargetType(m.TargetType)}
+	switch target.Type {
+	case autoscalingv2.UtilizationMetricType:
+		if m.Type != string(autoscalingv2.ResourceMetricSourceType) {
+			return spec, errors.New("Utilization类型的目标只能用于Resource指标")
+		}
+		target.AverageUtilization = &m.AverageUtilization
+	case autoscalingv2.AverageValueMetricType:
+		quantity, err := resource.ParseQuantity(m.AverageValue)
+		if err != nil {
+			return spec, errors.New("averageValue格式错误: " + m.AverageValue + ", " + err.Error())
+		}
+		target.AverageValue = &quantity
+	case autoscalingv2.ValueMetricType:
+		quantity, err := resource.ParseQuantity(m.Value)
+		if err != nil {
+			return spec, errors.New("value格式错误: " + m.Value + ", " + err.Error())
+		}
+		target.Value = &quantity
+	default:
+		return spec, errors.New("不支持的指标目标类型: " + m.TargetType + ", 可选值为Utilization/AverageValue/Value")
+	}
+
+	metric := autoscalingv2.MetricIdentifier{Name: m.MetricName}
+	if len(m.MetricSelector) > 0 {
+		metric.Selector = &metav1.LabelSelector{MatchLabels: m.MetricSelector}
+	}
+
+	spec.Type = autoscalingv2.MetricSourceType(m.Type)
+	switch spec.Type {
+	case autoscalingv2.ResourceMetricSourceType:
+		spec.Resource = &autoscalingv2.ResourceMetricSource{
+			Name:   corev1.ResourceName(m.ResourceName),
+			Target: target,
+		}
+	case autoscalingv2.PodsMetricSourceType:
+		spec.Pods = &autoscalingv2.PodsMetricSource{Metric: metric, Target: target}
+	case autoscalingv2.ObjectMetricSourceType:
+		spec.Object = &autoscalingv2.ObjectMetricSource{
+			DescribedObject: autoscalingv2.CrossVersionObjectReference{
+				Kind:       m.ObjectKind,
+				Name:       m.ObjectName,
+				APIVersion: m.ObjectAPIVersion,
+			},
+			Metric: metric,
+			Target: target,
+		}
+	case autoscalingv2.ExternalMetricSourceType:
+		spec.External = &autoscalingv2.ExternalMetricSource{Metric: metric, Target: target}
+	default:
+		return spec, errors.New("不支持的指标类型: " + m.Type + ", 可选值为Resource/Pods/Object/External")
+	}
+	return spec, nil
+}
+
+// 创建hpa, 并接收HpaCreate对象
+func (h *horizontalPodAutoscaler) CreateHorizontalPodAutoscaler(data *HpaCreate) (err error) {
+	if data.TargetKind != "Deployment" && data.TargetKind != "StatefulSet" {
+		return errors.New("不支持的扩缩容对象类型: " + data.TargetKind + ", 可选值为Deployment/StatefulSet")
+	}
+	if data.MaxReplicas <= 0 || data.MinReplicas > data.MaxReplicas {
+		return errors.New(fmt.Sprintf("副本数范围不合法, min: %d, max: %d", data.MinReplicas, data.MaxReplicas))
+	}
+	//同一个工作负载只能被一个hpa控制
+	exist, err := h.GetWorkloadHorizontalPodAutoscaler(data.TargetKind, data.TargetName, data.Namespace)
+	if err != nil {
+		return err
+	}
+	if exist != nil {
+		return errors.New(fmt.Sprintf("%s %s 已被HorizontalPodAutoscaler %s 控制", data.TargetKind, data.TargetName, exist.Name))
+	}
+
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      data.Name,
+			Namespace: data.Namespace,
+			Labels:    data.Labels,
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       data.TargetKind,
+				Name:       data.TargetName,
+			},
+			MaxReplicas: data.MaxReplicas,
+			Behavior:    data.Behavior,
+		},
+	}
+	if data.MinReplicas > 0 {
+		hpa.Spec.MinReplicas = &data.MinReplicas
+	}
+	for _, metric := range data.Metrics {
+		spec, err := metric.toMetricSpec()
+		if err != nil {
+			return err
+		}
+		hpa.Spec.Metrics = append(hpa.Spec.Metrics, spec)
+	}
+
+	_, err = K8s.Clientset.AutoscalingV2().HorizontalPodAutoscalers(data.Namespace).Create(context.TODO(), hpa, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建HorizontalPodAutoscaler失败, " + err.Error()))
+		return errors.New("创建HorizontalPodAutoscaler失败, " + err.Error())
+	}
+	return nil
+}
+
+// 更新hpa, content为hpa对象的json
+func (h *horizontalPodAutoscaler) UpdateHorizontalPodAutoscaler(namespace, content string) (err error) {
+	var hpa = &autoscalingv2.HorizontalPodAutoscaler{}
+	err = json.Unmarshal([]byte(content), hpa)
+	if err != nil {
+		logger.Error(errors.New("反序列化失败, " + err.Error()))
+		return errors.New("反序列化失败, " + err.Error())
+	}
+	_, err = K8s.Clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).Update(context.TODO(), hpa, metav1.UpdateOptions{})
+	if err != nil {
+		logger.Error(errors.New("更新HorizontalPodAutoscaler失败, " + err.Error()))
+		return errors.New("更新HorizontalPodAutoscaler失败, " + err.Error())
+	}
+	return nil
+}
+
+// 删除hpa
+func (h *horizontalPodAutoscaler) DeleteHorizontalPodAutoscaler(hpaName, namespace string) (err error) {
+	err = K8s.Clientset.AutoscalingV2().HorizontalPodAutoscalers(namespace).Delete(context.TODO(), hpaName, metav1.DeleteOptions{})
+	if err != nil {
+		logger.Error(errors.New("删除HorizontalPodAutoscaler失败, " + err.Error()))
+		return errors.New("删除HorizontalPodAutoscaler失败, " + err.Error())
+	}
+	return nil
+}
@@ -26,16 +26,29 @@ type IngressCreate struct {
 	Namespace	string	`json:"namespace"`
 	Label		map[string]string	`json:"label"`
 	Hosts		map[string][]*HttpPath	`json:"hosts"`
+	//为空时使用集群默认的IngressClass
+	IngressClassName	string	`json:"ingress_class_name"`
+	Annotations	map[string]string	`json:"annotations"`
+	//控制器注解预设, 展开后与Annotations合并, Annotations中同名的key优先
+	Presets		[]*AnnotationPreset	`json:"presets"`
+	TLS			[]*IngressTLSCreate	`json:"tls"`
+	DefaultBackend	*IngressBackendCreate	`json:"default_backend"`
 }
 
 type HttpPath struct {
 	Path	string	`json:"path"`
 	PathType	nwv1.PathType	`json:"path_type"`
 	ServiceName	string	`json:"service_name"`
-	ServicePort	int32	`json:"service_port"`	
+	ServicePort	int32	`json:"service_port"`
+	//使用service的端口名时填写, 优先于ServicePort
+	ServicePortName	string	`json:"service_port_name"`
 }
 
-
+type IngressBackendCreate struct {
+	ServiceName	string	`json:"service_name"`
+	ServicePort	int32	`json:"service_port"`
+	ServicePortName	string	`json:"service_port_name"`
+}
 
 //数据类型转换
 func (i *ingress) toCells(std []nwv1.Ingress) []DataCell {
@@ -104,9 +117,47 @@ func (i *ingress) DeleteIngress(ingressName, namespace string) (err error) {
 }
 
 func (i *ingress) CreateIngress(data *IngressCreate) (err error) {
-	//声明nwv1.IngressRule 和 nwv1.HTTPIngressPath变量, 后面组装数据中用到
+	ingress, err := buildIngress(data)
+	if err != nil {
+		logger.Error(errors.New("创建Ingress: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建 Ingress 失败, " + err.Error())
+	}
+
+	//创建前检查IngressClass是否存在以及host+path是否被其他ingress占用
+	if err = checkIngress(ingress); err != nil {
+		logger.Error(errors.New("创建Ingress: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建 Ingress 失败, " + err.Error())
+	}
+	//创建同一请求中携带证书的TLS Secret, ingress创建失败时删除
+	secretBackup, err := applyTLSSecrets(data.Namespace, data.TLS, false)
+	if err != nil {
+		logger.Error(errors.New("创建Ingress: " + data.Name + " 的TLS Secret失败, " + err.Error()))
+		return errors.New("创建 Ingress 的TLS Secret失败, " + err.Error())
+	}
+
+	//fmt.Println(ingress.Spec.Rules)
+	//创建ingress
+	_, err = K8s.Clientset.NetworkingV1().Ingresses(data.Namespace).Create(context.TODO(), ingress, metav1.CreateOptions{})
+	if err != nil {
+		rollbackTLSSecrets(data.Namespace, secretBackup)
+		logger.Error(errors.New("创建Namespace: %s 下的Ingress: %s 失败, " + err.Error()), data.Namespace, data.Name)
+		return errors.New("创建 Ingress 失败, " + err.Error())
+	}
+	return nil
+}
+
+//buildIngress 将创建参数组装成nwv1.Ingress对象, workflow更新和对比时也使用
+func buildIngress(data *IngressCreate) (*nwv1.Ingress, error) {
+	//声明nwv1.IngressRule变量, 后面组装数据中用到
 	var ingressRules []nwv1.IngressRule
-	var httpIngressPaths []nwv1.HTTPIngressPath
+
+	annotations, err := renderAnnotationPresets(data.Presets)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range data.Annotations {
+		annotations[key] = value
+	}
 
 	//将data中的数据组装成nwv1.Ingress对象
 	ingress := &nwv1.Ingress{
@@ -114,12 +165,27 @@ func (i *ingress) CreateIngress(data *IngressCreate) (err error) {
 			Name: data.Name,
 			Namespace: data.Namespace,
 			Labels: data.Label,
+			Annotations: annotations,
 		},
 		Status: nwv1.IngressStatus{},
 	}
+	if data.IngressClassName != "" {
+		ingressClassName := data.IngressClassName
+		ingress.Spec.IngressClassName = &ingressClassName
+	}
+	if data.DefaultBackend != nil {
+		ingress.Spec.DefaultBackend = &nwv1.IngressBackend{
+			Service: &nwv1.IngressServiceBackend{
+				Name: data.DefaultBackend.ServiceName,
+				Port: backendPort(data.DefaultBackend.ServicePort, data.DefaultBackend.ServicePortName),
+			},
+		}
+	}
 	//第一层for循环是将host组转成nwv1.IngressRule类型的对象
 	// 一个host对应一个ingressRule， 每个ingressRule中包含一个host和多个path
 	for key, value := range data.Hosts {
+		//每个host的path单独组装, 避免上一个host的path被带到下一个host中
+		var httpIngressPaths []nwv1.HTTPIngressPath
 		ir := nwv1.IngressRule{
 			Host: key,
 			//这里将nwv1.HTTPIngressRuleValue类型中的Paths置为空, 后面组装好数据在赋值
@@ -135,9 +201,7 @@ func (i *ingress) CreateIngress(data *IngressCreate) (err error) {
 				Backend: nwv1.IngressBackend{
 					Service: &nwv1.IngressServiceBackend{
 						Name: httpPath.ServiceName,
-						Port: nwv1.ServiceBackendPort{
-							Number: httpPath.ServicePort,
-						},
+						Port: backendPort(httpPath.ServicePort, httpPath.ServicePortName),
 					},
 				},
 			}
@@ -154,18 +218,17 @@ func (i *ingress) CreateIngress(data *IngressCreate) (err error) {
 	}
 	//将ingressRules对象加入到ingress的规则中
 	ingress.Spec.Rules = ingressRules
-
-	//fmt.Println(ingress.Spec.Rules)
-	//创建ingress
-	_, err = K8s.Clientset.NetworkingV1().Ingresses(data.Namespace).Create(context.TODO(), ingress, metav1.CreateOptions{})
-	if err != nil {
-		logger.Error(errors.New("创建Namespace: %s 下的Ingress: %s 失败, " + err.Error()), data.Namespace, data.Name)
-		return errors.New("创建 Ingress 失败, " + err.Error())
+	for _, tls := range data.TLS {
+		ingress.Spec.TLS = append(ingress.Spec.TLS, nwv1.IngressTLS{
+			Hosts: tls.Hosts,
+			SecretName: tls.SecretName,
+		})
 	}
-	return nil
+	return ingress, nil
 }
 
-func (i *ingress) UpdateIngress(namespace, content string) (err error)  {
+//更新ingress, tls中携带证书的Secret会在更新前创建或替换
+func (i *ingress) UpdateIngress(namespace, content string, tls []*IngressTLSCreate) (err error)  {
 	var ingress = &nwv1.Ingress{}
 
 	err = json.Unmarshal([]byte(content), ingress)
@@ -173,10 +236,22 @@ func (i *ingress) UpdateIngress(namespace, content string) (err error)  {
 		logger.Error(errors.New("JONS反序列化失败." + err.Error()))
 		return errors.New("JONS反序列化失败." + err.Error())
 	}
+	ingress.Namespace = namespace
+	if err = checkIngress(ingress); err != nil {
+		logger.Error(errors.New("更新Ingress: " + ingress.Name + " 失败, " + err.Error()))
+		return errors.New("更新 Ingress 失败, " + err.Error())
+	}
+	//替换前备份已存在的Secret, ingress更新失败时恢复原证书并删除新建的Secret
+	secretBackup, err := applyTLSSecrets(namespace, tls, true)
+	if err != nil {
+		logger.Error(errors.New("更新Ingress: " + ingress.Name + " 的TLS Secret失败, " + err.Error()))
+		return errors.New("更新 Ingress 的TLS Secret失败, " + err.Error())
+	}
 	_, err = K8s.Clientset.NetworkingV1().Ingresses(namespace).Update(context.TODO(), ingress, metav1.UpdateOptions{})
 	if err != nil {
+		rollbackTLSSecrets(namespace, secretBackup)
 		logger.Error(errors.New("更新Namespace: %s 下的Ingress: %s 失败, " + err.Error()), namespace, ingress.Name)
 		return errors.New("更新 Ingress 失败, " + err.Error())
 	}
 	return nil
-}
\ No newline at end of file
+}
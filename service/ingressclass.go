@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//IngressTLSCreate ingress的tls配置, 同时传入Cert和Key时会在同一请求中创建kubernetes.io/tls类型的Secret
+type IngressTLSCreate struct {
+	Hosts      []string `json:"hosts"`
+	SecretName string   `json:"secret_name"`
+	Cert       string   `json:"cert"`
+	Key        string   `json:"key"`
+}
+
+//AnnotationPreset 控制器注解预设, Value的含义见GetAnnotationPresets
+type AnnotationPreset struct {
+	Controller string `json:"controller"`
+	Name       string `json:"name"`
+	Value      string `json:"value"`
+}
+
+//AnnotationPresetInfo 预设说明, Annotations中的$value在展开时替换为AnnotationPreset.Value
+type AnnotationPresetInfo struct {
+	Controller   string            `json:"controller"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	DefaultValue string            `json:"default_value"`
+	Annotations  map[string]string `json:"annotations"`
+}
+
+//IngressConflict 与其他ingress重复的host+path
+type IngressConflict struct {
+	Host      string `json:"host"`
+	Path      string `json:"path"`
+	Namespace string `json:"namespace"`
+	Ingress   string `json:"ingress"`
+}
+
+const (
+	ingressClassDefaultAnnotation = "ingressclass.kubernetes.io/is-default-class"
+	ingressClassLegacyAnnotation  = "kubernetes.io/ingress.class"
+	presetValuePlaceholder        = "$value"
+)
+
+var annotationPresets = []*AnnotationPresetInfo{
+	{
+		Controller:   "nginx",
+		Name:         "rewrite",
+		Description:  "重写转发到后端的路径, value为rewrite-target",
+		DefaultValue: "/",
+		Annotations:  map[string]string{"nginx.ingress.kubernetes.io/rewrite-target": presetValuePlaceholder},
+	},
+	{
+		Controller:  "nginx",
+		Name:        "basic-auth",
+		Description: "basic认证, value为保存htpasswd的Secret名称",
+		Annotations: map[string]string{
+			"nginx.ingress.kubernetes.io/auth-type":   "basic",
+			"nginx.ingress.kubernetes.io/auth-secret": presetValuePlaceholder,
+			"nginx.ingress.kubernetes.io/auth-realm":  "Authentication Required",
+		},
+	},
+	{
+		Controller:   "nginx",
+		Name:         "rate-limit",
+		Description:  "按客户端IP限制每秒请求数, value为rps",
+		DefaultValue: "10",
+		Annotations:  map[string]string{"nginx.ingress.kubernetes.io/limit-rps": presetValuePlaceholder},
+	},
+	{
+		Controller:   "nginx",
+		Name:         "ssl-redirect",
+		Description:  "http跳转https",
+		DefaultValue: "true",
+		Annotations:  map[string]string{"nginx.ingress.kubernetes.io/ssl-redirect": presetValuePlaceholder},
+	},
+	{
+		Controller:   "nginx",
+		Name:         "body-size",
+		Description:  "请求体大小限制, value如10m",
+		DefaultValue: "10m",
+		Annotations:  map[string]string{"nginx.ingress.kubernetes.io/proxy-body-size": presetValuePlaceholder},
+	},
+	{
+		Controller:   "traefik",
+		Name:         "entrypoints",
+		Description:  "指定traefik入口, 多个用逗号分隔",
+		DefaultValue: "websecure",
+		Annotations:  map[string]string{"traefik.ingress.kubernetes.io/router.entrypoints": presetValuePlaceholder},
+	},
+	{
+		Controller:   "traefik",
+		Name:         "tls",
+		Description:  "路由启用tls",
+		DefaultValue: "true",
+		Annotations:  map[string]string{"traefik.ingress.kubernetes.io/router.tls": presetValuePlaceholder},
+	},
+	{
+		Controller:  "traefik",
+		Name:        "middlewares",
+		Description: "引用Middleware实现重写、认证、限流等, value如default-auth@kubernetescrd, 多个用逗号分隔",
+		Annotations: map[string]string{"traefik.ingress.kubernetes.io/router.middlewares": presetValuePlaceholder},
+	},
+}
+
+//获取注解预设列表, controller为空时返回全部
+func (i *ingress) GetAnnotationPresets(controller string) []*AnnotationPresetInfo {
+	presets := make([]*AnnotationPresetInfo, 0)
+	for _, preset := range annotationPresets {
+		if controller == "" || preset.Controller == controller {
+			presets = append(presets, preset)
+		}
+	}
+	return presets
+}
+
+//获取IngressClass列表
+func (i *ingress) GetIngressClasses() (ingressClasses []nwv1.IngressClass, err error) {
+	ingressClassList, err := K8s.Clientset.NetworkingV1().IngressClasses().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取IngressClass列表失败, " + err.Error()))
+		return nil, errors.New("获取IngressClass列表失败, " + err.Error())
+	}
+	return ingressClassList.Items, nil
+}
+
+//检查ingress与集群中其他ingress的host+path冲突
+func (i *ingress) GetIngressConflicts(ingress *nwv1.Ingress) (conflicts []*IngressConflict, err error) {
+	ingressClassList, err := K8s.Clientset.NetworkingV1().IngressClasses().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	ingressList, err := K8s.Clientset.NetworkingV1().Ingresses("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defaultClass := defaultIngressClass(ingressClassList.Items)
+	return findIngressConflicts(ingress, ingressList.Items, defaultClass), nil
+}
+
+func renderAnnotationPresets(presets []*AnnotationPreset) (map[string]string, error) {
+	annotations := map[string]string{}
+	for _, preset := range presets {
+		var info *AnnotationPresetInfo
+		for _, item := range annotationPresets {
+			if item.Controller == preset.Controller && item.Name == preset.Name {
+				info = item
+				break
+			}
+		}
+		if info == nil {
+			return nil, errors.New("不支持的注解预设: " + preset.Controller + "/" + preset.Name)
+		}
+		value := preset.Value
+		if value == "" {
+			value = info.DefaultValue
+		}
+		if value == "" {
+			return nil, errors.New("注解预设 " + preset.Controller + "/" + preset.Name + " 需要指定value")
+		}
+		for key, template := range info.Annotations {
+			annotations[key] = strings.ReplaceAll(template, presetValuePlaceholder, value)
+		}
+	}
+	return annotations, nil
+}
+
+func backendPort(number int32, name string) nwv1.ServiceBackendPort {
+	if name != "" {
+		return nwv1.ServiceBackendPort{Name: name}
+	}
+	return nwv1.ServiceBackendPort{Number: number}
+}
+
+//checkIngress 检查指定的IngressClass是否存在, 以及host+path是否已被其他ingress占用
+func checkIngress(ingress *nwv1.Ingress) error {
+	if ingress.Spec.IngressClassName != nil && *ingress.Spec.IngressClassName != "" {
+		_, err := K8s.Clientset.NetworkingV1().IngressClasses().Get(context.TODO(), *ingress.Spec.IngressClassName, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return errors.New("IngressClass " + *ingress.Spec.IngressClassName + " 不存在")
+			}
+			return err
+		}
+	}
+	conflicts, err := Ingress.GetIngressConflicts(ingress)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		items := make([]string, 0, len(conflicts))
+		for _, conflict := range conflicts {
+			items = append(items, fmt.Sprintf("%s%s 已被 %s/%s 使用", conflict.Host, conflict.Path, conflict.Namespace, conflict.Ingress))
+		}
+		return errors.New("host+path冲突: " + strings.Join(items, "; "))
+	}
+	return nil
+}
+
+//defaultIngressClass 返回标记为默认的IngressClass名称, 没有时返回空
+func defaultIngressClass(ingressClasses []nwv1.IngressClass) string {
+	for _, ingressClass := range ingressClasses {
+		if ingressClass.Annotations[ingressClassDefaultAnnotation] == "true" {
+			return ingressClass.Name
+		}
+	}
+	return ""
+}
+
+//effectiveIngressClass 未指定ingressClassName时, 依次使用旧版注解和默认IngressClass
+func effectiveIngressClass(ingress *nwv1.Ingress, defaultClass string) string {
+	if ingress.Spec.IngressClassName != nil && *ingress.Spec.IngressClassName != "" {
+		return *ingress.Spec.IngressClassName
+	}
+	if class := ingress.Annotations[ingressClassLegacyAnnotation]; class != "" {
+		return class
+	}
+	return defaultClass
+}
+
+//findIngressConflicts 同一IngressClass下host和path都相同即视为冲突, 跳过ingress自身
+func findIngressConflicts(ingress *nwv1.Ingress, existing []nwv1.Ingress, defaultClass string) []*IngressConflict {
+	class := effectiveIngressClass(ingress, defaultClass)
+	claimed := map[string]bool{}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			claimed[rule.Host+"\x00"+path.Path] = true
+		}
+	}
+
+	conflicts := make([]*IngressConflict, 0)
+	for i := range existing {
+		other := &existing[i]
+		if other.Namespace == ingress.Namespace && other.Name == ingress.Name {
+			continue
+		}
+		if effectiveIngressClass(other, defaultClass) != class {
+			continue
+		}
+		for _, rule := range other.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				if claimed[rule.Host+"\x00"+path.Path] {
+					conflicts = append(conflicts, &IngressConflict{
+						Host:      rule.Host,
+						Path:      path.Path,
+						Namespace: other.Namespace,
+						Ingress:   other.Name,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(conflicts, func(a, b int) bool {
+		return conflicts[a].Host+conflicts[a].Path < conflicts[b].Host+conflicts[b].Path
+	})
+	return conflicts
+}
+
+//tlsSecretBackup 记录applyTLSSecrets新建的Secret和被替换前的Secret, 用于失败时回滚
+type tlsSecretBackup struct {
+	created  []string
+	replaced []*corev1.Secret
+}
+
+//applyTLSSecrets 为携带证书的tls配置创建Secret, 返回回滚所需的备份
+//replace为true时替换已存在Secret的证书, 否则Secret已存在即报错
+func applyTLSSecrets(namespace string, tlsList []*IngressTLSCreate, replace bool) (*tlsSecretBackup, error) {
+	backup := &tlsSecretBackup{created: make([]string, 0)}
+	for _, tls := range tlsList {
+		if tls.Cert == "" && tls.Key == "" {
+			continue
+		}
+		if tls.Cert == "" || tls.Key == "" || tls.SecretName == "" {
+			rollbackTLSSecrets(namespace, backup)
+			return nil, errors.New("创建TLS Secret需要同时指定secret_name、cert和key")
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      tls.SecretName,
+				Namespace: namespace,
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       []byte(tls.Cert),
+				corev1.TLSPrivateKeyKey: []byte(tls.Key),
+			},
+		}
+		_, err := K8s.Clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+		if err == nil {
+			backup.created = append(backup.created, tls.SecretName)
+			continue
+		}
+		if !apierrors.IsAlreadyExists(err) || !replace {
+			rollbackTLSSecrets(namespace, backup)
+			return nil, err
+		}
+		existing, err := K8s.Clientset.CoreV1().Secrets(namespace).Get(context.TODO(), tls.SecretName, metav1.GetOptions{})
+		if err != nil {
+			rollbackTLSSecrets(namespace, backup)
+			return nil, err
+		}
+		if existing.Type != corev1.SecretTypeTLS {
+			rollbackTLSSecrets(namespace, backup)
+			return nil, errors.New("Secret " + tls.SecretName + " 已存在且不是kubernetes.io/tls类型")
+		}
+		old := existing.DeepCopy()
+		existing.Data = secret.Data
+		if _, err = K8s.Clientset.CoreV1().Secrets(namespace).Update(context.TODO(), existing, metav1.UpdateOptions{}); err != nil {
+			rollbackTLSSecrets(namespace, backup)
+			return nil, err
+		}
+		backup.replaced = append(backup.replaced, old)
+	}
+	return backup, nil
+}
+
+//rollbackTLSSecrets 删除新建的Secret, 并将被替换的Secret恢复为原来的证书
+func rollbackTLSSecrets(namespace string, backup *tlsSecretBackup) {
+	if backup == nil {
+		return
+	}
+	deleteTLSSecrets(namespace, backup.created)
+	for _, old := range backup.replaced {
+		current, err := K8s.Clientset.CoreV1().Secrets(namespace).Get(context.TODO(), old.Name, metav1.GetOptions{})
+		if err != nil {
+			logger.Error(errors.New("回滚TLS Secret: " + old.Name + " 失败, " + err.Error()))
+			continue
+		}
+		current.Data = old.Data
+		if _, err = K8s.Clientset.CoreV1().Secrets(namespace).Update(context.TODO(), current, metav1.UpdateOptions{}); err != nil {
+			logger.Error(errors.New("回滚TLS Secret: " + old.Name + " 失败, " + err.Error()))
+		}
+	}
+}
+
+func deleteTLSSecrets(namespace string, secretNames []string) {
+	for _, secretName := range secretNames {
+		err := K8s.Clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), secretName, metav1.DeleteOptions{})
+		if err != nil {
+			logger.Error(errors.New("回滚TLS Secret: " + secretName + " 失败, " + err.Error()))
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"test4/config"
 
 	"github.com/wonderivan/logger"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -12,6 +13,8 @@ var K8s k8s
 
 type k8s struct {
 	Clientset *kubernetes.Clientset
+	//dynamic client, 用于VolumeSnapshot等没有typed client的资源
+	Dynamic dynamic.Interface
 }
 
 func (k *k8s) Init()  {
@@ -25,6 +28,13 @@ func (k *k8s) Init()  {
 	} else {
 		logger.Info("创建k8s clientSet成功")
 	}
+	dynamicClient, err := dynamic.NewForConfig(conf)
+	if err != nil {
+		logger.Error("创建k8s dynamic client失败, " + err.Error())
+	} else {
+		logger.Info("创建k8s dynamic client成功")
+	}
 	k.Clientset = clientSet
+	k.Dynamic = dynamicClient
 }
 
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/wonderivan/logger"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var Job job
+
+type job struct{}
+
+type JobResp struct {
+	Items []batchv1.Job `json:"items"`
+	Total int           `json:"total"`
+}
+
+// 定义JobCreate结构体, 用于创建job需要的参数属性的定义
+// 数值为0的字段不设置, 使用k8s的默认值; BackoffLimit为0表示不重试, 不传时使用默认值
+type JobCreate struct {
+	Name                    string             `json:"name"`
+	Namespace               string             `json:"namespace"`
+	Label                   map[string]string  `json:"label"`
+	Containers              []corev1.Container `json:"containers"`
+	RestartPolicy           string             `json:"restart_policy"`
+	Completions             int32              `json:"completions"`
+	Parallelism             int32              `json:"parallelism"`
+	BackoffLimit            *int32             `json:"backoff_limit"`
+	ActiveDeadlineSeconds   int64              `json:"active_deadline_seconds"`
+	TTLSecondsAfterFinished int32              `json:"ttl_seconds_after_finished"`
+	Cpu                     string             `json:"cpu"`
+	Memory                  string             `json:"memory"`
+	ResourceCheck           bool               `json:"resource_check"`
+}
+
+// 定义JobPod类型, 用于返回job下的pod及其容器
+type JobPod struct {
+	Name       string          `json:"name"`
+	Phase      corev1.PodPhase `json:"phase"`
+	NodeName   string          `json:"node_name"`
+	Containers []string        `json:"containers"`
+	StartTime  *metav1.Time    `json:"start_time"`
+}
+
+// 定义JobPodLog类型, 用于返回job下每个pod中容器的日志
+type JobPodLog struct {
+	PodName       string `json:"pod_name"`
+	ContainerName string `json:"container_name"`
+	Log           string `json:"log"`
+}
+
+func (j *job) toCells(std []batchv1.Job) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = JobCell(std[i])
+	}
+	return cells
+}
+
+func (j *job) fromCells(cells []DataCell) []batchv1.Job {
+	jobs := make([]batchv1.Job, len(cells))
+	for i := range cells {
+		jobs[i] = batchv1.Job(cells[i].(JobCell))
+	}
+	return jobs
+}
+
+// 获取job列表, 支持过滤、排序、分页
+func (j *job) GetJobs(filterName, namespace string, limit, page int) (jobResp *JobResp, err error) {
+	jobList, err := K8s.Clientset.BatchV1().Jobs(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Job列表失败, " + err.Error()))
+		return nil, errors.New("获取Job列表失败, " + err.Error())
+	}
+
+	selectableData := &dataSelector{
+		GenericDataList: j.toCells(jobList.Items),
+		DataSelectQuery: &DataSelectQuery{
+			FilterQuery: &FilterQuery{Name: filterName},
+			PaginateQuery: &PaginateQuery{
+				Limit: limit,
+				Page:  page,
+			},
+		},
+	}
+
+	filtered := selectableData.Filter()
+	total := len(filtered.GenericDataList)
+	data := filtered.Sort().Paginate()
+
+	return &JobResp{
+		Items: j.fromCells(data.GenericDataList),
+		Total: total,
+	}, nil
+}
+
+// 获取job详情
+func (j *job) GetJobDetail(jobName, namespace string) (job *batchv1.Job, err error) {
+	job, err = K8s.Clientset.BatchV1().Jobs(namespace).Get(context.TODO(), jobName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Job详情失败, " + err.Error()))
+		return nil, errors.New("获取Job详情失败, " + err.Error())
+	}
+	return job, nil
+}
+
+// 根据JobCreate组装pod模板和job spec, CronJob的jobTemplate也复用该方法
+func buildJobSpec(data *JobCreate) (spec batchv1.JobSpec, err error) {
+	restartPolicy := corev1.RestartPolicy(data.RestartPolicy)
+	if restartPolicy == "" {
+		restartPolicy = corev1.RestartPolicyNever
+	}
+	spec = batchv1.JobSpec{
+		Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: data.Label,
+			},
+			Spec: corev1.PodSpec{
+				Containers:    data.Containers,
+				RestartPolicy: restartPolicy,
+			},
+		},
+	}
+	if data.Completions > 0 {
+		spec.Completions = &data.Completions
+	}
+	if data.Parallelism > 0 {
+		spec.Parallelism = &data.Parallelism
+	}
+	if data.BackoffLimit != nil {
+		spec.BackoffLimit = data.BackoffLimit
+	}
+	if data.ActiveDeadlineSeconds > 0 {
+		spec.ActiveDeadlineSeconds = &data.ActiveDeadlineSeconds
+	}
+	if data.TTLSecondsAfterFinished > 0 {
+		spec.TTLSecondsAfterFinished = &data.TTLSecondsAfterFinished
+	}
+	if data.ResourceCheck {
+		cpu, err := resource.ParseQuantity(data.Cpu)
+		if err != nil {
+			return spec, errors.New("cpu格式错误: " + data.Cpu + ", " + err.Error())
+		}
+		memory, err := resource.ParseQuantity(data.Memory)
+		if err != nil {
+			return spec, errors.New("memory格式错误: " + data.Memory + ", " + err.Error())
+		}
+		for i := range spec.Template.Spec.Containers {
+			spec.Template.Spec.Containers[i].Resources.Limits = corev1.ResourceList{
+				corev1.ResourceCPU:    cpu,
+				corev1.ResourceMemory: memory,
+			}
+			spec.Template.Spec.Containers[i].Resources.Requests = corev1.ResourceList{
+				corev1.ResourceCPU:    cpu,
+				corev1.ResourceMemory: memory,
+			}
+		}
+	}
+	return spec, nil
+}
+
+// 创建job, 并接收JobCreate对象
+func (j *job) CreateJob(data *JobCreate) (err error) {
+	spec, err := buildJobSpec(data)
+	if err != nil {
+		logger.Error(errors.New("创建Job失败, " + err.Error()))
+		return errors.New("创建Job失败, " + err.Error())
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      data.Name,
+			Namespace: data.Namespace,
+			Labels:    data.Label,
+		},
+		Spec: spec,
+	}
+	_, err = K8s.Clientset.BatchV1().Jobs(data.Namespace).Create(context.TODO(), job, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建Job失败, " + err.Error()))
+		return errors.New("创建Job失败, " + err.Error())
+	}
+	return nil
+}
+
+// 将删除传播策略转换为DeleteOptions
+// job默认的传播策略是Orphan, 会残留pod, 所以不传时使用Background
+func deleteOptions(propagation string) (options metav1.DeleteOptions, err error) {
+	policy := metav1.DeletePropagationBackground
+	switch metav1.DeletionPropagation(propagation) {
+	case "":
+	case metav1.DeletePropagationBackground, metav1.DeletePropagationForeground, metav1.DeletePropagationOrphan:
+		policy = metav1.DeletionPropagation(propagation)
+	default:
+		return options, errors.New("不支持的删除传播策略: " + propagation + ", 可选值为Background/Foreground/Orphan")
+	}
+	return metav1.DeleteOptions{PropagationPolicy: &policy}, nil
+}
+
+// 删除job, propagation为删除传播策略
+func (j *job) DeleteJob(jobName, namespace, propagation string) (err error) {
+	options, err := deleteOptions(propagation)
+	if err != nil {
+		return err
+	}
+	err = K8s.Clientset.BatchV1().Jobs(namespace).Delete(context.TODO(), jobName, options)
+	if err != nil {
+		logger.Error(errors.New("删除Job失败, " + err.Error()))
+		return errors.New("删除Job失败, " + err.Error())
+	}
+	return nil
+}
+
+// 获取job下的pod
+func (j *job) GetJobPods(jobName, namespace string) (pods []*JobPod, err error) {
+	job, err := j.GetJobDetail(jobName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if job.Spec.Selector == nil {
+		return nil, nil
+	}
+	podList, err := K8s.Clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(job.Spec.Selector),
+	})
+	if err != nil {
+		logger.Error(errors.New("获取Job下的Pod列表失败, " + err.Error()))
+		return nil, errors.New("获取Job下的Pod列表失败, " + err.Error())
+	}
+	for _, item := range podList.Items {
+		jobPod := &JobPod{
+			Name:      item.Name,
+			Phase:     item.Status.Phase,
+			NodeName:  item.Spec.NodeName,
+			StartTime: item.Status.StartTime,
+		}
+		for _, container := range item.Spec.Containers {
+			jobPod.Containers = append(jobPod.Containers, container.Name)
+		}
+		pods = append(pods, jobPod)
+	}
+	return pods, nil
+}
+
+// 获取job下所有pod中容器的日志
+func (j *job) GetJobLogs(jobName, namespace string) (logs []*JobPodLog, err error) {
+	pods, err := j.GetJobPods(jobName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range pods {
+		for _, containerName := range item.Containers {
+			log, err := Pod.GetPodLog(containerName, item.Name, namespace)
+			if err != nil {
+				log = fmt.Sprintf("获取日志失败: %s", err.Error())
+			}
+			logs = append(logs, &JobPodLog{
+				PodName:       item.Name,
+				ContainerName: containerName,
+				Log:           log,
+			})
+		}
+	}
+	return logs, nil
+}
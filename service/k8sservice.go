@@ -4,11 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"github.com/wonderivan/logger"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 var K8sService k8sService
@@ -21,6 +26,7 @@ type K8sServiceResp struct {
 }
 
 //定义ServiceCreate 结构体, 用于创建service需要的参数属性和定义
+//Ports为空时使用ContainerPort/Port/NodePort/Protocol组装单个端口, 兼容workflow等旧调用方
 type ServiceCreate struct {
 	Name		string	`json:"name"`
 	Namespace	string	`json:"namespace"`
@@ -31,9 +37,29 @@ type ServiceCreate struct {
 	Label		map[string]string	`json:"label"`
 	Protocol	string	`json:"protocol"`
 	Selector	map[string]string	`json:"selector"`
+	Ports		[]*ServicePortCreate	`json:"ports"`
+	Annotations	map[string]string	`json:"annotations"`
+	//Headless为true时clusterIP为None, 只能用于ClusterIP类型
+	Headless	bool	`json:"headless"`
+	PublishNotReadyAddresses	bool	`json:"publish_not_ready_addresses"`
+	//ExternalName类型指向的域名
+	ExternalName	string	`json:"external_name"`
+	LoadBalancerSourceRanges	[]string	`json:"load_balancer_source_ranges"`
+	//SessionAffinity为None或ClientIP, ClientIP时可设置超时时间(秒)
+	SessionAffinity	string	`json:"session_affinity"`
+	SessionAffinityTimeout	int32	`json:"session_affinity_timeout"`
+	//ExternalTrafficPolicy为Cluster或Local, 只能用于NodePort和LoadBalancer类型
+	ExternalTrafficPolicy	string	`json:"external_traffic_policy"`
 }
 
-
+//ServicePortCreate service的单个端口, TargetPort可以是数字或容器端口名, 为空时与Port相同
+type ServicePortCreate struct {
+	Name		string	`json:"name"`
+	Protocol	string	`json:"protocol"`
+	Port		int32	`json:"port"`
+	TargetPort	string	`json:"target_port"`
+	NodePort	int32	`json:"node_port"`
+}
 
 //类型转换
 func (svc *k8sService) toCells(std []corev1.Service) []DataCell {
@@ -104,40 +130,222 @@ func (svc *k8sService) DeleteK8sService(k8sServiceName, namespace string) (err e
 }
 
 func (svc *k8sService) CreateService(data *ServiceCreate) (err error) {
+	service, err := buildService(data)
+	if err != nil {
+		logger.Error(errors.New("创建Service: " + data.Name + " 参数校验失败, " + err.Error()))
+		return errors.New("创建Service参数校验失败, " + err.Error())
+	}
+
+	//创建service
+	_, err = K8s.Clientset.CoreV1().Services(data.Namespace).Create(context.TODO(), service, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建Namespace: %s 下 Service: %s 失败, " + err.Error()), data.Namespace, service.Name)
+		return errors.New("创建Service失败, " + err.Error())
+	}
+	return nil
+}
+
+//buildService 校验参数并组装成corev1.Service对象, workflow更新和对比时也使用
+func buildService(data *ServiceCreate) (*corev1.Service, error) {
+	//兼容旧的单端口参数
+	if len(data.Ports) == 0 && data.Port != 0 {
+		data.Ports = []*ServicePortCreate{
+			{
+				Name: "http",
+				Protocol: data.Protocol,
+				Port: data.Port,
+				TargetPort: strconv.Itoa(int(data.ContainerPort)),
+				NodePort: data.NodePort,
+			},
+		}
+	}
+	if data.Type == "" {
+		data.Type = string(corev1.ServiceTypeClusterIP)
+	}
+	//调用apiserver前先校验参数
+	if err := validateServiceCreate(data); err != nil {
+		return nil, err
+	}
+
 	//将data中的数据组装成corev1.service对象
 	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: data.Name,
 			Namespace: data.Namespace,
 			Labels: data.Label,
+			Annotations: data.Annotations,
 		},
 		Spec: corev1.ServiceSpec{
 			Type: corev1.ServiceType(data.Type),
-			Ports: []corev1.ServicePort{
-				{
-					Name: "http",
-					Port: data.Port,
-					Protocol: corev1.Protocol(data.Protocol),
-					TargetPort: intstr.IntOrString{
-						Type: 0,
-						IntVal: data.ContainerPort,
-					},
-				},
-			},
 			Selector: data.Selector,
+			PublishNotReadyAddresses: data.PublishNotReadyAddresses,
 		},
 	}
+	for _, port := range data.Ports {
+		servicePort := corev1.ServicePort{
+			Name: port.Name,
+			Protocol: corev1.Protocol(port.Protocol),
+			Port: port.Port,
+			TargetPort: intstr.Parse(port.TargetPort),
+		}
+		if port.TargetPort == "" {
+			servicePort.TargetPort = intstr.FromInt(int(port.Port))
+		}
+		//默认使用的是Cluster Ip， 这里判断NodePort, 添加配置
+		if port.NodePort != 0 && data.Type != string(corev1.ServiceTypeClusterIP) {
+			servicePort.NodePort = port.NodePort
+		}
+		service.Spec.Ports = append(service.Spec.Ports, servicePort)
+	}
 
-	//默认使用的是Cluster Ip， 这里判断NodePort, 添加配置
-	if data.NodePort != 0 && data.Type == "NodePort" {
-		service.Spec.Ports[0].NodePort = data.NodePort
+	switch corev1.ServiceType(data.Type) {
+	case corev1.ServiceTypeExternalName:
+		service.Spec.ExternalName = data.ExternalName
+		service.Spec.Selector = nil
+	case corev1.ServiceTypeLoadBalancer:
+		service.Spec.LoadBalancerSourceRanges = data.LoadBalancerSourceRanges
+	}
+	//未指定selector时沿用label
+	if len(service.Spec.Selector) == 0 && data.Type != string(corev1.ServiceTypeExternalName) {
+		service.Spec.Selector = data.Label
 	}
+	if data.Headless {
+		service.Spec.ClusterIP = corev1.ClusterIPNone
+	}
+	if data.ExternalTrafficPolicy != "" {
+		service.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyType(data.ExternalTrafficPolicy)
+	}
+	if data.SessionAffinity != "" {
+		service.Spec.SessionAffinity = corev1.ServiceAffinity(data.SessionAffinity)
+		if data.SessionAffinityTimeout > 0 {
+			timeout := data.SessionAffinityTimeout
+			service.Spec.SessionAffinityConfig = &corev1.SessionAffinityConfig{
+				ClientIP: &corev1.ClientIPConfig{TimeoutSeconds: &timeout},
+			}
+		}
+	}
+	return service, nil
+}
 
-	//创建service
-	_, err = K8s.Clientset.CoreV1().Services(data.Namespace).Create(context.TODO(), service, metav1.CreateOptions{})
-	if err != nil {
-		logger.Error(errors.New("创建Namespace: %s 下 Service: %s 失败, " + err.Error()), data.Namespace, service.Name)
-		return errors.New("创建Service失败, " + err.Error())
+//ClientIP会话保持的最大超时时间, 与apiserver校验一致
+const maxSessionAffinitySeconds = 86400
+
+//validateServiceCreate 校验service参数, 返回所有不合法的项, 未指定的协议默认为TCP
+func validateServiceCreate(data *ServiceCreate) error {
+	var errs []string
+	for _, msg := range validation.IsDNS1035Label(data.Name) {
+		errs = append(errs, "name: " + msg)
+	}
+	serviceType := corev1.ServiceType(data.Type)
+	switch serviceType {
+	case corev1.ServiceTypeClusterIP, corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer:
+		if len(data.Ports) == 0 && !data.Headless {
+			errs = append(errs, "至少需要一个端口")
+		}
+	case corev1.ServiceTypeExternalName:
+		if data.ExternalName == "" {
+			errs = append(errs, "ExternalName类型必须指定external_name")
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(data.ExternalName) {
+			errs = append(errs, "external_name: " + msg)
+		}
+	default:
+		errs = append(errs, "不支持的Service类型: " + data.Type)
+	}
+	if data.Headless && serviceType != corev1.ServiceTypeClusterIP {
+		errs = append(errs, "headless只能用于ClusterIP类型")
+	}
+
+	portNames := map[string]bool{}
+	portKeys := map[string]bool{}
+	for i, port := range data.Ports {
+		field := fmt.Sprintf("ports[%d]", i)
+		if port.Protocol == "" {
+			port.Protocol = string(corev1.ProtocolTCP)
+		}
+		switch corev1.Protocol(port.Protocol) {
+		case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+		default:
+			errs = append(errs, field + ": 不支持的协议 " + port.Protocol)
+		}
+		//多个端口时名称必填且不能重复
+		if len(data.Ports) > 1 && port.Name == "" {
+			errs = append(errs, field + ": 多个端口时必须指定name")
+		}
+		if port.Name != "" {
+			for _, msg := range validation.IsDNS1123Label(port.Name) {
+				errs = append(errs, field + ".name: " + msg)
+			}
+			if portNames[port.Name] {
+				errs = append(errs, field + ": 端口名 " + port.Name + " 重复")
+			}
+			portNames[port.Name] = true
+		}
+		for _, msg := range validation.IsValidPortNum(int(port.Port)) {
+			errs = append(errs, field + ".port: " + msg)
+		}
+		key := fmt.Sprintf("%d/%s", port.Port, port.Protocol)
+		if portKeys[key] {
+			errs = append(errs, field + ": 端口 " + key + " 重复")
+		}
+		portKeys[key] = true
+		if port.TargetPort != "" {
+			targetPort := intstr.Parse(port.TargetPort)
+			if targetPort.Type == intstr.Int {
+				for _, msg := range validation.IsValidPortNum(targetPort.IntValue()) {
+					errs = append(errs, field + ".target_port: " + msg)
+				}
+			} else {
+				for _, msg := range validation.IsValidPortName(targetPort.StrVal) {
+					errs = append(errs, field + ".target_port: " + msg)
+				}
+			}
+		}
+		if port.NodePort != 0 {
+			if serviceType != corev1.ServiceTypeNodePort && serviceType != corev1.ServiceTypeLoadBalancer {
+				errs = append(errs, field + ": node_port只能用于NodePort和LoadBalancer类型")
+			}
+			for _, msg := range validation.IsValidPortNum(int(port.NodePort)) {
+				errs = append(errs, field + ".node_port: " + msg)
+			}
+		}
+	}
+
+	switch corev1.ServiceAffinity(data.SessionAffinity) {
+	case "", corev1.ServiceAffinityNone:
+		if data.SessionAffinityTimeout != 0 {
+			errs = append(errs, "session_affinity_timeout只能用于ClientIP会话保持")
+		}
+	case corev1.ServiceAffinityClientIP:
+		if data.SessionAffinityTimeout < 0 || data.SessionAffinityTimeout > maxSessionAffinitySeconds {
+			errs = append(errs, fmt.Sprintf("session_affinity_timeout需在1-%d之间", maxSessionAffinitySeconds))
+		}
+	default:
+		errs = append(errs, "不支持的session_affinity: " + data.SessionAffinity)
+	}
+
+	if data.ExternalTrafficPolicy != "" {
+		if serviceType != corev1.ServiceTypeNodePort && serviceType != corev1.ServiceTypeLoadBalancer {
+			errs = append(errs, "external_traffic_policy只能用于NodePort和LoadBalancer类型")
+		}
+		switch corev1.ServiceExternalTrafficPolicyType(data.ExternalTrafficPolicy) {
+		case corev1.ServiceExternalTrafficPolicyTypeCluster, corev1.ServiceExternalTrafficPolicyTypeLocal:
+		default:
+			errs = append(errs, "不支持的external_traffic_policy: " + data.ExternalTrafficPolicy)
+		}
+	}
+
+	if len(data.LoadBalancerSourceRanges) > 0 && serviceType != corev1.ServiceTypeLoadBalancer {
+		errs = append(errs, "load_balancer_source_ranges只能用于LoadBalancer类型")
+	}
+	for _, sourceRange := range data.LoadBalancerSourceRanges {
+		if _, _, err := net.ParseCIDR(sourceRange); err != nil {
+			errs = append(errs, "load_balancer_source_ranges: " + sourceRange + " 不是合法的CIDR")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
 	}
 	return nil
 }
@@ -0,0 +1,687 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"test4/config"
+	"test4/dao"
+	"test4/model"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+var Kustomize kustomize
+
+type kustomize struct{}
+
+//diff和apply中每个对象的处理结果
+const (
+	KustomizeActionCreate      = "Create"
+	KustomizeActionUpdate      = "Update"
+	KustomizeActionUnchanged   = "Unchanged"
+	KustomizeActionPrune       = "Prune"
+	KustomizeActionApplied     = "Applied"
+	KustomizeActionPruned      = "Pruned"
+	KustomizeActionSkipped     = "Skipped"
+	KustomizeActionFailed      = "Failed"
+	KustomizeActionPruneFailed = "PruneFailed"
+)
+
+//KustomizeSource kustomization的来源, 未上传压缩包时使用BundleID对应的bundle
+//Path为kustomization所在的目录, 为空时使用bundle保存的目录或压缩包的根目录
+type KustomizeSource struct {
+	BundleID int    `json:"bundle_id" form:"bundle_id"`
+	Path     string `json:"path" form:"path"`
+	//删除上次apply过但overlay中已不存在的对象, 只能用于bundle
+	Prune  bool `json:"prune" form:"prune"`
+	DryRun bool `json:"dry_run" form:"dry_run"`
+}
+
+//KustomizeObjectRef 对象的标识, 同时作为bundle inventory中的一项
+type KustomizeObjectRef struct {
+	APIVersion string `json:"api_version"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+}
+
+type KustomizeBundleDetail struct {
+	*model.KustomizeBundle
+	Files []string `json:"files"`
+}
+
+type KustomizeRenderResp struct {
+	Objects []*unstructured.Unstructured `json:"objects"`
+	Yaml    string                       `json:"yaml"`
+}
+
+//KustomizeObjectDiff 单个对象与集群的差异, Diff为集群中对象与dry-run结果的unified diff
+type KustomizeObjectDiff struct {
+	KustomizeObjectRef
+	Action string `json:"action"`
+	Diff   string `json:"diff"`
+	Error  string `json:"error"`
+}
+
+type KustomizeApplyResult struct {
+	KustomizeObjectRef
+	Action string `json:"action"`
+	Error  string `json:"error"`
+}
+
+type KustomizeApplyResp struct {
+	Results []*KustomizeApplyResult `json:"results"`
+	Failed  int                     `json:"failed"`
+}
+
+//kustomizeTarget 渲染后的对象及其resource映射, namespace级别对象未指定namespace时已设置为default
+type kustomizeTarget struct {
+	obj        *unstructured.Unstructured
+	mapping    *meta.RESTMapping
+	namespaced bool
+}
+
+//获取bundle列表
+func (k *kustomize) GetBundles(name string, page, limit int) (bundlesResp *dao.KustomizeBundleResp, err error) {
+	return dao.KustomizeBundle.GetList(name, page, limit)
+}
+
+//获取bundle详情, 包括压缩包中的文件列表
+func (k *kustomize) GetBundleDetail(id int) (detail *KustomizeBundleDetail, err error) {
+	bundle, err := getKustomizeBundle(id)
+	if err != nil {
+		return nil, err
+	}
+	_, files, err := extractKustomizeArchive(bundle.Archive)
+	if err != nil {
+		logger.Error(errors.New("解压kustomize bundle失败, " + err.Error()))
+		return nil, errors.New("解压kustomize bundle失败, " + err.Error())
+	}
+	return &KustomizeBundleDetail{KustomizeBundle: bundle, Files: files}, nil
+}
+
+//保存bundle, 同名bundle存在时替换压缩包并保留inventory, 保存前校验能否渲染
+func (k *kustomize) SaveBundle(name, description, kustomizationPath string, archive []byte) (bundle *model.KustomizeBundle, err error) {
+	if name == "" {
+		return nil, errors.New("bundle名字不能为空")
+	}
+	fs, _, err := extractKustomizeArchive(archive)
+	if err != nil {
+		logger.Error(errors.New("解压kustomization压缩包失败, " + err.Error()))
+		return nil, errors.New("解压kustomization压缩包失败, " + err.Error())
+	}
+	if _, _, err = renderKustomization(fs, kustomizationPath); err != nil {
+		logger.Error(errors.New("渲染kustomization失败, " + err.Error()))
+		return nil, errors.New("渲染kustomization失败, " + err.Error())
+	}
+
+	bundle, err = dao.KustomizeBundle.GetByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if bundle == nil {
+		bundle = &model.KustomizeBundle{Name: name}
+	}
+	bundle.Description = description
+	bundle.Path = kustomizationPath
+	bundle.Size = len(archive)
+	bundle.Archive = archive
+	if err = dao.KustomizeBundle.Save(bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+//删除bundle, 只删除数据库数据, 已apply的对象保留在集群中
+func (k *kustomize) DeleteBundle(id int) (err error) {
+	if _, err = getKustomizeBundle(id); err != nil {
+		return err
+	}
+	return dao.KustomizeBundle.DelById(id)
+}
+
+//渲染kustomization, 返回渲染后的对象和yaml
+func (k *kustomize) Render(source *KustomizeSource, archive []byte) (renderResp *KustomizeRenderResp, err error) {
+	_, objs, content, err := loadKustomization(source, archive)
+	if err != nil {
+		return nil, err
+	}
+	return &KustomizeRenderResp{Objects: objs, Yaml: content}, nil
+}
+
+//对比渲染结果与集群中的对象, 使用server-side apply的dry-run结果作为对比对象, 忽略apiserver维护的字段
+//prune为true时同时列出将被删除的对象
+func (k *kustomize) Diff(source *KustomizeSource, archive []byte) (diffs []*KustomizeObjectDiff, err error) {
+	bundle, objs, _, err := loadKustomization(source, archive)
+	if err != nil {
+		return nil, err
+	}
+	if source.Prune && bundle == nil {
+		return nil, errors.New("prune只能用于已保存的bundle")
+	}
+	mapper, err := newRESTMapper()
+	if err != nil {
+		logger.Error(errors.New("获取API资源映射失败, " + err.Error()))
+		return nil, errors.New("获取API资源映射失败, " + err.Error())
+	}
+	if bundle != nil {
+		labelBundleObjects(bundle, objs)
+	}
+
+	diffs = make([]*KustomizeObjectDiff, 0, len(objs))
+	targets := make([]*kustomizeTarget, 0, len(objs))
+	for _, obj := range objs {
+		target, err := newKustomizeTarget(mapper, obj)
+		if err != nil {
+			diffs = append(diffs, &KustomizeObjectDiff{KustomizeObjectRef: objectRef(obj), Action: KustomizeActionFailed, Error: err.Error()})
+			continue
+		}
+		targets = append(targets, target)
+		diffs = append(diffs, diffObject(mapper, target))
+	}
+	if !source.Prune {
+		return diffs, nil
+	}
+
+	for _, ref := range pruneCandidates(bundle, targets) {
+		live, err := getPruneObject(mapper, bundle, ref)
+		switch {
+		case err != nil:
+			diffs = append(diffs, &KustomizeObjectDiff{KustomizeObjectRef: ref, Action: KustomizeActionFailed, Error: err.Error()})
+		case live != nil:
+			content, _ := yaml.Marshal(cleanDiffObject(live).Object)
+			diffs = append(diffs, &KustomizeObjectDiff{KustomizeObjectRef: ref, Action: KustomizeActionPrune, Diff: unifiedDiff(string(content), "")})
+		}
+	}
+	return diffs, nil
+}
+
+//使用server-side apply逐个应用渲染后的对象, 单个对象失败不影响其他对象
+//从bundle apply时对象带有bundle标签并记录到inventory, prune时删除inventory中已不在overlay里的对象
+//存在应用失败的对象时不执行prune, 避免误删
+func (k *kustomize) Apply(source *KustomizeSource, archive []byte) (applyResp *KustomizeApplyResp, err error) {
+	bundle, objs, _, err := loadKustomization(source, archive)
+	if err != nil {
+		return nil, err
+	}
+	if source.Prune && bundle == nil {
+		return nil, errors.New("prune只能用于已保存的bundle")
+	}
+	mapper, err := newRESTMapper()
+	if err != nil {
+		logger.Error(errors.New("获取API资源映射失败, " + err.Error()))
+		return nil, errors.New("获取API资源映射失败, " + err.Error())
+	}
+	if bundle != nil {
+		labelBundleObjects(bundle, objs)
+	}
+
+	applyResp = &KustomizeApplyResp{Results: make([]*KustomizeApplyResult, 0, len(objs))}
+	targets := make([]*kustomizeTarget, 0, len(objs))
+	applied := make([]KustomizeObjectRef, 0, len(objs))
+	for _, obj := range objs {
+		result := &KustomizeApplyResult{KustomizeObjectRef: objectRef(obj), Action: KustomizeActionApplied}
+		applyResp.Results = append(applyResp.Results, result)
+		target, err := newKustomizeTarget(mapper, obj)
+		if err == nil {
+			targets = append(targets, target)
+			result.KustomizeObjectRef = objectRef(obj)
+			_, err = applyObject(mapper, obj, source.DryRun)
+		}
+		if err != nil {
+			logger.Error(errors.New("应用" + obj.GetKind() + ": " + obj.GetName() + " 失败, " + err.Error()))
+			result.Action = KustomizeActionFailed
+			result.Error = err.Error()
+			applyResp.Failed++
+			continue
+		}
+		applied = append(applied, result.KustomizeObjectRef)
+	}
+
+	pruned := make([]KustomizeObjectRef, 0)
+	if source.Prune {
+		candidates := pruneCandidates(bundle, targets)
+		for _, ref := range candidates {
+			result := &KustomizeApplyResult{KustomizeObjectRef: ref, Action: KustomizeActionSkipped}
+			applyResp.Results = append(applyResp.Results, result)
+			if applyResp.Failed > 0 {
+				result.Error = "存在应用失败的对象, 跳过prune"
+				continue
+			}
+			if err := pruneObject(mapper, bundle, ref, source.DryRun); err != nil {
+				logger.Error(errors.New("prune " + ref.Kind + ": " + ref.Name + " 失败, " + err.Error()))
+				result.Action = KustomizeActionPruneFailed
+				result.Error = err.Error()
+				applyResp.Failed++
+				continue
+			}
+			result.Action = KustomizeActionPruned
+			pruned = append(pruned, ref)
+		}
+	}
+
+	if bundle != nil && !source.DryRun {
+		if err = saveBundleInventory(bundle, applied, pruned); err != nil {
+			return nil, err
+		}
+	}
+	return applyResp, nil
+}
+
+//getKustomizeBundle 获取bundle, 不存在时返回错误
+func getKustomizeBundle(id int) (*model.KustomizeBundle, error) {
+	bundle, err := dao.KustomizeBundle.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+	if bundle == nil {
+		logger.Error(errors.New("kustomize bundle: " + strconv.Itoa(id) + " 不存在"))
+		return nil, errors.New("kustomize bundle: " + strconv.Itoa(id) + " 不存在")
+	}
+	return bundle, nil
+}
+
+//loadKustomization 解压并渲染kustomization, 使用bundle时同时返回bundle
+func loadKustomization(source *KustomizeSource, archive []byte) (*model.KustomizeBundle, []*unstructured.Unstructured, string, error) {
+	var bundle *model.KustomizeBundle
+	kustomizationPath := source.Path
+	if len(archive) == 0 {
+		if source.BundleID == 0 {
+			return nil, nil, "", errors.New("请上传kustomization压缩包或指定bundle_id")
+		}
+		var err error
+		bundle, err = getKustomizeBundle(source.BundleID)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		archive = bundle.Archive
+		if kustomizationPath == "" {
+			kustomizationPath = bundle.Path
+		}
+	}
+
+	fs, _, err := extractKustomizeArchive(archive)
+	if err != nil {
+		logger.Error(errors.New("解压kustomization压缩包失败, " + err.Error()))
+		return nil, nil, "", errors.New("解压kustomization压缩包失败, " + err.Error())
+	}
+	objs, content, err := renderKustomization(fs, kustomizationPath)
+	if err != nil {
+		logger.Error(errors.New("渲染kustomization失败, " + err.Error()))
+		return nil, nil, "", errors.New("渲染kustomization失败, " + err.Error())
+	}
+	return bundle, objs, content, nil
+}
+
+//extractKustomizeArchive 将tar、tar.gz或zip压缩包解压到内存文件系统, 返回文件列表
+//文件路径以压缩包根目录清理, 不会跳出根目录, 解压后的总大小不能超过KustomizeMaxArchiveSize
+func extractKustomizeArchive(archive []byte) (filesys.FileSystem, []string, error) {
+	if len(archive) == 0 {
+		return nil, nil, errors.New("压缩包内容为空")
+	}
+	fs := filesys.MakeFsInMemory()
+	files := make([]string, 0)
+	total := int64(0)
+	write := func(name string, reader io.Reader) error {
+		name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+		content, err := io.ReadAll(io.LimitReader(reader, config.KustomizeMaxArchiveSize-total+1))
+		if err != nil {
+			return err
+		}
+		total += int64(len(content))
+		if total > config.KustomizeMaxArchiveSize {
+			return fmt.Errorf("解压后的大小超过%dMB", config.KustomizeMaxArchiveSize>>20)
+		}
+		if err = fs.MkdirAll(path.Dir(name)); err != nil {
+			return err
+		}
+		files = append(files, strings.TrimPrefix(name, "/"))
+		return fs.WriteFile(name, content)
+	}
+
+	if bytes.HasPrefix(archive, []byte("PK\x03\x04")) {
+		reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, file := range reader.File {
+			if file.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, nil, err
+			}
+			err = write(file.Name, rc)
+			rc.Close()
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		sort.Strings(files)
+		return fs, files, nil
+	}
+
+	var stream io.Reader = bytes.NewReader(archive)
+	if bytes.HasPrefix(archive, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(stream)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gzipReader.Close()
+		stream = gzipReader
+	}
+	tarReader := tar.NewReader(stream)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.New("不是合法的tar或zip压缩包, " + err.Error())
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err = write(header.Name, tarReader); err != nil {
+			return nil, nil, err
+		}
+	}
+	sort.Strings(files)
+	return fs, files, nil
+}
+
+//renderKustomization 使用kustomize渲染指定目录, 禁用插件且只能引用kustomization目录内的文件
+//对象按kustomize legacy顺序排列, namespace和CRD在前
+func renderKustomization(fs filesys.FileSystem, kustomizationPath string) ([]*unstructured.Unstructured, string, error) {
+	dir := path.Clean("/" + kustomizationPath)
+	options := krusty.MakeDefaultOptions()
+	options.Reorder = krusty.ReorderOptionLegacy
+	resMap, err := krusty.MakeKustomizer(options).Run(fs, dir)
+	if err != nil {
+		return nil, "", err
+	}
+	content, err := resMap.AsYaml()
+	if err != nil {
+		return nil, "", err
+	}
+	objs := make([]*unstructured.Unstructured, 0, resMap.Size())
+	for _, res := range resMap.Resources() {
+		object, err := res.Map()
+		if err != nil {
+			return nil, "", err
+		}
+		objs = append(objs, &unstructured.Unstructured{Object: object})
+	}
+	return objs, string(content), nil
+}
+
+//newKustomizeTarget 获取对象的resource映射, namespace级别对象未指定namespace时设置为default
+func newKustomizeTarget(mapper meta.RESTMapper, obj *unstructured.Unstructured) (*kustomizeTarget, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	if namespaced && obj.GetNamespace() == "" {
+		obj.SetNamespace("default")
+	}
+	if !namespaced {
+		obj.SetNamespace("")
+	}
+	return &kustomizeTarget{obj: obj, mapping: mapping, namespaced: namespaced}, nil
+}
+
+//diffObject 对比单个对象, 集群中不存在时与空内容对比
+func diffObject(mapper meta.RESTMapper, target *kustomizeTarget) *KustomizeObjectDiff {
+	obj := target.obj
+	diff := &KustomizeObjectDiff{KustomizeObjectRef: objectRef(obj)}
+	client := resourceClient(target.mapping.Resource, target.namespaced, obj.GetNamespace())
+	live, err := client.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		diff.Action = KustomizeActionFailed
+		diff.Error = err.Error()
+		return diff
+	}
+	if apierrors.IsNotFound(err) {
+		live = nil
+	}
+	merged, err := applyObject(mapper, obj.DeepCopy(), true)
+	if err != nil {
+		diff.Action = KustomizeActionFailed
+		diff.Error = err.Error()
+		return diff
+	}
+
+	if live == nil {
+		mergedContent, _ := yaml.Marshal(maskDiffSecret(nil, cleanDiffObject(merged)).Object)
+		diff.Action = KustomizeActionCreate
+		diff.Diff = unifiedDiff("", string(mergedContent))
+		return diff
+	}
+	if reflect.DeepEqual(cleanDiffObject(live).Object, cleanDiffObject(merged).Object) {
+		diff.Action = KustomizeActionUnchanged
+		return diff
+	}
+	//是否有变化按原始内容判断, 输出diff前再脱敏Secret
+	liveContent, _ := yaml.Marshal(maskDiffSecret(nil, cleanDiffObject(live)).Object)
+	mergedContent, _ := yaml.Marshal(maskDiffSecret(cleanDiffObject(live), cleanDiffObject(merged)).Object)
+	diff.Action = KustomizeActionUpdate
+	diff.Diff = unifiedDiff(string(liveContent), string(mergedContent))
+	return diff
+}
+
+//maskDiffSecret 脱敏Secret的data和stringData, 其他对象原样返回
+//live不为空时, 与live中值不同的key标记为changed, diff中仍能看出哪些key被修改
+func maskDiffSecret(live, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	gvk := obj.GroupVersionKind()
+	if gvk.Group != "" || gvk.Kind != "Secret" {
+		return obj
+	}
+	var liveData map[string]interface{}
+	if live != nil {
+		liveData, _, _ = unstructured.NestedMap(live.Object, "data")
+	}
+	for _, field := range []string{"data", "stringData"} {
+		values, found, _ := unstructured.NestedMap(obj.Object, field)
+		if !found {
+			continue
+		}
+		for key, value := range values {
+			liveValue, ok := liveData[key]
+			if live != nil && (!ok || !reflect.DeepEqual(liveValue, value)) {
+				values[key] = config.MaskValue + " (changed)"
+				continue
+			}
+			values[key] = config.MaskValue
+		}
+		_ = unstructured.SetNestedMap(obj.Object, values, field)
+	}
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[corev1.LastAppliedConfigAnnotation]; ok {
+		delete(annotations, corev1.LastAppliedConfigAnnotation)
+		obj.SetAnnotations(annotations)
+	}
+	return obj
+}
+
+//cleanDiffObject 去掉apiserver维护的字段和status, 只对比用户关心的内容
+func cleanDiffObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	cleaned := obj.DeepCopy()
+	for _, field := range []string{"managedFields", "resourceVersion", "generation", "uid", "creationTimestamp"} {
+		unstructured.RemoveNestedField(cleaned.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(cleaned.Object, "status")
+	return cleaned
+}
+
+func unifiedDiff(from, to string) string {
+	content, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "live",
+		ToFile:   "rendered",
+		Context:  3,
+	})
+	return content
+}
+
+//labelBundleObjects 为从bundle渲染的对象加上bundle标签, prune时据此确认对象属于该bundle
+func labelBundleObjects(bundle *model.KustomizeBundle, objs []*unstructured.Unstructured) {
+	for _, obj := range objs {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[config.KustomizeBundleLabel] = bundle.Name
+		obj.SetLabels(labels)
+	}
+}
+
+func objectRef(obj *unstructured.Unstructured) KustomizeObjectRef {
+	return KustomizeObjectRef{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
+
+//inventoryKey 对象的唯一标识, 忽略version, 同一个对象升级apiVersion后不会被prune
+func inventoryKey(ref KustomizeObjectRef) string {
+	gv, _ := schema.ParseGroupVersion(ref.APIVersion)
+	return gv.Group + "/" + ref.Kind + "/" + ref.Namespace + "/" + ref.Name
+}
+
+func bundleInventory(bundle *model.KustomizeBundle) []KustomizeObjectRef {
+	inventory := make([]KustomizeObjectRef, 0)
+	if bundle.Inventory == "" {
+		return inventory
+	}
+	if err := json.Unmarshal([]byte(bundle.Inventory), &inventory); err != nil {
+		logger.Error(errors.New("解析kustomize bundle inventory失败, " + err.Error()))
+	}
+	return inventory
+}
+
+//pruneCandidates inventory中不在本次渲染结果里的对象, 按inventory的倒序删除
+func pruneCandidates(bundle *model.KustomizeBundle, targets []*kustomizeTarget) []KustomizeObjectRef {
+	rendered := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		rendered[inventoryKey(objectRef(target.obj))] = true
+	}
+	inventory := bundleInventory(bundle)
+	candidates := make([]KustomizeObjectRef, 0)
+	for i := len(inventory) - 1; i >= 0; i-- {
+		if !rendered[inventoryKey(inventory[i])] {
+			candidates = append(candidates, inventory[i])
+		}
+	}
+	return candidates
+}
+
+//getPruneObject 获取待prune的对象, 对象不存在或不带该bundle的标签时返回nil
+func getPruneObject(mapper meta.RESTMapper, bundle *model.KustomizeBundle, ref KustomizeObjectRef) (*unstructured.Unstructured, error) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: ref.Kind}, gv.Version)
+	if err != nil {
+		return nil, err
+	}
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	live, err := resourceClient(mapping.Resource, namespaced, ref.Namespace).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if live.GetLabels()[config.KustomizeBundleLabel] != bundle.Name {
+		return nil, nil
+	}
+	return live, nil
+}
+
+//pruneObject 删除待prune的对象, 对象已不存在或已不属于该bundle时跳过
+func pruneObject(mapper meta.RESTMapper, bundle *model.KustomizeBundle, ref KustomizeObjectRef, dryRun bool) error {
+	live, err := getPruneObject(mapper, bundle, ref)
+	if err != nil || live == nil {
+		return err
+	}
+	gv, _ := schema.ParseGroupVersion(ref.APIVersion)
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: ref.Kind}, gv.Version)
+	if err != nil {
+		return err
+	}
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	propagation := metav1.DeletePropagationBackground
+	err = resourceClient(mapping.Resource, namespaced, ref.Namespace).Delete(context.TODO(), ref.Name, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+		DryRun:            dryRunOption(dryRun),
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+//saveBundleInventory 新的inventory为原有对象加上本次应用成功的对象, 再去掉本次prune的对象
+//未开启prune时overlay中删除的对象仍保留在inventory中, 之后开启prune时再删除
+func saveBundleInventory(bundle *model.KustomizeBundle, applied, pruned []KustomizeObjectRef) error {
+	removed := make(map[string]bool, len(pruned))
+	for _, ref := range pruned {
+		removed[inventoryKey(ref)] = true
+	}
+	inventory := make([]KustomizeObjectRef, 0)
+	seen := map[string]int{}
+	for _, ref := range append(bundleInventory(bundle), applied...) {
+		key := inventoryKey(ref)
+		if removed[key] {
+			continue
+		}
+		//同一个对象以最新apply的apiVersion为准
+		if i, ok := seen[key]; ok {
+			inventory[i] = ref
+			continue
+		}
+		seen[key] = len(inventory)
+		inventory = append(inventory, ref)
+	}
+
+	content, err := json.Marshal(inventory)
+	if err != nil {
+		logger.Error(errors.New("序列化kustomize bundle inventory失败, " + err.Error()))
+		return errors.New("序列化kustomize bundle inventory失败, " + err.Error())
+	}
+	now := time.Now()
+	bundle.Inventory = string(content)
+	bundle.AppliedAt = &now
+	return dao.KustomizeBundle.Save(bundle)
+}
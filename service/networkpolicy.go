@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/wonderivan/logger"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var NetworkPolicy networkPolicy
+
+type networkPolicy struct{}
+
+type NetworkPolicyResp struct {
+	Items []nwv1.NetworkPolicy `json:"items"`
+	Total int                  `json:"total"`
+}
+
+//定义NetworkPolicyCreate结构体, 用于创建networkpolicy需要的参数属性的定义
+//PodSelector为空时选中namespace下所有pod, PolicyTypes为空时由apiserver根据规则推断
+type NetworkPolicyCreate struct {
+	Name        string                          `json:"name"`
+	Namespace   string                          `json:"namespace"`
+	Label       map[string]string               `json:"label"`
+	PodSelector map[string]string               `json:"pod_selector"`
+	PolicyTypes []string                        `json:"policy_types"`
+	Ingress     []nwv1.NetworkPolicyIngressRule `json:"ingress"`
+	Egress      []nwv1.NetworkPolicyEgressRule  `json:"egress"`
+}
+
+//数据类型转换
+func (np *networkPolicy) toCells(std []nwv1.NetworkPolicy) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = NetworkPolicyCell(std[i])
+	}
+	return cells
+}
+
+func (np *networkPolicy) fromCells(cells []DataCell) []nwv1.NetworkPolicy {
+	networkPolicies := make([]nwv1.NetworkPolicy, len(cells))
+	for i := range cells {
+		networkPolicies[i] = nwv1.NetworkPolicy(cells[i].(NetworkPolicyCell))
+	}
+	return networkPolicies
+}
+
+func (np *networkPolicy) GetNetworkPolicies(filterName, namespace string, limit, page int) (networkPolicyResp *NetworkPolicyResp, err error) {
+	networkPolicyList, err := K8s.Clientset.NetworkingV1().NetworkPolicies(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取NetworkPolicy列表失败, " + err.Error()))
+		return nil, errors.New("获取NetworkPolicy列表失败, " + err.Error())
+	}
+
+	selectableData := &dataSelector{
+		GenericDataList: np.toCells(networkPolicyList.Items),
+		DataSelectQuery: &DataSelectQuery{
+			FilterQuery: &FilterQuery{Name: filterName},
+			PaginateQuery: &PaginateQuery{
+				Limit: limit,
+				Page:  page,
+			},
+		},
+	}
+
+	filtered := selectableData.Filter()
+	total := len(filtered.GenericDataList)
+	data := filtered.Sort().Paginate()
+
+	return &NetworkPolicyResp{
+		Items: np.fromCells(data.GenericDataList),
+		Total: total,
+	}, nil
+}
+
+func (np *networkPolicy) GetNetworkPolicyDetail(networkPolicyName, namespace string) (networkPolicy *nwv1.NetworkPolicy, err error) {
+	networkPolicy, err = K8s.Clientset.NetworkingV1().NetworkPolicies(namespace).Get(context.TODO(), networkPolicyName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取NetworkPolicy 详情失败, " + err.Error()))
+		return nil, errors.New("获取NetworkPolicy 详情失败, " + err.Error())
+	}
+	return networkPolicy, nil
+}
+
+func (np *networkPolicy) DeleteNetworkPolicy(networkPolicyName, namespace string) (err error) {
+	err = K8s.Clientset.NetworkingV1().NetworkPolicies(namespace).Delete(context.TODO(), networkPolicyName, metav1.DeleteOptions{})
+	if err != nil {
+		logger.Error(errors.New("删除NetworkPolicy: " + networkPolicyName + " 失败, " + err.Error()))
+		return errors.New("删除 NetworkPolicy 失败, " + err.Error())
+	}
+	return nil
+}
+
+func (np *networkPolicy) CreateNetworkPolicy(data *NetworkPolicyCreate) (err error) {
+	networkPolicy := &nwv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      data.Name,
+			Namespace: data.Namespace,
+			Labels:    data.Label,
+		},
+		Spec: nwv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: data.PodSelector},
+			Ingress:     data.Ingress,
+			Egress:      data.Egress,
+		},
+	}
+	for _, policyType := range data.PolicyTypes {
+		networkPolicy.Spec.PolicyTypes = append(networkPolicy.Spec.PolicyTypes, nwv1.PolicyType(policyType))
+	}
+
+	_, err = K8s.Clientset.NetworkingV1().NetworkPolicies(data.Namespace).Create(context.TODO(), networkPolicy, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建NetworkPolicy: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建 NetworkPolicy 失败, " + err.Error())
+	}
+	return nil
+}
+
+func (np *networkPolicy) UpdateNetworkPolicy(namespace, content string) (err error) {
+	var networkPolicy = &nwv1.NetworkPolicy{}
+
+	err = json.Unmarshal([]byte(content), networkPolicy)
+	if err != nil {
+		logger.Error(errors.New("JONS反序列化失败." + err.Error()))
+		return errors.New("JONS反序列化失败." + err.Error())
+	}
+	_, err = K8s.Clientset.NetworkingV1().NetworkPolicies(namespace).Update(context.TODO(), networkPolicy, metav1.UpdateOptions{})
+	if err != nil {
+		logger.Error(errors.New("更新NetworkPolicy: " + networkPolicy.Name + " 失败, " + err.Error()))
+		return errors.New("更新 NetworkPolicy 失败, " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// 定义NetworkPolicyExplainInput结构体, 离线判断连通性所需的全部数据
+// 只依赖策略和标签数据, 不依赖CNI, 可以直接构造数据进行判断
+type NetworkPolicyExplainInput struct {
+	//源pod和目标pod所在namespace下的所有NetworkPolicy
+	Policies []nwv1.NetworkPolicy
+	//namespace名 -> namespace标签, 用于匹配namespaceSelector
+	NamespaceLabels map[string]map[string]string
+	Source          *corev1.Pod
+	Destination     *corev1.Pod
+	Port            int32
+	Protocol        corev1.Protocol
+}
+
+// 定义NetworkPolicyExplain结构体, 返回连通性判断结果
+type NetworkPolicyExplain struct {
+	Allowed bool                    `json:"allowed"`
+	Reason  string                  `json:"reason"`
+	Egress  *NetworkPolicyDirection `json:"egress"`
+	Ingress *NetworkPolicyDirection `json:"ingress"`
+}
+
+// 定义NetworkPolicyDirection结构体, 返回单个方向(源pod出站/目标pod入站)的判断结果
+// Isolated为false表示没有策略选中该pod, 该方向默认放行
+type NetworkPolicyDirection struct {
+	Isolated     bool                 `json:"isolated"`
+	Allowed      bool                 `json:"allowed"`
+	Policies     []string             `json:"policies"`
+	MatchedRules []*NetworkPolicyRule `json:"matched_rules"`
+}
+
+// 定义NetworkPolicyRule结构体, 表示命中的一条规则, RuleIndex为规则在ingress/egress中的下标
+type NetworkPolicyRule struct {
+	Policy    string `json:"policy"`
+	RuleIndex int    `json:"rule_index"`
+}
+
+// 判断源pod到目标pod指定端口的流量是否被NetworkPolicy放行
+// 需要同时满足: 源pod出站方向放行, 且目标pod入站方向放行
+func (np *networkPolicy) ExplainNetworkPolicy(sourcePod, sourceNamespace, destinationPod, destinationNamespace string, port int32, protocol string) (explain *NetworkPolicyExplain, err error) {
+	source, err := K8s.Clientset.CoreV1().Pods(sourceNamespace).Get(context.TODO(), sourcePod, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取源Pod失败, " + err.Error()))
+		return nil, errors.New("获取源Pod失败, " + err.Error())
+	}
+	destination, err := K8s.Clientset.CoreV1().Pods(destinationNamespace).Get(context.TODO(), destinationPod, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取目标Pod失败, " + err.Error()))
+		return nil, errors.New("获取目标Pod失败, " + err.Error())
+	}
+
+	namespaceList, err := K8s.Clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Namespace列表失败, " + err.Error()))
+		return nil, errors.New("获取Namespace列表失败, " + err.Error())
+	}
+	namespaceLabels := map[string]map[string]string{}
+	for _, item := range namespaceList.Items {
+		namespaceLabels[item.Name] = item.Labels
+	}
+
+	input := &NetworkPolicyExplainInput{
+		NamespaceLabels: namespaceLabels,
+		Source:          source,
+		Destination:     destination,
+		Port:            port,
+		Protocol:        corev1.Protocol(protocol),
+	}
+	for _, namespace := range []string{sourceNamespace, destinationNamespace} {
+		networkPolicyList, err := K8s.Clientset.NetworkingV1().NetworkPolicies(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			logger.Error(errors.New("获取NetworkPolicy列表失败, " + err.Error()))
+			return nil, errors.New("获取NetworkPolicy列表失败, " + err.Error())
+		}
+		input.Policies = append(input.Policies, networkPolicyList.Items...)
+		if sourceNamespace == destinationNamespace {
+			break
+		}
+	}
+	return ExplainNetworkPolicies(input), nil
+}
+
+// 根据策略和标签数据离线判断连通性
+func ExplainNetworkPolicies(input *NetworkPolicyExplainInput) (explain *NetworkPolicyExplain) {
+	if input.Protocol == "" {
+		input.Protocol = corev1.ProtocolTCP
+	}
+	explain = &NetworkPolicyExplain{
+		Egress:  &NetworkPolicyDirection{},
+		Ingress: &NetworkPolicyDirection{},
+	}
+
+	for _, policy := range input.Policies {
+		//源pod的出站方向, 只看源pod所在namespace的策略
+		if policy.Namespace == input.Source.Namespace && hasPolicyType(&policy, nwv1.PolicyTypeEgress) &&
+			selectorMatches(&policy.Spec.PodSelector, input.Source.Labels) {
+			explain.Egress.Isolated = true
+			explain.Egress.Policies = append(explain.Egress.Policies, policy.Name)
+			for i, rule := range policy.Spec.Egress {
+				if input.peersMatch(policy.Namespace, rule.To, input.Destination) && input.portsMatch(rule.Ports) {
+					explain.Egress.MatchedRules = append(explain.Egress.MatchedRules, &NetworkPolicyRule{Policy: policy.Name, RuleIndex: i})
+				}
+			}
+		}
+		//目标pod的入站方向, 只看目标pod所在namespace的策略
+		if policy.Namespace == input.Destination.Namespace && hasPolicyType(&policy, nwv1.PolicyTypeIngress) &&
+			selectorMatches(&policy.Spec.PodSelector, input.Destination.Labels) {
+			explain.Ingress.Isolated = true
+			explain.Ingress.Policies = append(explain.Ingress.Policies, policy.Name)
+			for i, rule := range policy.Spec.Ingress {
+				if input.peersMatch(policy.Namespace, rule.From, input.Source) && input.portsMatch(rule.Ports) {
+					explain.Ingress.MatchedRules = append(explain.Ingress.MatchedRules, &NetworkPolicyRule{Policy: policy.Name, RuleIndex: i})
+				}
+			}
+		}
+	}
+
+	//没有被策略选中的方向默认放行, 被选中的方向只要命中任意一条规则即放行
+	explain.Egress.Allowed = !explain.Egress.Isolated || len(explain.Egress.MatchedRules) > 0
+	explain.Ingress.Allowed = !explain.Ingress.Isolated || len(explain.Ingress.MatchedRules) > 0
+	explain.Allowed = explain.Egress.Allowed && explain.Ingress.Allowed
+
+	target := fmt.Sprintf("%s/%s -> %s/%s:%d/%s", input.Source.Namespace, input.Source.Name,
+		input.Destination.Namespace, input.Destination.Name, input.Port, input.Protocol)
+	switch {
+	case !explain.Egress.Allowed:
+		explain.Reason = fmt.Sprintf("%s 被拒绝: 源Pod被策略 %v 选中, 但没有出站规则放行该流量", target, explain.Egress.Policies)
+	case !explain.Ingress.Allowed:
+		explain.Reason = fmt.Sprintf("%s 被拒绝: 目标Pod被策略 %v 选中, 但没有入站规则放行该流量", target, explain.Ingress.Policies)
+	default:
+		explain.Reason = fmt.Sprintf("%s 放行", target)
+	}
+	return explain
+}
+
+// 判断策略是否包含某个方向
+// 未设置policyTypes时, 默认包含Ingress, 有egress规则时才包含Egress
+func hasPolicyType(policy *nwv1.NetworkPolicy, policyType nwv1.PolicyType) bool {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return policyType == nwv1.PolicyTypeIngress || len(policy.Spec.Egress) > 0
+	}
+	for _, item := range policy.Spec.PolicyTypes {
+		if item == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断标签是否匹配选择器, 非法的选择器视为不匹配
+func selectorMatches(selector *metav1.LabelSelector, podLabels map[string]string) bool {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(podLabels))
+}
+
+// 判断对端pod是否命中规则中的peer, peer为空表示匹配所有对端
+func (input *NetworkPolicyExplainInput) peersMatch(policyNamespace string, peers []nwv1.NetworkPolicyPeer, pod *corev1.Pod) bool {
+	if len(peers) == 0 {
+		return true
+	}
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			if ipBlockMatches(peer.IPBlock, pod.Status.PodIP) {
+				return true
+			}
+			continue
+		}
+		//只有podSelector时, 匹配策略所在namespace下的pod
+		if peer.NamespaceSelector == nil {
+			if pod.Namespace == policyNamespace && selectorMatches(peer.PodSelector, pod.Labels) {
+				return true
+			}
+			continue
+		}
+		if !selectorMatches(peer.NamespaceSelector, input.NamespaceLabels[pod.Namespace]) {
+			continue
+		}
+		if peer.PodSelector == nil || selectorMatches(peer.PodSelector, pod.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断ip是否在ipBlock中且不在except中
+func ipBlockMatches(ipBlock *nwv1.IPBlock, podIP string) bool {
+	ip := net.ParseIP(podIP)
+	if ip == nil {
+		return false
+	}
+	_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil || !cidr.Contains(ip) {
+		return false
+	}
+	for _, except := range ipBlock.Except {
+		_, exceptCidr, err := net.ParseCIDR(except)
+		if err == nil && exceptCidr.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// 判断目标端口是否命中规则中的ports, ports为空表示匹配所有端口
+// 命名端口通过目标pod的容器端口解析
+func (input *NetworkPolicyExplainInput) portsMatch(ports []nwv1.NetworkPolicyPort) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, port := range ports {
+		protocol := corev1.ProtocolTCP
+		if port.Protocol != nil {
+			protocol = *port.Protocol
+		}
+		if protocol != input.Protocol {
+			continue
+		}
+		if port.Port == nil {
+			return true
+		}
+		number := port.Port.IntVal
+		if port.Port.StrVal != "" {
+			number = input.namedPort(port.Port.StrVal, protocol)
+			if number == 0 {
+				continue
+			}
+		}
+		if port.EndPort != nil && port.Port.StrVal == "" {
+			if input.Port >= number && input.Port <= *port.EndPort {
+				return true
+			}
+			continue
+		}
+		if input.Port == number {
+			return true
+		}
+	}
+	return false
+}
+
+// 在目标pod的容器端口中解析命名端口, 找不到返回0
+func (input *NetworkPolicyExplainInput) namedPort(name string, protocol corev1.Protocol) int32 {
+	for _, container := range input.Destination.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			portProtocol := containerPort.Protocol
+			if portProtocol == "" {
+				portProtocol = corev1.ProtocolTCP
+			}
+			if containerPort.Name == name && portProtocol == protocol {
+				return containerPort.ContainerPort
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func explainPod(namespace, name string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "app", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}},
+			},
+		},
+	}
+}
+
+func explainPolicy(namespace, name string, podSelector map[string]string, spec nwv1.NetworkPolicySpec) nwv1.NetworkPolicy {
+	spec.PodSelector = metav1.LabelSelector{MatchLabels: podSelector}
+	return nwv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}, Spec: spec}
+}
+
+func explainPorts(ports ...intstr.IntOrString) []nwv1.NetworkPolicyPort {
+	result := make([]nwv1.NetworkPolicyPort, 0, len(ports))
+	for i := range ports {
+		result = append(result, nwv1.NetworkPolicyPort{Port: &ports[i]})
+	}
+	return result
+}
+
+func TestExplainNetworkPolicies(t *testing.T) {
+	frontend := explainPod("web", "frontend", map[string]string{"app": "frontend"})
+	backend := explainPod("api", "backend", map[string]string{"app": "backend"})
+	namespaceLabels := map[string]map[string]string{
+		"web": {"team": "web"},
+		"api": {"team": "api"},
+	}
+	fromTeam := func(team string) []nwv1.NetworkPolicyIngressRule {
+		return []nwv1.NetworkPolicyIngressRule{{
+			From: []nwv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": team}}}},
+		}}
+	}
+
+	tests := []struct {
+		name            string
+		policies        []nwv1.NetworkPolicy
+		port            int32
+		allowed         bool
+		ingressIsolated bool
+		egressIsolated  bool
+		matchedIngress  int
+	}{
+		{
+			name:    "no policies allows by default",
+			port:    8080,
+			allowed: true,
+		},
+		{
+			name: "policy selecting another pod does not isolate",
+			policies: []nwv1.NetworkPolicy{
+				explainPolicy("api", "deny-db", map[string]string{"app": "db"}, nwv1.NetworkPolicySpec{}),
+			},
+			port:    8080,
+			allowed: true,
+		},
+		{
+			name: "default deny ingress",
+			policies: []nwv1.NetworkPolicy{
+				explainPolicy("api", "deny-all", nil, nwv1.NetworkPolicySpec{PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress}}),
+			},
+			port:            8080,
+			allowed:         false,
+			ingressIsolated: true,
+		},
+		{
+			name: "default deny egress in the source namespace",
+			policies: []nwv1.NetworkPolicy{
+				explainPolicy("web", "deny-egress", nil, nwv1.NetworkPolicySpec{PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeEgress}}),
+			},
+			port:           8080,
+			allowed:        false,
+			egressIsolated: true,
+		},
+		{
+			name: "namespaceSelector matches the source namespace",
+			policies: []nwv1.NetworkPolicy{
+				explainPolicy("api", "from-web", map[string]string{"app": "backend"}, nwv1.NetworkPolicySpec{Ingress: fromTeam("web")}),
+			},
+			port:            8080,
+			allowed:         true,
+			ingressIsolated: true,
+			matchedIngress:  1,
+		},
+		{
+			name: "namespaceSelector does not match the source namespace",
+			policies: []nwv1.NetworkPolicy{
+				explainPolicy("api", "from-ops", map[string]string{"app": "backend"}, nwv1.NetworkPolicySpec{Ingress: fromTeam("ops")}),
+			},
+			port:            8080,
+			allowed:         false,
+			ingressIsolated: true,
+		},
+		{
+			name: "podSelector without namespaceSelector only matches the policy namespace",
+			policies: []nwv1.NetworkPolicy{
+				explainPolicy("api", "from-frontend", map[string]string{"app": "backend"}, nwv1.NetworkPolicySpec{
+					Ingress: []nwv1.NetworkPolicyIngressRule{{
+						From: []nwv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}}},
+					}},
+				}),
+			},
+			port:            8080,
+			allowed:         false,
+			ingressIsolated: true,
+		},
+		{
+			name: "numeric port matches",
+			policies: []nwv1.NetworkPolicy{
+				explainPolicy("api", "port-8080", map[string]string{"app": "backend"}, nwv1.NetworkPolicySpec{
+					Ingress: []nwv1.NetworkPolicyIngressRule{{Ports: explainPorts(intstr.FromInt(8080))}},
+				}),
+			},
+			port:            8080,
+			allowed:         true,
+			ingressIsolated: true,
+			matchedIngress:  1,
+		},
+		{
+			name: "numeric port does not match",
+			policies: []nwv1.NetworkPolicy{
+				explainPolicy("api", "port-9090", map[string]string{"app": "backend"}, nwv1.NetworkPolicySpec{
+					Ingress: []nwv1.NetworkPolicyIngressRule{{Ports: explainPorts(intstr.FromInt(9090))}},
+				}),
+			},
+			port:            8080,
+			allowed:         false,
+			ingressIsolated: true,
+		},
+		{
+			name: "named port resolves through the destination container ports",
+			policies: []nwv1.NetworkPolicy{
+				explainPolicy("api", "port-http", map[string]string{"app": "backend"}, nwv1.NetworkPolicySpec{
+					Ingress: []nwv1.NetworkPolicyIngressRule{{Ports: explainPorts(intstr.FromString("http"))}},
+				}),
+			},
+			port:            8080,
+			allowed:         true,
+			ingressIsolated: true,
+			matchedIngress:  1,
+		},
+		{
+			name: "port range with endPort",
+			policies: []nwv1.NetworkPolicy{
+				explainPolicy("api", "port-range", map[string]string{"app": "backend"}, nwv1.NetworkPolicySpec{
+					Ingress: []nwv1.NetworkPolicyIngressRule{{Ports: []nwv1.NetworkPolicyPort{
+						{Port: &[]intstr.IntOrString{intstr.FromInt(8000)}[0], EndPort: &[]int32{8100}[0]},
+					}}},
+				}),
+			},
+			port:            8080,
+			allowed:         true,
+			ingressIsolated: true,
+			matchedIngress:  1,
+		},
+		{
+			name: "any allowing policy wins over default deny",
+			policies: []nwv1.NetworkPolicy{
+				explainPolicy("api", "deny-all", nil, nwv1.NetworkPolicySpec{PolicyTypes: []nwv1.PolicyType{nwv1.PolicyTypeIngress}}),
+				explainPolicy("api", "from-web", map[string]string{"app": "backend"}, nwv1.NetworkPolicySpec{Ingress: fromTeam("web")}),
+			},
+			port:            8080,
+			allowed:         true,
+			ingressIsolated: true,
+			matchedIngress:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explain := ExplainNetworkPolicies(&NetworkPolicyExplainInput{
+				Policies:        tt.policies,
+				NamespaceLabels: namespaceLabels,
+				Source:          frontend,
+				Destination:     backend,
+				Port:            tt.port,
+			})
+			if explain.Allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v (%s)", explain.Allowed, tt.allowed, explain.Reason)
+			}
+			if explain.Ingress.Isolated != tt.ingressIsolated {
+				t.Errorf("ingress isolated = %v, want %v", explain.Ingress.Isolated, tt.ingressIsolated)
+			}
+			if explain.Egress.Isolated != tt.egressIsolated {
+				t.Errorf("egress isolated = %v, want %v", explain.Egress.Isolated, tt.egressIsolated)
+			}
+			if len(explain.Ingress.MatchedRules) != tt.matchedIngress {
+				t.Errorf("matched ingress rules = %d, want %d", len(explain.Ingress.MatchedRules), tt.matchedIngress)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"test4/config"
+
+	"github.com/wonderivan/logger"
+)
+
+// kubelet summary API (/stats/summary) 返回内容中用到的部分
+type kubeletSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		VolumeStats []struct {
+			Name   string `json:"name"`
+			PVCRef *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+			VolumeUsage
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+// 定义VolumeUsage类型, 用于返回卷的用量, 单位为字节
+type VolumeUsage struct {
+	CapacityBytes  *uint64 `json:"capacityBytes"`
+	UsedBytes      *uint64 `json:"usedBytes"`
+	AvailableBytes *uint64 `json:"availableBytes"`
+	Inodes         *uint64 `json:"inodes"`
+	InodesUsed     *uint64 `json:"inodesUsed"`
+	InodesFree     *uint64 `json:"inodesFree"`
+}
+
+// 通过apiserver的node proxy获取kubelet summary
+func getKubeletSummary(ctx context.Context, nodeName string) (summary *kubeletSummary, err error) {
+	raw, err := K8s.Clientset.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(nodeName).
+		SubResource("proxy").
+		Suffix("stats/summary").
+		DoRaw(ctx)
+	if err != nil {
+		logger.Error(errors.New("获取Node: " + nodeName + " 的kubelet summary失败, " + err.Error()))
+		return nil, errors.New("获取Node: " + nodeName + " 的kubelet summary失败, " + err.Error())
+	}
+	summary = &kubeletSummary{}
+	if err = json.Unmarshal(raw, summary); err != nil {
+		logger.Error(errors.New("反序列化kubelet summary失败, " + err.Error()))
+		return nil, errors.New("反序列化kubelet summary失败, " + err.Error())
+	}
+	return summary, nil
+}
+
+// PVC用量map的key
+func pvcUsageKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// 并发获取一组node上各PVC的用量, key为namespace/PVC名, 所有node共用config.KubeletStatsTimeout的超时时间
+// 获取失败或超时的node直接跳过, 只返回能取到的数据
+func getPVCUsage(nodeNames []string) map[string]*VolumeUsage {
+	ctx, cancel := context.WithTimeout(context.Background(), config.KubeletStatsTimeout)
+	defer cancel()
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	usage := map[string]*VolumeUsage{}
+	visited := map[string]bool{}
+	for _, nodeName := range nodeNames {
+		if nodeName == "" || visited[nodeName] {
+			continue
+		}
+		visited[nodeName] = true
+		wg.Add(1)
+		go func(nodeName string) {
+			defer wg.Done()
+			summary, err := getKubeletSummary(ctx, nodeName)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, pod := range summary.Pods {
+				for i := range pod.VolumeStats {
+					stats := pod.VolumeStats[i]
+					if stats.PVCRef == nil {
+						continue
+					}
+					volumeUsage := stats.VolumeUsage
+					usage[pvcUsageKey(stats.PVCRef.Namespace, stats.PVCRef.Name)] = &volumeUsage
+				}
+			}
+		}(nodeName)
+	}
+	wg.Wait()
+	return usage
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/wonderivan/logger"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 var PersistentVolumeClaim persistentVolumeClaim
@@ -17,6 +20,40 @@ type persistentVolumeClaim struct {}
 type PersistentVolumeClaimResp struct {
 	Items []corev1.PersistentVolumeClaim	`json:"items"`
 	Total	int								`json:"total"`
+	//当前页PVC的用量, key为namespace/PVC名, 只包含能从kubelet获取到的数据
+	Usage	map[string]*VolumeUsage			`json:"usage"`
+}
+
+//定义PersistentVolumeClaimCreate结构体, 用于创建pvc需要的参数属性的定义
+type PersistentVolumeClaimCreate struct {
+	Name				string				`json:"name"`
+	Namespace			string				`json:"namespace"`
+	Labels				map[string]string	`json:"labels"`
+	StorageClassName	string				`json:"storage_class_name"`
+	AccessModes			[]string			`json:"access_modes"`
+	VolumeMode			string				`json:"volume_mode"`
+	StorageSize			string				`json:"storage_size"`
+}
+
+//定义PersistentVolumeClaimStatus结构体, 用于返回pvc的扩容状态
+type PersistentVolumeClaimStatus struct {
+	Name					string										`json:"name"`
+	Namespace				string										`json:"namespace"`
+	Phase					corev1.PersistentVolumeClaimPhase			`json:"phase"`
+	Requested				string										`json:"requested"`
+	Capacity				string										`json:"capacity"`
+	Resizing				bool										`json:"resizing"`
+	FileSystemResizePending	bool										`json:"file_system_resize_pending"`
+	Conditions				[]corev1.PersistentVolumeClaimCondition		`json:"conditions"`
+}
+
+//定义PersistentVolumeClaimPod结构体, 用于返回挂载了pvc的pod
+type PersistentVolumeClaimPod struct {
+	PodName		string				`json:"pod_name"`
+	NodeName	string				`json:"node_name"`
+	Phase		corev1.PodPhase		`json:"phase"`
+	VolumeName	string				`json:"volume_name"`
+	ReadOnly	bool				`json:"read_only"`
 }
 
 func (pvc *persistentVolumeClaim) toCells(std []corev1.PersistentVolumeClaim) []DataCell {
@@ -58,9 +95,36 @@ func (pvc *persistentVolumeClaim) GetPersistentVolumeClaims(filterName, namespac
 
 	persistentVolumeClaimResps := pvc.fromCells(data.GenericDataList)
 
+	//用量数据来自挂载了pvc的pod所在节点的kubelet, 只查询当前页的pvc所在的节点
+	usage := map[string]*VolumeUsage{}
+	if len(persistentVolumeClaimResps) > 0 {
+		page := map[string]bool{}
+		for _, item := range persistentVolumeClaimResps {
+			page[pvcUsageKey(item.Namespace, item.Name)] = true
+		}
+		var nodeNames []string
+		podList, err := K8s.Clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err == nil {
+			for _, item := range podList.Items {
+				for _, volume := range item.Spec.Volumes {
+					if volume.PersistentVolumeClaim != nil && page[pvcUsageKey(item.Namespace, volume.PersistentVolumeClaim.ClaimName)] {
+						nodeNames = append(nodeNames, item.Spec.NodeName)
+						break
+					}
+				}
+			}
+		}
+		for key, u := range getPVCUsage(nodeNames) {
+			if page[key] {
+				usage[key] = u
+			}
+		}
+	}
+
 	return &PersistentVolumeClaimResp{
 		Items: persistentVolumeClaimResps,
 		Total: total,
+		Usage: usage,
 	}, nil
 }
 
@@ -99,3 +163,171 @@ func (pvc *persistentVolumeClaim) UpdatePersistentVolumeClaim(namespace, content
 	}
 	return nil
 }
+
+
+//创建pvc, storageClassName为空时使用集群默认的StorageClass
+func (pvc *persistentVolumeClaim) CreatePersistentVolumeClaim(data *PersistentVolumeClaimCreate) (err error) {
+	storageSize, err := resource.ParseQuantity(data.StorageSize)
+	if err != nil {
+		logger.Error(errors.New("存储容量格式错误: " + data.StorageSize + ", " + err.Error()))
+		return errors.New("存储容量格式错误: " + data.StorageSize + ", " + err.Error())
+	}
+	accessModes := []corev1.PersistentVolumeAccessMode{}
+	for _, mode := range data.AccessModes {
+		accessModes = append(accessModes, corev1.PersistentVolumeAccessMode(mode))
+	}
+	if len(accessModes) == 0 {
+		accessModes = append(accessModes, corev1.ReadWriteOnce)
+	}
+
+	persistentVolumeClaim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: data.Name,
+			Namespace: data.Namespace,
+			Labels: data.Labels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: accessModes,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: storageSize,
+				},
+			},
+		},
+	}
+	//不传StorageClass则不设置该字段, 由默认StorageClass接管
+	if data.StorageClassName != "" {
+		persistentVolumeClaim.Spec.StorageClassName = &data.StorageClassName
+	}
+	if data.VolumeMode != "" {
+		volumeMode := corev1.PersistentVolumeMode(data.VolumeMode)
+		persistentVolumeClaim.Spec.VolumeMode = &volumeMode
+	}
+
+	_, err = K8s.Clientset.CoreV1().PersistentVolumeClaims(data.Namespace).Create(context.TODO(), persistentVolumeClaim, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建Namespace: %s 下的PersistentVolumeClaim: %s 失败. " + err.Error()), data.Namespace, data.Name)
+		return errors.New("创建Namespace下的PersistentVolumeClaim 失败. " + err.Error())
+	}
+	return nil
+}
+
+//扩容pvc, 要求StorageClass开启allowVolumeExpansion, 且新容量大于当前申请的容量
+func (pvc *persistentVolumeClaim) ResizePersistentVolumeClaim(persistentVolumeClaimName, namespace, storageSize string) (status *PersistentVolumeClaimStatus, err error) {
+	newSize, err := resource.ParseQuantity(storageSize)
+	if err != nil {
+		logger.Error(errors.New("存储容量格式错误: " + storageSize + ", " + err.Error()))
+		return nil, errors.New("存储容量格式错误: " + storageSize + ", " + err.Error())
+	}
+	PersistentVolumeClaim, err := pvc.GetPersistentVolumeClaimDetail(persistentVolumeClaimName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if PersistentVolumeClaim.Spec.StorageClassName == nil || *PersistentVolumeClaim.Spec.StorageClassName == "" {
+		return nil, errors.New("PersistentVolumeClaim未使用StorageClass, 不支持扩容")
+	}
+	storageClassName := *PersistentVolumeClaim.Spec.StorageClassName
+	storageClass, err := K8s.Clientset.StorageV1().StorageClasses().Get(context.TODO(), storageClassName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取StorageClass: " + storageClassName + " 失败, " + err.Error()))
+		return nil, errors.New("获取StorageClass: " + storageClassName + " 失败, " + err.Error())
+	}
+	if storageClass.AllowVolumeExpansion == nil || !*storageClass.AllowVolumeExpansion {
+		return nil, errors.New(fmt.Sprintf("StorageClass: %s 未开启allowVolumeExpansion, 不支持扩容", storageClassName))
+	}
+	currentSize := PersistentVolumeClaim.Spec.Resources.Requests[corev1.ResourceStorage]
+	if newSize.Cmp(currentSize) <= 0 {
+		return nil, errors.New(fmt.Sprintf("新容量 %s 必须大于当前容量 %s", newSize.String(), currentSize.String()))
+	}
+
+	//只修改spec.resources.requests.storage, 使用merge patch
+	patchData := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"resources": map[string]interface{}{
+				"requests": map[string]string{
+					string(corev1.ResourceStorage): newSize.String(),
+				},
+			},
+		},
+	}
+	patchByte, err := json.Marshal(patchData)
+	if err != nil {
+		logger.Error(errors.New("JSON序列化失败, " + err.Error()))
+		return nil, errors.New("JSON序列化失败, " + err.Error())
+	}
+	_, err = K8s.Clientset.CoreV1().PersistentVolumeClaims(namespace).Patch(context.TODO(), persistentVolumeClaimName, types.MergePatchType, patchByte, metav1.PatchOptions{})
+	if err != nil {
+		logger.Error(errors.New("扩容Namespace: %s 下的PersistentVolumeClaim: %s 失败. " + err.Error()), namespace, persistentVolumeClaimName)
+		return nil, errors.New("扩容Namespace下的PersistentVolumeClaim 失败. " + err.Error())
+	}
+	return pvc.GetPersistentVolumeClaimStatus(persistentVolumeClaimName, namespace)
+}
+
+//获取pvc的扩容状态, 包括Resizing和FileSystemResizePending条件
+//FileSystemResizePending表示卷已扩容, 需等待挂载它的pod(重新)启动后完成文件系统扩容
+func (pvc *persistentVolumeClaim) GetPersistentVolumeClaimStatus(persistentVolumeClaimName, namespace string) (status *PersistentVolumeClaimStatus, err error) {
+	PersistentVolumeClaim, err := pvc.GetPersistentVolumeClaimDetail(persistentVolumeClaimName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	requested := PersistentVolumeClaim.Spec.Resources.Requests[corev1.ResourceStorage]
+	capacity := PersistentVolumeClaim.Status.Capacity[corev1.ResourceStorage]
+	status = &PersistentVolumeClaimStatus{
+		Name: PersistentVolumeClaim.Name,
+		Namespace: PersistentVolumeClaim.Namespace,
+		Phase: PersistentVolumeClaim.Status.Phase,
+		Requested: requested.String(),
+		Capacity: capacity.String(),
+		Conditions: PersistentVolumeClaim.Status.Conditions,
+	}
+	for _, condition := range PersistentVolumeClaim.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case corev1.PersistentVolumeClaimResizing:
+			status.Resizing = true
+		case corev1.PersistentVolumeClaimFileSystemResizePending:
+			status.FileSystemResizePending = true
+		}
+	}
+	return status, nil
+}
+
+//获取挂载了pvc的pod列表
+func (pvc *persistentVolumeClaim) GetPersistentVolumeClaimPods(persistentVolumeClaimName, namespace string) (pods []*PersistentVolumeClaimPod, err error) {
+	podList, err := K8s.Clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Pod列表失败, " + err.Error()))
+		return nil, errors.New("获取Pod列表失败, " + err.Error())
+	}
+	for _, item := range podList.Items {
+		for _, volume := range item.Spec.Volumes {
+			if volume.PersistentVolumeClaim == nil || volume.PersistentVolumeClaim.ClaimName != persistentVolumeClaimName {
+				continue
+			}
+			pods = append(pods, &PersistentVolumeClaimPod{
+				PodName: item.Name,
+				NodeName: item.Spec.NodeName,
+				Phase: item.Status.Phase,
+				VolumeName: volume.Name,
+				ReadOnly: volume.PersistentVolumeClaim.ReadOnly,
+			})
+		}
+	}
+	return pods, nil
+}
+
+//获取pvc用量, 数据来自挂载它的pod所在节点的kubelet summary API
+//没有被挂载或kubelet不可达时返回nil
+func (pvc *persistentVolumeClaim) GetPersistentVolumeClaimUsage(persistentVolumeClaimName, namespace string) (usage *VolumeUsage, err error) {
+	pods, err := pvc.GetPersistentVolumeClaimPods(persistentVolumeClaimName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var nodeNames []string
+	for _, item := range pods {
+		nodeNames = append(nodeNames, item.NodeName)
+	}
+	return getPVCUsage(nodeNames)[pvcUsageKey(namespace, persistentVolumeClaimName)], nil
+}
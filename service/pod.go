@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"test4/config"
 
@@ -26,6 +27,14 @@ type PodsResp struct {
 	Total	int 			`json:"total"`
 }
 
+// 定义ContainerEnv类型, 用于返回容器解析后的环境变量, 敏感来源的值会脱敏
+type ContainerEnv struct {
+	Name	string	`json:"name"`
+	Value	string	`json:"value"`
+	Source	string	`json:"source"`
+	Masked	bool	`json:"masked"`
+}
+
 // 定义PodsNp类型, 用于返回namespace中的pod数量
 type PodsNp struct {
 	Namespace	string	`json:"namespace"`
@@ -154,6 +163,97 @@ func (p *pod) GetPodContainer(podName, namespace string) (containers []string, e
 	return containers, nil
 }
 
+// 获取pod容器的环境变量, 解析env和envFrom的来源
+// 来自secretKeyRef/secretRef以及敏感ConfigMap的值会脱敏返回
+func (p *pod) GetPodEnv(containerName, podName, namespace string) (envs []*ContainerEnv, err error) {
+	pod, err := p.GetPodDetail(podName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var container *corev1.Container
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == containerName {
+			container = &pod.Spec.Containers[i]
+		}
+	}
+	if container == nil {
+		return nil, errors.New(fmt.Sprintf("Pod %s 中不存在容器: %s", podName, containerName))
+	}
+
+	//同一个请求中ConfigMap只获取一次
+	configMaps := map[string]*corev1.ConfigMap{}
+	getConfigMap := func(name string) (*corev1.ConfigMap, error) {
+		if cm, ok := configMaps[name]; ok {
+			return cm, nil
+		}
+		cm, err := K8s.Clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		configMaps[name] = cm
+		return cm, nil
+	}
+
+	//envFrom中的变量先展开, 同名时会被env覆盖
+	for _, envFrom := range container.EnvFrom {
+		if envFrom.ConfigMapRef != nil {
+			source := "configMapRef: " + envFrom.ConfigMapRef.Name
+			cm, err := getConfigMap(envFrom.ConfigMapRef.Name)
+			if err != nil {
+				envs = append(envs, &ContainerEnv{Name: envFrom.Prefix + "*", Source: source + " (" + err.Error() + ")"})
+				continue
+			}
+			masked := isSensitiveConfigMap(cm)
+			for key, value := range cm.Data {
+				if masked {
+					value = config.MaskValue
+				}
+				envs = append(envs, &ContainerEnv{Name: envFrom.Prefix + key, Value: value, Source: source, Masked: masked})
+			}
+		}
+		if envFrom.SecretRef != nil {
+			source := "secretRef: " + envFrom.SecretRef.Name
+			st, err := K8s.Clientset.CoreV1().Secrets(namespace).Get(context.TODO(), envFrom.SecretRef.Name, metav1.GetOptions{})
+			if err != nil {
+				envs = append(envs, &ContainerEnv{Name: envFrom.Prefix + "*", Source: source + " (" + err.Error() + ")", Masked: true})
+				continue
+			}
+			for key := range st.Data {
+				envs = append(envs, &ContainerEnv{Name: envFrom.Prefix + key, Value: config.MaskValue, Source: source, Masked: true})
+			}
+		}
+	}
+
+	for _, env := range container.Env {
+		containerEnv := &ContainerEnv{Name: env.Name, Value: env.Value, Source: "value"}
+		switch {
+		case env.ValueFrom == nil:
+		case env.ValueFrom.SecretKeyRef != nil:
+			containerEnv.Source = fmt.Sprintf("secretKeyRef: %s/%s", env.ValueFrom.SecretKeyRef.Name, env.ValueFrom.SecretKeyRef.Key)
+			containerEnv.Value = config.MaskValue
+			containerEnv.Masked = true
+		case env.ValueFrom.ConfigMapKeyRef != nil:
+			ref := env.ValueFrom.ConfigMapKeyRef
+			containerEnv.Source = fmt.Sprintf("configMapKeyRef: %s/%s", ref.Name, ref.Key)
+			cm, err := getConfigMap(ref.Name)
+			if err != nil {
+				containerEnv.Source += " (" + err.Error() + ")"
+			} else if isSensitiveConfigMap(cm) {
+				containerEnv.Value = config.MaskValue
+				containerEnv.Masked = true
+			} else {
+				containerEnv.Value = cm.Data[ref.Key]
+			}
+		case env.ValueFrom.FieldRef != nil:
+			containerEnv.Source = "fieldRef: " + env.ValueFrom.FieldRef.FieldPath
+		case env.ValueFrom.ResourceFieldRef != nil:
+			containerEnv.Source = "resourceFieldRef: " + env.ValueFrom.ResourceFieldRef.Resource
+		}
+		envs = append(envs, containerEnv)
+	}
+	return envs, nil
+}
+
 // 获取pod容器日志
 func (p *pod) GetPodLog(containerName, podName, namespace string) (log string, err error) {
 	//设置日志的配置, 容器名、tail的行数
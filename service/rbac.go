@@ -0,0 +1,382 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var Rbac rbac
+
+type rbac struct{}
+
+type RoleResp struct {
+	Items []rbacv1.Role `json:"items"`
+	Total int           `json:"total"`
+}
+
+type ClusterRoleResp struct {
+	Items []rbacv1.ClusterRole `json:"items"`
+	Total int                  `json:"total"`
+}
+
+type RoleBindingResp struct {
+	Items []rbacv1.RoleBinding `json:"items"`
+	Total int                  `json:"total"`
+}
+
+type ClusterRoleBindingResp struct {
+	Items []rbacv1.ClusterRoleBinding `json:"items"`
+	Total int                         `json:"total"`
+}
+
+type ServiceAccountResp struct {
+	Items []corev1.ServiceAccount `json:"items"`
+	Total int                     `json:"total"`
+}
+
+//RbacSubject 绑定的主体, Kind为User、Group或ServiceAccount, 只有ServiceAccount需要Namespace
+type RbacSubject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+//定义RoleBindingCreate结构体, 用于创建rolebinding需要的参数属性的定义
+//RoleKind为Role或ClusterRole, 引用ClusterRole时权限仅在rolebinding所在namespace内生效
+type RoleBindingCreate struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Label     map[string]string `json:"label"`
+	RoleKind  string            `json:"role_kind"`
+	RoleName  string            `json:"role_name"`
+	Subjects  []*RbacSubject    `json:"subjects"`
+}
+
+//定义ClusterRoleBindingCreate结构体, 只能引用ClusterRole
+type ClusterRoleBindingCreate struct {
+	Name     string            `json:"name"`
+	Label    map[string]string `json:"label"`
+	RoleName string            `json:"role_name"`
+	Subjects []*RbacSubject    `json:"subjects"`
+}
+
+//数据类型转换
+func (r *rbac) roleToCells(std []rbacv1.Role) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = RoleCell(std[i])
+	}
+	return cells
+}
+
+func (r *rbac) roleFromCells(cells []DataCell) []rbacv1.Role {
+	roles := make([]rbacv1.Role, len(cells))
+	for i := range cells {
+		roles[i] = rbacv1.Role(cells[i].(RoleCell))
+	}
+	return roles
+}
+
+func (r *rbac) clusterRoleToCells(std []rbacv1.ClusterRole) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = ClusterRoleCell(std[i])
+	}
+	return cells
+}
+
+func (r *rbac) clusterRoleFromCells(cells []DataCell) []rbacv1.ClusterRole {
+	clusterRoles := make([]rbacv1.ClusterRole, len(cells))
+	for i := range cells {
+		clusterRoles[i] = rbacv1.ClusterRole(cells[i].(ClusterRoleCell))
+	}
+	return clusterRoles
+}
+
+func (r *rbac) roleBindingToCells(std []rbacv1.RoleBinding) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = RoleBindingCell(std[i])
+	}
+	return cells
+}
+
+func (r *rbac) roleBindingFromCells(cells []DataCell) []rbacv1.RoleBinding {
+	roleBindings := make([]rbacv1.RoleBinding, len(cells))
+	for i := range cells {
+		roleBindings[i] = rbacv1.RoleBinding(cells[i].(RoleBindingCell))
+	}
+	return roleBindings
+}
+
+func (r *rbac) clusterRoleBindingToCells(std []rbacv1.ClusterRoleBinding) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = ClusterRoleBindingCell(std[i])
+	}
+	return cells
+}
+
+func (r *rbac) clusterRoleBindingFromCells(cells []DataCell) []rbacv1.ClusterRoleBinding {
+	clusterRoleBindings := make([]rbacv1.ClusterRoleBinding, len(cells))
+	for i := range cells {
+		clusterRoleBindings[i] = rbacv1.ClusterRoleBinding(cells[i].(ClusterRoleBindingCell))
+	}
+	return clusterRoleBindings
+}
+
+func (r *rbac) serviceAccountToCells(std []corev1.ServiceAccount) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = ServiceAccountCell(std[i])
+	}
+	return cells
+}
+
+func (r *rbac) serviceAccountFromCells(cells []DataCell) []corev1.ServiceAccount {
+	serviceAccounts := make([]corev1.ServiceAccount, len(cells))
+	for i := range cells {
+		serviceAccounts[i] = corev1.ServiceAccount(cells[i].(ServiceAccountCell))
+	}
+	return serviceAccounts
+}
+
+//selectCells 对转换后的数据做过滤、排序、分页, 返回当页数据和过滤后的总数
+func (r *rbac) selectCells(cells []DataCell, filterName string, limit, page int) ([]DataCell, int) {
+	selectableData := &dataSelector{
+		GenericDataList: cells,
+		DataSelectQuery: &DataSelectQuery{
+			FilterQuery: &FilterQuery{Name: filterName},
+			PaginateQuery: &PaginateQuery{
+				Limit: limit,
+				Page:  page,
+			},
+		},
+	}
+
+	filtered := selectableData.Filter()
+	total := len(filtered.GenericDataList)
+	data := filtered.Sort().Paginate()
+	return data.GenericDataList, total
+}
+
+func (r *rbac) GetRoles(filterName, namespace string, limit, page int) (roleResp *RoleResp, err error) {
+	roleList, err := K8s.Clientset.RbacV1().Roles(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Role列表失败, " + err.Error()))
+		return nil, errors.New("获取Role列表失败, " + err.Error())
+	}
+	data, total := r.selectCells(r.roleToCells(roleList.Items), filterName, limit, page)
+	return &RoleResp{
+		Items: r.roleFromCells(data),
+		Total: total,
+	}, nil
+}
+
+func (r *rbac) GetRoleDetail(roleName, namespace string) (role *rbacv1.Role, err error) {
+	role, err = K8s.Clientset.RbacV1().Roles(namespace).Get(context.TODO(), roleName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Role 详情失败, " + err.Error()))
+		return nil, errors.New("获取Role 详情失败, " + err.Error())
+	}
+	return role, nil
+}
+
+func (r *rbac) GetClusterRoles(filterName string, limit, page int) (clusterRoleResp *ClusterRoleResp, err error) {
+	clusterRoleList, err := K8s.Clientset.RbacV1().ClusterRoles().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取ClusterRole列表失败, " + err.Error()))
+		return nil, errors.New("获取ClusterRole列表失败, " + err.Error())
+	}
+	data, total := r.selectCells(r.clusterRoleToCells(clusterRoleList.Items), filterName, limit, page)
+	return &ClusterRoleResp{
+		Items: r.clusterRoleFromCells(data),
+		Total: total,
+	}, nil
+}
+
+func (r *rbac) GetClusterRoleDetail(clusterRoleName string) (clusterRole *rbacv1.ClusterRole, err error) {
+	clusterRole, err = K8s.Clientset.RbacV1().ClusterRoles().Get(context.TODO(), clusterRoleName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取ClusterRole 详情失败, " + err.Error()))
+		return nil, errors.New("获取ClusterRole 详情失败, " + err.Error())
+	}
+	return clusterRole, nil
+}
+
+func (r *rbac) GetRoleBindings(filterName, namespace string, limit, page int) (roleBindingResp *RoleBindingResp, err error) {
+	roleBindingList, err := K8s.Clientset.RbacV1().RoleBindings(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取RoleBinding列表失败, " + err.Error()))
+		return nil, errors.New("获取RoleBinding列表失败, " + err.Error())
+	}
+	data, total := r.selectCells(r.roleBindingToCells(roleBindingList.Items), filterName, limit, page)
+	return &RoleBindingResp{
+		Items: r.roleBindingFromCells(data),
+		Total: total,
+	}, nil
+}
+
+func (r *rbac) GetRoleBindingDetail(roleBindingName, namespace string) (roleBinding *rbacv1.RoleBinding, err error) {
+	roleBinding, err = K8s.Clientset.RbacV1().RoleBindings(namespace).Get(context.TODO(), roleBindingName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取RoleBinding 详情失败, " + err.Error()))
+		return nil, errors.New("获取RoleBinding 详情失败, " + err.Error())
+	}
+	return roleBinding, nil
+}
+
+func (r *rbac) GetClusterRoleBindings(filterName string, limit, page int) (clusterRoleBindingResp *ClusterRoleBindingResp, err error) {
+	clusterRoleBindingList, err := K8s.Clientset.RbacV1().ClusterRoleBindings().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取ClusterRoleBinding列表失败, " + err.Error()))
+		return nil, errors.New("获取ClusterRoleBinding列表失败, " + err.Error())
+	}
+	data, total := r.selectCells(r.clusterRoleBindingToCells(clusterRoleBindingList.Items), filterName, limit, page)
+	return &ClusterRoleBindingResp{
+		Items: r.clusterRoleBindingFromCells(data),
+		Total: total,
+	}, nil
+}
+
+func (r *rbac) GetClusterRoleBindingDetail(clusterRoleBindingName string) (clusterRoleBinding *rbacv1.ClusterRoleBinding, err error) {
+	clusterRoleBinding, err = K8s.Clientset.RbacV1().ClusterRoleBindings().Get(context.TODO(), clusterRoleBindingName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取ClusterRoleBinding 详情失败, " + err.Error()))
+		return nil, errors.New("获取ClusterRoleBinding 详情失败, " + err.Error())
+	}
+	return clusterRoleBinding, nil
+}
+
+func (r *rbac) GetServiceAccounts(filterName, namespace string, limit, page int) (serviceAccountResp *ServiceAccountResp, err error) {
+	serviceAccountList, err := K8s.Clientset.CoreV1().ServiceAccounts(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取ServiceAccount列表失败, " + err.Error()))
+		return nil, errors.New("获取ServiceAccount列表失败, " + err.Error())
+	}
+	data, total := r.selectCells(r.serviceAccountToCells(serviceAccountList.Items), filterName, limit, page)
+	return &ServiceAccountResp{
+		Items: r.serviceAccountFromCells(data),
+		Total: total,
+	}, nil
+}
+
+func (r *rbac) GetServiceAccountDetail(serviceAccountName, namespace string) (serviceAccount *corev1.ServiceAccount, err error) {
+	serviceAccount, err = K8s.Clientset.CoreV1().ServiceAccounts(namespace).Get(context.TODO(), serviceAccountName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取ServiceAccount 详情失败, " + err.Error()))
+		return nil, errors.New("获取ServiceAccount 详情失败, " + err.Error())
+	}
+	return serviceAccount, nil
+}
+
+//toSubjects 校验并转换绑定主体
+func (r *rbac) toSubjects(subjects []*RbacSubject) ([]rbacv1.Subject, error) {
+	if len(subjects) == 0 {
+		return nil, errors.New("至少需要指定一个绑定主体")
+	}
+	result := make([]rbacv1.Subject, 0, len(subjects))
+	for _, subject := range subjects {
+		switch subject.Kind {
+		case rbacv1.UserKind, rbacv1.GroupKind:
+			result = append(result, rbacv1.Subject{
+				Kind:     subject.Kind,
+				APIGroup: rbacv1.GroupName,
+				Name:     subject.Name,
+			})
+		case rbacv1.ServiceAccountKind:
+			if subject.Namespace == "" {
+				return nil, errors.New("ServiceAccount " + subject.Name + " 未指定namespace")
+			}
+			result = append(result, rbacv1.Subject{
+				Kind:      subject.Kind,
+				Name:      subject.Name,
+				Namespace: subject.Namespace,
+			})
+		default:
+			return nil, errors.New("不支持的主体类型: " + subject.Kind)
+		}
+	}
+	return result, nil
+}
+
+func (r *rbac) CreateRoleBinding(data *RoleBindingCreate) (err error) {
+	if data.RoleKind != "Role" && data.RoleKind != "ClusterRole" {
+		return errors.New("RoleKind只能为Role或ClusterRole")
+	}
+	subjects, err := r.toSubjects(data.Subjects)
+	if err != nil {
+		logger.Error(errors.New("创建RoleBinding: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建 RoleBinding 失败, " + err.Error())
+	}
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      data.Name,
+			Namespace: data.Namespace,
+			Labels:    data.Label,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     data.RoleKind,
+			Name:     data.RoleName,
+		},
+		Subjects: subjects,
+	}
+
+	_, err = K8s.Clientset.RbacV1().RoleBindings(data.Namespace).Create(context.TODO(), roleBinding, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建RoleBinding: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建 RoleBinding 失败, " + err.Error())
+	}
+	return nil
+}
+
+func (r *rbac) DeleteRoleBinding(roleBindingName, namespace string) (err error) {
+	err = K8s.Clientset.RbacV1().RoleBindings(namespace).Delete(context.TODO(), roleBindingName, metav1.DeleteOptions{})
+	if err != nil {
+		logger.Error(errors.New("删除RoleBinding: " + roleBindingName + " 失败, " + err.Error()))
+		return errors.New("删除 RoleBinding 失败, " + err.Error())
+	}
+	return nil
+}
+
+func (r *rbac) CreateClusterRoleBinding(data *ClusterRoleBindingCreate) (err error) {
+	subjects, err := r.toSubjects(data.Subjects)
+	if err != nil {
+		logger.Error(errors.New("创建ClusterRoleBinding: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建 ClusterRoleBinding 失败, " + err.Error())
+	}
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   data.Name,
+			Labels: data.Label,
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     data.RoleName,
+		},
+		Subjects: subjects,
+	}
+
+	_, err = K8s.Clientset.RbacV1().ClusterRoleBindings().Create(context.TODO(), clusterRoleBinding, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建ClusterRoleBinding: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建 ClusterRoleBinding 失败, " + err.Error())
+	}
+	return nil
+}
+
+func (r *rbac) DeleteClusterRoleBinding(clusterRoleBindingName string) (err error) {
+	err = K8s.Clientset.RbacV1().ClusterRoleBindings().Delete(context.TODO(), clusterRoleBindingName, metav1.DeleteOptions{})
+	if err != nil {
+		logger.Error(errors.New("删除ClusterRoleBinding: " + clusterRoleBindingName + " 失败, " + err.Error()))
+		return errors.New("删除 ClusterRoleBinding 失败, " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/wonderivan/logger"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//RbacGrant who-can查询结果, 表示某个主体通过哪个binding、哪个角色获得了权限
+//Scope为空表示集群范围(ClusterRoleBinding), 否则为rolebinding所在namespace
+type RbacGrant struct {
+	SubjectKind      string `json:"subject_kind"`
+	SubjectName      string `json:"subject_name"`
+	SubjectNamespace string `json:"subject_namespace"`
+	BindingKind      string `json:"binding_kind"`
+	BindingName      string `json:"binding_name"`
+	RoleKind         string `json:"role_kind"`
+	RoleName         string `json:"role_name"`
+	Scope            string `json:"scope"`
+}
+
+//RbacRuleGrant what-can查询结果, Via为命中binding的主体, 可能是主体所属的组
+type RbacRuleGrant struct {
+	BindingKind string              `json:"binding_kind"`
+	BindingName string              `json:"binding_name"`
+	RoleKind    string              `json:"role_kind"`
+	RoleName    string              `json:"role_name"`
+	Scope       string              `json:"scope"`
+	Via         string              `json:"via"`
+	Rules       []rbacv1.PolicyRule `json:"rules"`
+}
+
+//rbacSnapshot 一次查询用到的全部rbac对象
+type rbacSnapshot struct {
+	roles               map[string]*rbacv1.Role
+	clusterRoles        map[string]*rbacv1.ClusterRole
+	roleBindings        []rbacv1.RoleBinding
+	clusterRoleBindings []rbacv1.ClusterRoleBinding
+}
+
+//loadRbacSnapshot 获取rbac对象, namespace为空时获取所有namespace的role和rolebinding
+func (r *rbac) loadRbacSnapshot(namespace string) (*rbacSnapshot, error) {
+	snapshot := &rbacSnapshot{
+		roles:        map[string]*rbacv1.Role{},
+		clusterRoles: map[string]*rbacv1.ClusterRole{},
+	}
+	clusterRoleList, err := K8s.Clientset.RbacV1().ClusterRoles().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range clusterRoleList.Items {
+		snapshot.clusterRoles[clusterRoleList.Items[i].Name] = &clusterRoleList.Items[i]
+	}
+	clusterRoleBindingList, err := K8s.Clientset.RbacV1().ClusterRoleBindings().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	snapshot.clusterRoleBindings = clusterRoleBindingList.Items
+
+	roleList, err := K8s.Clientset.RbacV1().Roles(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range roleList.Items {
+		snapshot.roles[roleList.Items[i].Namespace+"/"+roleList.Items[i].Name] = &roleList.Items[i]
+	}
+	roleBindingList, err := K8s.Clientset.RbacV1().RoleBindings(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	snapshot.roleBindings = roleBindingList.Items
+	return snapshot, nil
+}
+
+//rules 获取binding引用角色的规则, 角色不存在时返回false
+func (s *rbacSnapshot) rules(roleRef rbacv1.RoleRef, namespace string) ([]rbacv1.PolicyRule, bool) {
+	if roleRef.Kind == "ClusterRole" {
+		clusterRole, ok := s.clusterRoles[roleRef.Name]
+		if !ok {
+			return nil, false
+		}
+		return clusterRole.Rules, true
+	}
+	role, ok := s.roles[namespace+"/"+roleRef.Name]
+	if !ok {
+		return nil, false
+	}
+	return role.Rules, true
+}
+
+//WhoCan 查询哪些主体可以在namespace下对resource执行verb, namespace为空时只计算集群范围的授权
+//resource可以带子资源, 如pods/log; resourceName为空时, 限定了resourceNames的规则不算授权
+func (r *rbac) WhoCan(verb, apiGroup, resource, resourceName, namespace string) (grants []*RbacGrant, err error) {
+	if verb == "" || resource == "" {
+		return nil, errors.New("verb和resource不能为空")
+	}
+	snapshot, err := r.loadRbacSnapshot(namespace)
+	if err != nil {
+		logger.Error(errors.New("获取RBAC对象失败, " + err.Error()))
+		return nil, errors.New("获取RBAC对象失败, " + err.Error())
+	}
+	if namespace == "" {
+		snapshot.roleBindings = nil
+	}
+
+	grants = make([]*RbacGrant, 0)
+	for _, binding := range snapshot.clusterRoleBindings {
+		rules, ok := snapshot.rules(binding.RoleRef, "")
+		if !ok || !rulesAllow(rules, verb, apiGroup, resource, resourceName) {
+			continue
+		}
+		for _, subject := range binding.Subjects {
+			grants = append(grants, &RbacGrant{
+				SubjectKind:      subject.Kind,
+				SubjectName:      subject.Name,
+				SubjectNamespace: subject.Namespace,
+				BindingKind:      "ClusterRoleBinding",
+				BindingName:      binding.Name,
+				RoleKind:         binding.RoleRef.Kind,
+				RoleName:         binding.RoleRef.Name,
+			})
+		}
+	}
+	for _, binding := range snapshot.roleBindings {
+		rules, ok := snapshot.rules(binding.RoleRef, binding.Namespace)
+		if !ok || !rulesAllow(rules, verb, apiGroup, resource, resourceName) {
+			continue
+		}
+		for _, subject := range binding.Subjects {
+			grants = append(grants, &RbacGrant{
+				SubjectKind:      subject.Kind,
+				SubjectName:      subject.Name,
+				SubjectNamespace: subject.Namespace,
+				BindingKind:      "RoleBinding",
+				BindingName:      binding.Name,
+				RoleKind:         binding.RoleRef.Kind,
+				RoleName:         binding.RoleRef.Name,
+				Scope:            binding.Namespace,
+			})
+		}
+	}
+	return grants, nil
+}
+
+//WhatCan 查询主体拥有的权限, namespace为空时查询所有namespace的rolebinding
+//ServiceAccount和User会同时匹配其隐含所属的组, 如system:serviceaccounts、system:authenticated
+func (r *rbac) WhatCan(subjectKind, subjectName, subjectNamespace, namespace string) (grants []*RbacRuleGrant, err error) {
+	if subjectKind == rbacv1.ServiceAccountKind && subjectNamespace == "" {
+		return nil, errors.New("ServiceAccount需要指定subject_namespace")
+	}
+	snapshot, err := r.loadRbacSnapshot(namespace)
+	if err != nil {
+		logger.Error(errors.New("获取RBAC对象失败, " + err.Error()))
+		return nil, errors.New("获取RBAC对象失败, " + err.Error())
+	}
+
+	grants = make([]*RbacRuleGrant, 0)
+	for _, binding := range snapshot.clusterRoleBindings {
+		via, matched := matchSubjects(binding.Subjects, subjectKind, subjectName, subjectNamespace)
+		if !matched {
+			continue
+		}
+		rules, _ := snapshot.rules(binding.RoleRef, "")
+		grants = append(grants, &RbacRuleGrant{
+			BindingKind: "ClusterRoleBinding",
+			BindingName: binding.Name,
+			RoleKind:    binding.RoleRef.Kind,
+			RoleName:    binding.RoleRef.Name,
+			Via:         via,
+			Rules:       rules,
+		})
+	}
+	for _, binding := range snapshot.roleBindings {
+		via, matched := matchSubjects(binding.Subjects, subjectKind, subjectName, subjectNamespace)
+		if !matched {
+			continue
+		}
+		rules, _ := snapshot.rules(binding.RoleRef, binding.Namespace)
+		grants = append(grants, &RbacRuleGrant{
+			BindingKind: "RoleBinding",
+			BindingName: binding.Name,
+			RoleKind:    binding.RoleRef.Kind,
+			RoleName:    binding.RoleRef.Name,
+			Scope:       binding.Namespace,
+			Via:         via,
+			Rules:       rules,
+		})
+	}
+	return grants, nil
+}
+
+//matchSubjects 判断binding的主体中是否包含查询的主体或其隐含所属的组, 返回命中的主体描述
+func matchSubjects(subjects []rbacv1.Subject, kind, name, namespace string) (string, bool) {
+	var groups []string
+	switch kind {
+	case rbacv1.ServiceAccountKind:
+		groups = []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace, "system:authenticated"}
+	case rbacv1.UserKind:
+		groups = []string{"system:authenticated"}
+	}
+	for _, subject := range subjects {
+		if subject.Kind == kind && subject.Name == name {
+			if kind != rbacv1.ServiceAccountKind || subject.Namespace == namespace {
+				return kind + " " + name, true
+			}
+		}
+		if subject.Kind != rbacv1.GroupKind {
+			continue
+		}
+		for _, group := range groups {
+			if subject.Name == group {
+				return "Group " + group, true
+			}
+		}
+	}
+	return "", false
+}
+
+//rulesAllow 与apiserver的rbac鉴权逻辑一致, 任一规则允许即放行
+func rulesAllow(rules []rbacv1.PolicyRule, verb, apiGroup, resource, resourceName string) bool {
+	for _, rule := range rules {
+		if ruleAllows(rule, verb, apiGroup, resource, resourceName) {
+			return true
+		}
+	}
+	return false
+}
+
+func ruleAllows(rule rbacv1.PolicyRule, verb, apiGroup, resource, resourceName string) bool {
+	if !containsOrStar(rule.Verbs, verb) || !containsOrStar(rule.APIGroups, apiGroup) {
+		return false
+	}
+	if !resourceMatches(rule.Resources, resource) {
+		return false
+	}
+	if len(rule.ResourceNames) == 0 {
+		return true
+	}
+	if resourceName == "" {
+		return false
+	}
+	for _, name := range rule.ResourceNames {
+		if name == resourceName {
+			return true
+		}
+	}
+	return false
+}
+
+func containsOrStar(items []string, value string) bool {
+	for _, item := range items {
+		if item == "*" || item == value {
+			return true
+		}
+	}
+	return false
+}
+
+//resourceMatches resource带子资源时, 规则中的"*/子资源"也视为匹配
+func resourceMatches(ruleResources []string, resource string) bool {
+	subresource := ""
+	if i := strings.Index(resource, "/"); i >= 0 {
+		subresource = resource[i+1:]
+	}
+	for _, ruleResource := range ruleResources {
+		if ruleResource == "*" || ruleResource == resource {
+			return true
+		}
+		if subresource != "" && ruleResource == "*/"+subresource {
+			return true
+		}
+	}
+	return false
+}
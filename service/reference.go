@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var Reference reference
+
+type reference struct{}
+
+// 被引用资源的类型
+const (
+	RefKindConfigMap             = "ConfigMap"
+	RefKindSecret                = "Secret"
+	RefKindPersistentVolumeClaim = "PersistentVolumeClaim"
+)
+
+// 定义Consumer类型, 表示引用了某个ConfigMap/Secret/PVC的工作负载
+// Via 为引用方式, 例如 volume:config、envFrom、env:DB_HOST、imagePullSecrets
+type Consumer struct {
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Via       []string `json:"via"`
+}
+
+// 定义ReferenceIndex类型, key为 "类型/名称", 例如 ConfigMap/nginx-conf
+type ReferenceIndex map[string][]*Consumer
+
+// 单个工作负载中的一条引用
+type podSpecRef struct {
+	kind string
+	name string
+	via  string
+}
+
+func refKey(kind, name string) string {
+	return kind + "/" + name
+}
+
+// 扫描pod模板, 找出其中引用的ConfigMap、Secret、PVC
+func podSpecReferences(spec *corev1.PodSpec) (refs []podSpecRef) {
+	for _, volume := range spec.Volumes {
+		switch {
+		case volume.ConfigMap != nil:
+			refs = append(refs, podSpecRef{RefKindConfigMap, volume.ConfigMap.Name, "volume:" + volume.Name})
+		case volume.Secret != nil:
+			refs = append(refs, podSpecRef{RefKindSecret, volume.Secret.SecretName, "volume:" + volume.Name})
+		case volume.PersistentVolumeClaim != nil:
+			refs = append(refs, podSpecRef{RefKindPersistentVolumeClaim, volume.PersistentVolumeClaim.ClaimName, "volume:" + volume.Name})
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					refs = append(refs, podSpecRef{RefKindConfigMap, source.ConfigMap.Name, "projected:" + volume.Name})
+				}
+				if source.Secret != nil {
+					refs = append(refs, podSpecRef{RefKindSecret, source.Secret.Name, "projected:" + volume.Name})
+				}
+			}
+		}
+	}
+
+	//init容器与普通容器的引用方式一致
+	containers := append([]corev1.Container{}, spec.InitContainers...)
+	containers = append(containers, spec.Containers...)
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				refs = append(refs, podSpecRef{RefKindConfigMap, envFrom.ConfigMapRef.Name, "envFrom:" + container.Name})
+			}
+			if envFrom.SecretRef != nil {
+				refs = append(refs, podSpecRef{RefKindSecret, envFrom.SecretRef.Name, "envFrom:" + container.Name})
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				refs = append(refs, podSpecRef{RefKindConfigMap, env.ValueFrom.ConfigMapKeyRef.Name, "env:" + env.Name})
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				refs = append(refs, podSpecRef{RefKindSecret, env.ValueFrom.SecretKeyRef.Name, "env:" + env.Name})
+			}
+		}
+	}
+
+	for _, pullSecret := range spec.ImagePullSecrets {
+		refs = append(refs, podSpecRef{RefKindSecret, pullSecret.Name, "imagePullSecrets"})
+	}
+	return refs
+}
+
+// 将一个工作负载的引用加入索引, 同一工作负载对同一资源的多次引用合并到Via中
+func (index ReferenceIndex) add(kind, name, namespace string, refs []podSpecRef) {
+	consumers := map[string]*Consumer{}
+	for _, ref := range refs {
+		key := refKey(ref.kind, ref.name)
+		consumer, ok := consumers[key]
+		if !ok {
+			consumer = &Consumer{Kind: kind, Name: name, Namespace: namespace}
+			consumers[key] = consumer
+			index[key] = append(index[key], consumer)
+		}
+		consumer.Via = append(consumer.Via, ref.via)
+	}
+}
+
+// 构建namespace下的引用索引
+// 扫描Deployment、StatefulSet、DaemonSet、Job以及没有ownerReferences的裸Pod
+func (r *reference) BuildIndex(namespace string) (index ReferenceIndex, err error) {
+	index = ReferenceIndex{}
+
+	deploymentList, err := K8s.Clientset.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("构建引用索引时获取Deployment列表失败, " + err.Error()))
+		return nil, errors.New("构建引用索引时获取Deployment列表失败, " + err.Error())
+	}
+	for _, item := range deploymentList.Items {
+		index.add("Deployment", item.Name, item.Namespace, podSpecReferences(&item.Spec.Template.Spec))
+	}
+
+	statefulSetList, err := K8s.Clientset.AppsV1().StatefulSets(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("构建引用索引时获取StatefulSet列表失败, " + err.Error()))
+		return nil, errors.New("构建引用索引时获取StatefulSet列表失败, " + err.Error())
+	}
+	pvcList, err := K8s.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("构建引用索引时获取PersistentVolumeClaim列表失败, " + err.Error()))
+		return nil, errors.New("构建引用索引时获取PersistentVolumeClaim列表失败, " + err.Error())
+	}
+	for _, item := range statefulSetList.Items {
+		refs := podSpecReferences(&item.Spec.Template.Spec)
+		//volumeClaimTemplates生成的PVC名称为 <模板名>-<statefulset名>-<序号>
+		for _, tmpl := range item.Spec.VolumeClaimTemplates {
+			prefix := tmpl.Name + "-" + item.Name + "-"
+			for _, pvc := range pvcList.Items {
+				if pvc.Namespace == item.Namespace && isOrdinalName(pvc.Name, prefix) {
+					refs = append(refs, podSpecRef{RefKindPersistentVolumeClaim, pvc.Name, "volumeClaimTemplate:" + tmpl.Name})
+				}
+			}
+		}
+		index.add("StatefulSet", item.Name, item.Namespace, refs)
+	}
+
+	daemonSetList, err := K8s.Clientset.AppsV1().DaemonSets(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("构建引用索引时获取DaemonSet列表失败, " + err.Error()))
+		return nil, errors.New("构建引用索引时获取DaemonSet列表失败, " + err.Error())
+	}
+	for _, item := range daemonSetList.Items {
+		index.add("DaemonSet", item.Name, item.Namespace, podSpecReferences(&item.Spec.Template.Spec))
+	}
+
+	jobList, err := K8s.Clientset.BatchV1().Jobs(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("构建引用索引时获取Job列表失败, " + err.Error()))
+		return nil, errors.New("构建引用索引时获取Job列表失败, " + err.Error())
+	}
+	for _, item := range jobList.Items {
+		index.add("Job", item.Name, item.Namespace, podSpecReferences(&item.Spec.Template.Spec))
+	}
+
+	podList, err := K8s.Clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("构建引用索引时获取Pod列表失败, " + err.Error()))
+		return nil, errors.New("构建引用索引时获取Pod列表失败, " + err.Error())
+	}
+	for _, item := range podList.Items {
+		//由控制器管理的pod已经通过其模板统计过了
+		if len(item.OwnerReferences) > 0 {
+			continue
+		}
+		index.add("Pod", item.Name, item.Namespace, podSpecReferences(&item.Spec))
+	}
+	return index, nil
+}
+
+// 获取引用了指定ConfigMap/Secret/PVC的工作负载
+func (r *reference) GetConsumers(kind, name, namespace string) (consumers []*Consumer, err error) {
+	if kind != RefKindConfigMap && kind != RefKindSecret && kind != RefKindPersistentVolumeClaim {
+		return nil, errors.New("不支持的资源类型: " + kind + ", 可选值为ConfigMap/Secret/PersistentVolumeClaim")
+	}
+	index, err := r.BuildIndex(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return index[refKey(kind, name)], nil
+}
+
+// 定义RestartResult类型, 用于返回配置变更后工作负载的重启结果
+type RestartResult struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Restarted bool   `json:"restarted"`
+	Message   string `json:"message"`
+}
+
+// 重启引用了指定ConfigMap/Secret的工作负载, 重启方式与RestartDeployment等一致(更新模板annotation)
+// dryRun为true时只返回将要重启的工作负载, 不做实际操作
+// Job和裸Pod无法滚动重启, 只在结果中标记为跳过
+func (r *reference) RestartConsumers(kind, name, namespace string, dryRun bool) (results []*RestartResult, err error) {
+	consumers, err := r.GetConsumers(kind, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, consumer := range consumers {
+		result := &RestartResult{Kind: consumer.Kind, Name: consumer.Name, Namespace: consumer.Namespace}
+		results = append(results, result)
+
+		var restart func(name, namespace string) error
+		switch consumer.Kind {
+		case "Deployment":
+			restart = Deployment.RestartDeployment
+		case "StatefulSet":
+			restart = StatefulSet.RestartStatefulSet
+		case "DaemonSet":
+			restart = DaemonSet.RestartDaemonSet
+		default:
+			result.Message = consumer.Kind + " 不支持滚动重启, 已跳过"
+			continue
+		}
+		if dryRun {
+			result.Message = "预览: 将会重启"
+			continue
+		}
+		if err := restart(consumer.Name, consumer.Namespace); err != nil {
+			result.Message = err.Error()
+			continue
+		}
+		result.Restarted = true
+		result.Message = "已重启"
+	}
+	return results, nil
+}
+
+// 将dryRun开关转换为apiserver的DryRun参数
+func dryRunOption(dryRun bool) []string {
+	if dryRun {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+//isOrdinalName 判断name是否为 <prefix><序号>, 避免 <模板名>-web-x-0 被当作statefulset web的PVC
+func isOrdinalName(name, prefix string) bool {
+	ordinal := strings.TrimPrefix(name, prefix)
+	if ordinal == name || ordinal == "" {
+		return false
+	}
+	for _, c := range ordinal {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"test4/config"
+	"test4/dao"
+	"test4/model"
 
 	"github.com/wonderivan/logger"
 	corev1 "k8s.io/api/core/v1"
@@ -59,6 +63,10 @@ func (st *secret) GetSecrets(filterName, namespace string, limit, page int) (sec
 	data := filtered.Sort().Paginate()
 
 	secretResps := st.fromCells(data.GenericDataList)
+	//列表中的data默认脱敏
+	for i := range secretResps {
+		st.maskSecret(&secretResps[i])
+	}
 
 	return &SecretResp{
 		Items: secretResps,
@@ -72,9 +80,51 @@ func (st *secret) GetSecretDetail(secretName, namespace string) (secret *corev1.
 		logger.Error(errors.New("获取Namespace: %s Secret %s 详情失败. " + err.Error()), namespace, secretName)
 		return nil, errors.New("获取Namespace下的Secret 详情失败. " + err.Error())
 	}
+	//详情中的data默认脱敏, 明文需通过RevealSecret查看
+	st.maskSecret(Secret)
 	return Secret, nil
 }
 
+//脱敏secret, 将data的值替换为占位值, 并去掉可能包含明文的last-applied-configuration
+func (st *secret) maskSecret(secret *corev1.Secret) {
+	for key := range secret.Data {
+		secret.Data[key] = []byte(config.MaskValue)
+	}
+	for key := range secret.StringData {
+		secret.StringData[key] = config.MaskValue
+	}
+	delete(secret.Annotations, corev1.LastAppliedConfigAnnotation)
+}
+
+//查看secret中单个key的明文, 并记录审计日志
+func (st *secret) RevealSecret(secretName, namespace, key, userName, clientIP string) (value string, err error) {
+	Secret, err := K8s.Clientset.CoreV1().Secrets(namespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Namespace: %s Secret %s 详情失败. " + err.Error()), namespace, secretName)
+		return "", errors.New("获取Namespace下的Secret 详情失败. " + err.Error())
+	}
+	data, ok := Secret.Data[key]
+	if !ok {
+		return "", errors.New(fmt.Sprintf("Secret %s 中不存在key: %s", secretName, key))
+	}
+
+	//先落审计记录, 记录失败则不返回明文
+	err = dao.Audit.Add(&model.AuditLog{
+		UserName:  userName,
+		ClientIP:  clientIP,
+		Action:    "reveal",
+		Kind:      "Secret",
+		Namespace: namespace,
+		Name:      secretName,
+		Key:       key,
+	})
+	if err != nil {
+		return "", err
+	}
+	logger.Info(fmt.Sprintf("用户 %s 查看了Namespace: %s 下Secret %s 的key: %s", userName, namespace, secretName, key))
+	return string(data), nil
+}
+
 func (st *secret) DeleteSecret(secretName, namespace string) (err error) {
 	err = K8s.Clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), secretName, metav1.DeleteOptions{})
 	if err != nil {
@@ -85,19 +135,49 @@ func (st *secret) DeleteSecret(secretName, namespace string) (err error) {
 }
 
 
-func (st *secret) UpdateSecret(namespace, content string) (err error) {
+//更新Secret, restart为true时重启引用了它的工作负载
+//dryRun为true时使用apiserver的dry-run校验更新, 不落库, 只返回将要重启的工作负载
+func (st *secret) UpdateSecret(namespace, content string, restart, dryRun bool) (restarted []*RestartResult, err error) {
 	var secret = &corev1.Secret{}
 
 	err = json.Unmarshal([]byte(content), secret)
 	if err != nil {
 		logger.Error(errors.New("JONS反序列化失败." + err.Error()))
-		return errors.New("JONS反序列化失败." + err.Error())
+		return nil, errors.New("JONS反序列化失败." + err.Error())
+	}
+
+	//详情是脱敏返回的, 提交内容中仍为占位值的key保留集群中的原值
+	current, err := K8s.Clientset.CoreV1().Secrets(namespace).Get(context.TODO(), secret.Name, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Namespace: %s Secret %s 详情失败. " + err.Error()), namespace, secret.Name)
+		return nil, errors.New("获取Namespace下的Secret 详情失败. " + err.Error())
 	}
-	
-	_, err = K8s.Clientset.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+	for key, value := range secret.Data {
+		if string(value) == config.MaskValue {
+			secret.Data[key] = current.Data[key]
+		}
+	}
+	for key, value := range secret.StringData {
+		if value == config.MaskValue {
+			delete(secret.StringData, key)
+		}
+	}
+	if last, ok := current.Annotations[corev1.LastAppliedConfigAnnotation]; ok {
+		if _, exist := secret.Annotations[corev1.LastAppliedConfigAnnotation]; !exist {
+			if secret.Annotations == nil {
+				secret.Annotations = map[string]string{}
+			}
+			secret.Annotations[corev1.LastAppliedConfigAnnotation] = last
+		}
+	}
+
+	_, err = K8s.Clientset.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{DryRun: dryRunOption(dryRun)})
 	if err != nil {
 		logger.Error(errors.New("更新Namespace: %s 下的Secret %s 失败. " + err.Error()), namespace, secret.Name)
-		return errors.New("更新Namespace下的Secret 失败. " + err.Error())
+		return nil, errors.New("更新Namespace下的Secret 失败. " + err.Error())
 	}
-	return nil
+	if !restart {
+		return nil, nil
+	}
+	return Reference.RestartConsumers(RefKindSecret, secret.Name, namespace, dryRun)
 }
\ No newline at end of file
@@ -0,0 +1,399 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+//链路中断的类型
+const (
+	ChainNoMatchingPods   = "NoMatchingPods"
+	ChainNoReadyEndpoints = "NoReadyEndpoints"
+	ChainPortMismatch     = "PortMismatch"
+	ChainMissingService   = "MissingService"
+	ChainMissingSecret    = "MissingSecret"
+	ChainInvalidSecret    = "InvalidSecret"
+)
+
+//ChainProblem 链路中发现的问题
+type ChainProblem struct {
+	Type    string `json:"type"`
+	Object  string `json:"object"`
+	Message string `json:"message"`
+}
+
+//ServiceChainPort service端口及解析到的后端端口, 命名的targetPort在各pod中可能解析为不同端口
+type ServiceChainPort struct {
+	Name       string  `json:"name"`
+	Port       int32   `json:"port"`
+	Protocol   string  `json:"protocol"`
+	TargetPort string  `json:"target_port"`
+	Resolved   []int32 `json:"resolved"`
+}
+
+//ServiceChainPod selector选中的pod及其在endpointslice中的状态
+type ServiceChainPod struct {
+	Name        string `json:"name"`
+	IP          string `json:"ip"`
+	NodeName    string `json:"node_name"`
+	Phase       string `json:"phase"`
+	InEndpoints bool   `json:"in_endpoints"`
+	Ready       bool   `json:"ready"`
+	Terminating bool   `json:"terminating"`
+}
+
+type ServiceChain struct {
+	Name           string                      `json:"name"`
+	Namespace      string                      `json:"namespace"`
+	Type           string                      `json:"type"`
+	Selector       map[string]string           `json:"selector"`
+	Ports          []*ServiceChainPort         `json:"ports"`
+	EndpointSlices []discoveryv1.EndpointSlice `json:"endpoint_slices"`
+	Pods           []*ServiceChainPod          `json:"pods"`
+	ReadyCount     int                         `json:"ready_count"`
+	NotReadyCount  int                         `json:"not_ready_count"`
+	Problems       []*ChainProblem             `json:"problems"`
+}
+
+type IngressChainBackend struct {
+	Host        string        `json:"host"`
+	Path        string        `json:"path"`
+	ServiceName string        `json:"service_name"`
+	ServicePort string        `json:"service_port"`
+	Service     *ServiceChain `json:"service"`
+}
+
+type IngressChainTLS struct {
+	Hosts      []string `json:"hosts"`
+	SecretName string   `json:"secret_name"`
+	Exists     bool     `json:"exists"`
+	SecretType string   `json:"secret_type"`
+}
+
+type IngressChain struct {
+	Name             string                 `json:"name"`
+	Namespace        string                 `json:"namespace"`
+	IngressClassName string                 `json:"ingress_class_name"`
+	Backends         []*IngressChainBackend `json:"backends"`
+	TLS              []*IngressChainTLS     `json:"tls"`
+	Problems         []*ChainProblem        `json:"problems"`
+}
+
+//获取service的链路: endpointslice、selector选中的pod及其就绪状态, 并标记断开的环节
+func (svc *k8sService) GetK8sServiceChain(k8sServiceName, namespace string) (chain *ServiceChain, err error) {
+	service, err := K8s.Clientset.CoreV1().Services(namespace).Get(context.TODO(), k8sServiceName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Service: " + k8sServiceName + " 失败, " + err.Error()))
+		return nil, errors.New("获取Service: " + k8sServiceName + " 失败, " + err.Error())
+	}
+	chain, err = svc.buildServiceChain(service)
+	if err != nil {
+		logger.Error(errors.New("获取Service: " + k8sServiceName + " 链路失败, " + err.Error()))
+		return nil, errors.New("获取Service: " + k8sServiceName + " 链路失败, " + err.Error())
+	}
+	return chain, nil
+}
+
+func (svc *k8sService) buildServiceChain(service *corev1.Service) (*ServiceChain, error) {
+	chain := &ServiceChain{
+		Name:      service.Name,
+		Namespace: service.Namespace,
+		Type:      string(service.Spec.Type),
+		Selector:  service.Spec.Selector,
+		Pods:      make([]*ServiceChainPod, 0),
+		Problems:  make([]*ChainProblem, 0),
+	}
+	for _, port := range service.Spec.Ports {
+		chain.Ports = append(chain.Ports, &ServiceChainPort{
+			Name:       port.Name,
+			Port:       port.Port,
+			Protocol:   string(port.Protocol),
+			TargetPort: port.TargetPort.String(),
+		})
+	}
+	//ExternalName类型没有后端pod
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		return chain, nil
+	}
+
+	sliceList, err := K8s.Clientset.DiscoveryV1().EndpointSlices(service.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + service.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	chain.EndpointSlices = sliceList.Items
+
+	//按pod名称汇总endpoint的状态
+	endpointStates := map[string]discoveryv1.EndpointConditions{}
+	for _, slice := range sliceList.Items {
+		for _, endpoint := range slice.Endpoints {
+			ready := endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+			if ready {
+				chain.ReadyCount++
+			} else {
+				chain.NotReadyCount++
+			}
+			if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+				endpointStates[endpoint.TargetRef.Name] = endpoint.Conditions
+			}
+		}
+	}
+
+	//没有selector时endpoint由用户自行维护, 不检查pod
+	if len(service.Spec.Selector) == 0 {
+		return chain, nil
+	}
+	podList, err := K8s.Clientset.CoreV1().Pods(service.Namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(service.Spec.Selector).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		chainPod := &ServiceChainPod{
+			Name:     pod.Name,
+			IP:       pod.Status.PodIP,
+			NodeName: pod.Spec.NodeName,
+			Phase:    string(pod.Status.Phase),
+		}
+		if conditions, ok := endpointStates[pod.Name]; ok {
+			chainPod.InEndpoints = true
+			chainPod.Ready = conditions.Ready == nil || *conditions.Ready
+			chainPod.Terminating = conditions.Terminating != nil && *conditions.Terminating
+		}
+		chain.Pods = append(chain.Pods, chainPod)
+	}
+
+	if len(podList.Items) == 0 {
+		chain.Problems = append(chain.Problems, &ChainProblem{
+			Type:    ChainNoMatchingPods,
+			Object:  "Service/" + service.Name,
+			Message: "selector " + labels.SelectorFromSet(service.Spec.Selector).String() + " 未匹配到任何pod",
+		})
+		return chain, nil
+	}
+	if chain.ReadyCount == 0 {
+		chain.Problems = append(chain.Problems, &ChainProblem{
+			Type:    ChainNoReadyEndpoints,
+			Object:  "Service/" + service.Name,
+			Message: fmt.Sprintf("匹配到%d个pod, 但没有就绪的endpoint", len(podList.Items)),
+		})
+	}
+	for i, port := range service.Spec.Ports {
+		resolved, problem := resolveTargetPort(port, podList.Items)
+		chain.Ports[i].Resolved = resolved
+		if problem != "" {
+			chain.Problems = append(chain.Problems, &ChainProblem{
+				Type:    ChainPortMismatch,
+				Object:  "Service/" + service.Name,
+				Message: problem,
+			})
+		}
+	}
+	return chain, nil
+}
+
+//resolveTargetPort 在选中的pod中解析targetPort
+//命名端口在pod中找不到即为不匹配; 数字端口只有在pod都声明了端口且都不包含时才视为不匹配
+func resolveTargetPort(port corev1.ServicePort, pods []corev1.Pod) ([]int32, string) {
+	resolved := make([]int32, 0)
+	seen := map[int32]bool{}
+	missing := 0
+	declared := 0
+	for _, pod := range pods {
+		found := false
+		hasPorts := false
+		for _, container := range pod.Spec.Containers {
+			for _, containerPort := range container.Ports {
+				hasPorts = true
+				if containerPort.Protocol != "" && port.Protocol != "" && containerPort.Protocol != port.Protocol {
+					continue
+				}
+				if port.TargetPort.Type == intstr.String && containerPort.Name == port.TargetPort.StrVal ||
+					port.TargetPort.Type == intstr.Int && containerPort.ContainerPort == port.TargetPort.IntVal {
+					found = true
+					if !seen[containerPort.ContainerPort] {
+						seen[containerPort.ContainerPort] = true
+						resolved = append(resolved, containerPort.ContainerPort)
+					}
+				}
+			}
+		}
+		if hasPorts {
+			declared++
+		}
+		if !found {
+			missing++
+		}
+	}
+	if port.TargetPort.Type == intstr.String {
+		if missing > 0 {
+			return resolved, fmt.Sprintf("端口 %s 的targetPort %s 在%d个pod中未找到同名容器端口", servicePortName(port), port.TargetPort.StrVal, missing)
+		}
+		return resolved, ""
+	}
+	if len(resolved) == 0 {
+		resolved = append(resolved, port.TargetPort.IntVal)
+		if declared == len(pods) {
+			return resolved, fmt.Sprintf("端口 %s 的targetPort %d 不在pod声明的容器端口中", servicePortName(port), port.TargetPort.IntVal)
+		}
+	}
+	return resolved, ""
+}
+
+func servicePortName(port corev1.ServicePort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return strconv.Itoa(int(port.Port))
+}
+
+//获取ingress的链路: 后端service、端口、endpoint以及tls secret, 并标记断开的环节
+func (i *ingress) GetIngressChain(ingressName, namespace string) (chain *IngressChain, err error) {
+	ingress, err := K8s.Clientset.NetworkingV1().Ingresses(namespace).Get(context.TODO(), ingressName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取Ingress: " + ingressName + " 失败, " + err.Error()))
+		return nil, errors.New("获取Ingress: " + ingressName + " 失败, " + err.Error())
+	}
+	chain = &IngressChain{
+		Name:      ingress.Name,
+		Namespace: ingress.Namespace,
+		Backends:  make([]*IngressChainBackend, 0),
+		TLS:       make([]*IngressChainTLS, 0),
+		Problems:  make([]*ChainProblem, 0),
+	}
+	if ingress.Spec.IngressClassName != nil {
+		chain.IngressClassName = *ingress.Spec.IngressClassName
+	}
+
+	//同一个service只解析一次
+	serviceChains := map[string]*ServiceChain{}
+	addBackend := func(host, path string, backend nwv1.IngressBackend) error {
+		if backend.Service == nil {
+			return nil
+		}
+		chainBackend := &IngressChainBackend{
+			Host:        host,
+			Path:        path,
+			ServiceName: backend.Service.Name,
+			ServicePort: ingressServicePort(backend.Service.Port),
+		}
+		chain.Backends = append(chain.Backends, chainBackend)
+		object := "Ingress/" + ingress.Name + " " + host + path
+
+		serviceChain, ok := serviceChains[backend.Service.Name]
+		if !ok {
+			service, err := K8s.Clientset.CoreV1().Services(namespace).Get(context.TODO(), backend.Service.Name, metav1.GetOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			if err == nil {
+				serviceChain, err = K8sService.buildServiceChain(service)
+				if err != nil {
+					return err
+				}
+				chain.Problems = append(chain.Problems, serviceChain.Problems...)
+			}
+			serviceChains[backend.Service.Name] = serviceChain
+		}
+		if serviceChain == nil {
+			chain.Problems = append(chain.Problems, &ChainProblem{
+				Type:    ChainMissingService,
+				Object:  object,
+				Message: "后端Service " + backend.Service.Name + " 不存在",
+			})
+			return nil
+		}
+		chainBackend.Service = serviceChain
+		if !serviceHasPort(serviceChain, backend.Service.Port) {
+			chain.Problems = append(chain.Problems, &ChainProblem{
+				Type:    ChainPortMismatch,
+				Object:  object,
+				Message: "Service " + backend.Service.Name + " 没有端口 " + chainBackend.ServicePort,
+			})
+		}
+		return nil
+	}
+
+	if ingress.Spec.DefaultBackend != nil {
+		if err = addBackend("*", "", *ingress.Spec.DefaultBackend); err != nil {
+			logger.Error(errors.New("获取Ingress: " + ingressName + " 链路失败, " + err.Error()))
+			return nil, errors.New("获取Ingress: " + ingressName + " 链路失败, " + err.Error())
+		}
+	}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if err = addBackend(rule.Host, path.Path, path.Backend); err != nil {
+				logger.Error(errors.New("获取Ingress: " + ingressName + " 链路失败, " + err.Error()))
+				return nil, errors.New("获取Ingress: " + ingressName + " 链路失败, " + err.Error())
+			}
+		}
+	}
+
+	for _, tls := range ingress.Spec.TLS {
+		chainTLS := &IngressChainTLS{
+			Hosts:      tls.Hosts,
+			SecretName: tls.SecretName,
+		}
+		chain.TLS = append(chain.TLS, chainTLS)
+		//未指定secret时使用ingress controller的默认证书
+		if tls.SecretName == "" {
+			continue
+		}
+		secret, err := K8s.Clientset.CoreV1().Secrets(namespace).Get(context.TODO(), tls.SecretName, metav1.GetOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				logger.Error(errors.New("获取Ingress: " + ingressName + " 链路失败, " + err.Error()))
+				return nil, errors.New("获取Ingress: " + ingressName + " 链路失败, " + err.Error())
+			}
+			chain.Problems = append(chain.Problems, &ChainProblem{
+				Type:    ChainMissingSecret,
+				Object:  "Ingress/" + ingress.Name,
+				Message: "TLS Secret " + tls.SecretName + " 不存在",
+			})
+			continue
+		}
+		chainTLS.Exists = true
+		chainTLS.SecretType = string(secret.Type)
+		if len(secret.Data[corev1.TLSCertKey]) == 0 || len(secret.Data[corev1.TLSPrivateKeyKey]) == 0 {
+			chain.Problems = append(chain.Problems, &ChainProblem{
+				Type:    ChainInvalidSecret,
+				Object:  "Ingress/" + ingress.Name,
+				Message: "TLS Secret " + tls.SecretName + " 缺少tls.crt或tls.key",
+			})
+		}
+	}
+	return chain, nil
+}
+
+func ingressServicePort(port nwv1.ServiceBackendPort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return strconv.Itoa(int(port.Number))
+}
+
+func serviceHasPort(chain *ServiceChain, port nwv1.ServiceBackendPort) bool {
+	for _, servicePort := range chain.Ports {
+		if port.Name != "" && servicePort.Name == port.Name || port.Name == "" && servicePort.Port == port.Number {
+			return true
+		}
+	}
+	return false
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/wonderivan/logger"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -24,6 +25,12 @@ type StatefulSetResp struct {
 	Total	int					`json:"total"`
 }
 
+//statefulset详情, statefulset的字段平铺, 附带控制该statefulset的hpa, 没有时为null
+type StatefulSetDetail struct {
+	*appsv1.StatefulSet
+	HorizontalPodAutoscaler *autoscalingv2.HorizontalPodAutoscaler `json:"hpa"`
+}
+
 type StatefulSetCreate struct {
 	StatefulSetName		string				`json:"name"`
 	Namespace			string				`json:"namespace"`
@@ -47,6 +54,11 @@ type StatefulSetCreate struct {
 	HostPathCheck		bool				`json:"host_path_check"`
 	ResourceCheck		bool				`json:"resource_check"`
 	HostPath			string				`json:"host_path"`
+	//管理pod网络标识的headless service名
+	ServiceName			string				`json:"service_name"`
+	//传入containers时忽略上面的单容器参数, 传入volume_claim_templates时忽略上面的单个存储卷参数
+	Containers			[]corev1.Container	`json:"containers"`
+	VolumeClaimTemplates	[]corev1.PersistentVolumeClaim	`json:"volume_claim_templates"`
 }
 
 //定义namespace 下statefulset的数量
@@ -137,6 +149,17 @@ func (s *statefulSet) ScaleStatefulSet(statefulSetName, namespace string, scalen
 
 //创建statefulset, 接收statefulset对象
 func (s *statefulSet) CreateStatefulSet(data *StatefulSetCreate) (err error) {
+	statefulSet := buildStatefulSet(data)
+	_, err = K8s.Clientset.AppsV1().StatefulSets(data.Namespace).Create(context.TODO(), statefulSet, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建StatefulSet失败, " + err.Error()))
+		return errors.New("创建StatefulSet失败, " + err.Error())
+	}
+	return nil	
+}
+
+//buildStatefulSet 将创建参数组装成appsv1.StatefulSet对象, workflow更新和对比时也使用
+func buildStatefulSet(data *StatefulSetCreate) *appsv1.StatefulSet {
 	statefulSet := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: data.StatefulSetName,
@@ -145,6 +168,7 @@ func (s *statefulSet) CreateStatefulSet(data *StatefulSetCreate) (err error) {
 		},
 		Spec: appsv1.StatefulSetSpec{
 			Replicas: &data.Replicas,
+			ServiceName: data.ServiceName,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: data.Labels,
 			},
@@ -154,99 +178,101 @@ func (s *statefulSet) CreateStatefulSet(data *StatefulSetCreate) (err error) {
 					Labels: data.Labels,
 				},
 				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
-						{
-							Name: data.ContainerName,
-							Image: data.Image,
-							ImagePullPolicy: "IfNotPresent",
-							Ports: []corev1.ContainerPort{
-								{
-									Name: data.ContainerPortName,
-									ContainerPort: data.ContainerPort,
-									Protocol: corev1.Protocol(data.Protocol),
-								},
-							},
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name: data.VolumeMountName,
-									MountPath: data.VolumeNameMountPath,
-								},
-							},
-						},
-					},
-				},
-			},
-			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: data.VolumeMountName,
-					},
-					Spec: corev1.PersistentVolumeClaimSpec{
-						AccessModes: []corev1.PersistentVolumeAccessMode{corev1.PersistentVolumeAccessMode(data.AccessModes)},
-						StorageClassName: &data.StorageClassName,
-						VolumeMode: (*corev1.PersistentVolumeMode)(&data.VolumeMode),
-						Resources: corev1.ResourceRequirements{
-							Requests: corev1.ResourceList{
-								corev1.ResourceStorage : resource.MustParse(data.StorageSize),
-							},
-						},
-					},
-					Status: corev1.PersistentVolumeClaimStatus{},
+					Containers: data.Containers,
 				},
 			},
+			VolumeClaimTemplates: data.VolumeClaimTemplates,
 		},
 		Status: appsv1.StatefulSetStatus{},
 	}
-	//判断是否打开健康检查, 如果打开则胚子健康检查
-	if data.HealthCheck {
-		//设置第一个容器的ReadinessProbe, 因为我们pod中只有一个容器, 所以直接使用index0 即可
-		//若pod中有多个容器, 则这里需要使用for循环去定义了
-		statefulSet.Spec.Template.Spec.Containers[0].ReadinessProbe = &corev1.Probe{
-			ProbeHandler: corev1.ProbeHandler{
-				HTTPGet: &corev1.HTTPGetAction{
-					Path: data.HealthPath,
-					//instr.InOrString 的作用时端口可以定义为整型, 也可以定义为字符串
-					//Type=0 则表示该结构体实例内的数据为整型, 转json时只使用IntVal的数据
-					//Type=1 则表示该结构体实例内的数据为字符串, 转json时只使用StrVal的数据
-					Port: intstr.IntOrString{
-						Type: 0,
-						IntVal: data.ContainerPort,
-					},
+	//未传入containers时使用单容器参数
+	if len(data.Containers) == 0 {
+		container := corev1.Container{
+			Name: data.ContainerName,
+			Image: data.Image,
+			ImagePullPolicy: "IfNotPresent",
+			Ports: []corev1.ContainerPort{
+				{
+					Name: data.ContainerPortName,
+					ContainerPort: data.ContainerPort,
+					Protocol: corev1.Protocol(data.Protocol),
 				},
 			},
 		}
-		statefulSet.Spec.Template.Spec.Containers[0].LivenessProbe = &corev1.Probe{
-			ProbeHandler: corev1.ProbeHandler{
-				HTTPGet: &corev1.HTTPGetAction{
-					Path: data.HealthPath,
-					//instr.InOrString 的作用时端口可以定义为整型, 也可以定义为字符串
-					//Type=0 则表示该结构体实例内的数据为整型, 转json时只使用IntVal的数据
-					//Type=1 则表示该结构体实例内的数据为字符串, 转json时只使用StrVal的数据
-					Port: intstr.IntOrString{
-						Type: 0,
-						IntVal: data.ContainerPort,
+		if data.VolumeMountName != "" {
+			container.VolumeMounts = []corev1.VolumeMount{
+				{
+					Name: data.VolumeMountName,
+					MountPath: data.VolumeNameMountPath,
+				},
+			}
+		}
+		statefulSet.Spec.Template.Spec.Containers = []corev1.Container{container}
+	}
+	//未传入volume_claim_templates时使用单个存储卷参数
+	if len(data.VolumeClaimTemplates) == 0 && data.VolumeMountName != "" {
+		statefulSet.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{
+			{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: data.VolumeMountName,
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.PersistentVolumeAccessMode(data.AccessModes)},
+					StorageClassName: &data.StorageClassName,
+					VolumeMode: (*corev1.PersistentVolumeMode)(&data.VolumeMode),
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceStorage : resource.MustParse(data.StorageSize),
+						},
 					},
 				},
+				Status: corev1.PersistentVolumeClaimStatus{},
 			},
 		}
 	}
-	//判断是否打开资源限额配置, 如果打开则配置
-	if data.ResourceCheck {
-		statefulSet.Spec.Template.Spec.Containers[0].Resources.Limits = map[corev1.ResourceName]resource.Quantity{
-			corev1.ResourceCPU : resource.MustParse(data.Cpu),
-			corev1.ResourceMemory : resource.MustParse(data.Memory),
+	for i := range statefulSet.Spec.Template.Spec.Containers {
+		container := &statefulSet.Spec.Template.Spec.Containers[i]
+		//判断是否打开健康检查, 如果打开则配置健康检查, 探测容器的第一个端口
+		if data.HealthCheck && len(container.Ports) > 0 {
+			container.ReadinessProbe = &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					HTTPGet: &corev1.HTTPGetAction{
+						Path: data.HealthPath,
+						//instr.InOrString 的作用时端口可以定义为整型, 也可以定义为字符串
+						//Type=0 则表示该结构体实例内的数据为整型, 转json时只使用IntVal的数据
+						//Type=1 则表示该结构体实例内的数据为字符串, 转json时只使用StrVal的数据
+						Port: intstr.IntOrString{
+							Type: 0,
+							IntVal: container.Ports[0].ContainerPort,
+						},
+					},
+				},
+			}
+			container.LivenessProbe = &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					HTTPGet: &corev1.HTTPGetAction{
+						Path: data.HealthPath,
+						Port: intstr.IntOrString{
+							Type: 0,
+							IntVal: container.Ports[0].ContainerPort,
+						},
+					},
+				},
+			}
 		}
-		statefulSet.Spec.Template.Spec.Containers[0].Resources.Requests = map[corev1.ResourceName]resource.Quantity{
-			corev1.ResourceCPU : resource.MustParse(data.Cpu),
-			corev1.ResourceMemory : resource.MustParse(data.Memory),
+		//判断是否打开资源限额配置, 如果打开则配置
+		if data.ResourceCheck {
+			container.Resources.Limits = map[corev1.ResourceName]resource.Quantity{
+				corev1.ResourceCPU : resource.MustParse(data.Cpu),
+				corev1.ResourceMemory : resource.MustParse(data.Memory),
+			}
+			container.Resources.Requests = map[corev1.ResourceName]resource.Quantity{
+				corev1.ResourceCPU : resource.MustParse(data.Cpu),
+				corev1.ResourceMemory : resource.MustParse(data.Memory),
+			}
 		}
 	}
-	_, err = K8s.Clientset.AppsV1().StatefulSets(data.Namespace).Create(context.TODO(), statefulSet, metav1.CreateOptions{})
-	if err != nil {
-		logger.Error(errors.New("创建StatefulSet失败, " + err.Error()))
-		return errors.New("创建StatefulSet失败, " + err.Error())
-	}
-	return nil	
+	return statefulSet
 }
 
 //删除statefulset
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var StorageClass storageClass
+
+type storageClass struct{}
+
+// 默认StorageClass的annotation, beta版本的key也需要兼容
+const (
+	defaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+type StorageClassResp struct {
+	Items []storagev1.StorageClass `json:"items"`
+	Total int                      `json:"total"`
+}
+
+// 定义StorageClassCreate结构体, 用于创建StorageClass需要的参数属性的定义
+type StorageClassCreate struct {
+	Name                 string            `json:"name"`
+	Labels               map[string]string `json:"labels"`
+	Provisioner          string            `json:"provisioner"`
+	Parameters           map[string]string `json:"parameters"`
+	ReclaimPolicy        string            `json:"reclaim_policy"`
+	VolumeBindingMode    string            `json:"volume_binding_mode"`
+	AllowVolumeExpansion bool              `json:"allow_volume_expansion"`
+	MountOptions         []string          `json:"mount_options"`
+	IsDefault            bool              `json:"is_default"`
+}
+
+func (sc *storageClass) toCells(std []storagev1.StorageClass) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = StorageClassCell(std[i])
+	}
+	return cells
+}
+
+func (sc *storageClass) fromCells(cells []DataCell) []storagev1.StorageClass {
+	storageClasses := make([]storagev1.StorageClass, len(cells))
+	for i := range cells {
+		storageClasses[i] = storagev1.StorageClass(cells[i].(StorageClassCell))
+	}
+	return storageClasses
+}
+
+// 获取StorageClass列表, 支持过滤、排序、分页
+func (sc *storageClass) GetStorageClasses(filterName string, limit, page int) (storageClassResp *StorageClassResp, err error) {
+	storageClassList, err := K8s.Clientset.StorageV1().StorageClasses().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取StorageClass列表失败, " + err.Error()))
+		return nil, errors.New("获取StorageClass列表失败, " + err.Error())
+	}
+
+	selectableData := &dataSelector{
+		GenericDataList: sc.toCells(storageClassList.Items),
+		DataSelectQuery: &DataSelectQuery{
+			FilterQuery: &FilterQuery{Name: filterName},
+			PaginateQuery: &PaginateQuery{
+				Limit: limit,
+				Page:  page,
+			},
+		},
+	}
+
+	filtered := selectableData.Filter()
+	total := len(filtered.GenericDataList)
+	data := filtered.Sort().Paginate()
+
+	return &StorageClassResp{
+		Items: sc.fromCells(data.GenericDataList),
+		Total: total,
+	}, nil
+}
+
+// 获取StorageClass详情
+func (sc *storageClass) GetStorageClassDetail(storageClassName string) (storageClass *storagev1.StorageClass, err error) {
+	storageClass, err = K8s.Clientset.StorageV1().StorageClasses().Get(context.TODO(), storageClassName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取StorageClass: " + storageClassName + " 详情失败, " + err.Error()))
+		return nil, errors.New("获取StorageClass详情失败, " + err.Error())
+	}
+	return storageClass, nil
+}
+
+// 创建StorageClass, IsDefault为true时同时设置为默认StorageClass
+func (sc *storageClass) CreateStorageClass(data *StorageClassCreate) (err error) {
+	storageClass := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   data.Name,
+			Labels: data.Labels,
+		},
+		Provisioner:          data.Provisioner,
+		Parameters:           data.Parameters,
+		MountOptions:         data.MountOptions,
+		AllowVolumeExpansion: &data.AllowVolumeExpansion,
+	}
+	if data.ReclaimPolicy != "" {
+		reclaimPolicy := corev1.PersistentVolumeReclaimPolicy(data.ReclaimPolicy)
+		storageClass.ReclaimPolicy = &reclaimPolicy
+	}
+	if data.VolumeBindingMode != "" {
+		volumeBindingMode := storagev1.VolumeBindingMode(data.VolumeBindingMode)
+		storageClass.VolumeBindingMode = &volumeBindingMode
+	}
+
+	_, err = K8s.Clientset.StorageV1().StorageClasses().Create(context.TODO(), storageClass, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建StorageClass: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建StorageClass失败, " + err.Error())
+	}
+	if data.IsDefault {
+		return sc.SetDefaultStorageClass(data.Name, true)
+	}
+	return nil
+}
+
+// 删除StorageClass
+func (sc *storageClass) DeleteStorageClass(storageClassName string) (err error) {
+	err = K8s.Clientset.StorageV1().StorageClasses().Delete(context.TODO(), storageClassName, metav1.DeleteOptions{})
+	if err != nil {
+		logger.Error(errors.New("删除StorageClass: " + storageClassName + " 失败, " + err.Error()))
+		return errors.New("删除StorageClass失败, " + err.Error())
+	}
+	return nil
+}
+
+// 设置或取消默认StorageClass
+// 设置时会先取消其他StorageClass的默认标记, 保证集群中只有一个默认StorageClass
+func (sc *storageClass) SetDefaultStorageClass(storageClassName string, isDefault bool) (err error) {
+	if isDefault {
+		storageClassList, err := K8s.Clientset.StorageV1().StorageClasses().List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			logger.Error(errors.New("获取StorageClass列表失败, " + err.Error()))
+			return errors.New("获取StorageClass列表失败, " + err.Error())
+		}
+		for _, item := range storageClassList.Items {
+			if item.Name == storageClassName || !isDefaultStorageClass(&item) {
+				continue
+			}
+			if err = sc.patchDefaultAnnotation(item.Name, false); err != nil {
+				return err
+			}
+		}
+	}
+	return sc.patchDefaultAnnotation(storageClassName, isDefault)
+}
+
+// 判断StorageClass是否为默认StorageClass
+func isDefaultStorageClass(storageClass *storagev1.StorageClass) bool {
+	return storageClass.Annotations[defaultStorageClassAnnotation] == "true" ||
+		storageClass.Annotations[betaDefaultStorageClassAnnotation] == "true"
+}
+
+// 通过merge patch修改默认StorageClass的annotation, 取消时同时去掉beta版本的key
+func (sc *storageClass) patchDefaultAnnotation(storageClassName string, isDefault bool) (err error) {
+	annotations := map[string]interface{}{
+		defaultStorageClassAnnotation: "false",
+		//值为nil时merge patch会删除该key
+		betaDefaultStorageClassAnnotation: nil,
+	}
+	if isDefault {
+		annotations[defaultStorageClassAnnotation] = "true"
+	}
+	patchData := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	}
+	patchByte, err := json.Marshal(patchData)
+	if err != nil {
+		logger.Error(errors.New("JSON序列化失败, " + err.Error()))
+		return errors.New("JSON序列化失败, " + err.Error())
+	}
+	_, err = K8s.Clientset.StorageV1().StorageClasses().Patch(context.TODO(), storageClassName, types.MergePatchType, patchByte, metav1.PatchOptions{})
+	if err != nil {
+		logger.Error(errors.New("设置默认StorageClass: " + storageClassName + " 失败, " + err.Error()))
+		return errors.New("设置默认StorageClass失败, " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var VolumeSnapshot volumeSnapshot
+
+type volumeSnapshot struct{}
+
+// CSI快照的资源定义, 没有typed client, 通过dynamic client操作
+const snapshotGroup = "snapshot.storage.k8s.io"
+
+var (
+	volumeSnapshotGVR      = schema.GroupVersionResource{Group: snapshotGroup, Version: "v1", Resource: "volumesnapshots"}
+	volumeSnapshotClassGVR = schema.GroupVersionResource{Group: snapshotGroup, Version: "v1", Resource: "volumesnapshotclasses"}
+)
+
+type VolumeSnapshotResp struct {
+	Items []unstructured.Unstructured `json:"items"`
+	Total int                         `json:"total"`
+}
+
+// 定义VolumeSnapshotCreate结构体, 用于给pvc创建快照
+type VolumeSnapshotCreate struct {
+	Name                    string            `json:"name"`
+	Namespace               string            `json:"namespace"`
+	Labels                  map[string]string `json:"labels"`
+	PersistentVolumeClaim   string            `json:"persistent_volume_claim_name"`
+	VolumeSnapshotClassName string            `json:"volume_snapshot_class_name"`
+}
+
+// 定义VolumeSnapshotRestore结构体, 用于将快照恢复为新的pvc
+// StorageSize为空时使用快照的restoreSize
+type VolumeSnapshotRestore struct {
+	VolumeSnapshotName string            `json:"volume_snapshot_name"`
+	Namespace          string            `json:"namespace"`
+	Name               string            `json:"name"`
+	Labels             map[string]string `json:"labels"`
+	StorageClassName   string            `json:"storage_class_name"`
+	AccessModes        []string          `json:"access_modes"`
+	StorageSize        string            `json:"storage_size"`
+}
+
+func (vs *volumeSnapshot) toCells(std []unstructured.Unstructured) []DataCell {
+	cells := make([]DataCell, len(std))
+	for i := range std {
+		cells[i] = UnstructuredCell(std[i])
+	}
+	return cells
+}
+
+func (vs *volumeSnapshot) fromCells(cells []DataCell) []unstructured.Unstructured {
+	items := make([]unstructured.Unstructured, len(cells))
+	for i := range cells {
+		items[i] = unstructured.Unstructured(cells[i].(UnstructuredCell))
+	}
+	return items
+}
+
+// 获取VolumeSnapshotClass列表
+func (vs *volumeSnapshot) GetVolumeSnapshotClasses() (items []unstructured.Unstructured, err error) {
+	list, err := K8s.Dynamic.Resource(volumeSnapshotClassGVR).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取VolumeSnapshotClass列表失败, " + err.Error()))
+		return nil, errors.New("获取VolumeSnapshotClass列表失败, " + err.Error())
+	}
+	return list.Items, nil
+}
+
+// 获取VolumeSnapshot列表, 支持过滤、排序、分页
+func (vs *volumeSnapshot) GetVolumeSnapshots(filterName, namespace string, limit, page int) (volumeSnapshotResp *VolumeSnapshotResp, err error) {
+	list, err := K8s.Dynamic.Resource(volumeSnapshotGVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取VolumeSnapshot列表失败, " + err.Error()))
+		return nil, errors.New("获取VolumeSnapshot列表失败, " + err.Error())
+	}
+
+	selectableData := &dataSelector{
+		GenericDataList: vs.toCells(list.Items),
+		DataSelectQuery: &DataSelectQuery{
+			FilterQuery: &FilterQuery{Name: filterName},
+			PaginateQuery: &PaginateQuery{
+				Limit: limit,
+				Page:  page,
+			},
+		},
+	}
+
+	filtered := selectableData.Filter()
+	total := len(filtered.GenericDataList)
+	data := filtered.Sort().Paginate()
+
+	return &VolumeSnapshotResp{
+		Items: vs.fromCells(data.GenericDataList),
+		Total: total,
+	}, nil
+}
+
+// 获取VolumeSnapshot详情
+func (vs *volumeSnapshot) GetVolumeSnapshotDetail(volumeSnapshotName, namespace string) (volumeSnapshot *unstructured.Unstructured, err error) {
+	volumeSnapshot, err = K8s.Dynamic.Resource(volumeSnapshotGVR).Namespace(namespace).Get(context.TODO(), volumeSnapshotName, metav1.GetOptions{})
+	if err != nil {
+		logger.Error(errors.New("获取VolumeSnapshot: " + volumeSnapshotName + " 详情失败, " + err.Error()))
+		return nil, errors.New("获取VolumeSnapshot详情失败, " + err.Error())
+	}
+	return volumeSnapshot, nil
+}
+
+// 给pvc创建快照
+func (vs *volumeSnapshot) CreateVolumeSnapshot(data *VolumeSnapshotCreate) (err error) {
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": data.PersistentVolumeClaim,
+		},
+	}
+	//不传VolumeSnapshotClass则使用默认的VolumeSnapshotClass
+	if data.VolumeSnapshotClassName != "" {
+		spec["volumeSnapshotClassName"] = data.VolumeSnapshotClassName
+	}
+	volumeSnapshot := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": volumeSnapshotGVR.GroupVersion().String(),
+			"kind":       "VolumeSnapshot",
+			"metadata": map[string]interface{}{
+				"name":      data.Name,
+				"namespace": data.Namespace,
+			},
+			"spec": spec,
+		},
+	}
+	if len(data.Labels) > 0 {
+		volumeSnapshot.SetLabels(data.Labels)
+	}
+
+	_, err = K8s.Dynamic.Resource(volumeSnapshotGVR).Namespace(data.Namespace).Create(context.TODO(), volumeSnapshot, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("创建VolumeSnapshot: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建VolumeSnapshot失败, " + err.Error())
+	}
+	return nil
+}
+
+// 删除VolumeSnapshot
+func (vs *volumeSnapshot) DeleteVolumeSnapshot(volumeSnapshotName, namespace string) (err error) {
+	err = K8s.Dynamic.Resource(volumeSnapshotGVR).Namespace(namespace).Delete(context.TODO(), volumeSnapshotName, metav1.DeleteOptions{})
+	if err != nil {
+		logger.Error(errors.New("删除VolumeSnapshot: " + volumeSnapshotName + " 失败, " + err.Error()))
+		return errors.New("删除VolumeSnapshot失败, " + err.Error())
+	}
+	return nil
+}
+
+// 将快照恢复为新的pvc, 快照需处于readyToUse状态
+func (vs *volumeSnapshot) RestoreVolumeSnapshot(data *VolumeSnapshotRestore) (err error) {
+	volumeSnapshot, err := vs.GetVolumeSnapshotDetail(data.VolumeSnapshotName, data.Namespace)
+	if err != nil {
+		return err
+	}
+	readyToUse, _, _ := unstructured.NestedBool(volumeSnapshot.Object, "status", "readyToUse")
+	if !readyToUse {
+		return errors.New(fmt.Sprintf("VolumeSnapshot: %s 尚未就绪(readyToUse=false), 无法恢复", data.VolumeSnapshotName))
+	}
+
+	storageSize := data.StorageSize
+	if storageSize == "" {
+		storageSize, _, _ = unstructured.NestedString(volumeSnapshot.Object, "status", "restoreSize")
+	}
+	size, err := resource.ParseQuantity(storageSize)
+	if err != nil {
+		logger.Error(errors.New("存储容量格式错误: " + storageSize + ", " + err.Error()))
+		return errors.New("存储容量格式错误: " + storageSize + ", " + err.Error())
+	}
+	accessModes := []corev1.PersistentVolumeAccessMode{}
+	for _, mode := range data.AccessModes {
+		accessModes = append(accessModes, corev1.PersistentVolumeAccessMode(mode))
+	}
+	if len(accessModes) == 0 {
+		accessModes = append(accessModes, corev1.ReadWriteOnce)
+	}
+
+	apiGroup := snapshotGroup
+	persistentVolumeClaim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      data.Name,
+			Namespace: data.Namespace,
+			Labels:    data.Labels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: accessModes,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: size,
+				},
+			},
+			DataSource: &corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     "VolumeSnapshot",
+				Name:     data.VolumeSnapshotName,
+			},
+		},
+	}
+	if data.StorageClassName != "" {
+		persistentVolumeClaim.Spec.StorageClassName = &data.StorageClassName
+	}
+
+	_, err = K8s.Clientset.CoreV1().PersistentVolumeClaims(data.Namespace).Create(context.TODO(), persistentVolumeClaim, metav1.CreateOptions{})
+	if err != nil {
+		logger.Error(errors.New("从VolumeSnapshot: " + data.VolumeSnapshotName + " 恢复PersistentVolumeClaim失败, " + err.Error()))
+		return errors.New("从VolumeSnapshot恢复PersistentVolumeClaim失败, " + err.Error())
+	}
+	return nil
+}
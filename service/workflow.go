@@ -1,9 +1,17 @@
 package service
 
 import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"test4/config"
 	"test4/dao"
 	"test4/model"
+
+	"github.com/wonderivan/logger"
 	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
 )
 
 var Workflow workflow
@@ -28,24 +36,92 @@ type WorkflowCreate struct {
 	Port          int32                  `json:"port"`
 	NodePort      int32                  `json:"node_port"`
 	Hosts         map[string][]*HttpPath `json:"hosts"`
+	//Ingress类型的workflow的入口资源, 为空或Ingress时创建Ingress, HTTPRoute时挂载到指定的Gateway
+	IngressKind      string `json:"ingress_kind"`
+	GatewayName      string `json:"gateway_name"`
+	GatewayNamespace string `json:"gateway_namespace"`
+	GatewaySection   string `json:"gateway_section"`
+	//工作负载类型, 为空或Deployment时创建Deployment, StatefulSet时service为headless, DaemonSet时忽略replicas
+	WorkloadKind string `json:"workload_kind"`
+	//StatefulSet的存储卷模板, 容器中通过volumeMounts引用
+	VolumeClaimTemplates []corev1.PersistentVolumeClaim `json:"volume_claim_templates"`
+	//负责的团队以及workflow标签, 只用于查询过滤, 不会设置到k8s资源上
+	Team           string            `json:"team"`
+	WorkflowLabels map[string]string `json:"workflow_labels"`
 }
 
-// 获取列表分页查询
-func (wf *workflow) GetList(name string, page, limit int) (workflowResp *dao.WorkflowResp, err error) {
-	workflowResp, err = dao.Workflow.GetList(name, page, limit)
+//workflow入口资源类型
+const (
+	IngressKindIngress   = "Ingress"
+	IngressKindHTTPRoute = "HTTPRoute"
+)
+
+// 获取列表分页查询, 支持按namespace、类型、团队、标签和创建时间过滤并排序, 每个workflow附带集群中的状态
+func (wf *workflow) GetList(query *WorkflowListQuery) (workflowResp *WorkflowListResp, err error) {
+	daoQuery, err := query.toDaoQuery()
+	if err != nil {
+		logger.Error(errors.New("获取workflow列表失败, " + err.Error()))
+		return nil, errors.New("获取workflow列表失败, " + err.Error())
+	}
+	list, err := dao.Workflow.GetList(daoQuery)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(list.Items))
+	for _, item := range list.Items {
+		ids = append(ids, item.ID)
+	}
+	labels, err := dao.WorkflowLabel.GetByWorkflowIds(ids)
 	if err != nil {
 		return nil, err
 	}
-	return workflowResp, nil
+	for _, item := range list.Items {
+		item.WorkflowLabels = labels[item.ID]
+	}
+	return &WorkflowListResp{
+		Items: newWorkflowInfos(list.Items),
+		Total: list.Total,
+	}, nil
+}
+
+//newWorkflowInfos 使用config.WorkflowHealthWorkers个goroutine并发获取每个workflow的状态, 结果保持原顺序
+func newWorkflowInfos(workflows []*model.Workflow) []*WorkflowInfo {
+	items := make([]*WorkflowInfo, len(workflows))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < config.WorkflowHealthWorkers && w < len(workflows); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				items[i] = newWorkflowInfo(workflows[i], false)
+			}
+		}()
+	}
+	for i := range workflows {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return items
 }
 
-//获取workflow详情
-func (wf *workflow) GetById(id int) (workflow *model.Workflow, err error) {
-	workflow, err = dao.Workflow.GetById(id)
+//获取workflow详情, 附带集群中的状态、pod最近的Warning事件以及最近一次操作的步骤
+func (wf *workflow) GetById(id int) (workflowInfo *WorkflowInfo, err error) {
+	workflow, err := getWorkflow(id)
+	if err != nil {
+		return nil, err
+	}
+	workflow.Steps, err = dao.WorkflowStep.GetList(workflow.ID)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := dao.WorkflowLabel.GetByWorkflowIds([]uint{workflow.ID})
 	if err != nil {
 		return nil, err
 	}
-	return workflow, nil
+	workflow.WorkflowLabels = labels[workflow.ID]
+	return newWorkflowInfo(workflow, true), nil
 }
 
 
@@ -59,46 +135,76 @@ func getIngressName(workflowName string) (ingressName string) {
 	return workflowName + "-ing"
 }
 
+//workflow名字转httproute名字, 添加-route后缀
+func getHTTPRouteName(workflowName string) (httpRouteName string) {
+	return workflowName + "-route"
+}
+
+//workflow名字和host转httproute名字, 每个host一个httproute, 通配符*替换为wildcard, host为空时不加后缀
+func getHostHTTPRouteName(workflowName, host string) (httpRouteName string) {
+	if host == "" {
+		return getHTTPRouteName(workflowName)
+	}
+	return getHTTPRouteName(workflowName) + "-" + strings.ReplaceAll(strings.ToLower(host), "*", "wildcard")
+}
+
 //创建workflow
+//各k8s资源按saga的方式依次创建, 某一步失败时倒序删除已创建的资源
 func (wf *workflow) CreateWorkflow(data *WorkflowCreate) (err error) {
-	//若workflow不是ingress类型, 传入空字符串即可
-	var ingressName string
-	if data.Type == "Ingress" {
-		ingressName = getIngressName(data.Name)
-	} else {
-		ingressName = ""
-	}
-	//组装mysql中workflow的单条数据
+	if err = validateWorkloadKind(data.WorkloadKind); err != nil {
+		logger.Error(errors.New("创建workflow失败, " + err.Error()))
+		return errors.New("创建workflow失败, " + err.Error())
+	}
+	//组装mysql中workflow的单条数据, 若workflow不是ingress类型, ingress为空字符串
 	workflow := &model.Workflow{
 		Name: 		data.Name,
 		Namespace: 	data.Namespace,
 		Replicas: 	data.Replicas,
 		Deployment: data.Name,
 		Service: 	getServiceName(data.Name),
-		Ingress: 	ingressName,
+		Ingress: 	workflowEntranceName(data),
 		Type: 		data.Type,
+		IngressKind: data.IngressKind,
+		WorkloadKind: workflowWorkloadKind(data.WorkloadKind),
+		Team: 		data.Team,
+		Status: 	WorkflowStatusCreating,
 	}
 	//调用dao层执行数据库添加操作
 	err = dao.Workflow.Add(workflow)
 	if err != nil {
 		return err
 	}
+	err = dao.WorkflowLabel.Replace(workflow.ID, data.WorkflowLabels)
+	if err != nil {
+		delWorkflowRecord(workflow)
+		return err
+	}
+	//保存完整的创建参数作为第一个spec版本, 用于后续更新、对比和回滚
+	err = saveWorkflowSpec(workflow, data)
+	if err != nil {
+		delWorkflowRecord(workflow)
+		return err
+	}
 
 	//创建k8s资源
-	err = createWorkflowRes(data)
+	compensated, err := createWorkflowRes(workflow, data)
 	if err != nil {
-		return err
+		//已创建的资源全部回滚成功时删除数据库数据, 否则保留数据和步骤状态, 便于排查后再次删除
+		if compensated {
+			delWorkflowRecord(workflow)
+			return err
+		}
+		workflow.Status = WorkflowStatusFailed
+		dao.Workflow.Update(workflow)
+		return errors.New(err.Error() + ", 部分资源回滚失败, 请查看workflow步骤后重新删除")
 	}
-	return nil
+	workflow.Status = WorkflowStatusRunning
+	return dao.Workflow.Update(workflow)
 }
 
-//封装创建workflow对应的k8s资源
-//小写开头的函数, 作用域只在当前包中, 不支持跨包调用
-func createWorkflowRes(data *WorkflowCreate) (err error) {
-	//声明service类型
-	var serviceType string
-	//组装deploymentCreate类型的数据
-	dc := &DeployCreate{
+//组装deploymentCreate类型的数据
+func workflowDeployCreate(data *WorkflowCreate) *DeployCreate {
+	return &DeployCreate{
 		Name: data.Name,
 		Namespace: data.Namespace,
 		Replicas: data.Replicas,
@@ -111,22 +217,16 @@ func createWorkflowRes(data *WorkflowCreate) (err error) {
 		HealthPath: data.HealthPath,
 		ResourceCheck: data.ResourceCheck,
 	}
+}
 
-	//创建deployment
-	err = Deployment.CreateDeployment(dc)
-	if err != nil {
-		return err
-	}
-
+//组装ServiceCreate类型的数据
+func workflowServiceCreate(data *WorkflowCreate) *ServiceCreate {
 	//判断service类型
+	serviceType := "ClusterIP"
 	if data.Type != "Ingress" {
 		serviceType = data.Type
-	} else {
-		serviceType = "ClusterIP"
 	}
-
-	//组装ServiceCreate类型的数据
-	sc := &ServiceCreate{
+	return &ServiceCreate{
 		Name: getServiceName(data.Name),
 		Namespace: data.Namespace,
 		Type: serviceType,
@@ -134,67 +234,112 @@ func createWorkflowRes(data *WorkflowCreate) (err error) {
 		Port: data.Port,
 		NodePort: data.NodePort,
 		Label: data.Label,
+		//StatefulSet需要headless service提供pod的网络标识, 只有ClusterIP类型可以设置为headless
+		Headless: workflowWorkloadKind(data.WorkloadKind) == WorkloadKindStatefulSet && serviceType == "ClusterIP",
 	}
-	err = K8sService.CreateService(sc)
-	if err != nil {
-		return err
-	}
+}
 
-	//组装IngressCreate类型的数据, 创建ingres, 只有ingress类型的workflow才有ingress资源, 所以这里做了一层判断
-	if data.Type == "Ingress" {
-		ic := &IngressCreate{
-			Name: getIngressName(data.Name),
-			Namespace: data.Namespace,
-			Label: data.Label,
-			Hosts: data.Hosts,
-		}
-		err = Ingress.CreateIngress(ic)
-		if err != nil {
-			return err
-		}
+//组装IngressCreate类型的数据
+func workflowIngressCreate(data *WorkflowCreate) *IngressCreate {
+	return &IngressCreate{
+		Name: getIngressName(data.Name),
+		Namespace: data.Namespace,
+		Label: data.Label,
+		Hosts: data.Hosts,
 	}
-	return nil
 }
 
-
-//删除workflow
+//删除workflow, 资源不存在时视为已删除, 某一步失败时继续删除其他资源
 func (wf *workflow) DelById(id int) (err error) {
 	//获取workflow资源
-	workflow, err := dao.Workflow.GetById(id)
+	workflow, err := getWorkflow(id)
 	if err != nil {
 		return err
 	}
-	//删除k8s资源
-	err = delWorkflowRes(workflow)
+	//发布过程中还有预览或金丝雀资源, 需要先终止或完成发布
+	if err = checkNoActiveRelease(workflow); err != nil {
+		logger.Error(errors.New("删除workflow失败, " + err.Error()))
+		return errors.New("删除workflow失败, " + err.Error())
+	}
+	workflow.Status = WorkflowStatusDeleting
+	err = dao.Workflow.Update(workflow)
 	if err != nil {
 		return err
 	}
-	//删除数据库数据
-	err = dao.Workflow.DelById(id)
+	//删除k8s资源
+	err = delWorkflowRes(workflow)
 	if err != nil {
+		workflow.Status = WorkflowStatusDeleteFailed
+		dao.Workflow.Update(workflow)
 		return err
 	}
-	return nil
+	//删除数据库数据
+	return delWorkflowRecord(workflow)
 }
 
-//封装删除workflow对应的k8s资源
-func delWorkflowRes(workflow *model.Workflow) (err error) {
-	//删除deployment
-	err = Deployment.DeleteDeployment(workflow.Name, workflow.Namespace)
-	if err != nil {
-		return err
+//workflowHTTPRoutes 将workflow的hosts转换为HTTPRoute, 按host排序
+//HTTPRoute的hostnames作用于所有规则, 与Ingress中按host区分path的语义一致需要每个host一个HTTPRoute
+//path未指定service时使用workflow的service, 未指定端口时使用workflow的service端口
+func workflowHTTPRoutes(data *WorkflowCreate) []*HTTPRouteCreate {
+	hosts := make([]string, 0, len(data.Hosts))
+	for host := range data.Hosts {
+		hosts = append(hosts, host)
 	}
-	//删除service
-	err = K8sService.DeleteK8sService(getServiceName(workflow.Name), workflow.Namespace)
-	if err != nil {
-		return err
-	}
-	//删除ingress, 这里多了一层判断, 因为只有type为ingress的workflow才有ingress资源
-	if workflow.Type == "Ingress" {
-		err = Ingress.DeleteIngress(getIngressName(workflow.Name), workflow.Namespace)
-		if err != nil {
-			return err
+	sort.Strings(hosts)
+
+	routes := make([]*HTTPRouteCreate, 0, len(hosts))
+	for _, host := range hosts {
+		labels := map[string]string{}
+		for key, value := range data.Label {
+			labels[key] = value
+		}
+		labels[config.WorkflowLabel] = data.Name
+		route := &HTTPRouteCreate{
+			Name: getHostHTTPRouteName(data.Name, host),
+			Namespace: data.Namespace,
+			Labels: labels,
+			ParentRefs: []*RouteParentRef{
+				{
+					Name: data.GatewayName,
+					Namespace: data.GatewayNamespace,
+					SectionName: data.GatewaySection,
+				},
+			},
+		}
+		if host != "" {
+			route.Hostnames = []string{host}
 		}
+		seen := map[string]bool{}
+		for _, httpPath := range data.Hosts[host] {
+			pathType := "PathPrefix"
+			if httpPath.PathType == nwv1.PathTypeExact {
+				pathType = "Exact"
+			}
+			key := pathType + httpPath.Path
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			serviceName := httpPath.ServiceName
+			if serviceName == "" {
+				serviceName = getServiceName(data.Name)
+			}
+			//HTTPRoute的backendRef只支持端口号, workflow自己的service只有一个端口
+			servicePort := httpPath.ServicePort
+			if servicePort == 0 && serviceName == getServiceName(data.Name) {
+				servicePort = data.Port
+			}
+			route.Rules = append(route.Rules, &HTTPRouteRuleCreate{
+				Path: httpPath.Path,
+				PathType: pathType,
+				ServiceName: serviceName,
+				ServicePort: servicePort,
+			})
+		}
+		sort.Slice(route.Rules, func(i, j int) bool {
+			return route.Rules[i].PathType+route.Rules[i].Path < route.Rules[j].PathType+route.Rules[j].Path
+		})
+		routes = append(routes, route)
 	}
-	return nil
-} 
\ No newline at end of file
+	return routes
+}
@@ -0,0 +1,382 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+//workflow健康状态
+const (
+	WorkflowHealthHealthy     = "Healthy"
+	WorkflowHealthDegraded    = "Degraded"
+	WorkflowHealthProgressing = "Progressing"
+	WorkflowHealthMissing     = "Missing"
+)
+
+//详情中返回的最近Warning事件数量
+const workflowEventLimit = 10
+
+//WorkflowWorkloadStatus 工作负载的副本状态, DaemonSet的Replicas为期望调度的节点数
+type WorkflowWorkloadStatus struct {
+	Kind              string `json:"kind"`
+	Name              string `json:"name"`
+	Exists            bool   `json:"exists"`
+	Replicas          int32  `json:"replicas"`
+	ReadyReplicas     int32  `json:"ready_replicas"`
+	AvailableReplicas int32  `json:"available_replicas"`
+	UpdatedReplicas   int32  `json:"updated_replicas"`
+	//Rollout 滚动更新的状态说明, 来自Deployment的Progressing condition或revision对比
+	Rollout string `json:"rollout"`
+}
+
+type WorkflowServiceStatus struct {
+	Name              string `json:"name"`
+	Type              string `json:"type"`
+	Exists            bool   `json:"exists"`
+	ReadyEndpoints    int    `json:"ready_endpoints"`
+	NotReadyEndpoints int    `json:"not_ready_endpoints"`
+}
+
+//WorkflowEntranceStatus Ingress或HTTPRoute的状态, Ingress的Addresses来自status.loadBalancer
+type WorkflowEntranceStatus struct {
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name"`
+	Exists    bool     `json:"exists"`
+	Hosts     []string `json:"hosts"`
+	Addresses []string `json:"addresses"`
+	//HTTPRoute是否已被所有父Gateway接受
+	Attached bool `json:"attached"`
+}
+
+type WorkflowEvent struct {
+	Object        string `json:"object"`
+	Reason        string `json:"reason"`
+	Message       string `json:"message"`
+	Count         int32  `json:"count"`
+	LastTimestamp string `json:"last_timestamp"`
+}
+
+type WorkflowHealth struct {
+	State    string                  `json:"state"`
+	Reasons  []string                `json:"reasons"`
+	Workload *WorkflowWorkloadStatus `json:"workload"`
+	Service  *WorkflowServiceStatus  `json:"service"`
+	Entrance *WorkflowEntranceStatus `json:"entrance"`
+	Events   []*WorkflowEvent        `json:"events,omitempty"`
+}
+
+//WorkflowInfo 列表和详情返回的workflow, 在数据库数据的基础上附带集群中的状态
+//获取状态失败时Health为空, HealthError为失败原因
+type WorkflowInfo struct {
+	*model.Workflow
+	Health      *WorkflowHealth `json:"health"`
+	HealthError string          `json:"health_error,omitempty"`
+}
+
+type WorkflowListResp struct {
+	Items []*WorkflowInfo `json:"items"`
+	Total int             `json:"total"`
+}
+
+//newWorkflowInfo 获取workflow在集群中的状态, withEvents为true时附带pod的Warning事件
+func newWorkflowInfo(workflow *model.Workflow, withEvents bool) *WorkflowInfo {
+	info := &WorkflowInfo{Workflow: workflow}
+	health, err := getWorkflowHealth(workflow, withEvents)
+	if err != nil {
+		logger.Error(errors.New("获取workflow: " + workflow.Name + " 的状态失败, " + err.Error()))
+		info.HealthError = err.Error()
+		return info
+	}
+	info.Health = health
+	return info
+}
+
+//getWorkflowHealth 汇总工作负载、service和入口资源的状态
+//资源缺失为Missing, 滚动更新未完成为Progressing, 就绪副本或endpoint不足为Degraded
+func getWorkflowHealth(workflow *model.Workflow, withEvents bool) (*WorkflowHealth, error) {
+	health := &WorkflowHealth{Reasons: make([]string, 0)}
+
+	workload, selector, progressing, rolloutFailed, err := workflowWorkloadStatus(workflow)
+	if err != nil {
+		return nil, err
+	}
+	health.Workload = workload
+
+	health.Service, err = workflowServiceStatus(workflow)
+	if err != nil {
+		return nil, err
+	}
+
+	if workflow.Type == "Ingress" {
+		health.Entrance, err = workflowEntranceStatus(workflow)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if withEvents && selector != nil {
+		health.Events, err = workflowWarningEvents(workflow.Namespace, selector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//资源缺失
+	if !workload.Exists {
+		health.Reasons = append(health.Reasons, workload.Kind+" "+workload.Name+" 不存在")
+	}
+	if !health.Service.Exists {
+		health.Reasons = append(health.Reasons, "Service "+health.Service.Name+" 不存在")
+	}
+	if health.Entrance != nil && !health.Entrance.Exists {
+		health.Reasons = append(health.Reasons, health.Entrance.Kind+" "+health.Entrance.Name+" 不存在")
+	}
+	if len(health.Reasons) > 0 {
+		health.State = WorkflowHealthMissing
+		return health, nil
+	}
+
+	switch {
+	case rolloutFailed:
+		health.State = WorkflowHealthDegraded
+		health.Reasons = append(health.Reasons, "滚动更新失败, "+workload.Rollout)
+	case progressing:
+		health.State = WorkflowHealthProgressing
+		health.Reasons = append(health.Reasons, fmt.Sprintf("滚动更新中, 已更新 %d/%d", workload.UpdatedReplicas, workload.Replicas))
+	case workload.ReadyReplicas < workload.Replicas:
+		health.State = WorkflowHealthDegraded
+		health.Reasons = append(health.Reasons, fmt.Sprintf("就绪副本数 %d/%d", workload.ReadyReplicas, workload.Replicas))
+	case workload.Replicas > 0 && health.Service.Type != string(corev1.ServiceTypeExternalName) && health.Service.ReadyEndpoints == 0:
+		health.State = WorkflowHealthDegraded
+		health.Reasons = append(health.Reasons, "Service "+health.Service.Name+" 没有就绪的endpoint")
+	case health.Entrance != nil && health.Entrance.Kind == IngressKindHTTPRoute && !health.Entrance.Attached:
+		health.State = WorkflowHealthDegraded
+		health.Reasons = append(health.Reasons, "HTTPRoute "+health.Entrance.Name+" 未被Gateway接受")
+	default:
+		health.State = WorkflowHealthHealthy
+	}
+	return health, nil
+}
+
+//workflowWorkloadStatus 获取工作负载的副本状态, 返回pod的selector用于查询事件
+func workflowWorkloadStatus(workflow *model.Workflow) (status *WorkflowWorkloadStatus, selector *metav1.LabelSelector, progressing, rolloutFailed bool, err error) {
+	kind := workflowWorkloadKind(workflow.WorkloadKind)
+	status = &WorkflowWorkloadStatus{Kind: kind, Name: workflow.Name}
+	switch kind {
+	case WorkloadKindStatefulSet:
+		statefulSet, err := K8s.Clientset.AppsV1().StatefulSets(workflow.Namespace).Get(context.TODO(), workflow.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return status, nil, false, false, nil
+		}
+		if err != nil {
+			return nil, nil, false, false, err
+		}
+		status.Exists = true
+		status.Replicas = replicasOrOne(statefulSet.Spec.Replicas)
+		status.ReadyReplicas = statefulSet.Status.ReadyReplicas
+		status.AvailableReplicas = statefulSet.Status.AvailableReplicas
+		status.UpdatedReplicas = statefulSet.Status.UpdatedReplicas
+		progressing = statefulSet.Status.ObservedGeneration < statefulSet.Generation ||
+			status.UpdatedReplicas < status.Replicas ||
+			(statefulSet.Status.UpdateRevision != "" && statefulSet.Status.CurrentRevision != statefulSet.Status.UpdateRevision)
+		status.Rollout = "revision " + statefulSet.Status.CurrentRevision
+		if statefulSet.Status.UpdateRevision != statefulSet.Status.CurrentRevision {
+			status.Rollout += " -> " + statefulSet.Status.UpdateRevision
+		}
+		return status, statefulSet.Spec.Selector, progressing, false, nil
+	case WorkloadKindDaemonSet:
+		daemonSet, err := K8s.Clientset.AppsV1().DaemonSets(workflow.Namespace).Get(context.TODO(), workflow.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return status, nil, false, false, nil
+		}
+		if err != nil {
+			return nil, nil, false, false, err
+		}
+		status.Exists = true
+		status.Replicas = daemonSet.Status.DesiredNumberScheduled
+		status.ReadyReplicas = daemonSet.Status.NumberReady
+		status.AvailableReplicas = daemonSet.Status.NumberAvailable
+		status.UpdatedReplicas = daemonSet.Status.UpdatedNumberScheduled
+		progressing = daemonSet.Status.ObservedGeneration < daemonSet.Generation || status.UpdatedReplicas < status.Replicas
+		return status, daemonSet.Spec.Selector, progressing, false, nil
+	}
+
+	deployment, err := K8s.Clientset.AppsV1().Deployments(workflow.Namespace).Get(context.TODO(), workflow.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return status, nil, false, false, nil
+	}
+	if err != nil {
+		return nil, nil, false, false, err
+	}
+	status.Exists = true
+	status.Replicas = replicasOrOne(deployment.Spec.Replicas)
+	status.ReadyReplicas = deployment.Status.ReadyReplicas
+	status.AvailableReplicas = deployment.Status.AvailableReplicas
+	status.UpdatedReplicas = deployment.Status.UpdatedReplicas
+	progressing = deployment.Status.ObservedGeneration < deployment.Generation ||
+		status.UpdatedReplicas < status.Replicas ||
+		deployment.Status.Replicas > status.UpdatedReplicas
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type != appsv1.DeploymentProgressing {
+			continue
+		}
+		status.Rollout = condition.Reason + ": " + condition.Message
+		//超过progressDeadlineSeconds仍未完成
+		if condition.Status == corev1.ConditionFalse && condition.Reason == "ProgressDeadlineExceeded" {
+			rolloutFailed = true
+		}
+	}
+	return status, deployment.Spec.Selector, progressing, rolloutFailed, nil
+}
+
+//未设置replicas时apiserver默认为1
+func replicasOrOne(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+//workflowServiceStatus 获取service的就绪和未就绪endpoint数量
+func workflowServiceStatus(workflow *model.Workflow) (*WorkflowServiceStatus, error) {
+	status := &WorkflowServiceStatus{Name: getServiceName(workflow.Name)}
+	service, err := K8s.Clientset.CoreV1().Services(workflow.Namespace).Get(context.TODO(), status.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status.Exists = true
+	status.Type = string(service.Spec.Type)
+	chain, err := K8sService.buildServiceChain(service)
+	if err != nil {
+		return nil, err
+	}
+	status.ReadyEndpoints = chain.ReadyCount
+	status.NotReadyEndpoints = chain.NotReadyCount
+	return status, nil
+}
+
+//workflowEntranceStatus 获取Ingress的host和地址, 或HTTPRoute的hostnames和挂载状态
+func workflowEntranceStatus(workflow *model.Workflow) (*WorkflowEntranceStatus, error) {
+	if workflow.IngressKind == IngressKindHTTPRoute {
+		//每个host一个HTTPRoute, 汇总所有HTTPRoute的hostnames, 全部被Gateway接受时才算挂载成功
+		status := &WorkflowEntranceStatus{Kind: IngressKindHTTPRoute, Name: getHTTPRouteName(workflow.Name), Hosts: make([]string, 0), Addresses: make([]string, 0)}
+		routes, err := listWorkflowHTTPRoutes(workflow.Name, workflow.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		status.Exists = len(routes) > 0
+		status.Attached = len(routes) > 0
+		for i := range routes {
+			if hosts, _, _ := unstructured.NestedStringSlice(routes[i].Object, "spec", "hostnames"); hosts != nil {
+				status.Hosts = append(status.Hosts, hosts...)
+			}
+			parents := routeParentStatus(&routes[i])
+			if len(parents) == 0 {
+				status.Attached = false
+			}
+			for _, parent := range parents {
+				if !parent.Attached {
+					status.Attached = false
+				}
+			}
+		}
+		return status, nil
+	}
+
+	status := &WorkflowEntranceStatus{Kind: IngressKindIngress, Name: getIngressName(workflow.Name), Hosts: make([]string, 0), Addresses: make([]string, 0)}
+	ingress, err := K8s.Clientset.NetworkingV1().Ingresses(workflow.Namespace).Get(context.TODO(), status.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status.Exists = true
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != "" {
+			status.Hosts = append(status.Hosts, rule.Host)
+		}
+	}
+	for _, lb := range ingress.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			status.Addresses = append(status.Addresses, lb.IP)
+		}
+		if lb.Hostname != "" {
+			status.Addresses = append(status.Addresses, lb.Hostname)
+		}
+	}
+	status.Attached = len(status.Addresses) > 0
+	return status, nil
+}
+
+//workflowWarningEvents 获取workflow的pod最近的Warning事件, 按最后发生时间倒序
+func workflowWarningEvents(namespace string, selector *metav1.LabelSelector) ([]*WorkflowEvent, error) {
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	podList, err := K8s.Clientset.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: labelSelector.String()})
+	if err != nil {
+		return nil, err
+	}
+	podNames := map[string]bool{}
+	for _, pod := range podList.Items {
+		podNames[pod.Name] = true
+	}
+
+	eventList, err := K8s.Clientset.CoreV1().Events(namespace).List(context.TODO(), metav1.ListOptions{
+		FieldSelector: "involvedObject.kind=Pod,type=" + corev1.EventTypeWarning,
+	})
+	if err != nil {
+		return nil, err
+	}
+	items := make([]corev1.Event, 0)
+	for _, event := range eventList.Items {
+		if podNames[event.InvolvedObject.Name] {
+			items = append(items, event)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return eventTime(&items[i]).After(eventTime(&items[j]).Time)
+	})
+	if len(items) > workflowEventLimit {
+		items = items[:workflowEventLimit]
+	}
+
+	events := make([]*WorkflowEvent, 0, len(items))
+	for i := range items {
+		events = append(events, &WorkflowEvent{
+			Object:        "Pod/" + items[i].InvolvedObject.Name,
+			Reason:        items[i].Reason,
+			Message:       items[i].Message,
+			Count:         items[i].Count,
+			LastTimestamp: eventTime(&items[i]).Format("2006-01-02 15:04:05"),
+		})
+	}
+	return events, nil
+}
+
+//eventTime 新版本的事件只设置eventTime, 旧版本设置lastTimestamp
+func eventTime(event *corev1.Event) metav1.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp
+	}
+	if !event.EventTime.IsZero() {
+		return metav1.NewTime(event.EventTime.Time)
+	}
+	return event.CreationTimestamp
+}
@@ -0,0 +1,688 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"test4/dao"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+//WorkflowSpecResp workflow的spec版本, Spec为反序列化后的创建参数
+type WorkflowSpecResp struct {
+	Version   int             `json:"version"`
+	Current   bool            `json:"current"`
+	CreatedAt string          `json:"created_at"`
+	Spec      *WorkflowCreate `json:"spec"`
+}
+
+//WorkflowDrift 集群中的资源与spec不一致的字段
+type WorkflowDrift struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+type WorkflowDriftResp struct {
+	Version int              `json:"version"`
+	InSync  bool             `json:"in_sync"`
+	Drifts  []*WorkflowDrift `json:"drifts"`
+}
+
+//资源不存在时Field使用的值
+const driftFieldMissing = "missing"
+
+//集群中存在spec中没有的资源时Field使用的值
+const driftFieldUnexpected = "unexpected"
+
+//获取workflow的所有spec版本
+func (wf *workflow) GetWorkflowSpecs(id int) (specs []*WorkflowSpecResp, err error) {
+	workflow, err := getWorkflow(id)
+	if err != nil {
+		return nil, err
+	}
+	list, err := dao.WorkflowSpec.GetList(workflow.ID)
+	if err != nil {
+		return nil, err
+	}
+	specs = make([]*WorkflowSpecResp, 0, len(list))
+	for _, item := range list {
+		data := &WorkflowCreate{}
+		if err = json.Unmarshal([]byte(item.Spec), data); err != nil {
+			logger.Error(errors.New("解析workflow spec失败, " + err.Error()))
+			return nil, errors.New("解析workflow spec失败, " + err.Error())
+		}
+		resp := &WorkflowSpecResp{
+			Version: item.Version,
+			Current: item.Version == workflow.SpecVersion,
+			Spec:    data,
+		}
+		if item.CreatedAt != nil {
+			resp.CreatedAt = item.CreatedAt.Format("2006-01-02 15:04:05")
+		}
+		specs = append(specs, resp)
+	}
+	return specs, nil
+}
+
+//更新workflow, 将新的spec应用到deployment、service和ingress, 并保存为新的版本
+//name和namespace决定了各资源的名字, 不允许修改
+func (wf *workflow) UpdateWorkflow(id int, data *WorkflowCreate) (err error) {
+	workflow, err := getWorkflow(id)
+	if err != nil {
+		return err
+	}
+	if data.Name != workflow.Name || data.Namespace != workflow.Namespace {
+		logger.Error(errors.New("更新workflow失败, 不允许修改name和namespace"))
+		return errors.New("更新workflow失败, 不允许修改name和namespace")
+	}
+	if err = validateWorkloadKind(data.WorkloadKind); err != nil {
+		logger.Error(errors.New("更新workflow失败, " + err.Error()))
+		return errors.New("更新workflow失败, " + err.Error())
+	}
+
+	if err = checkNoActiveRelease(workflow); err != nil {
+		logger.Error(errors.New("更新workflow失败, " + err.Error()))
+		return errors.New("更新workflow失败, " + err.Error())
+	}
+
+	err = applyWorkflowRes(workflow, data)
+	if err != nil {
+		logger.Error(errors.New("更新workflow: " + workflow.Name + " 失败, " + err.Error()))
+		return errors.New("更新workflow失败, " + err.Error())
+	}
+	return saveWorkflowUpdate(workflow, data)
+}
+
+//saveWorkflowUpdate 资源应用成功后更新workflow的数据库数据, 并保存为新的spec版本
+func saveWorkflowUpdate(workflow *model.Workflow, data *WorkflowCreate) error {
+	workflow.Replicas = data.Replicas
+	workflow.WorkloadKind = workflowWorkloadKind(data.WorkloadKind)
+	workflow.Type = data.Type
+	workflow.IngressKind = data.IngressKind
+	workflow.Ingress = workflowEntranceName(data)
+	workflow.Team = data.Team
+	if err := dao.WorkflowLabel.Replace(workflow.ID, data.WorkflowLabels); err != nil {
+		return err
+	}
+	return saveWorkflowSpec(workflow, data)
+}
+
+//回滚workflow到指定的spec版本, 回滚本身也会生成一个新版本
+func (wf *workflow) RollbackWorkflow(id, version int) (err error) {
+	workflow, err := getWorkflow(id)
+	if err != nil {
+		return err
+	}
+	data, err := getWorkflowSpec(workflow, version)
+	if err != nil {
+		return err
+	}
+	return wf.UpdateWorkflow(id, data)
+}
+
+//按当前spec重新应用workflow的资源, 用于修复drift
+func (wf *workflow) ReconcileWorkflow(id int) (err error) {
+	workflow, err := getWorkflow(id)
+	if err != nil {
+		return err
+	}
+	if err = checkNoActiveRelease(workflow); err != nil {
+		logger.Error(errors.New("同步workflow失败, " + err.Error()))
+		return errors.New("同步workflow失败, " + err.Error())
+	}
+	data, err := getWorkflowSpec(workflow, workflow.SpecVersion)
+	if err != nil {
+		return err
+	}
+	err = applyWorkflowRes(workflow, data)
+	if err != nil {
+		logger.Error(errors.New("同步workflow: " + workflow.Name + " 失败, " + err.Error()))
+		return errors.New("同步workflow失败, " + err.Error())
+	}
+	return nil
+}
+
+//对比集群中的资源与当前spec, 只比较spec中声明的字段, 忽略apiserver填充的默认值
+func (wf *workflow) GetWorkflowDrift(id int) (driftResp *WorkflowDriftResp, err error) {
+	workflow, err := getWorkflow(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := getWorkflowSpec(workflow, workflow.SpecVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	drifts := make([]*WorkflowDrift, 0)
+	workloadDrifts, err := workloadDrift(data)
+	if err != nil {
+		return nil, err
+	}
+	drifts = append(drifts, workloadDrifts...)
+
+	serviceDrifts, err := serviceDrift(data)
+	if err != nil {
+		return nil, err
+	}
+	drifts = append(drifts, serviceDrifts...)
+
+	if data.Type == "Ingress" && data.IngressKind == IngressKindHTTPRoute {
+		routeDrifts, err := httpRouteDrift(data)
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, routeDrifts...)
+	} else if data.Type == "Ingress" {
+		ingressDrifts, err := ingressDrift(data)
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, ingressDrifts...)
+	}
+
+	return &WorkflowDriftResp{
+		Version: workflow.SpecVersion,
+		InSync:  len(drifts) == 0,
+		Drifts:  drifts,
+	}, nil
+}
+
+//getWorkflow 获取workflow, 不存在时返回错误
+func getWorkflow(id int) (*model.Workflow, error) {
+	workflow, err := dao.Workflow.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+	if workflow.ID == 0 {
+		logger.Error(errors.New("workflow: " + strconv.Itoa(id) + " 不存在"))
+		return nil, errors.New("workflow: " + strconv.Itoa(id) + " 不存在")
+	}
+	return workflow, nil
+}
+
+//getWorkflowSpec 获取workflow指定版本的spec, version为0时获取最新版本
+func getWorkflowSpec(workflow *model.Workflow, version int) (*WorkflowCreate, error) {
+	spec, err := dao.WorkflowSpec.Get(workflow.ID, version)
+	if err != nil {
+		return nil, err
+	}
+	if spec == nil {
+		logger.Error(errors.New("workflow: " + workflow.Name + " 不存在spec版本 " + strconv.Itoa(version)))
+		return nil, errors.New("workflow: " + workflow.Name + " 不存在spec版本 " + strconv.Itoa(version))
+	}
+	data := &WorkflowCreate{}
+	if err = json.Unmarshal([]byte(spec.Spec), data); err != nil {
+		logger.Error(errors.New("解析workflow spec失败, " + err.Error()))
+		return nil, errors.New("解析workflow spec失败, " + err.Error())
+	}
+	return data, nil
+}
+
+//saveWorkflowSpec 将创建参数保存为新的spec版本, 并更新workflow当前的版本号
+func saveWorkflowSpec(workflow *model.Workflow, data *WorkflowCreate) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		logger.Error(errors.New("序列化workflow spec失败, " + err.Error()))
+		return errors.New("序列化workflow spec失败, " + err.Error())
+	}
+	latest, err := dao.WorkflowSpec.Get(workflow.ID, 0)
+	if err != nil {
+		return err
+	}
+	version := 1
+	if latest != nil {
+		version = latest.Version + 1
+	}
+	err = dao.WorkflowSpec.Add(&model.WorkflowSpec{
+		WorkflowID: workflow.ID,
+		Version:    version,
+		Spec:       string(content),
+	})
+	if err != nil {
+		return err
+	}
+	workflow.SpecVersion = version
+	return dao.Workflow.Update(workflow)
+}
+
+//workflowEntranceName 返回workflow入口资源的名字, 非Ingress类型返回空
+func workflowEntranceName(data *WorkflowCreate) string {
+	if data.Type != "Ingress" {
+		return ""
+	}
+	if data.IngressKind == IngressKindHTTPRoute {
+		return getHTTPRouteName(data.Name)
+	}
+	return getIngressName(data.Name)
+}
+
+//applyWorkflowRes 将spec应用到集群, 资源存在则更新, 不存在则创建
+//工作负载类型或入口资源类型(Ingress/HTTPRoute/无)变化时, 先删除旧的资源
+func applyWorkflowRes(workflow *model.Workflow, data *WorkflowCreate) error {
+	if err := applyWorkflowWorkload(workflow, data); err != nil {
+		return err
+	}
+	if err := applyWorkflowService(data); err != nil {
+		return err
+	}
+
+	oldEntrance := ""
+	if workflow.Type == "Ingress" {
+		oldEntrance = IngressKindIngress
+		if workflow.IngressKind == IngressKindHTTPRoute {
+			oldEntrance = IngressKindHTTPRoute
+		}
+	}
+	newEntrance := ""
+	if data.Type == "Ingress" {
+		newEntrance = IngressKindIngress
+		if data.IngressKind == IngressKindHTTPRoute {
+			newEntrance = IngressKindHTTPRoute
+		}
+	}
+	if oldEntrance != newEntrance {
+		var err error
+		switch oldEntrance {
+		case IngressKindIngress:
+			err = deleteIngressIfExists(getIngressName(workflow.Name), workflow.Namespace)
+		case IngressKindHTTPRoute:
+			err = deleteWorkflowHTTPRoutes(workflow.Name, workflow.Namespace, nil)
+		}
+		if err != nil {
+			return errors.New("删除旧的入口资源失败, " + err.Error())
+		}
+	}
+
+	switch newEntrance {
+	case IngressKindIngress:
+		return applyWorkflowIngress(data)
+	case IngressKindHTTPRoute:
+		//每个host一个HTTPRoute, 删除已经不在hosts中的host对应的HTTPRoute
+		keep := map[string]bool{}
+		for _, route := range workflowHTTPRoutes(data) {
+			if err := Gateway.applyHTTPRoute(route); err != nil {
+				return errors.New("更新HTTPRoute: " + route.Name + " 失败, " + err.Error())
+			}
+			keep[route.Name] = true
+		}
+		if err := deleteWorkflowHTTPRoutes(data.Name, data.Namespace, keep); err != nil {
+			return errors.New("删除多余的HTTPRoute失败, " + err.Error())
+		}
+	}
+	return nil
+}
+
+//applyWorkflowService 更新时保留已分配的clusterIP, 以及未显式指定的nodePort
+//clusterIP无法原地修改, 在headless与普通Service之间切换时删除后重建
+func applyWorkflowService(data *WorkflowCreate) error {
+	desired, err := buildService(workflowServiceCreate(data))
+	if err != nil {
+		return errors.New("Service参数校验失败, " + err.Error())
+	}
+	client := K8s.Clientset.CoreV1().Services(data.Namespace)
+	live, err := client.Get(context.TODO(), desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(context.TODO(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return errors.New("创建Service失败, " + err.Error())
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.New("获取Service失败, " + err.Error())
+	}
+	if isHeadlessService(desired) != isHeadlessService(live) {
+		err = client.Delete(context.TODO(), live.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.New("删除Service失败, " + err.Error())
+		}
+		_, err = client.Create(context.TODO(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return errors.New("重建Service失败, " + err.Error())
+		}
+		return nil
+	}
+
+	keepNodePort := desired.Spec.Type == corev1.ServiceTypeNodePort || desired.Spec.Type == corev1.ServiceTypeLoadBalancer
+	for i := range desired.Spec.Ports {
+		port := &desired.Spec.Ports[i]
+		if port.NodePort != 0 || !keepNodePort {
+			continue
+		}
+		for _, livePort := range live.Spec.Ports {
+			if livePort.Port == port.Port && livePort.Protocol == port.Protocol {
+				port.NodePort = livePort.NodePort
+			}
+		}
+	}
+	live.Labels = desired.Labels
+	live.Spec.Type = desired.Spec.Type
+	live.Spec.Selector = desired.Spec.Selector
+	live.Spec.Ports = desired.Spec.Ports
+	_, err = client.Update(context.TODO(), live, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.New("更新Service失败, " + err.Error())
+	}
+	return nil
+}
+
+//applyWorkflowIngress 与创建ingress一样, 更新前检查IngressClass和host+path冲突
+func applyWorkflowIngress(data *WorkflowCreate) error {
+	desired, err := buildIngress(workflowIngressCreate(data))
+	if err != nil {
+		return errors.New("Ingress参数错误, " + err.Error())
+	}
+	if err = checkIngress(desired); err != nil {
+		return err
+	}
+	client := K8s.Clientset.NetworkingV1().Ingresses(data.Namespace)
+	live, err := client.Get(context.TODO(), desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = client.Create(context.TODO(), desired, metav1.CreateOptions{})
+		if err != nil {
+			return errors.New("创建Ingress失败, " + err.Error())
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.New("获取Ingress失败, " + err.Error())
+	}
+	live.Labels = desired.Labels
+	live.Spec = desired.Spec
+	_, err = client.Update(context.TODO(), live, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.New("更新Ingress失败, " + err.Error())
+	}
+	return nil
+}
+
+//serviceDrift 对比类型、selector和端口, nodePort只在spec中指定时比较
+func serviceDrift(data *WorkflowCreate) ([]*WorkflowDrift, error) {
+	desired, err := buildService(workflowServiceCreate(data))
+	if err != nil {
+		return nil, errors.New("Service参数校验失败, " + err.Error())
+	}
+	live, err := K8s.Clientset.CoreV1().Services(data.Namespace).Get(context.TODO(), desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return []*WorkflowDrift{missingDrift("Service", desired.Name)}, nil
+	}
+	if err != nil {
+		logger.Error(errors.New("获取Service失败, " + err.Error()))
+		return nil, errors.New("获取Service失败, " + err.Error())
+	}
+
+	drifts := make([]*WorkflowDrift, 0)
+	add := func(field string, expected, actual interface{}) {
+		if drift := compareField("Service", desired.Name, field, expected, actual); drift != nil {
+			drifts = append(drifts, drift)
+		}
+	}
+	add("spec.type", desired.Spec.Type, live.Spec.Type)
+	add("spec.clusterIP", serviceClusterIPMode(desired), serviceClusterIPMode(live))
+	add("spec.selector", desired.Spec.Selector, live.Spec.Selector)
+	add("spec.ports", servicePortKeys(desired.Spec.Ports, desired.Spec.Ports), servicePortKeys(live.Spec.Ports, desired.Spec.Ports))
+	return drifts, nil
+}
+
+func isHeadlessService(service *corev1.Service) bool {
+	return service.Spec.ClusterIP == corev1.ClusterIPNone
+}
+
+//serviceClusterIPMode 只对比是否headless, 已分配的clusterIP地址不算漂移
+func serviceClusterIPMode(service *corev1.Service) string {
+	if isHeadlessService(service) {
+		return corev1.ClusterIPNone
+	}
+	return "ClusterIP"
+}
+
+//ingressDrift 对比ingressClassName、rules和tls
+func ingressDrift(data *WorkflowCreate) ([]*WorkflowDrift, error) {
+	desired, err := buildIngress(workflowIngressCreate(data))
+	if err != nil {
+		return nil, errors.New("Ingress参数错误, " + err.Error())
+	}
+	live, err := K8s.Clientset.NetworkingV1().Ingresses(data.Namespace).Get(context.TODO(), desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return []*WorkflowDrift{missingDrift("Ingress", desired.Name)}, nil
+	}
+	if err != nil {
+		logger.Error(errors.New("获取Ingress失败, " + err.Error()))
+		return nil, errors.New("获取Ingress失败, " + err.Error())
+	}
+
+	drifts := make([]*WorkflowDrift, 0)
+	add := func(field string, expected, actual interface{}) {
+		if drift := compareField("Ingress", desired.Name, field, expected, actual); drift != nil {
+			drifts = append(drifts, drift)
+		}
+	}
+	if desired.Spec.IngressClassName != nil {
+		add("spec.ingressClassName", desired.Spec.IngressClassName, live.Spec.IngressClassName)
+	}
+	add("spec.rules", ingressRuleKeys(desired.Spec.Rules), ingressRuleKeys(live.Spec.Rules))
+	add("spec.tls", desired.Spec.TLS, live.Spec.TLS)
+	return drifts, nil
+}
+
+//httpRouteDrift 逐个对比每个host的HTTPRoute的hostnames和路由规则, 规则只比较path和后端, 忽略CRD填充的默认字段
+//集群中多出的HTTPRoute也记为drift
+func httpRouteDrift(data *WorkflowCreate) ([]*WorkflowDrift, error) {
+	gvr, err := Gateway.gvr("httproutes")
+	if err != nil {
+		return nil, err
+	}
+	client := resourceClient(gvr, true, data.Namespace)
+	drifts := make([]*WorkflowDrift, 0)
+	expected := map[string]bool{}
+	for _, route := range workflowHTTPRoutes(data) {
+		desired := buildHTTPRoute(route)
+		expected[desired.GetName()] = true
+		live, err := client.Get(context.TODO(), desired.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			drifts = append(drifts, missingDrift("HTTPRoute", desired.GetName()))
+			continue
+		}
+		if err != nil {
+			logger.Error(errors.New("获取HTTPRoute失败, " + err.Error()))
+			return nil, errors.New("获取HTTPRoute失败, " + err.Error())
+		}
+
+		add := func(field string, expected, actual interface{}) {
+			if drift := compareField("HTTPRoute", desired.GetName(), field, expected, actual); drift != nil {
+				drifts = append(drifts, drift)
+			}
+		}
+		desiredHosts, _, _ := unstructured.NestedStringSlice(desired.Object, "spec", "hostnames")
+		liveHosts, _, _ := unstructured.NestedStringSlice(live.Object, "spec", "hostnames")
+		sort.Strings(liveHosts)
+		add("spec.hostnames", desiredHosts, liveHosts)
+		add("spec.rules", httpRouteRuleKeys(desired), httpRouteRuleKeys(live))
+	}
+
+	routes, err := listWorkflowHTTPRoutes(data.Name, data.Namespace)
+	if err != nil {
+		logger.Error(errors.New("获取HTTPRoute列表失败, " + err.Error()))
+		return nil, errors.New("获取HTTPRoute列表失败, " + err.Error())
+	}
+	for _, route := range routes {
+		if !expected[route.GetName()] {
+			drifts = append(drifts, &WorkflowDrift{Kind: "HTTPRoute", Name: route.GetName(), Field: driftFieldUnexpected, Expected: "absent", Actual: "present"})
+		}
+	}
+	return drifts, nil
+}
+
+func missingDrift(kind, name string) *WorkflowDrift {
+	return &WorkflowDrift{Kind: kind, Name: name, Field: driftFieldMissing, Expected: "present", Actual: driftFieldMissing}
+}
+
+//compareField 按json序列化后的结果比较, 不一致时返回drift
+func compareField(kind, name, field string, expected, actual interface{}) *WorkflowDrift {
+	expectedJSON, _ := json.Marshal(expected)
+	actualJSON, _ := json.Marshal(actual)
+	if string(expectedJSON) == string(actualJSON) {
+		return nil
+	}
+	return &WorkflowDrift{
+		Kind:     kind,
+		Name:     name,
+		Field:    field,
+		Expected: string(expectedJSON),
+		Actual:   string(actualJSON),
+	}
+}
+
+//subsetOf 只保留actual中expected声明过的key, 其他组件添加的标签不算drift
+func subsetOf(expected, actual map[string]string) map[string]string {
+	subset := map[string]string{}
+	for key := range expected {
+		if value, ok := actual[key]; ok {
+			subset[key] = value
+		}
+	}
+	return subset
+}
+
+//defaultContainerPorts 补齐apiserver为容器端口填充的默认协议
+func defaultContainerPorts(ports []corev1.ContainerPort) []corev1.ContainerPort {
+	result := make([]corev1.ContainerPort, len(ports))
+	for i, port := range ports {
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		result[i] = port
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+//defaultProbe 补齐apiserver为探针填充的默认值
+func defaultProbe(probe *corev1.Probe) *corev1.Probe {
+	if probe == nil {
+		return nil
+	}
+	probe = probe.DeepCopy()
+	if probe.TimeoutSeconds == 0 {
+		probe.TimeoutSeconds = 1
+	}
+	if probe.PeriodSeconds == 0 {
+		probe.PeriodSeconds = 10
+	}
+	if probe.SuccessThreshold == 0 {
+		probe.SuccessThreshold = 1
+	}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = 3
+	}
+	if probe.HTTPGet != nil && probe.HTTPGet.Scheme == "" {
+		probe.HTTPGet.Scheme = corev1.URISchemeHTTP
+	}
+	return probe
+}
+
+//servicePortKeys 将端口转换为 协议/端口->目标端口 的形式, nodePort只在spec中指定时参与比较
+func servicePortKeys(ports []corev1.ServicePort, desired []corev1.ServicePort) []string {
+	pinnedNodePort := map[int32]bool{}
+	for _, port := range desired {
+		if port.NodePort != 0 {
+			pinnedNodePort[port.Port] = true
+		}
+	}
+	keys := make([]string, 0, len(ports))
+	for _, port := range ports {
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		key := fmt.Sprintf("%s/%d->%s", protocol, port.Port, port.TargetPort.String())
+		if pinnedNodePort[port.Port] {
+			key += fmt.Sprintf(" nodePort=%d", port.NodePort)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//ingressRuleKeys 将ingress规则转换为 host+path->service:port 的形式
+func ingressRuleKeys(rules []nwv1.IngressRule) []string {
+	keys := make([]string, 0)
+	for _, rule := range rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			pathType := ""
+			if path.PathType != nil {
+				pathType = string(*path.PathType)
+			}
+			backend := ""
+			if path.Backend.Service != nil {
+				backend = path.Backend.Service.Name + ":" + strconv.Itoa(int(path.Backend.Service.Port.Number))
+				if path.Backend.Service.Port.Name != "" {
+					backend = path.Backend.Service.Name + ":" + path.Backend.Service.Port.Name
+				}
+			}
+			keys = append(keys, fmt.Sprintf("%s%s(%s)->%s", rule.Host, path.Path, pathType, backend))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//httpRouteRuleKeys 将HTTPRoute规则转换为 path(type)->service:port 的形式
+func httpRouteRuleKeys(route *unstructured.Unstructured) []string {
+	keys := make([]string, 0)
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	for _, rule := range rules {
+		ruleMap, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		paths := make([]string, 0)
+		matches, _, _ := unstructured.NestedSlice(ruleMap, "matches")
+		for _, match := range matches {
+			matchMap, ok := match.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			pathType, _, _ := unstructured.NestedString(matchMap, "path", "type")
+			value, _, _ := unstructured.NestedString(matchMap, "path", "value")
+			paths = append(paths, value+"("+pathType+")")
+		}
+		backends := make([]string, 0)
+		refs, _, _ := unstructured.NestedSlice(ruleMap, "backendRefs")
+		for _, ref := range refs {
+			refMap, ok := ref.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(refMap, "name")
+			port, _, _ := unstructured.NestedFieldNoCopy(refMap, "port")
+			backends = append(backends, fmt.Sprintf("%s:%v", name, port))
+		}
+		sort.Strings(backends)
+		for _, path := range paths {
+			keys = append(keys, fmt.Sprintf("%s->%v", path, backends))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
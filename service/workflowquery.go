@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"test4/dao"
+)
+
+//WorkflowListQuery workflow列表的查询参数, 直接绑定GET请求的参数
+//Labels格式为 k1=v1,k2=v2; 时间格式为 2006-01-02 或 2006-01-02 15:04:05, 只有日期时created_to包含当天
+type WorkflowListQuery struct {
+	Name        string `form:"name"`
+	Namespace   string `form:"namespace"`
+	Type        string `form:"type"`
+	Team        string `form:"team"`
+	Labels      string `form:"labels"`
+	CreatedFrom string `form:"created_from"`
+	CreatedTo   string `form:"created_to"`
+	SortBy      string `form:"sort_by"`
+	Order       string `form:"order"`
+	Page        int    `form:"page"`
+	Limit       int    `form:"limit"`
+}
+
+//WorkflowBulkResult 批量操作中单个workflow的结果
+type WorkflowBulkResult struct {
+	ID      int    `json:"id"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+//批量删除workflow, 单个失败不影响其他workflow
+func (wf *workflow) BulkDelete(ids []int) []*WorkflowBulkResult {
+	return runWorkflowBulk(ids, wf.DelById)
+}
+
+//批量按当前spec同步workflow
+func (wf *workflow) BulkReconcile(ids []int) []*WorkflowBulkResult {
+	return runWorkflowBulk(ids, wf.ReconcileWorkflow)
+}
+
+func runWorkflowBulk(ids []int, fn func(id int) error) []*WorkflowBulkResult {
+	results := make([]*WorkflowBulkResult, 0, len(ids))
+	for _, id := range ids {
+		result := &WorkflowBulkResult{ID: id, Success: true}
+		if err := fn(id); err != nil {
+			result.Success = false
+			result.Message = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+//toDaoQuery 解析标签和时间范围
+func (query *WorkflowListQuery) toDaoQuery() (*dao.WorkflowQuery, error) {
+	daoQuery := &dao.WorkflowQuery{
+		Name:      query.Name,
+		Namespace: query.Namespace,
+		Type:      query.Type,
+		Team:      query.Team,
+		SortBy:    query.SortBy,
+		Order:     query.Order,
+		Page:      query.Page,
+		Limit:     query.Limit,
+	}
+	labels, err := parseWorkflowLabels(query.Labels)
+	if err != nil {
+		return nil, err
+	}
+	daoQuery.Labels = labels
+	if query.CreatedFrom != "" {
+		from, _, err := parseQueryTime(query.CreatedFrom)
+		if err != nil {
+			return nil, err
+		}
+		daoQuery.CreatedFrom = &from
+	}
+	if query.CreatedTo != "" {
+		to, dateOnly, err := parseQueryTime(query.CreatedTo)
+		if err != nil {
+			return nil, err
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		daoQuery.CreatedTo = &to
+	}
+	return daoQuery, nil
+}
+
+//parseWorkflowLabels 解析 k1=v1,k2=v2 格式的标签
+func parseWorkflowLabels(selector string) (map[string]string, error) {
+	labels := map[string]string{}
+	if strings.TrimSpace(selector) == "" {
+		return labels, nil
+	}
+	for _, item := range strings.Split(selector, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.New("标签格式错误: " + item + ", 应为 key=value")
+		}
+		labels[kv[0]] = kv[1]
+	}
+	return labels, nil
+}
+
+//parseQueryTime 按本地时区解析时间, dateOnly表示只传了日期
+func parseQueryTime(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.ParseInLocation("2006-01-02 15:04:05", value, time.Local); err == nil {
+		return t, false, nil
+	}
+	if t, err = time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	if t, err = time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	return t, false, errors.New("时间格式错误: " + value + ", 应为 2006-01-02 或 2006-01-02 15:04:05")
+}
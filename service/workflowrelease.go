@@ -0,0 +1,654 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"test4/dao"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+	appsv1 "k8s.io/api/apps/v1"
+	nwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//发布策略
+const (
+	ReleaseStrategyBlueGreen = "BlueGreen"
+	ReleaseStrategyCanary    = "Canary"
+)
+
+//金丝雀的分流方式, Ingress通过nginx canary注解按权重分流, Replicas按新旧两个Deployment的副本数比例分流
+const (
+	CanaryModeIngress  = "Ingress"
+	CanaryModeReplicas = "Replicas"
+)
+
+//发布阶段
+const (
+	ReleasePhaseProgressing = "Progressing"
+	ReleasePhasePromoted    = "Promoted"
+	ReleasePhaseAborted     = "Aborted"
+)
+
+const (
+	//releaseTrackLabel 预览和金丝雀pod额外的标签, 用于service区分新旧版本
+	releaseTrackLabel = "workflow-release-track"
+	//podTemplateHashLabel deployment为每个replicaSet的pod生成的标签
+	podTemplateHashLabel = "pod-template-hash"
+	//deploymentRevisionAnnotation deployment和当前replicaSet上的版本号
+	deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+	nginxCanaryAnnotation       = "nginx.ingress.kubernetes.io/canary"
+	nginxCanaryWeightAnnotation = "nginx.ingress.kubernetes.io/canary-weight"
+)
+
+//未指定时金丝雀各步骤的流量比例
+var defaultCanaryWeights = []int{10, 30, 50, 100}
+
+//WorkflowReleaseCreate 开始发布的参数, Spec为新版本完整的创建参数
+type WorkflowReleaseCreate struct {
+	ID         int             `json:"id"`
+	Strategy   string          `json:"strategy"`
+	CanaryMode string          `json:"canary_mode"`
+	Weights    []int           `json:"weights"`
+	Spec       *WorkflowCreate `json:"spec"`
+}
+
+//WorkflowReleaseResp 发布记录, weights和spec为反序列化后的值
+type WorkflowReleaseResp struct {
+	*model.WorkflowRelease
+	Weights []int           `json:"weights"`
+	Spec    *WorkflowCreate `json:"spec"`
+}
+
+//获取workflow的发布记录
+func (wf *workflow) GetReleases(id int) (releases []*WorkflowReleaseResp, err error) {
+	workflow, err := getWorkflow(id)
+	if err != nil {
+		return nil, err
+	}
+	list, err := dao.WorkflowRelease.GetList(workflow.ID)
+	if err != nil {
+		return nil, err
+	}
+	releases = make([]*WorkflowReleaseResp, 0, len(list))
+	for _, item := range list {
+		resp := &WorkflowReleaseResp{WorkflowRelease: item, Spec: &WorkflowCreate{}}
+		if err = json.Unmarshal([]byte(item.Spec), resp.Spec); err != nil {
+			logger.Error(errors.New("解析发布spec失败, " + err.Error()))
+			return nil, errors.New("解析发布spec失败, " + err.Error())
+		}
+		if item.Weights != "" {
+			if err = json.Unmarshal([]byte(item.Weights), &resp.Weights); err != nil {
+				logger.Error(errors.New("解析发布权重失败, " + err.Error()))
+				return nil, errors.New("解析发布权重失败, " + err.Error())
+			}
+		}
+		releases = append(releases, resp)
+	}
+	return releases, nil
+}
+
+//开始发布, 旧版本保持不变, 新版本部署到单独的Deployment中
+//蓝绿: 创建预览Deployment和Service, 主Service固定到旧版本的pod, 推进时切换主Service的selector
+//金丝雀: 创建金丝雀Deployment, 按第一步的比例通过Ingress权重或副本数分流
+func (wf *workflow) StartRelease(data *WorkflowReleaseCreate) (err error) {
+	workflow, err := getWorkflow(data.ID)
+	if err != nil {
+		return err
+	}
+	if err = validateRelease(workflow, data); err != nil {
+		logger.Error(errors.New("开始发布失败, " + err.Error()))
+		return errors.New("开始发布失败, " + err.Error())
+	}
+	if err = checkNoActiveRelease(workflow); err != nil {
+		logger.Error(errors.New("开始发布失败, " + err.Error()))
+		return errors.New("开始发布失败, " + err.Error())
+	}
+	oldSpec, err := getWorkflowSpec(workflow, workflow.SpecVersion)
+	if err != nil {
+		return err
+	}
+	//新旧版本的pod通过workflow标签加track标签区分, workflow标签不一致时主Service无法同时选中
+	if !reflect.DeepEqual(data.Spec.Label, oldSpec.Label) {
+		logger.Error(errors.New("开始发布失败, 发布过程中不允许修改label"))
+		return errors.New("开始发布失败, 发布过程中不允许修改label")
+	}
+
+	spec, err := json.Marshal(data.Spec)
+	if err != nil {
+		logger.Error(errors.New("序列化发布spec失败, " + err.Error()))
+		return errors.New("序列化发布spec失败, " + err.Error())
+	}
+	release := &model.WorkflowRelease{
+		WorkflowID:  workflow.ID,
+		Strategy:    data.Strategy,
+		Phase:       ReleasePhaseProgressing,
+		FromVersion: workflow.SpecVersion,
+		Spec:        string(spec),
+	}
+	if data.Strategy == ReleaseStrategyCanary {
+		weights, err := json.Marshal(data.Weights)
+		if err != nil {
+			logger.Error(errors.New("序列化发布权重失败, " + err.Error()))
+			return errors.New("序列化发布权重失败, " + err.Error())
+		}
+		release.CanaryMode = data.CanaryMode
+		release.Weights = string(weights)
+	}
+
+	release.Message, err = startReleaseRes(workflow, release, data.Weights, data.Spec, oldSpec)
+	if err != nil {
+		logger.Error(errors.New("开始发布workflow: " + workflow.Name + " 失败, " + err.Error()))
+		//清理已创建的新版本资源, 清理结果记录到发布记录中
+		release.Phase = ReleasePhaseAborted
+		release.Message = "开始发布失败, " + err.Error()
+		if cleanErr := cleanReleaseRes(workflow, release, oldSpec); cleanErr != nil {
+			release.Message += ", 清理新版本资源失败, " + cleanErr.Error()
+		}
+		dao.WorkflowRelease.Save(release)
+		return errors.New("开始发布失败, " + err.Error())
+	}
+	return dao.WorkflowRelease.Save(release)
+}
+
+//推进发布到下一步
+//蓝绿: 切换流量 -> 新版本同步到主Deployment -> 主Service恢复并删除预览资源
+//金丝雀: 依次调整流量比例 -> 新版本同步到主Deployment -> 主Service恢复并删除金丝雀资源
+//切换流量前要求新版本就绪, 删除新版本资源前要求主Deployment滚动更新完成
+func (wf *workflow) PromoteRelease(id int) (release *model.WorkflowRelease, err error) {
+	workflow, release, spec, err := getActiveRelease(id)
+	if err != nil {
+		return nil, err
+	}
+	weights, err := releaseWeights(release)
+	if err != nil {
+		return nil, err
+	}
+
+	//syncStep之前为切换流量的步骤, syncStep同步主Deployment, 之后完成发布
+	syncStep := 1
+	if release.Strategy == ReleaseStrategyCanary {
+		syncStep = len(weights) - 1
+	}
+	switch {
+	case release.Step < syncStep:
+		err = shiftReleaseTraffic(workflow, release, weights, spec)
+	case release.Step == syncStep:
+		err = syncReleaseStable(workflow, release, spec)
+	default:
+		err = finishRelease(workflow, release, spec)
+	}
+	if err != nil {
+		logger.Error(errors.New("推进workflow: " + workflow.Name + " 的发布失败, " + err.Error()))
+		return nil, errors.New("推进发布失败, " + err.Error())
+	}
+	if release.Phase == ReleasePhaseProgressing {
+		release.Step++
+	}
+	if err = dao.WorkflowRelease.Save(release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+//终止发布, 主Deployment、Service和入口资源恢复为发布前的spec, 并删除新版本资源
+func (wf *workflow) AbortRelease(id int) (err error) {
+	workflow, release, _, err := getActiveRelease(id)
+	if err != nil {
+		return err
+	}
+	oldSpec, err := getWorkflowSpec(workflow, release.FromVersion)
+	if err != nil {
+		return err
+	}
+	if err = cleanReleaseRes(workflow, release, oldSpec); err != nil {
+		logger.Error(errors.New("终止workflow: " + workflow.Name + " 的发布失败, " + err.Error()))
+		release.Message = "终止发布失败, " + err.Error()
+		dao.WorkflowRelease.Save(release)
+		return errors.New("终止发布失败, " + err.Error())
+	}
+	release.Phase = ReleasePhaseAborted
+	release.Message = "发布已终止, 已恢复到版本" + strconv.Itoa(release.FromVersion)
+	return dao.WorkflowRelease.Save(release)
+}
+
+//checkNoActiveRelease 发布过程中主Service的selector由发布流程控制, 不允许更新、同步和删除workflow
+func checkNoActiveRelease(workflow *model.Workflow) error {
+	release, err := dao.WorkflowRelease.GetActive(workflow.ID, ReleasePhaseProgressing)
+	if err != nil {
+		return err
+	}
+	if release != nil {
+		return errors.New("workflow: " + workflow.Name + " 正在发布中, 请先完成或终止发布")
+	}
+	return nil
+}
+
+//validateRelease 校验发布参数, 并补全默认的金丝雀分流方式和流量比例
+//发布只替换Deployment的pod模板和副本数, 名字、标签、工作负载类型和入口类型需要通过更新接口修改
+func validateRelease(workflow *model.Workflow, data *WorkflowReleaseCreate) error {
+	if data.Spec == nil {
+		return errors.New("spec不能为空")
+	}
+	if workflowWorkloadKind(workflow.WorkloadKind) != WorkloadKindDeployment || workflowWorkloadKind(data.Spec.WorkloadKind) != WorkloadKindDeployment {
+		return errors.New("只有Deployment类型的workflow支持发布策略")
+	}
+	if workflow.Status != "" && workflow.Status != WorkflowStatusRunning {
+		return errors.New("workflow当前状态为" + workflow.Status + ", 无法发布")
+	}
+	if data.Spec.Name != workflow.Name || data.Spec.Namespace != workflow.Namespace {
+		return errors.New("不允许修改name和namespace")
+	}
+	if data.Spec.Type != workflow.Type || data.Spec.IngressKind != workflow.IngressKind {
+		return errors.New("发布过程中不允许修改service类型和入口资源类型")
+	}
+	if data.Spec.Replicas <= 0 {
+		return errors.New("replicas必须大于0")
+	}
+
+	switch data.Strategy {
+	case ReleaseStrategyBlueGreen:
+		return nil
+	case ReleaseStrategyCanary:
+	default:
+		return errors.New("不支持的发布策略: " + data.Strategy + ", 可选BlueGreen或Canary")
+	}
+
+	//nginx canary注解只对Ingress生效, 其他入口默认按副本数分流
+	ingressEntrance := workflow.Type == "Ingress" && workflow.IngressKind != IngressKindHTTPRoute
+	if data.CanaryMode == "" {
+		data.CanaryMode = CanaryModeReplicas
+		if ingressEntrance {
+			data.CanaryMode = CanaryModeIngress
+		}
+	}
+	switch data.CanaryMode {
+	case CanaryModeIngress:
+		if !ingressEntrance {
+			return errors.New("只有使用Ingress入口的workflow支持按Ingress权重分流")
+		}
+	case CanaryModeReplicas:
+	default:
+		return errors.New("不支持的金丝雀分流方式: " + data.CanaryMode + ", 可选Ingress或Replicas")
+	}
+
+	if len(data.Weights) == 0 {
+		data.Weights = defaultCanaryWeights
+	}
+	for i, weight := range data.Weights {
+		if weight <= 0 || weight > 100 || (i > 0 && weight <= data.Weights[i-1]) {
+			return errors.New("weights必须在1-100之间且依次递增")
+		}
+	}
+	//最后一步必须将全部流量切换到新版本, 再同步主Deployment
+	if data.Weights[len(data.Weights)-1] != 100 {
+		data.Weights = append(data.Weights, 100)
+	}
+	return nil
+}
+
+//getActiveRelease 获取workflow进行中的发布及其新版本spec
+func getActiveRelease(id int) (*model.Workflow, *model.WorkflowRelease, *WorkflowCreate, error) {
+	workflow, err := getWorkflow(id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	release, err := dao.WorkflowRelease.GetActive(workflow.ID, ReleasePhaseProgressing)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if release == nil {
+		logger.Error(errors.New("workflow: " + workflow.Name + " 没有进行中的发布"))
+		return nil, nil, nil, errors.New("workflow: " + workflow.Name + " 没有进行中的发布")
+	}
+	spec := &WorkflowCreate{}
+	if err = json.Unmarshal([]byte(release.Spec), spec); err != nil {
+		logger.Error(errors.New("解析发布spec失败, " + err.Error()))
+		return nil, nil, nil, errors.New("解析发布spec失败, " + err.Error())
+	}
+	return workflow, release, spec, nil
+}
+
+func releaseWeights(release *model.WorkflowRelease) ([]int, error) {
+	weights := make([]int, 0)
+	if release.Weights == "" {
+		return weights, nil
+	}
+	if err := json.Unmarshal([]byte(release.Weights), &weights); err != nil {
+		logger.Error(errors.New("解析发布权重失败, " + err.Error()))
+		return nil, errors.New("解析发布权重失败, " + err.Error())
+	}
+	return weights, nil
+}
+
+//releaseTrack 新版本的track标签值, 同时作为新版本资源名字的后缀
+func releaseTrack(release *model.WorkflowRelease) string {
+	if release.Strategy == ReleaseStrategyCanary {
+		return "canary"
+	}
+	return "preview"
+}
+
+func releaseDeploymentName(workflow *model.Workflow, release *model.WorkflowRelease) string {
+	return workflow.Name + "-" + releaseTrack(release)
+}
+
+//releaseLabels 新版本pod的标签, 在workflow的标签基础上增加track标签
+func releaseLabels(spec *WorkflowCreate, release *model.WorkflowRelease) map[string]string {
+	labels := make(map[string]string, len(spec.Label)+1)
+	for key, value := range spec.Label {
+		labels[key] = value
+	}
+	labels[releaseTrackLabel] = releaseTrack(release)
+	return labels
+}
+
+//canaryReplicas 按流量比例计算金丝雀的副本数, 向上取整且至少为1
+func canaryReplicas(total int32, weight int) int32 {
+	replicas := (int(total)*weight + 99) / 100
+	if replicas < 1 {
+		replicas = 1
+	}
+	return int32(replicas)
+}
+
+//startReleaseRes 创建新版本资源, 返回发布记录的说明
+//主Service先固定到旧版本的pod, 再创建同样带有workflow标签的新版本pod, 避免流量提前进入新版本
+func startReleaseRes(workflow *model.Workflow, release *model.WorkflowRelease, weights []int, spec, oldSpec *WorkflowCreate) (string, error) {
+	replicas := spec.Replicas
+	pinStable := release.Strategy == ReleaseStrategyBlueGreen || release.CanaryMode == CanaryModeIngress
+	if release.Strategy == ReleaseStrategyCanary {
+		replicas = canaryReplicas(spec.Replicas, weights[0])
+	}
+
+	if pinStable {
+		hash, err := stableTemplateHash(workflow.Name, workflow.Namespace)
+		if err != nil {
+			return "", err
+		}
+		selector := map[string]string{podTemplateHashLabel: hash}
+		for key, value := range oldSpec.Label {
+			selector[key] = value
+		}
+		if err = setServiceSelector(getServiceName(workflow.Name), workflow.Namespace, selector); err != nil {
+			return "", err
+		}
+	}
+
+	deploy := workflowDeployCreate(spec)
+	deploy.Name = releaseDeploymentName(workflow, release)
+	deploy.Label = releaseLabels(spec, release)
+	deploy.Replicas = replicas
+	_, err := K8s.Clientset.AppsV1().Deployments(workflow.Namespace).Create(context.TODO(), buildDeployment(deploy), metav1.CreateOptions{})
+	if err != nil {
+		return "", errors.New("创建" + deploy.Name + "失败, " + err.Error())
+	}
+	if !pinStable {
+		//按副本数分流时主Service同时选中新旧版本, 旧版本缩容让出对应比例
+		stable := int(spec.Replicas - replicas)
+		if _, err = Deployment.ScaleDeployment(workflow.Name, workflow.Namespace, stable); err != nil {
+			return "", errors.New("调整" + workflow.Name + "副本数失败, " + err.Error())
+		}
+		return fmt.Sprintf("金丝雀已部署, 新旧版本副本数 %d:%d", replicas, stable), nil
+	}
+
+	//预览或金丝雀service, 只选中新版本的pod, 用于发布前验证和Ingress分流
+	svc := workflowServiceCreate(spec)
+	svc.Name = getServiceName(deploy.Name)
+	svc.Type = "ClusterIP"
+	svc.NodePort = 0
+	svc.Label = deploy.Label
+	if err = K8sService.CreateService(svc); err != nil {
+		return "", errors.New("创建" + svc.Name + "失败, " + err.Error())
+	}
+	if release.Strategy == ReleaseStrategyBlueGreen {
+		return "预览版本已部署, 可通过" + svc.Name + "验证, 流量仍在旧版本", nil
+	}
+
+	if err = createCanaryIngress(workflow, deploy.Name, weights[0]); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("金丝雀已部署, 流量比例 %d%%", weights[0]), nil
+}
+
+//shiftReleaseTraffic 切换流量前要求新版本就绪
+//蓝绿将主Service切换到预览版本, 金丝雀调整到下一步的流量比例
+func shiftReleaseTraffic(workflow *model.Workflow, release *model.WorkflowRelease, weights []int, spec *WorkflowCreate) error {
+	name := releaseDeploymentName(workflow, release)
+	if err := deploymentAvailable(name, workflow.Namespace); err != nil {
+		return err
+	}
+	if release.Strategy == ReleaseStrategyBlueGreen {
+		if err := setServiceSelector(getServiceName(workflow.Name), workflow.Namespace, releaseLabels(spec, release)); err != nil {
+			return err
+		}
+		release.Message = "流量已切换到新版本, 旧版本保留用于快速回滚"
+		return nil
+	}
+
+	weight := weights[release.Step+1]
+	replicas := canaryReplicas(spec.Replicas, weight)
+	if _, err := Deployment.ScaleDeployment(name, workflow.Namespace, int(replicas)); err != nil {
+		return errors.New("调整" + name + "副本数失败, " + err.Error())
+	}
+	if release.CanaryMode == CanaryModeReplicas {
+		stable := int(spec.Replicas - replicas)
+		if _, err := Deployment.ScaleDeployment(workflow.Name, workflow.Namespace, stable); err != nil {
+			return errors.New("调整" + workflow.Name + "副本数失败, " + err.Error())
+		}
+		release.Message = fmt.Sprintf("新旧版本副本数调整为 %d:%d", replicas, stable)
+		return nil
+	}
+	if err := setCanaryWeight(workflow, release, weight); err != nil {
+		return err
+	}
+	release.Message = fmt.Sprintf("金丝雀流量比例调整为 %d%%", weight)
+	return nil
+}
+
+//syncReleaseStable 将新版本同步到主Deployment
+//主Service此时选中新版本的pod, 主Deployment滚动更新期间流量不会回到旧版本
+func syncReleaseStable(workflow *model.Workflow, release *model.WorkflowRelease, spec *WorkflowCreate) error {
+	if err := deploymentAvailable(releaseDeploymentName(workflow, release), workflow.Namespace); err != nil {
+		return err
+	}
+	if release.CanaryMode == CanaryModeIngress {
+		if err := setServiceSelector(getServiceName(workflow.Name), workflow.Namespace, releaseLabels(spec, release)); err != nil {
+			return err
+		}
+	}
+	if err := applyWorkflowWorkload(workflow, spec); err != nil {
+		return err
+	}
+	release.Message = "正在将新版本同步到" + workflow.Name + ", 滚动更新完成后再次推进以完成发布"
+	return nil
+}
+
+//finishRelease 主Deployment滚动更新完成后恢复主Service和入口资源, 删除新版本资源并保存新的spec版本
+func finishRelease(workflow *model.Workflow, release *model.WorkflowRelease, spec *WorkflowCreate) error {
+	if err := deploymentAvailable(workflow.Name, workflow.Namespace); err != nil {
+		return err
+	}
+	if err := applyWorkflowRes(workflow, spec); err != nil {
+		return err
+	}
+	if err := removeReleaseRes(workflow, release); err != nil {
+		return err
+	}
+	if err := saveWorkflowUpdate(workflow, spec); err != nil {
+		return err
+	}
+	release.Phase = ReleasePhasePromoted
+	release.ToVersion = workflow.SpecVersion
+	release.Message = "发布完成, 当前版本" + strconv.Itoa(workflow.SpecVersion)
+	return nil
+}
+
+//cleanReleaseRes 先删除金丝雀Ingress停止分流, 再恢复旧版本资源, 最后删除新版本的service和Deployment
+//恢复主Service的selector后新旧版本的pod同时提供服务, 删除新版本时不会中断流量
+func cleanReleaseRes(workflow *model.Workflow, release *model.WorkflowRelease, oldSpec *WorkflowCreate) error {
+	if err := deleteIngressIfExists(getIngressName(releaseDeploymentName(workflow, release)), workflow.Namespace); err != nil {
+		return errors.New("删除金丝雀Ingress失败, " + err.Error())
+	}
+	if err := applyWorkflowRes(workflow, oldSpec); err != nil {
+		return err
+	}
+	return removeReleaseRes(workflow, release)
+}
+
+//removeReleaseRes 删除新版本的Ingress、Service和Deployment, 资源不存在时视为已删除
+func removeReleaseRes(workflow *model.Workflow, release *model.WorkflowRelease) error {
+	name := releaseDeploymentName(workflow, release)
+	failed := make([]string, 0)
+	if err := deleteIngressIfExists(getIngressName(name), workflow.Namespace); err != nil {
+		failed = append(failed, "Ingress: "+err.Error())
+	}
+	if err := deleteServiceIfExists(getServiceName(name), workflow.Namespace); err != nil {
+		failed = append(failed, "Service: "+err.Error())
+	}
+	if err := deleteWorkloadIfExists(WorkloadKindDeployment, name, workflow.Namespace); err != nil {
+		failed = append(failed, "Deployment: "+err.Error())
+	}
+	if len(failed) > 0 {
+		return errors.New("删除新版本资源失败, " + strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+//stableTemplateHash 获取deployment当前版本replicaSet的pod-template-hash
+func stableTemplateHash(name, namespace string) (string, error) {
+	deployment, err := K8s.Clientset.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.New("获取Deployment失败, " + err.Error())
+	}
+	replicaSets, err := K8s.Clientset.AppsV1().ReplicaSets(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(deployment.Spec.Selector),
+	})
+	if err != nil {
+		return "", errors.New("获取ReplicaSet列表失败, " + err.Error())
+	}
+	revision := deployment.Annotations[deploymentRevisionAnnotation]
+	for _, replicaSet := range replicaSets.Items {
+		owner := metav1.GetControllerOf(&replicaSet)
+		if owner == nil || owner.UID != deployment.UID || replicaSet.Annotations[deploymentRevisionAnnotation] != revision {
+			continue
+		}
+		if hash := replicaSet.Labels[podTemplateHashLabel]; hash != "" {
+			return hash, nil
+		}
+	}
+	return "", errors.New("未找到" + name + "当前版本的ReplicaSet, 请等待Deployment更新完成")
+}
+
+//deploymentAvailable deployment已观察到最新的spec, 所有副本都已更新且可用时返回nil
+func deploymentAvailable(name, namespace string) error {
+	deployment, err := K8s.Clientset.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return errors.New("获取Deployment失败, " + err.Error())
+	}
+	if !deploymentRolledOut(deployment) {
+		return fmt.Errorf("%s尚未就绪, 可用副本数 %d/%d, 请稍后再推进", name, deployment.Status.AvailableReplicas, replicasOrOne(deployment.Spec.Replicas))
+	}
+	return nil
+}
+
+func deploymentRolledOut(deployment *appsv1.Deployment) bool {
+	replicas := replicasOrOne(deployment.Spec.Replicas)
+	status := deployment.Status
+	return status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == replicas &&
+		status.Replicas == replicas &&
+		status.AvailableReplicas == replicas
+}
+
+func setServiceSelector(name, namespace string, selector map[string]string) error {
+	client := K8s.Clientset.CoreV1().Services(namespace)
+	service, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return errors.New("获取Service失败, " + err.Error())
+	}
+	service.Spec.Selector = selector
+	_, err = client.Update(context.TODO(), service, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.New("更新Service的selector失败, " + err.Error())
+	}
+	return nil
+}
+
+//createCanaryIngress 复制workflow的Ingress, 后端替换为金丝雀service, 并设置nginx canary注解
+//金丝雀Ingress与主Ingress的host和path相同, 不经过冲突检查
+func createCanaryIngress(workflow *model.Workflow, canaryName string, weight int) error {
+	client := K8s.Clientset.NetworkingV1().Ingresses(workflow.Namespace)
+	ingress, err := client.Get(context.TODO(), getIngressName(workflow.Name), metav1.GetOptions{})
+	if err != nil {
+		return errors.New("获取Ingress失败, " + err.Error())
+	}
+	//沿用主Ingress的注解, 通过注解指定IngressClass时金丝雀Ingress才能被同一个controller处理
+	annotations := map[string]string{}
+	for key, value := range ingress.Annotations {
+		annotations[key] = value
+	}
+	annotations[nginxCanaryAnnotation] = "true"
+	annotations[nginxCanaryWeightAnnotation] = strconv.Itoa(weight)
+	canary := &nwv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        getIngressName(canaryName),
+			Namespace:   workflow.Namespace,
+			Labels:      ingress.Labels,
+			Annotations: annotations,
+		},
+		Spec: *ingress.Spec.DeepCopy(),
+	}
+	serviceName := getServiceName(canaryName)
+	mainService := getServiceName(workflow.Name)
+	for i := range canary.Spec.Rules {
+		if canary.Spec.Rules[i].HTTP == nil {
+			continue
+		}
+		for j := range canary.Spec.Rules[i].HTTP.Paths {
+			backend := canary.Spec.Rules[i].HTTP.Paths[j].Backend.Service
+			if backend != nil && backend.Name == mainService {
+				backend.Name = serviceName
+			}
+		}
+	}
+	if canary.Spec.DefaultBackend != nil && canary.Spec.DefaultBackend.Service != nil && canary.Spec.DefaultBackend.Service.Name == mainService {
+		canary.Spec.DefaultBackend.Service.Name = serviceName
+	}
+	_, err = client.Create(context.TODO(), canary, metav1.CreateOptions{})
+	if err != nil {
+		return errors.New("创建金丝雀Ingress失败, " + err.Error())
+	}
+	return nil
+}
+
+func setCanaryWeight(workflow *model.Workflow, release *model.WorkflowRelease, weight int) error {
+	client := K8s.Clientset.NetworkingV1().Ingresses(workflow.Namespace)
+	name := getIngressName(releaseDeploymentName(workflow, release))
+	ingress, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return errors.New("金丝雀Ingress: " + name + " 不存在")
+	}
+	if err != nil {
+		return errors.New("获取金丝雀Ingress失败, " + err.Error())
+	}
+	if ingress.Annotations == nil {
+		ingress.Annotations = map[string]string{}
+	}
+	ingress.Annotations[nginxCanaryAnnotation] = "true"
+	ingress.Annotations[nginxCanaryWeightAnnotation] = strconv.Itoa(weight)
+	_, err = client.Update(context.TODO(), ingress, metav1.UpdateOptions{})
+	if err != nil {
+		return errors.New("更新金丝雀Ingress失败, " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"test4/config"
+	"test4/dao"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+//workflow状态
+const (
+	WorkflowStatusCreating     = "Creating"
+	WorkflowStatusRunning      = "Running"
+	WorkflowStatusFailed       = "Failed"
+	WorkflowStatusDeleting     = "Deleting"
+	WorkflowStatusDeleteFailed = "DeleteFailed"
+)
+
+//workflow步骤状态
+const (
+	WorkflowStepPending          = "Pending"
+	WorkflowStepSucceeded        = "Succeeded"
+	WorkflowStepFailed           = "Failed"
+	WorkflowStepCompensated      = "Compensated"
+	WorkflowStepCompensateFailed = "CompensateFailed"
+)
+
+//workflowStep saga中的一步, create创建资源, remove删除资源且资源不存在时不返回错误
+type workflowStep struct {
+	kind   string
+	name   string
+	create func() error
+	remove func() error
+}
+
+//workflowSteps 按创建顺序返回workflow的步骤, data为空时只能用于删除
+func workflowSteps(workflow *model.Workflow, data *WorkflowCreate) []*workflowStep {
+	namespace := workflow.Namespace
+	steps := []*workflowStep{
+		{
+			kind: workflowWorkloadKind(workflow.WorkloadKind),
+			name: workflow.Name,
+			create: func() error {
+				return createWorkflowWorkload(data)
+			},
+			remove: func() error {
+				return deleteWorkloadIfExists(workflow.WorkloadKind, workflow.Name, namespace)
+			},
+		},
+		{
+			kind: "Service",
+			name: getServiceName(workflow.Name),
+			create: func() error {
+				return K8sService.CreateService(workflowServiceCreate(data))
+			},
+			remove: func() error {
+				return deleteServiceIfExists(getServiceName(workflow.Name), namespace)
+			},
+		},
+	}
+	//只有ingress类型的workflow才有入口资源, 选择Gateway API时为挂载到指定Gateway的HTTPRoute
+	//每个host一个HTTPRoute, 删除时data为空, 按标签删除workflow的全部HTTPRoute
+	if workflow.Type == "Ingress" && workflow.IngressKind == IngressKindHTTPRoute && data == nil {
+		steps = append(steps, &workflowStep{
+			kind: IngressKindHTTPRoute,
+			name: getHTTPRouteName(workflow.Name),
+			remove: func() error {
+				return deleteWorkflowHTTPRoutes(workflow.Name, namespace, nil)
+			},
+		})
+	} else if workflow.Type == "Ingress" && workflow.IngressKind == IngressKindHTTPRoute {
+		for _, route := range workflowHTTPRoutes(data) {
+			route := route
+			steps = append(steps, &workflowStep{
+				kind: IngressKindHTTPRoute,
+				name: route.Name,
+				create: func() error {
+					return Gateway.CreateHTTPRoute(route)
+				},
+				remove: func() error {
+					return deleteHTTPRouteIfExists(route.Name, namespace)
+				},
+			})
+		}
+	} else if workflow.Type == "Ingress" {
+		steps = append(steps, &workflowStep{
+			kind: IngressKindIngress,
+			name: getIngressName(workflow.Name),
+			create: func() error {
+				return Ingress.CreateIngress(workflowIngressCreate(data))
+			},
+			remove: func() error {
+				return deleteIngressIfExists(getIngressName(workflow.Name), namespace)
+			},
+		})
+	}
+	return steps
+}
+
+//newStepRecords 清空workflow之前的步骤, 并为本次操作的每一步保存Pending状态
+func newStepRecords(workflow *model.Workflow, action string, steps []*workflowStep) []*model.WorkflowStep {
+	if err := dao.WorkflowStep.DelByWorkflowId(workflow.ID); err != nil {
+		logger.Error(errors.New("清空workflow: " + workflow.Name + " 的步骤失败, " + err.Error()))
+	}
+	records := make([]*model.WorkflowStep, len(steps))
+	for i, step := range steps {
+		records[i] = &model.WorkflowStep{
+			WorkflowID: workflow.ID,
+			Action:     action,
+			Kind:       step.kind,
+			Name:       step.name,
+			Status:     WorkflowStepPending,
+		}
+		saveStepRecord(records[i], WorkflowStepPending, nil)
+	}
+	return records
+}
+
+//saveStepRecord 更新步骤状态, 步骤只用于展示, 保存失败不影响资源的创建和删除
+func saveStepRecord(record *model.WorkflowStep, status string, err error) {
+	record.Status = status
+	record.Message = ""
+	if err != nil {
+		record.Message = err.Error()
+	}
+	dao.WorkflowStep.Save(record)
+}
+
+//createWorkflowRes 依次创建workflow的k8s资源, 失败时倒序删除已创建的资源
+//compensated表示已创建的资源是否全部回滚成功
+func createWorkflowRes(workflow *model.Workflow, data *WorkflowCreate) (compensated bool, err error) {
+	steps := workflowSteps(workflow, data)
+	records := newStepRecords(workflow, "create", steps)
+	for i, step := range steps {
+		err = step.create()
+		if err == nil {
+			saveStepRecord(records[i], WorkflowStepSucceeded, nil)
+			continue
+		}
+		saveStepRecord(records[i], WorkflowStepFailed, err)
+		logger.Error(errors.New("创建workflow: " + workflow.Name + " 的" + step.kind + "失败, 开始回滚, " + err.Error()))
+
+		compensated = true
+		for j := i - 1; j >= 0; j-- {
+			if removeErr := steps[j].remove(); removeErr != nil {
+				compensated = false
+				saveStepRecord(records[j], WorkflowStepCompensateFailed, removeErr)
+				logger.Error(errors.New("回滚workflow: " + workflow.Name + " 的" + steps[j].kind + "失败, " + removeErr.Error()))
+				continue
+			}
+			saveStepRecord(records[j], WorkflowStepCompensated, nil)
+		}
+		return compensated, errors.New("创建workflow的" + step.kind + "失败, " + err.Error())
+	}
+	return true, nil
+}
+
+//delWorkflowRes 倒序删除workflow的k8s资源, 某一步失败时继续删除其他资源, 最后返回所有错误
+func delWorkflowRes(workflow *model.Workflow) error {
+	steps := workflowSteps(workflow, nil)
+	records := newStepRecords(workflow, "delete", steps)
+	failed := make([]string, 0)
+	for i := len(steps) - 1; i >= 0; i-- {
+		if err := steps[i].remove(); err != nil {
+			saveStepRecord(records[i], WorkflowStepFailed, err)
+			failed = append(failed, steps[i].kind+": "+err.Error())
+			continue
+		}
+		saveStepRecord(records[i], WorkflowStepSucceeded, nil)
+	}
+	if len(failed) > 0 {
+		logger.Error(errors.New("删除workflow: " + workflow.Name + " 失败, " + strings.Join(failed, "; ")))
+		return errors.New("删除workflow失败, " + strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+//delWorkflowRecord 删除workflow及其spec、步骤、标签和发布记录的数据库数据
+//workflow使用硬删除, 回滚或删除后可以用同样的名字重新创建、恢复或克隆
+func delWorkflowRecord(workflow *model.Workflow) error {
+	if err := dao.Workflow.HardDelById(int(workflow.ID)); err != nil {
+		return err
+	}
+	if err := dao.WorkflowRelease.DelByWorkflowId(workflow.ID); err != nil {
+		return err
+	}
+	if err := dao.WorkflowSpec.DelByWorkflowId(workflow.ID); err != nil {
+		return err
+	}
+	if err := dao.WorkflowLabel.DelByWorkflowId(workflow.ID); err != nil {
+		return err
+	}
+	return dao.WorkflowStep.DelByWorkflowId(workflow.ID)
+}
+
+func deleteServiceIfExists(name, namespace string) error {
+	err := K8s.Clientset.CoreV1().Services(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func deleteIngressIfExists(name, namespace string) error {
+	err := K8s.Clientset.NetworkingV1().Ingresses(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func deleteHTTPRouteIfExists(name, namespace string) error {
+	err := Gateway.delete("httproutes", name, namespace)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+//listWorkflowHTTPRoutes 按标签获取workflow的HTTPRoute, 按名字排序
+//按host拆分之前创建的HTTPRoute没有标签, 名字为不带host后缀的名字, 一并返回
+func listWorkflowHTTPRoutes(workflowName, namespace string) ([]unstructured.Unstructured, error) {
+	gvr, err := Gateway.gvr("httproutes")
+	if err != nil {
+		return nil, err
+	}
+	client := resourceClient(gvr, true, namespace)
+	list, err := client.List(context.TODO(), metav1.ListOptions{LabelSelector: config.WorkflowLabel + "=" + workflowName})
+	if err != nil {
+		return nil, err
+	}
+	routes := list.Items
+	legacyName := getHTTPRouteName(workflowName)
+	found := false
+	for _, route := range routes {
+		if route.GetName() == legacyName {
+			found = true
+		}
+	}
+	if !found {
+		legacy, err := client.Get(context.TODO(), legacyName, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err == nil {
+			routes = append(routes, *legacy)
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].GetName() < routes[j].GetName()
+	})
+	return routes, nil
+}
+
+//deleteWorkflowHTTPRoutes 删除workflow的HTTPRoute中不在keep里的部分, keep为空时全部删除
+func deleteWorkflowHTTPRoutes(workflowName, namespace string, keep map[string]bool) error {
+	routes, err := listWorkflowHTTPRoutes(workflowName, namespace)
+	if err != nil {
+		return err
+	}
+	for _, route := range routes {
+		if keep[route.GetName()] {
+			continue
+		}
+		if err = deleteHTTPRouteIfExists(route.GetName(), namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"test4/dao"
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+)
+
+var WorkflowTemplate workflowTemplate
+
+type workflowTemplate struct{}
+
+//模板参数类型
+const (
+	TemplateParamString = "string"
+	TemplateParamInt    = "int"
+	TemplateParamBool   = "bool"
+)
+
+//TemplateParameter 模板参数定义, 在模板中通过 {{ .name }} 引用
+//Enum、Pattern只作用于string类型, Min、Max只作用于int类型
+type TemplateParameter struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Required    bool        `json:"required"`
+	Default     interface{} `json:"default"`
+	Enum        []string    `json:"enum"`
+	Pattern     string      `json:"pattern"`
+	Min         *int64      `json:"min"`
+	Max         *int64      `json:"max"`
+}
+
+//WorkflowTemplateCreate 创建、更新模板的参数
+//Template渲染后必须是WorkflowCreate的json, 字符串参数建议使用 {{ json .name }} 插入以处理转义
+type WorkflowTemplateCreate struct {
+	Name        string               `json:"name"`
+	Category    string               `json:"category"`
+	Description string               `json:"description"`
+	Parameters  []*TemplateParameter `json:"parameters"`
+	Template    string               `json:"template"`
+}
+
+//WorkflowTemplateInfo 目录中展示的模板, 参数定义为反序列化后的结构
+type WorkflowTemplateInfo struct {
+	ID          uint                 `json:"id"`
+	Name        string               `json:"name"`
+	Category    string               `json:"category"`
+	Description string               `json:"description"`
+	Parameters  []*TemplateParameter `json:"parameters"`
+	Template    string               `json:"template,omitempty"`
+	UpdatedAt   string               `json:"updated_at"`
+}
+
+type WorkflowTemplateResp struct {
+	Items []*WorkflowTemplateInfo `json:"items"`
+	Total int                     `json:"total"`
+}
+
+//模板参数名需要能在go template中以 .name 的形式引用
+var templateParamNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+//模板中可用的函数
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		content, err := json.Marshal(v)
+		return string(content), err
+	},
+}
+
+//获取模板目录, 列表中不返回模板内容
+func (wt *workflowTemplate) GetTemplates(name, category string, page, limit int) (templateResp *WorkflowTemplateResp, err error) {
+	list, err := dao.WorkflowTemplate.GetList(name, category, page, limit)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*WorkflowTemplateInfo, 0, len(list.Items))
+	for _, item := range list.Items {
+		info, err := toTemplateInfo(item)
+		if err != nil {
+			return nil, err
+		}
+		info.Template = ""
+		items = append(items, info)
+	}
+	return &WorkflowTemplateResp{
+		Items: items,
+		Total: list.Total,
+	}, nil
+}
+
+//获取模板详情
+func (wt *workflowTemplate) GetTemplateDetail(id int) (info *WorkflowTemplateInfo, err error) {
+	item, err := getWorkflowTemplate(id)
+	if err != nil {
+		return nil, err
+	}
+	return toTemplateInfo(item)
+}
+
+//创建模板, 保存前校验参数定义以及模板语法
+func (wt *workflowTemplate) CreateTemplate(data *WorkflowTemplateCreate) (err error) {
+	item, err := toTemplateModel(data)
+	if err != nil {
+		logger.Error(errors.New("创建workflow模板: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("创建workflow模板失败, " + err.Error())
+	}
+	return dao.WorkflowTemplate.Add(item)
+}
+
+//更新模板
+func (wt *workflowTemplate) UpdateTemplate(id int, data *WorkflowTemplateCreate) (err error) {
+	if data == nil {
+		return errors.New("更新workflow模板失败, template 未传参")
+	}
+	old, err := getWorkflowTemplate(id)
+	if err != nil {
+		return err
+	}
+	item, err := toTemplateModel(data)
+	if err != nil {
+		logger.Error(errors.New("更新workflow模板: " + data.Name + " 失败, " + err.Error()))
+		return errors.New("更新workflow模板失败, " + err.Error())
+	}
+	item.ID = old.ID
+	item.CreatedAt = old.CreatedAt
+	return dao.WorkflowTemplate.Update(item)
+}
+
+//删除模板, 已经通过模板创建的workflow不受影响
+func (wt *workflowTemplate) DeleteTemplate(id int) (err error) {
+	if _, err = getWorkflowTemplate(id); err != nil {
+		return err
+	}
+	return dao.WorkflowTemplate.DelById(id)
+}
+
+//使用参数渲染模板, 返回WorkflowCreate但不创建, 用于预览
+func (wt *workflowTemplate) RenderTemplate(id int, values map[string]interface{}) (data *WorkflowCreate, err error) {
+	item, err := getWorkflowTemplate(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err = renderWorkflowTemplate(item, values)
+	if err != nil {
+		logger.Error(errors.New("渲染workflow模板: " + item.Name + " 失败, " + err.Error()))
+		return nil, errors.New("渲染workflow模板失败, " + err.Error())
+	}
+	return data, nil
+}
+
+//使用参数渲染模板并创建workflow
+func (wt *workflowTemplate) InstantiateTemplate(id int, values map[string]interface{}) (data *WorkflowCreate, err error) {
+	data, err = wt.RenderTemplate(id, values)
+	if err != nil {
+		return nil, err
+	}
+	if err = Workflow.CreateWorkflow(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+//getWorkflowTemplate 获取模板, 不存在时返回错误
+func getWorkflowTemplate(id int) (*model.WorkflowTemplate, error) {
+	item, err := dao.WorkflowTemplate.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		logger.Error(errors.New("workflow模板: " + strconv.Itoa(id) + " 不存在"))
+		return nil, errors.New("workflow模板: " + strconv.Itoa(id) + " 不存在")
+	}
+	return item, nil
+}
+
+func toTemplateInfo(item *model.WorkflowTemplate) (*WorkflowTemplateInfo, error) {
+	info := &WorkflowTemplateInfo{
+		ID:          item.ID,
+		Name:        item.Name,
+		Category:    item.Category,
+		Description: item.Description,
+		Template:    item.Template,
+	}
+	if item.Parameters != "" {
+		if err := json.Unmarshal([]byte(item.Parameters), &info.Parameters); err != nil {
+			logger.Error(errors.New("解析workflow模板: " + item.Name + " 的参数定义失败, " + err.Error()))
+			return nil, errors.New("解析workflow模板的参数定义失败, " + err.Error())
+		}
+	}
+	if item.UpdatedAt != nil {
+		info.UpdatedAt = item.UpdatedAt.Format("2006-01-02 15:04:05")
+	}
+	return info, nil
+}
+
+//toTemplateModel 校验参数定义和模板语法后组装成数据库模型
+func toTemplateModel(data *WorkflowTemplateCreate) (*model.WorkflowTemplate, error) {
+	if data.Name == "" {
+		return nil, errors.New("模板名不能为空")
+	}
+	if err := validateTemplateParameters(data.Parameters); err != nil {
+		return nil, err
+	}
+	if _, err := template.New(data.Name).Funcs(templateFuncs).Option("missingkey=error").Parse(data.Template); err != nil {
+		return nil, errors.New("模板语法错误, " + err.Error())
+	}
+	parameters, err := json.Marshal(data.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	return &model.WorkflowTemplate{
+		Name:        data.Name,
+		Category:    data.Category,
+		Description: data.Description,
+		Parameters:  string(parameters),
+		Template:    data.Template,
+	}, nil
+}
+
+//validateTemplateParameters 校验参数定义, 包括名字、类型以及默认值是否满足约束, 收集所有错误后一起返回
+func validateTemplateParameters(parameters []*TemplateParameter) error {
+	errs := make([]string, 0)
+	seen := map[string]bool{}
+	for _, param := range parameters {
+		if !templateParamNameRegexp.MatchString(param.Name) {
+			errs = append(errs, fmt.Sprintf("参数名 %q 不合法, 只能包含字母、数字和下划线且不能以数字开头", param.Name))
+			continue
+		}
+		if seen[param.Name] {
+			errs = append(errs, "参数 "+param.Name+" 重复定义")
+			continue
+		}
+		seen[param.Name] = true
+		switch param.Type {
+		case TemplateParamString, TemplateParamInt, TemplateParamBool:
+		default:
+			errs = append(errs, "参数 "+param.Name+" 的类型 "+param.Type+" 不支持, 可选 string、int、bool")
+			continue
+		}
+		if param.Pattern != "" {
+			if _, err := regexp.Compile(param.Pattern); err != nil {
+				errs = append(errs, "参数 "+param.Name+" 的pattern不合法, "+err.Error())
+				continue
+			}
+		}
+		if param.Min != nil && param.Max != nil && *param.Min > *param.Max {
+			errs = append(errs, "参数 "+param.Name+" 的min大于max")
+			continue
+		}
+		if param.Default != nil {
+			if _, err := convertTemplateParam(param, param.Default); err != nil {
+				errs = append(errs, "参数 "+param.Name+" 的默认值不合法, "+err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+//resolveTemplateParams 按参数定义补齐默认值并校验类型和约束, 未定义的参数视为错误
+func resolveTemplateParams(parameters []*TemplateParameter, values map[string]interface{}) (map[string]interface{}, error) {
+	errs := make([]string, 0)
+	resolved := map[string]interface{}{}
+	defined := map[string]bool{}
+	for _, param := range parameters {
+		defined[param.Name] = true
+		value, ok := values[param.Name]
+		if !ok || value == nil {
+			if param.Default == nil {
+				if param.Required {
+					errs = append(errs, "缺少必填参数 "+param.Name)
+				} else {
+					resolved[param.Name] = zeroTemplateParam(param.Type)
+				}
+				continue
+			}
+			value = param.Default
+		}
+		converted, err := convertTemplateParam(param, value)
+		if err != nil {
+			errs = append(errs, "参数 "+param.Name+" 不合法, "+err.Error())
+			continue
+		}
+		resolved[param.Name] = converted
+	}
+	for name := range values {
+		if !defined[name] {
+			errs = append(errs, "未定义的参数 "+name)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+	return resolved, nil
+}
+
+//convertTemplateParam 将json中的值转换为参数类型并校验约束, 数字和布尔值也可以使用字符串传入
+func convertTemplateParam(param *TemplateParameter, value interface{}) (interface{}, error) {
+	switch param.Type {
+	case TemplateParamInt:
+		var number int64
+		switch v := value.(type) {
+		case float64:
+			if v != float64(int64(v)) {
+				return nil, fmt.Errorf("%v 不是整数", v)
+			}
+			number = int64(v)
+		case int:
+			number = int64(v)
+		case int64:
+			number = v
+		case string:
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q 不是整数", v)
+			}
+			number = parsed
+		default:
+			return nil, fmt.Errorf("%v 不是整数", value)
+		}
+		if param.Min != nil && number < *param.Min {
+			return nil, fmt.Errorf("%d 小于最小值 %d", number, *param.Min)
+		}
+		if param.Max != nil && number > *param.Max {
+			return nil, fmt.Errorf("%d 大于最大值 %d", number, *param.Max)
+		}
+		return number, nil
+	case TemplateParamBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("%q 不是布尔值", v)
+			}
+			return parsed, nil
+		}
+		return nil, fmt.Errorf("%v 不是布尔值", value)
+	default:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v 不是字符串", value)
+		}
+		if len(param.Enum) > 0 {
+			matched := false
+			for _, item := range param.Enum {
+				if item == str {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("%q 不在可选值 %s 中", str, strings.Join(param.Enum, ","))
+			}
+		}
+		if param.Pattern != "" {
+			matched, err := regexp.MatchString(param.Pattern, str)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				return nil, fmt.Errorf("%q 不匹配 %s", str, param.Pattern)
+			}
+		}
+		return str, nil
+	}
+}
+
+func zeroTemplateParam(paramType string) interface{} {
+	switch paramType {
+	case TemplateParamInt:
+		return int64(0)
+	case TemplateParamBool:
+		return false
+	}
+	return ""
+}
+
+//renderWorkflowTemplate 校验参数后渲染模板, 渲染结果中出现WorkflowCreate未定义的字段视为错误
+func renderWorkflowTemplate(item *model.WorkflowTemplate, values map[string]interface{}) (*WorkflowCreate, error) {
+	var parameters []*TemplateParameter
+	if item.Parameters != "" {
+		if err := json.Unmarshal([]byte(item.Parameters), &parameters); err != nil {
+			return nil, errors.New("解析参数定义失败, " + err.Error())
+		}
+	}
+	resolved, err := resolveTemplateParams(parameters, values)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(item.Name).Funcs(templateFuncs).Option("missingkey=error").Parse(item.Template)
+	if err != nil {
+		return nil, errors.New("模板语法错误, " + err.Error())
+	}
+	buf := &bytes.Buffer{}
+	if err = tmpl.Execute(buf, resolved); err != nil {
+		return nil, errors.New("执行模板失败, " + err.Error())
+	}
+	data := &WorkflowCreate{}
+	decoder := json.NewDecoder(buf)
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(data); err != nil {
+		return nil, errors.New("模板渲染结果不是合法的workflow参数, " + err.Error())
+	}
+	if data.Name == "" || data.Namespace == "" {
+		return nil, errors.New("模板渲染结果缺少name或namespace")
+	}
+	return data, nil
+}
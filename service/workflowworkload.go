@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"test4/model"
+
+	"github.com/wonderivan/logger"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//workflow工作负载类型
+const (
+	WorkloadKindDeployment  = "Deployment"
+	WorkloadKindStatefulSet = "StatefulSet"
+	WorkloadKindDaemonSet   = "DaemonSet"
+)
+
+//workloadView 不同工作负载中workflow关心的字段, 用于统一对比, DaemonSet的replicas为nil
+type workloadView struct {
+	kind     string
+	name     string
+	labels   map[string]string
+	replicas *int32
+	selector *metav1.LabelSelector
+	template corev1.PodTemplateSpec
+}
+
+//workflowWorkloadKind 未指定工作负载类型时为Deployment, 兼容旧的workflow
+func workflowWorkloadKind(kind string) string {
+	if kind == "" {
+		return WorkloadKindDeployment
+	}
+	return kind
+}
+
+//validateWorkloadKind 校验工作负载类型
+func validateWorkloadKind(kind string) error {
+	switch workflowWorkloadKind(kind) {
+	case WorkloadKindDeployment, WorkloadKindStatefulSet, WorkloadKindDaemonSet:
+		return nil
+	}
+	return errors.New("不支持的工作负载类型 " + kind + ", 可选 Deployment、StatefulSet、DaemonSet")
+}
+
+//组装StatefulSetCreate类型的数据, serviceName指向workflow的service
+func workflowStatefulSetCreate(data *WorkflowCreate) *StatefulSetCreate {
+	return &StatefulSetCreate{
+		StatefulSetName: data.Name,
+		Namespace: data.Namespace,
+		Labels: data.Label,
+		Replicas: data.Replicas,
+		Cpu: data.Cpu,
+		Memory: data.Memory,
+		HealthCheck: data.HealthCheck,
+		HealthPath: data.HealthPath,
+		ResourceCheck: data.ResourceCheck,
+		ServiceName: getServiceName(data.Name),
+		Containers: data.Containers,
+		VolumeClaimTemplates: data.VolumeClaimTemplates,
+	}
+}
+
+//组装DaemonSetCreate类型的数据, DaemonSet每个节点一个pod, 忽略replicas
+func workflowDaemonSetCreate(data *WorkflowCreate) *DaemonSetCreate {
+	return &DaemonSetCreate{
+		DaemonSetName: data.Name,
+		Namespace: data.Namespace,
+		Labels: data.Label,
+		Containers: data.Containers,
+		Health_Check: data.HealthCheck,
+		Health_Path: data.HealthPath,
+		Resource_Check: data.ResourceCheck,
+		Cpu: data.Cpu,
+		Memory: data.Memory,
+	}
+}
+
+//createWorkflowWorkload 按工作负载类型创建
+func createWorkflowWorkload(data *WorkflowCreate) error {
+	switch workflowWorkloadKind(data.WorkloadKind) {
+	case WorkloadKindStatefulSet:
+		return StatefulSet.CreateStatefulSet(workflowStatefulSetCreate(data))
+	case WorkloadKindDaemonSet:
+		return DaemonSet.CreateDaemonSet(workflowDaemonSetCreate(data))
+	}
+	return Deployment.CreateDeployment(workflowDeployCreate(data))
+}
+
+//deleteWorkloadIfExists 删除工作负载, 不存在时不返回错误
+//StatefulSet通过volumeClaimTemplates创建的pvc不会随之删除, 需要手动清理
+func deleteWorkloadIfExists(kind, name, namespace string) error {
+	var err error
+	switch workflowWorkloadKind(kind) {
+	case WorkloadKindStatefulSet:
+		err = K8s.Clientset.AppsV1().StatefulSets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	case WorkloadKindDaemonSet:
+		err = K8s.Clientset.AppsV1().DaemonSets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	default:
+		err = K8s.Clientset.AppsV1().Deployments(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+//重启工作负载时写入pod模板的annotation, 包括kubectl rollout restart和dashboard的重启接口
+var restartAnnotations = []string{"kubectl.kubernetes.io/restartedAt", "kubectl.kubenetes.io/restarteAt"}
+
+//keepRestartAnnotations 将集群中模板的重启annotation带到新模板上
+func keepRestartAnnotations(desired, live *corev1.PodTemplateSpec) {
+	for _, key := range restartAnnotations {
+		value, ok := live.Annotations[key]
+		if !ok {
+			continue
+		}
+		if desired.Annotations == nil {
+			desired.Annotations = map[string]string{}
+		}
+		desired.Annotations[key] = value
+	}
+}
+
+//workloadAutoscaled 工作负载是否被HPA管理副本数, DaemonSet不支持HPA
+func workloadAutoscaled(kind, name, namespace string) (bool, error) {
+	if workflowWorkloadKind(kind) == WorkloadKindDaemonSet {
+		return false, nil
+	}
+	hpa, err := HorizontalPodAutoscaler.GetWorkloadHorizontalPodAutoscaler(workflowWorkloadKind(kind), name, namespace)
+	if err != nil {
+		return false, err
+	}
+	return hpa != nil, nil
+}
+
+//desiredWorkload 按spec组装工作负载并转换为workloadView
+func desiredWorkload(data *WorkflowCreate) *workloadView {
+	switch workflowWorkloadKind(data.WorkloadKind) {
+	case WorkloadKindStatefulSet:
+		statefulSet := buildStatefulSet(workflowStatefulSetCreate(data))
+		return &workloadView{WorkloadKindStatefulSet, statefulSet.Name, statefulSet.Labels, statefulSet.Spec.Replicas, statefulSet.Spec.Selector, statefulSet.Spec.Template}
+	case WorkloadKindDaemonSet:
+		daemonSet := buildDaemonSet(workflowDaemonSetCreate(data))
+		return &workloadView{WorkloadKindDaemonSet, daemonSet.Name, daemonSet.Labels, nil, daemonSet.Spec.Selector, daemonSet.Spec.Template}
+	}
+	deployment := buildDeployment(workflowDeployCreate(data))
+	return &workloadView{WorkloadKindDeployment, deployment.Name, deployment.Labels, deployment.Spec.Replicas, deployment.Spec.Selector, deployment.Spec.Template}
+}
+
+//liveWorkload 获取集群中的工作负载并转换为workloadView, 不存在时返回NotFound错误
+func liveWorkload(kind, name, namespace string) (*workloadView, error) {
+	switch workflowWorkloadKind(kind) {
+	case WorkloadKindStatefulSet:
+		statefulSet, err := K8s.Clientset.AppsV1().StatefulSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &workloadView{WorkloadKindStatefulSet, statefulSet.Name, statefulSet.Labels, statefulSet.Spec.Replicas, statefulSet.Spec.Selector, statefulSet.Spec.Template}, nil
+	case WorkloadKindDaemonSet:
+		daemonSet, err := K8s.Clientset.AppsV1().DaemonSets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &workloadView{WorkloadKindDaemonSet, daemonSet.Name, daemonSet.Labels, nil, daemonSet.Spec.Selector, daemonSet.Spec.Template}, nil
+	}
+	deployment, err := K8s.Clientset.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &workloadView{WorkloadKindDeployment, deployment.Name, deployment.Labels, deployment.Spec.Replicas, deployment.Spec.Selector, deployment.Spec.Template}, nil
+}
+
+//applyWorkflowWorkload 工作负载类型变化时先删除旧的工作负载, 然后按新类型创建或更新
+//selector不可修改, label变化导致selector变化时返回错误; StatefulSet的volumeClaimTemplates同样不可修改, 更新时保持不变
+func applyWorkflowWorkload(workflow *model.Workflow, data *WorkflowCreate) error {
+	kind := workflowWorkloadKind(data.WorkloadKind)
+	if oldKind := workflowWorkloadKind(workflow.WorkloadKind); oldKind != kind {
+		if err := deleteWorkloadIfExists(oldKind, workflow.Name, workflow.Namespace); err != nil {
+			return errors.New("删除旧的" + oldKind + "失败, " + err.Error())
+		}
+	}
+
+	desired := desiredWorkload(data)
+	live, err := liveWorkload(kind, data.Name, data.Namespace)
+	if apierrors.IsNotFound(err) {
+		if err = createWorkflowWorkload(data); err != nil {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.New("获取" + kind + "失败, " + err.Error())
+	}
+	if !reflect.DeepEqual(live.selector.MatchLabels, desired.selector.MatchLabels) {
+		return errors.New(kind + "的selector不可修改, 请保持label不变")
+	}
+	//副本数由HPA管理时保持集群中的值, 否则每次更新或reconcile都会覆盖HPA扩缩的结果
+	autoscaled, err := workloadAutoscaled(kind, data.Name, data.Namespace)
+	if err != nil {
+		return errors.New("获取HorizontalPodAutoscaler失败, " + err.Error())
+	}
+	if autoscaled {
+		desired.replicas = live.replicas
+	}
+	//保留重启写入模板的annotation, 否则更新会额外触发一次滚动
+	keepRestartAnnotations(&desired.template, &live.template)
+
+	switch kind {
+	case WorkloadKindStatefulSet:
+		client := K8s.Clientset.AppsV1().StatefulSets(data.Namespace)
+		statefulSet, err := client.Get(context.TODO(), data.Name, metav1.GetOptions{})
+		if err != nil {
+			return errors.New("获取StatefulSet失败, " + err.Error())
+		}
+		statefulSet.Labels = desired.labels
+		statefulSet.Spec.Replicas = desired.replicas
+		statefulSet.Spec.Template = desired.template
+		_, err = client.Update(context.TODO(), statefulSet, metav1.UpdateOptions{})
+		if err != nil {
+			return errors.New("更新StatefulSet失败, " + err.Error())
+		}
+	case WorkloadKindDaemonSet:
+		client := K8s.Clientset.AppsV1().DaemonSets(data.Namespace)
+		daemonSet, err := client.Get(context.TODO(), data.Name, metav1.GetOptions{})
+		if err != nil {
+			return errors.New("获取DaemonSet失败, " + err.Error())
+		}
+		daemonSet.Labels = desired.labels
+		daemonSet.Spec.Template = desired.template
+		_, err = client.Update(context.TODO(), daemonSet, metav1.UpdateOptions{})
+		if err != nil {
+			return errors.New("更新DaemonSet失败, " + err.Error())
+		}
+	default:
+		client := K8s.Clientset.AppsV1().Deployments(data.Namespace)
+		deployment, err := client.Get(context.TODO(), data.Name, metav1.GetOptions{})
+		if err != nil {
+			return errors.New("获取Deployment失败, " + err.Error())
+		}
+		deployment.Labels = desired.labels
+		deployment.Spec.Replicas = desired.replicas
+		deployment.Spec.Template = desired.template
+		_, err = client.Update(context.TODO(), deployment, metav1.UpdateOptions{})
+		if err != nil {
+			return errors.New("更新Deployment失败, " + err.Error())
+		}
+	}
+	return nil
+}
+
+//workloadDrift 对比副本数、标签以及各容器的镜像、端口、资源、环境变量和探针
+func workloadDrift(data *WorkflowCreate) ([]*WorkflowDrift, error) {
+	desired := desiredWorkload(data)
+	live, err := liveWorkload(desired.kind, desired.name, data.Namespace)
+	if apierrors.IsNotFound(err) {
+		return []*WorkflowDrift{missingDrift(desired.kind, desired.name)}, nil
+	}
+	if err != nil {
+		logger.Error(errors.New("获取" + desired.kind + "失败, " + err.Error()))
+		return nil, errors.New("获取" + desired.kind + "失败, " + err.Error())
+	}
+
+	drifts := make([]*WorkflowDrift, 0)
+	add := func(field string, expected, actual interface{}) {
+		if drift := compareField(desired.kind, desired.name, field, expected, actual); drift != nil {
+			drifts = append(drifts, drift)
+		}
+	}
+	autoscaled, err := workloadAutoscaled(desired.kind, desired.name, data.Namespace)
+	if err != nil {
+		logger.Error(errors.New("获取HorizontalPodAutoscaler失败, " + err.Error()))
+		return nil, errors.New("获取HorizontalPodAutoscaler失败, " + err.Error())
+	}
+	if desired.replicas != nil && !autoscaled {
+		add("spec.replicas", desired.replicas, live.replicas)
+	}
+	add("metadata.labels", desired.labels, subsetOf(desired.labels, live.labels))
+
+	liveContainers := map[string]corev1.Container{}
+	for _, container := range live.template.Spec.Containers {
+		liveContainers[container.Name] = container
+	}
+	for _, container := range desired.template.Spec.Containers {
+		prefix := "containers[" + container.Name + "]"
+		liveContainer, ok := liveContainers[container.Name]
+		if !ok {
+			drifts = append(drifts, &WorkflowDrift{Kind: desired.kind, Name: desired.name, Field: prefix, Expected: "present", Actual: driftFieldMissing})
+			continue
+		}
+		add(prefix+".image", container.Image, liveContainer.Image)
+		add(prefix+".ports", defaultContainerPorts(container.Ports), liveContainer.Ports)
+		add(prefix+".resources", container.Resources, liveContainer.Resources)
+		add(prefix+".env", container.Env, liveContainer.Env)
+		add(prefix+".readinessProbe", defaultProbe(container.ReadinessProbe), liveContainer.ReadinessProbe)
+		add(prefix+".livenessProbe", defaultProbe(container.LivenessProbe), liveContainer.LivenessProbe)
+	}
+	return drifts, nil
+}